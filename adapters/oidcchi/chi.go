@@ -0,0 +1,40 @@
+//go:build oidcadapters
+// +build oidcadapters
+
+package oidcchi
+
+import (
+	"net/http"
+
+	"github.com/Bplotka/oidc/authorize"
+	"github.com/Bplotka/oidc/websession"
+	"github.com/go-chi/chi"
+)
+
+// Authorize returns chi middleware that rejects requests not authorized by a, based on bearer token in headerName
+// header (usually "Authorization").
+//
+//    r.With(oidcchi.Authorize(authorizer, "Authorization")).Get("/api", handler)
+//
+func Authorize(a authorize.Authorizer, headerName string) func(http.Handler) http.Handler {
+	return authorize.Middleware(a, headerName)
+}
+
+// RequireLogin returns chi middleware that redirects users without valid web session to the provider.
+// Identity is available via websession.IdentityFromContext(r.Context()).
+func RequireLogin(m *websession.Manager) func(http.Handler) http.Handler {
+	return m.RequireLogin
+}
+
+// Session returns chi middleware that loads web session (if any) into request context.
+func Session(m *websession.Manager) func(http.Handler) http.Handler {
+	return m.Middleware
+}
+
+// Mount registers login ("/login"), callback ("/callback") and logout ("/logout") handlers on r. Config.OIDC.RedirectURL
+// of the manager must point to the callback handler.
+func Mount(r chi.Router, m *websession.Manager) {
+	r.Method(http.MethodGet, "/login", m.LoginHandler())
+	r.Method(http.MethodGet, "/callback", m.CallbackHandler())
+	r.Method(http.MethodGet, "/logout", m.LogoutHandler())
+}
@@ -0,0 +1,2 @@
+// Package oidcchi adapts authorize and websession packages to github.com/go-chi/chi router.
+package oidcchi
@@ -0,0 +1,2 @@
+// Package oidcecho adapts authorize and websession packages to github.com/labstack/echo router.
+package oidcecho
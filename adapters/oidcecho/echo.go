@@ -0,0 +1,63 @@
+//go:build oidcadapters
+// +build oidcadapters
+
+package oidcecho
+
+import (
+	"net/http"
+
+	"github.com/Bplotka/oidc/authorize"
+	"github.com/Bplotka/oidc/websession"
+	"github.com/labstack/echo/v4"
+)
+
+// Authorize returns echo middleware that rejects requests not authorized by a with 401, based on bearer token in
+// headerName header (usually "Authorization").
+func Authorize(a authorize.Authorizer, headerName string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := authorize.IsRequestAuthorized(c.Request(), a, headerName); err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireLogin returns echo middleware that redirects users without valid web session to the provider.
+// Identity is available via websession.IdentityFromContext(c.Request().Context()).
+func RequireLogin(m *websession.Manager) echo.MiddlewareFunc {
+	return wrap(m.RequireLogin)
+}
+
+// Session returns echo middleware that loads web session (if any) into request context.
+func Session(m *websession.Manager) echo.MiddlewareFunc {
+	return wrap(m.Middleware)
+}
+
+// wrap converts net/http middleware into echo middleware, passing error of the next handler through.
+func wrap(mw func(http.Handler) http.Handler) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			mw(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				err = next(c)
+			})).ServeHTTP(c.Response(), c.Request())
+			return err
+		}
+	}
+}
+
+// router is implemented by both *echo.Echo and *echo.Group.
+type router interface {
+	GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+}
+
+// Register registers login ("/login"), callback ("/callback") and logout ("/logout") handlers on r (*echo.Echo or
+// *echo.Group). Config.OIDC.RedirectURL of the manager must point to the callback handler.
+func Register(r router, m *websession.Manager) {
+	r.GET("/login", echo.WrapHandler(m.LoginHandler()))
+	r.GET("/callback", echo.WrapHandler(m.CallbackHandler()))
+	r.GET("/logout", echo.WrapHandler(m.LogoutHandler()))
+}
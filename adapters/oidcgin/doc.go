@@ -0,0 +1,2 @@
+// Package oidcgin adapts authorize and websession packages to github.com/gin-gonic/gin router.
+package oidcgin
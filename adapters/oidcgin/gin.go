@@ -0,0 +1,59 @@
+//go:build oidcadapters
+// +build oidcadapters
+
+package oidcgin
+
+import (
+	"net/http"
+
+	"github.com/Bplotka/oidc/authorize"
+	"github.com/Bplotka/oidc/websession"
+	"github.com/gin-gonic/gin"
+)
+
+// Authorize returns gin middleware that aborts requests not authorized by a with 401, based on bearer token in
+// headerName header (usually "Authorization").
+func Authorize(a authorize.Authorizer, headerName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := authorize.IsRequestAuthorized(c.Request, a, headerName); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireLogin returns gin middleware that redirects users without valid web session to the provider.
+// Identity is available via websession.IdentityFromContext(c.Request.Context()).
+func RequireLogin(m *websession.Manager) gin.HandlerFunc {
+	return wrap(m.RequireLogin)
+}
+
+// Session returns gin middleware that loads web session (if any) into request context.
+func Session(m *websession.Manager) gin.HandlerFunc {
+	return wrap(m.Middleware)
+}
+
+// wrap converts net/http middleware into gin middleware. Gin chain continues only if the middleware calls next
+// handler, otherwise response written by the middleware is final.
+func wrap(mw func(http.Handler) http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		called := false
+		mw(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			called = true
+			c.Request = r
+			c.Next()
+		})).ServeHTTP(c.Writer, c.Request)
+		if !called {
+			c.Abort()
+		}
+	}
+}
+
+// Register registers login ("/login"), callback ("/callback") and logout ("/logout") handlers on r.
+// Config.OIDC.RedirectURL of the manager must point to the callback handler.
+func Register(r gin.IRoutes, m *websession.Manager) {
+	r.GET("/login", gin.WrapH(m.LoginHandler()))
+	r.GET("/callback", gin.WrapH(m.CallbackHandler()))
+	r.GET("/logout", gin.WrapH(m.LogoutHandler()))
+}
@@ -0,0 +1,73 @@
+//go:build oidcadapters
+// +build oidcadapters
+
+package oidcgrpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Bplotka/oidc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// PerRPCCredentials is a credentials.PerRPCCredentials that attaches bearer access token from TokenSource to every
+// RPC. Token freshness is evaluated on every RPC, so TokenSource that refreshes tokens (e.g oidc.ReuseTokenSource or
+// login.OIDCTokenSource) keeps long-lived connections authenticated.
+//
+//    grpc.Dial(addr, grpc.WithTransportCredentials(creds), grpc.WithPerRPCCredentials(oidcgrpc.NewPerRPCCredentials(src)))
+//
+type PerRPCCredentials struct {
+	src        oidc.TokenSourceCtx
+	requireTLS bool
+}
+
+var _ credentials.PerRPCCredentials = &PerRPCCredentials{}
+
+// PerRPCCredentialsOption configures PerRPCCredentials.
+type PerRPCCredentialsOption func(*PerRPCCredentials)
+
+// WithInsecureTransport allows sending tokens over connections without transport security, e.g to local sidecar over
+// unix socket. Never use it for connections leaving the host, since tokens can be intercepted.
+func WithInsecureTransport() PerRPCCredentialsOption {
+	return func(c *PerRPCCredentials) {
+		c.requireTLS = false
+	}
+}
+
+// NewPerRPCCredentials constructs PerRPCCredentials that use tokens from given source. Transport security is required
+// unless WithInsecureTransport is given.
+func NewPerRPCCredentials(src oidc.TokenSource, opts ...PerRPCCredentialsOption) *PerRPCCredentials {
+	c := &PerRPCCredentials{
+		src:        oidc.NewTokenSourceCtx(src),
+		requireTLS: true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GetRequestMetadata returns authorization metadata with current access token. RPC context limits obtaining the token
+// too. Errors meaning user needs to log in again (see oidc.IsAuthError) are returned with Unauthenticated code, others
+// with Unavailable code, so RPC fails with appropriate status.
+func (c *PerRPCCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := c.src.OIDCTokenCtx(ctx)
+	if err != nil {
+		if oidc.IsAuthError(err) {
+			return nil, status.Errorf(codes.Unauthenticated, "oidcgrpc: failed to obtain token: %v", err)
+		}
+		return nil, status.Errorf(codes.Unavailable, "oidcgrpc: failed to obtain token: %v", err)
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "Bearer") {
+		return nil, status.Errorf(codes.Unauthenticated, "oidcgrpc: unsupported token type %q", token.TokenType)
+	}
+	return map[string]string{"authorization": "Bearer " + token.AccessToken}, nil
+}
+
+// RequireTransportSecurity returns true unless WithInsecureTransport was given.
+func (c *PerRPCCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
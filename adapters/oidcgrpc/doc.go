@@ -0,0 +1,2 @@
+// Package oidcgrpc adapts oidc token sources and verifiers to google.golang.org/grpc clients and servers.
+package oidcgrpc
@@ -0,0 +1,231 @@
+//go:build oidcadapters
+// +build oidcadapters
+
+package oidcgrpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Bplotka/oidc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Requirements are checked for every verified token, on top of verification done by oidc.Verifier.
+type Requirements struct {
+	// Scopes that must all be granted to the token.
+	Scopes []string
+	// Audiences of which at least one must be in the token's audience. Any audience accepted by the verifier is enough
+	// if empty.
+	Audiences []string
+}
+
+type interceptorConfig struct {
+	verifier oidc.Verifier
+	idTokens bool
+
+	defaults Requirements
+	methods  map[string]Requirements
+	skip     map[string]struct{}
+}
+
+// InterceptorOption configures server interceptors.
+type InterceptorOption func(*interceptorConfig)
+
+// WithIDTokens makes interceptors verify bearer tokens as ID tokens instead of JWT access tokens, e.g for services
+// called directly by CLI tools using login package. Scopes are then checked against "scope" claim of ID token, if any.
+func WithIDTokens() InterceptorOption {
+	return func(c *interceptorConfig) {
+		c.idTokens = true
+	}
+}
+
+// WithRequirements sets requirements checked for methods without own requirements given by WithMethodRequirements.
+func WithRequirements(r Requirements) InterceptorOption {
+	return func(c *interceptorConfig) {
+		c.defaults = r
+	}
+}
+
+// WithMethodRequirements sets requirements for given full method name, e.g "/pkg.Service/Method", replacing ones set
+// by WithRequirements.
+func WithMethodRequirements(fullMethod string, r Requirements) InterceptorOption {
+	return func(c *interceptorConfig) {
+		c.methods[fullMethod] = r
+	}
+}
+
+// WithSkippedMethods disables authentication for given full method names, e.g "/grpc.health.v1.Health/Check".
+func WithSkippedMethods(fullMethods ...string) InterceptorOption {
+	return func(c *interceptorConfig) {
+		for _, m := range fullMethods {
+			c.skip[m] = struct{}{}
+		}
+	}
+}
+
+func newInterceptorConfig(verifier oidc.Verifier, opts []InterceptorOption) *interceptorConfig {
+	c := &interceptorConfig{
+		verifier: verifier,
+		methods:  map[string]Requirements{},
+		skip:     map[string]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// UnaryServerInterceptor returns interceptor that rejects RPCs without valid bearer token in "authorization" metadata
+// with Unauthenticated code, and RPCs with tokens not meeting requirements with PermissionDenied code. Verified token
+// is available to handlers via AccessTokenFromContext (or IDTokenFromContext with WithIDTokens).
+func UnaryServerInterceptor(verifier oidc.Verifier, opts ...InterceptorOption) grpc.UnaryServerInterceptor {
+	c := newInterceptorConfig(verifier, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := c.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the same as UnaryServerInterceptor, but for streaming RPCs.
+func StreamServerInterceptor(verifier oidc.Verifier, opts ...InterceptorOption) grpc.StreamServerInterceptor {
+	c := newInterceptorConfig(verifier, opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := c.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides context of the stream with one holding verified token.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+type contextKey struct{}
+
+// verifiedToken is the token verified by interceptor. Exactly one of the fields is set.
+type verifiedToken struct {
+	accessToken *oidc.AccessToken
+	idToken     *oidc.IDToken
+}
+
+// AccessTokenFromContext returns access token verified by server interceptor. Claims are available via
+// AccessToken.Claims.
+func AccessTokenFromContext(ctx context.Context) (*oidc.AccessToken, bool) {
+	v, ok := ctx.Value(contextKey{}).(*verifiedToken)
+	if !ok || v.accessToken == nil {
+		return nil, false
+	}
+	return v.accessToken, true
+}
+
+// IDTokenFromContext returns ID token verified by server interceptor configured with WithIDTokens. Claims are
+// available via IDToken.Claims.
+func IDTokenFromContext(ctx context.Context) (*oidc.IDToken, bool) {
+	v, ok := ctx.Value(contextKey{}).(*verifiedToken)
+	if !ok || v.idToken == nil {
+		return nil, false
+	}
+	return v.idToken, true
+}
+
+// authenticate verifies bearer token of the RPC and returns ctx with verified token.
+func (c *interceptorConfig) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	if _, ok := c.skip[fullMethod]; ok {
+		return ctx, nil
+	}
+
+	raw, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &verifiedToken{}
+	var aud oidc.Audience
+	var scope string
+	if c.idTokens {
+		v.idToken, err = c.verifier.VerifyIDToken(ctx, raw)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "oidcgrpc: invalid token: %v", err)
+		}
+		var claims struct {
+			Scope string `json:"scope"`
+		}
+		if err := v.idToken.Claims(&claims); err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "oidcgrpc: invalid token claims: %v", err)
+		}
+		aud, scope = v.idToken.Audience, claims.Scope
+	} else {
+		v.accessToken, err = c.verifier.VerifyAccessToken(ctx, raw)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "oidcgrpc: invalid token: %v", err)
+		}
+		aud, scope = v.accessToken.Audience, v.accessToken.Scope
+	}
+
+	r, ok := c.methods[fullMethod]
+	if !ok {
+		r = c.defaults
+	}
+	if err := r.check(aud, scope); err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, contextKey{}, v), nil
+}
+
+// check returns PermissionDenied error if token with given audience and space-separated scopes does not meet r.
+func (r Requirements) check(aud oidc.Audience, scope string) error {
+	if len(r.Audiences) > 0 && !containsAny(aud, r.Audiences) {
+		return status.Errorf(codes.PermissionDenied, "oidcgrpc: token audience %v does not include any of %v", []string(aud), r.Audiences)
+	}
+
+	granted := strings.Fields(scope)
+	for _, s := range r.Scopes {
+		if !containsAny(granted, []string{s}) {
+			return status.Errorf(codes.PermissionDenied, "oidcgrpc: token is missing required scope %q", s)
+		}
+	}
+	return nil
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bearerToken returns bearer token from "authorization" metadata of incoming RPC.
+func bearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "oidcgrpc: no metadata")
+	}
+	auth := md.Get("authorization")
+	if len(auth) == 0 {
+		return "", status.Error(codes.Unauthenticated, "oidcgrpc: no authorization metadata")
+	}
+	parts := strings.SplitN(strings.TrimSpace(auth[0]), " ", 2)
+	if len(parts) < 2 || !strings.EqualFold(parts[0], "bearer") {
+		return "", status.Error(codes.Unauthenticated, "oidcgrpc: authorization metadata does not have Bearer format")
+	}
+	return strings.TrimSpace(parts[1]), nil
+}
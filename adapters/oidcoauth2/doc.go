@@ -0,0 +1,2 @@
+// Package oidcoauth2 adapts oidc token sources to golang.org/x/oauth2 token sources and back.
+package oidcoauth2
@@ -0,0 +1,89 @@
+//go:build oidcadapters
+// +build oidcadapters
+
+package oidcoauth2
+
+import (
+	"github.com/Bplotka/oidc"
+	"golang.org/x/oauth2"
+)
+
+// TokenSource returns oauth2.TokenSource that returns tokens from src. ID token is available via
+// oauth2.Token.Extra("id_token"), as libraries like google.golang.org/api expect.
+//
+//    oauth2.NewClient(ctx, oidcoauth2.TokenSource(loginSource))
+//
+func TokenSource(src oidc.TokenSource) oauth2.TokenSource {
+	return &oauth2TokenSource{src: src}
+}
+
+type oauth2TokenSource struct {
+	src oidc.TokenSource
+}
+
+// Token returns current token of the underlying source.
+func (s *oauth2TokenSource) Token() (*oauth2.Token, error) {
+	t, err := s.src.OIDCToken()
+	if err != nil {
+		return nil, err
+	}
+	return ToOAuth2Token(t), nil
+}
+
+// FromOAuth2 returns oidc.TokenSource that returns tokens from src. ID token is taken from "id_token" field of token
+// response (oauth2.Token.Extra("id_token")). Verifier of the returned source is the given one, which is used e.g by
+// oidc.ReuseTokenSource to check returned ID tokens.
+func FromOAuth2(src oauth2.TokenSource, verifier oidc.Verifier) oidc.TokenSource {
+	return &oidcTokenSource{src: src, verifier: verifier}
+}
+
+type oidcTokenSource struct {
+	src      oauth2.TokenSource
+	verifier oidc.Verifier
+}
+
+// OIDCToken returns current token of the underlying source.
+func (s *oidcTokenSource) OIDCToken() (*oidc.Token, error) {
+	t, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	return FromOAuth2Token(t), nil
+}
+
+// Verifier returns verifier given on construction.
+func (s *oidcTokenSource) Verifier() oidc.Verifier {
+	return s.verifier
+}
+
+// ToOAuth2Token converts oidc token to oauth2 token, keeping ID token and scope as extra fields.
+func ToOAuth2Token(t *oidc.Token) *oauth2.Token {
+	extra := map[string]interface{}{}
+	if t.IDToken != "" {
+		extra["id_token"] = t.IDToken
+	}
+	if t.Scope != "" {
+		extra["scope"] = t.Scope
+	}
+
+	return (&oauth2.Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+		Expiry:       t.AccessTokenExpiry,
+	}).WithExtra(extra)
+}
+
+// FromOAuth2Token converts oauth2 token to oidc token, taking ID token and scope from extra fields if present.
+func FromOAuth2Token(t *oauth2.Token) *oidc.Token {
+	idToken, _ := t.Extra("id_token").(string)
+	scope, _ := t.Extra("scope").(string)
+	return &oidc.Token{
+		AccessToken:       t.AccessToken,
+		TokenType:         t.TokenType,
+		RefreshToken:      t.RefreshToken,
+		AccessTokenExpiry: t.Expiry,
+		IDToken:           idToken,
+		Scope:             scope,
+	}
+}
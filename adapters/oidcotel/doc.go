@@ -0,0 +1,2 @@
+// Package oidcotel adapts OpenTelemetry tracers to oidc.Tracer.
+package oidcotel
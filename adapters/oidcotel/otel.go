@@ -0,0 +1,75 @@
+//go:build oidcadapters
+// +build oidcadapters
+
+package oidcotel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Bplotka/oidc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultInstrumentationName is the instrumentation name of tracer returned by NewTracerFromProvider.
+const DefaultInstrumentationName = "github.com/Bplotka/oidc"
+
+// NewTracer returns oidc.Tracer that records spans using tracer.
+//
+//    client, err := oidc.NewClient(ctx, issuer, oidc.WithTracer(oidcotel.NewTracer(otel.Tracer("auth"))))
+//
+func NewTracer(tracer trace.Tracer) oidc.Tracer {
+	return &otelTracer{tracer: tracer}
+}
+
+// NewTracerFromProvider is like NewTracer, but uses tracer of given provider named DefaultInstrumentationName.
+func NewTracerFromProvider(provider trace.TracerProvider) oidc.Tracer {
+	return NewTracer(provider.Tracer(DefaultInstrumentationName))
+}
+
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+// StartSpan starts client span, since all traced operations call the provider or verify its tokens.
+func (t *otelTracer) StartSpan(ctx context.Context, name string, attrs ...oidc.SpanAttribute) (context.Context, oidc.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(toAttributes(attrs)...))
+	return ctx, &otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+// SetAttributes sets attributes of the span.
+func (s *otelSpan) SetAttributes(attrs ...oidc.SpanAttribute) {
+	s.span.SetAttributes(toAttributes(attrs)...)
+}
+
+// End records err (if any) as span error and ends the span.
+func (s *otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+func toAttributes(attrs []oidc.SpanAttribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,182 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AuditOperation is a type of token operation reported to AuditHook.
+type AuditOperation string
+
+const (
+	// AuditLogin is reported when new token is obtained by exchanging authorization grant (e.g code).
+	AuditLogin AuditOperation = "login"
+	// AuditRefresh is reported when token is refreshed using refresh token.
+	AuditRefresh AuditOperation = "refresh"
+	// AuditRevoke is reported when token is revoked.
+	AuditRevoke AuditOperation = "revoke"
+	// AuditTokenExchange is reported when token is exchanged for another one using token exchange.
+	AuditTokenExchange AuditOperation = "token-exchange"
+	// AuditVerifyFailure is reported when ID token verification fails.
+	AuditVerifyFailure AuditOperation = "verify-fail"
+)
+
+// AuditErrorKind classifies failure reported in AuditEvent.
+type AuditErrorKind string
+
+const (
+	// AuditErrorCanceled means the operation was canceled or timed out by the caller's context.
+	AuditErrorCanceled AuditErrorKind = "canceled"
+	// AuditErrorNetwork means the provider could not be reached.
+	AuditErrorNetwork AuditErrorKind = "network"
+	// AuditErrorOAuth2 means the provider returned OAuth2 error response. See AuditEvent.ErrorCode.
+	AuditErrorOAuth2 AuditErrorKind = "oauth2"
+	// AuditErrorHTTP means the provider returned unexpected HTTP status.
+	AuditErrorHTTP AuditErrorKind = "http"
+	// AuditErrorFIPS means an algorithm or key not allowed in FIPS mode was used.
+	AuditErrorFIPS AuditErrorKind = "fips"
+	// AuditErrorUnknownIssuer means the token was issued by an issuer which is not trusted.
+	AuditErrorUnknownIssuer AuditErrorKind = "unknown-issuer"
+	// AuditErrorInvalidToken means token failed verification e.g bad signature, audience, nonce or expiry.
+	AuditErrorInvalidToken AuditErrorKind = "invalid-token"
+	// AuditErrorOther is any other failure.
+	AuditErrorOther AuditErrorKind = "other"
+)
+
+// AuditEvent describes single token operation. It never contains tokens, client secrets or raw error messages, which
+// might include e.g expected nonce.
+type AuditEvent struct {
+	Time      time.Time
+	Operation AuditOperation
+	Issuer    string
+	ClientID  string
+	// Subject is the "sub" claim of ID token obtained in the operation if known. Empty for AuditVerifyFailure.
+	Subject string
+	// UnverifiedSubject is the "sub" claim of token which failed verification (AuditVerifyFailure). It comes from
+	// the token that was rejected, so it is controlled by whoever sent it and must not be trusted.
+	UnverifiedSubject string
+	Success           bool
+	// ErrorKind classifies reason of failure. Empty on success.
+	ErrorKind AuditErrorKind
+	// ErrorCode is the OAuth2 error code (e.g invalid_grant) if ErrorKind is AuditErrorOAuth2.
+	ErrorCode string
+}
+
+// Fields returns event as a list of key-value pairs for structured loggers.
+func (e AuditEvent) Fields() []interface{} {
+	fields := []interface{}{
+		"time", e.Time,
+		"operation", string(e.Operation),
+		"issuer", e.Issuer,
+		"client_id", e.ClientID,
+		"sub", e.Subject,
+		"success", e.Success,
+	}
+	if e.UnverifiedSubject != "" {
+		fields = append(fields, "unverified_sub", e.UnverifiedSubject)
+	}
+	if e.ErrorKind != "" {
+		fields = append(fields, "error_kind", string(e.ErrorKind))
+	}
+	if e.ErrorCode != "" {
+		fields = append(fields, "error_code", e.ErrorCode)
+	}
+	return fields
+}
+
+// AuditHook is notified about all token operations performed by the Client and its verifiers and token sources.
+// Audit must be safe for concurrent use and should not block.
+type AuditHook interface {
+	Audit(event AuditEvent)
+}
+
+// AuditHookFunc is a function adapter for AuditHook.
+type AuditHookFunc func(event AuditEvent)
+
+// Audit calls f(event).
+func (f AuditHookFunc) Audit(event AuditEvent) {
+	f(event)
+}
+
+// WithAuditHook sets a hook that is notified about every login, refresh, revoke and failed verification.
+func WithAuditHook(hook AuditHook) Option {
+	return func(o *options) {
+		o.auditHook = hook
+	}
+}
+
+// audit reports event to the hook if any.
+func (c *Client) audit(op AuditOperation, clientID string, subject string, err error) {
+	auditEvent(c.opts.auditHook, op, c.issuer, clientID, subject, err)
+}
+
+func auditEvent(hook AuditHook, op AuditOperation, issuer string, clientID string, subject string, err error) {
+	if hook == nil {
+		return
+	}
+
+	e := AuditEvent{
+		Time:      time.Now(),
+		Operation: op,
+		Issuer:    issuer,
+		ClientID:  clientID,
+		Subject:   subject,
+		Success:   err == nil,
+	}
+	if op == AuditVerifyFailure {
+		e.Subject, e.UnverifiedSubject = "", subject
+	}
+	if err != nil {
+		e.ErrorKind, e.ErrorCode = classifyAuditError(op, err)
+	}
+	hook.Audit(e)
+}
+
+// classifyAuditError returns kind of err and its OAuth2 error code if any. Anything else from err is dropped, since
+// error messages are not safe to be logged.
+func classifyAuditError(op AuditOperation, err error) (kind AuditErrorKind, code string) {
+	kind = AuditErrorOther
+	if op == AuditVerifyFailure {
+		kind = AuditErrorInvalidToken
+	}
+	walkErrors(err, func(err error) bool {
+		switch e := err.(type) {
+		case *OAuth2Error:
+			kind, code = AuditErrorOAuth2, e.Code
+		case *HTTPError:
+			kind = AuditErrorHTTP
+		case *NetworkError:
+			kind = AuditErrorNetwork
+		case *FIPSError:
+			kind = AuditErrorFIPS
+		case *UnknownIssuerError:
+			kind = AuditErrorUnknownIssuer
+		default:
+			if err != context.Canceled && err != context.DeadlineExceeded {
+				return false
+			}
+			kind = AuditErrorCanceled
+		}
+		return true
+	})
+	return kind, code
+}
+
+// unverifiedSubject returns "sub" claim of given ID token without verifying it. Only for audit purposes.
+func unverifiedSubject(rawIDToken string) string {
+	if rawIDToken == "" {
+		return ""
+	}
+	payload, err := parseJWT(rawIDToken)
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Subject
+}
@@ -0,0 +1,76 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ClientTestSuite) TestAudit() {
+	idToken, _ := s.validIDToken()
+
+	var events []AuditEvent
+	client := *s.client
+	client.opts.auditHook = AuditHookFunc(func(e AuditEvent) {
+		events = append(events, e)
+	})
+
+	// Audience mismatch, so verification fails before fetching keys.
+	_, err := client.Verifier(VerificationConfig{ClientID: "client2"}).Verify(s.testCtx, idToken)
+	s.Error(err)
+
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access1",
+		RefreshToken: "refresh2",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+	})
+	s.NoError(err)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+
+	_, err = NewTokenRefresher(s.testCtx, &client, Config{ClientID: "client1"}, "refresh1").OIDCToken()
+	s.NoError(err)
+
+	s.Equal(0, s.s.Len())
+	s.Len(events, 2)
+
+	s.Equal(AuditVerifyFailure, events[0].Operation)
+	s.Equal(exampleIssuer, events[0].Issuer)
+	s.Equal("client2", events[0].ClientID)
+	s.Empty(events[0].Subject)
+	s.Equal("subject1", events[0].UnverifiedSubject)
+	s.False(events[0].Success)
+	s.Equal(AuditErrorInvalidToken, events[0].ErrorKind)
+
+	s.Equal(AuditRefresh, events[1].Operation)
+	s.Equal("client1", events[1].ClientID)
+	s.Equal("subject1", events[1].Subject)
+	s.Empty(events[1].UnverifiedSubject)
+	s.True(events[1].Success)
+	s.Empty(events[1].ErrorKind)
+}
+
+func TestClassifyAuditError(t *testing.T) {
+	for _, tcase := range []struct {
+		op   AuditOperation
+		err  error
+		kind AuditErrorKind
+		code string
+	}{
+		{op: AuditVerifyFailure, err: errors.New("oidc: Invalid configuration. ClaimNonce must match. Got a, expected b"), kind: AuditErrorInvalidToken},
+		{op: AuditRefresh, err: errors.New("oidc: failed"), kind: AuditErrorOther},
+		{op: AuditRefresh, err: wrapErrorf(&OAuth2Error{Code: "invalid_grant"}, "oauth2: refresh failed"), kind: AuditErrorOAuth2, code: "invalid_grant"},
+		{op: AuditLogin, err: &NetworkError{Err: errors.New("connection refused")}, kind: AuditErrorNetwork},
+		{op: AuditLogin, err: wrapErrorf(context.DeadlineExceeded, "oidc: exchange failed"), kind: AuditErrorCanceled},
+		{op: AuditVerifyFailure, err: &FIPSError{Algorithm: "EdDSA"}, kind: AuditErrorFIPS},
+	} {
+		kind, code := classifyAuditError(tcase.op, tcase.err)
+		assert.Equal(t, tcase.kind, kind, tcase.err.Error())
+		assert.Equal(t, tcase.code, code, tcase.err.Error())
+	}
+}
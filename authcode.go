@@ -0,0 +1,179 @@
+package oidc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Prompt values. See http://openid.net/specs/openid-connect-core-1_0.html#AuthRequest.
+	PromptNone          = "none"
+	PromptLogin         = "login"
+	PromptConsent       = "consent"
+	PromptSelectAccount = "select_account"
+
+	// PKCEMethodS256 is the only PKCE code challenge method we support. See https://tools.ietf.org/html/rfc7636.
+	PKCEMethodS256 = "S256"
+)
+
+// AuthCodeOption sets parameter of the authorization request built by Client.AuthCodeURLWithOptions.
+type AuthCodeOption func(v url.Values)
+
+// WithScopes overrides scopes from Config for single authorization request.
+func WithScopes(scopes ...string) AuthCodeOption {
+	return func(v url.Values) {
+		v.Set("scope", strings.Join(scopes, " "))
+	}
+}
+
+// WithState sets state parameter. State is a token to protect the user from CSRF attacks. You must
+// always provide a non-zero string and validate that it matches the state query parameter on your redirect callback.
+func WithState(state string) AuthCodeOption {
+	return func(v url.Values) {
+		v.Set("state", state)
+	}
+}
+
+// WithNonce sets nonce parameter that provider includes in the ID token. Use VerificationConfig.ClaimNonce to check it.
+func WithNonce(nonce string) AuthCodeOption {
+	return func(v url.Values) {
+		v.Set("nonce", nonce)
+	}
+}
+
+// WithPKCE sets S256 code challenge derived from given code verifier (see NewPKCEVerifier). The same verifier needs
+// to be passed to Exchange using PKCEVerifierParam.
+func WithPKCE(codeVerifier string) AuthCodeOption {
+	return func(v url.Values) {
+		v.Set("code_challenge", PKCEChallenge(codeVerifier))
+		v.Set("code_challenge_method", PKCEMethodS256)
+	}
+}
+
+// WithPrompt sets prompt parameter e.g PromptConsent.
+func WithPrompt(prompts ...string) AuthCodeOption {
+	return func(v url.Values) {
+		v.Set("prompt", strings.Join(prompts, " "))
+	}
+}
+
+// WithACRValues sets requested Authentication Context Class Reference values in order of preference.
+func WithACRValues(acrValues ...string) AuthCodeOption {
+	return func(v url.Values) {
+		v.Set("acr_values", strings.Join(acrValues, " "))
+	}
+}
+
+// WithResource adds resource parameter for each given target service. See https://tools.ietf.org/html/rfc8707.
+func WithResource(resources ...string) AuthCodeOption {
+	return func(v url.Values) {
+		for _, r := range resources {
+			v.Add("resource", r)
+		}
+	}
+}
+
+// WithIncludeGrantedScopes asks provider to include scopes previously granted to the client in the new token, so scopes
+// can be requested incrementally. Supported e.g by Google.
+func WithIncludeGrantedScopes() AuthCodeOption {
+	return func(v url.Values) {
+		v.Set("include_granted_scopes", "true")
+	}
+}
+
+// WithAuthParam sets arbitrary parameter of the authorization request.
+func WithAuthParam(key string, value string) AuthCodeOption {
+	return func(v url.Values) {
+		v.Set(key, value)
+	}
+}
+
+// WithAuthParams sets arbitrary parameters of the authorization request. Only first value of each key is used.
+func WithAuthParams(extra url.Values) AuthCodeOption {
+	return func(v url.Values) {
+		for key := range extra {
+			v.Set(key, extra.Get(key))
+		}
+	}
+}
+
+// NewPKCEVerifier returns new random PKCE code verifier.
+func NewPKCEVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PKCEChallenge returns S256 code challenge for given code verifier.
+func PKCEChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PKCEVerifierParam returns extra parameters for Exchange that pass code verifier used in WithPKCE.
+func PKCEVerifierParam(codeVerifier string) url.Values {
+	return url.Values{"code_verifier": {codeVerifier}}
+}
+
+// AuthCodeURLWithOptions returns a URL to OIDC provider's consent page for authorization code flow.
+// Client ID, redirect URL and scopes are taken from cfg, all other parameters are set by options, applied in order.
+// See http://openid.net/specs/openid-connect-core-1_0.html#AuthRequest for more info.
+//
+//    verifier, err := oidc.NewPKCEVerifier()
+//    if err != nil {
+//        // handle error
+//    }
+//    url := client.AuthCodeURLWithOptions(cfg, oidc.WithState(state), oidc.WithNonce(nonce), oidc.WithPKCE(verifier))
+//
+func (c *Client) AuthCodeURLWithOptions(cfg Config, opts ...AuthCodeOption) string {
+	return c.authURL(authCodeValues(cfg, opts...))
+}
+
+// authCodeValues returns parameters of the authorization request.
+func authCodeValues(cfg Config, opts ...AuthCodeOption) url.Values {
+	v := url.Values{
+		"response_type": {ResponseTypeCode},
+		"client_id":     {cfg.ClientID},
+		"redirect_uri":  {cfg.RedirectURL},
+	}
+
+	if len(cfg.Scopes) > 0 {
+		v.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+	setResources(v, cfg.Resources)
+	return v
+}
+
+// setResources adds resource parameters unless request already has some, e.g from WithResource or
+// WithExchangeResource options.
+func setResources(v url.Values, resources []string) {
+	if _, ok := v["resource"]; ok {
+		return
+	}
+	for _, r := range resources {
+		v.Add("resource", r)
+	}
+}
+
+func (c *Client) authURL(v url.Values) string {
+	discovery := c.Discovery()
+	var buf bytes.Buffer
+	buf.WriteString(discovery.AuthURL)
+	if strings.Contains(discovery.AuthURL, "?") {
+		buf.WriteByte('&')
+	} else {
+		buf.WriteByte('?')
+	}
+	buf.WriteString(v.Encode())
+	return buf.String()
+}
@@ -0,0 +1,90 @@
+package oidc
+
+import (
+	"net/http"
+	"net/url"
+)
+
+func (s *ClientTestSuite) TestAuthCodeURLWithOptions() {
+	cfg := Config{
+		ClientID:    "client1",
+		RedirectURL: "http://127.0.0.1/callback",
+		Scopes:      []string{ScopeOpenID},
+	}
+
+	verifier, err := NewPKCEVerifier()
+	s.Require().NoError(err)
+
+	authURL, err := url.Parse(s.client.AuthCodeURLWithOptions(cfg,
+		WithState("state1"),
+		WithNonce("nonce1"),
+		WithPKCE(verifier),
+		WithScopes(ScopeOpenID, ScopeEmail),
+		WithPrompt(PromptLogin, PromptConsent),
+		WithACRValues("acr1"),
+		WithResource("https://api1.example.com", "https://api2.example.com"),
+		WithAuthParam("login_hint", "user1"),
+	))
+	s.Require().NoError(err)
+
+	s.Equal(exampleIssuer+"/auth1", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	q := authURL.Query()
+	s.Equal(ResponseTypeCode, q.Get("response_type"))
+	s.Equal("client1", q.Get("client_id"))
+	s.Equal("http://127.0.0.1/callback", q.Get("redirect_uri"))
+	s.Equal("state1", q.Get("state"))
+	s.Equal("nonce1", q.Get("nonce"))
+	s.Equal(PKCEChallenge(verifier), q.Get("code_challenge"))
+	s.Equal(PKCEMethodS256, q.Get("code_challenge_method"))
+	s.Equal("openid email", q.Get("scope"))
+	s.Equal("login consent", q.Get("prompt"))
+	s.Equal("acr1", q.Get("acr_values"))
+	s.Equal([]string{"https://api1.example.com", "https://api2.example.com"}, q["resource"])
+	s.Equal("user1", q.Get("login_hint"))
+
+	// Legacy AuthCodeURL produces the same URL as equivalent options.
+	s.Equal(
+		s.client.AuthCodeURLWithOptions(cfg, WithState("state1"), WithNonce("nonce1")),
+		s.client.AuthCodeURL(cfg, "state1", url.Values{"nonce": {"nonce1"}}),
+	)
+}
+
+func (s *ClientTestSuite) TestPKCEChallenge() {
+	// Example from https://tools.ietf.org/html/rfc7636#appendix-B.
+	s.Equal("E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", PKCEChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
+
+func (s *ClientTestSuite) TestConfigResources() {
+	cfg := Config{
+		ClientID:  "client1",
+		Resources: []string{"https://api1.example.com", "https://api2.example.com"},
+	}
+
+	authURL, err := url.Parse(s.client.AuthCodeURLWithOptions(cfg, WithState("state1")))
+	s.Require().NoError(err)
+	s.Equal(cfg.Resources, authURL.Query()["resource"])
+
+	// Explicit option takes precedence.
+	authURL, err = url.Parse(s.client.AuthCodeURLWithOptions(cfg, WithResource("https://api3.example.com")))
+	s.Require().NoError(err)
+	s.Equal([]string{"https://api3.example.com"}, authURL.Query()["resource"])
+
+	var form url.Values
+	var header http.Header
+	s.pushTokenRequestCapture(&form, &header)
+	_, err = s.client.Exchange(s.testCtx, cfg, "code1")
+	s.Require().NoError(err)
+	s.Equal(cfg.Resources, form["resource"])
+
+	s.pushTokenRequestCapture(&form, &header)
+	_, err = NewTokenRefresher(s.testCtx, s.client, cfg, "refresh1").OIDCToken()
+	s.Require().NoError(err)
+	s.Equal(GrantTypeRefreshToken, form.Get("grant_type"))
+	s.Equal(cfg.Resources, form["resource"])
+
+	s.pushTokenRequestCapture(&form, &header)
+	_, err = s.client.TokenExchange(s.testCtx, cfg, "subject1", WithExchangeResource("https://api3.example.com"))
+	s.Require().NoError(err)
+	s.Equal([]string{"https://api3.example.com"}, form["resource"])
+	s.Equal(0, s.s.Len())
+}
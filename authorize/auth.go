@@ -23,7 +23,7 @@ type authorizer struct {
 }
 
 func New(ctx context.Context, config Config) (Authorizer, error) {
-	client, err := oidc.NewClient(ctx, config.Provider)
+	client, err := oidc.NewClient(ctx, config.Provider, config.ClientOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create OIDC client agains %q provider. Err: %v", config.Provider, err)
 	}
@@ -88,3 +88,17 @@ func IsRequestAuthorized(req *http.Request, a Authorizer, headerName string) err
 
 	return a.IsAuthorized(req.Context(), parts[1])
 }
+
+// Middleware returns net/http middleware that responds with 401 to requests that are not authorized by a, based on
+// bearer token in headerName header (usually "Authorization").
+func Middleware(a Authorizer, headerName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := IsRequestAuthorized(r, a, headerName); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package authorize_test
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -63,3 +67,31 @@ func TestIsAuthorized(t *testing.T) {
 	p.MockPubKeysCall(keys)
 	require.NoError(t, a.IsAuthorized(p.Context(), authorizedToken), "token ok - expected to be authorized.")
 }
+
+type tokenAuthorizer string
+
+func (a tokenAuthorizer) IsAuthorized(_ context.Context, token string) error {
+	if token != string(a) {
+		return errors.New("Unauthorized.")
+	}
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	h := authorize.Middleware(tokenAuthorizer("token1"), "Authorization")(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for header, expectedCode := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"token1":        http.StatusUnauthorized,
+		"Bearer token2": http.StatusUnauthorized,
+		"Bearer token1": http.StatusNoContent,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", header)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, expectedCode, rec.Code, header)
+	}
+}
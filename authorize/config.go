@@ -1,5 +1,7 @@
 package authorize
 
+import "github.com/Bplotka/oidc"
+
 // Config is an authorize configuration.
 // TODO(bplotka): Add proper unmarshaller/marshaller for that data struct.
 type Config struct {
@@ -14,4 +16,7 @@ type Config struct {
 
 	// Permission condition that will authorize token.
 	PermCondition Condition
+
+	// ClientOptions are passed to the underlying oidc.Client e.g oidc.WithAuditHook.
+	ClientOptions []oidc.Option
 }
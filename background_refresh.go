@@ -0,0 +1,89 @@
+package oidc
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	// backgroundRefreshMinInterval limits how often background refresh runs, e.g when tokens live shorter than the
+	// configured margin.
+	backgroundRefreshMinInterval = 1 * time.Second
+	// backgroundRefreshRetryInterval is how long background refresh waits after failed refresh.
+	backgroundRefreshRetryInterval = 10 * time.Second
+)
+
+// WithBackgroundRefresh makes ReuseTokenSource refresh token in background goroutine, before (plus random jitter of up
+// to 10% of before) its access token expires, so callers never wait for a token round trip. Background refresh runs
+// only between Start and Stop. Failed background refresh is logged and retried; callers still refresh on their own
+// if token expires anyway.
+func WithBackgroundRefresh(before time.Duration) ReuseTokenSourceOption {
+	return func(s *ReuseTokenSource) {
+		s.refreshBefore = before
+	}
+}
+
+// Start starts background refresh configured with WithBackgroundRefresh. If there is no token yet, it is obtained
+// right away. Start is no-op if background refresh is not configured or already started.
+func (s *ReuseTokenSource) Start() {
+	if s.refreshBefore <= 0 {
+		return
+	}
+
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.backgroundRefresh(s.stop, s.stopped)
+}
+
+// Stop stops background refresh and waits until refresh in progress, if any, finishes. Token source can still be
+// used and started again.
+func (s *ReuseTokenSource) Stop() {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.stopped
+	s.stop, s.stopped = nil, nil
+}
+
+func (s *ReuseTokenSource) backgroundRefresh(stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	var wait time.Duration
+	margin := s.refreshBefore
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		t, err := s.token(s.ctx, s.new.OIDCToken, margin)
+		if err != nil {
+			s.logger.Warn("reuseTokenSource: Background refresh failed.", "retry_in", backgroundRefreshRetryInterval, "err", err)
+			wait = backgroundRefreshRetryInterval
+			continue
+		}
+
+		// The same margin is used when timer fires, so token is refreshed then.
+		margin = s.refreshBefore + time.Duration(rand.Int63n(int64(s.refreshBefore/10)+1))
+		wait = s.refreshBefore
+		if !t.AccessTokenExpiry.IsZero() {
+			wait = time.Until(t.AccessTokenExpiry.Add(-margin))
+		}
+		if wait < backgroundRefreshMinInterval {
+			wait = backgroundRefreshMinInterval
+		}
+	}
+}
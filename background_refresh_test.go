@@ -0,0 +1,63 @@
+package oidc
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type countingTokenSource struct {
+	calls    int32
+	idToken  string
+	expiry   time.Duration
+	verifier Verifier
+}
+
+func (s *countingTokenSource) OIDCToken() (*Token, error) {
+	n := atomic.AddInt32(&s.calls, 1)
+	return &Token{
+		AccessToken:       fmt.Sprintf("access%d", n),
+		IDToken:           s.idToken,
+		AccessTokenExpiry: time.Now().Add(s.expiry),
+	}, nil
+}
+
+func (s *countingTokenSource) Verifier() Verifier {
+	return s.verifier
+}
+
+func (s *ClientTestSuite) TestReuseTokenSource_BackgroundRefresh() {
+	idToken, jwkSetJSON := s.validIDToken()
+	verifier, err := NewStaticVerifier(exampleIssuer, jwkSetJSON, VerificationConfig{ClientID: "client1"})
+	s.Require().NoError(err)
+
+	// Token expires right after refresh margin, so every background refresh happens after min interval.
+	src := &countingTokenSource{idToken: idToken, expiry: 30*time.Second + 500*time.Millisecond, verifier: verifier}
+	ret, _ := NewReuseTokenSource(s.testCtx, nil, src, WithBackgroundRefresh(30*time.Second))
+	reuse := ret.(*ReuseTokenSource)
+
+	reuse.Start()
+	reuse.Start()
+	waitForCalls := func(n int32) {
+		for i := 0; i < 300 && atomic.LoadInt32(&src.calls) < n; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		s.Require().Equal(n, atomic.LoadInt32(&src.calls))
+	}
+
+	// Token is obtained right away and then refreshed before expiry.
+	waitForCalls(1)
+	token, err := reuse.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+	waitForCalls(2)
+	reuse.Stop()
+	reuse.Stop()
+
+	token, err = reuse.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken, "caller should get token refreshed in the background")
+
+	time.Sleep(backgroundRefreshMinInterval + 200*time.Millisecond)
+	s.Equal(int32(2), atomic.LoadInt32(&src.calls), "no refresh should happen after Stop")
+}
@@ -0,0 +1,226 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GrantTypeCIBA is the Client-Initiated Backchannel Authentication grant. See
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html.
+const GrantTypeCIBA = "urn:openid:params:grant-type:ciba"
+
+// BackchannelAuthOption sets parameter of the backchannel authentication request.
+type BackchannelAuthOption func(v url.Values)
+
+// WithLoginHint identifies the user to authenticate, e.g by email or phone number.
+func WithLoginHint(hint string) BackchannelAuthOption {
+	return func(v url.Values) {
+		v.Set("login_hint", hint)
+	}
+}
+
+// WithIDTokenHint identifies the user to authenticate by previously issued ID token.
+func WithIDTokenHint(idToken string) BackchannelAuthOption {
+	return func(v url.Values) {
+		v.Set("id_token_hint", idToken)
+	}
+}
+
+// WithBindingMessage sets short message displayed on both the consumption device (e.g CLI) and the authentication
+// device (e.g phone), so user can tell the request is the one they started.
+func WithBindingMessage(msg string) BackchannelAuthOption {
+	return func(v url.Values) {
+		v.Set("binding_message", msg)
+	}
+}
+
+// WithUserCode sets secret code known only to the user, if provider supports it.
+func WithUserCode(code string) BackchannelAuthOption {
+	return func(v url.Values) {
+		v.Set("user_code", code)
+	}
+}
+
+// WithRequestedExpiry asks for given lifetime of the authentication request.
+func WithRequestedExpiry(d time.Duration) BackchannelAuthOption {
+	return func(v url.Values) {
+		v.Set("requested_expiry", strconv.Itoa(int(d.Seconds())))
+	}
+}
+
+// WithClientNotificationToken enables ping mode: provider notifies client's registered notification endpoint when
+// user authenticates, using token as bearer authorization. See BackchannelNotificationHandler.
+func WithClientNotificationToken(token string) BackchannelAuthOption {
+	return func(v url.Values) {
+		v.Set("client_notification_token", token)
+	}
+}
+
+// BackchannelAuthResponse is the response of backchannel authentication endpoint.
+type BackchannelAuthResponse struct {
+	AuthReqID string `json:"auth_req_id"`
+	// ExpiresIn is lifetime of the request in seconds.
+	ExpiresIn int `json:"expires_in"`
+	// Interval is minimum polling interval in seconds.
+	Interval int `json:"interval,omitempty"`
+
+	// Expiry is the time request expires, computed from ExpiresIn.
+	Expiry time.Time `json:"-"`
+}
+
+// BackchannelAuth starts Client-Initiated Backchannel Authentication: provider asks the user identified by one of
+// hint options to approve the login on their authentication device, e.g by push notification on the phone, without
+// any browser redirect. Call BackchannelToken to wait for the approval. "openid" scope is always requested.
+func (c *Client) BackchannelAuth(ctx context.Context, cfg Config, opts ...BackchannelAuthOption) (*BackchannelAuthResponse, error) {
+	discovery := c.Discovery()
+	if discovery.BackchannelAuthURL == "" {
+		return nil, errors.New("oidc: backchannel authentication endpoint is not supported by this provider")
+	}
+
+	scopes := cfg.Scopes
+	if !contains(scopes, ScopeOpenID) {
+		scopes = append([]string{ScopeOpenID}, scopes...)
+	}
+	v := url.Values{"scope": {strings.Join(scopes, " ")}}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	req, err := c.newClientAuthRequest(discovery.BackchannelAuthURL, mtlsBackchannelAuthURL, cfg, v)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.doClientAuthRequest(ctx, RequestBackchannelAuth, req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, wrapErrorf(&NetworkError{Err: err}, "oidc: cannot start backchannel authentication: %v", err)
+	}
+	if code := r.StatusCode; code < 200 || code > 299 {
+		return nil, newOAuth2Error("oidc: cannot start backchannel authentication", r, body)
+	}
+
+	var b BackchannelAuthResponse
+	if err := json.Unmarshal(body, &b); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode backchannel authentication response: %v", err)
+	}
+	if b.AuthReqID == "" {
+		return nil, fmt.Errorf("oidc: backchannel authentication response without auth_req_id: %s", body)
+	}
+	if b.ExpiresIn > 0 {
+		b.Expiry = time.Now().Add(time.Duration(b.ExpiresIn) * time.Second)
+	}
+	return &b, nil
+}
+
+// BackchannelToken polls token endpoint (poll mode) until user approves the authentication request, denies it,
+// the request expires or the context is done. Returned token is not verified.
+func (c *Client) BackchannelToken(ctx context.Context, cfg Config, b *BackchannelAuthResponse) (*Token, error) {
+	interval := defaultDeviceInterval
+	if b.Interval > 0 {
+		interval = time.Duration(b.Interval) * time.Second
+	}
+	return c.pollToken(ctx, cfg, backchannelTokenValues(b), interval, b.Expiry, nil, "backchannel authentication")
+}
+
+// BackchannelTokenOnPing waits for ping notification of the authentication request (ping mode, see
+// BackchannelNotificationHandler) and then fetches the token. Token endpoint is additionally polled in long
+// intervals in case notification got lost. Returned token is not verified.
+func (c *Client) BackchannelTokenOnPing(ctx context.Context, cfg Config, b *BackchannelAuthResponse, h *BackchannelNotificationHandler) (*Token, error) {
+	wakeup := h.subscribe(b.AuthReqID)
+	defer h.unsubscribe(b.AuthReqID)
+
+	interval := time.Duration(b.ExpiresIn) * time.Second / 4
+	if interval < defaultDeviceInterval {
+		interval = defaultDeviceInterval
+	}
+	return c.pollToken(ctx, cfg, backchannelTokenValues(b), interval, b.Expiry, wakeup, "backchannel authentication")
+}
+
+func backchannelTokenValues(b *BackchannelAuthResponse) url.Values {
+	return url.Values{
+		"grant_type":  {GrantTypeCIBA},
+		"auth_req_id": {b.AuthReqID},
+	}
+}
+
+func mtlsBackchannelAuthURL(a *MTLSEndpointAliases) string { return a.BackchannelAuthURL }
+
+// BackchannelNotificationHandler is the client notification endpoint for ping mode. Serve it under the URL registered
+// as backchannel_client_notification_endpoint for the client, and pass it to BackchannelTokenOnPing.
+type BackchannelNotificationHandler struct {
+	token string
+
+	mu      sync.Mutex
+	waiting map[string]chan struct{}
+}
+
+// NewBackchannelNotificationHandler constructs BackchannelNotificationHandler that accepts notifications authorized
+// with given client notification token (see WithClientNotificationToken).
+func NewBackchannelNotificationHandler(clientNotificationToken string) *BackchannelNotificationHandler {
+	return &BackchannelNotificationHandler{
+		token:   clientNotificationToken,
+		waiting: map[string]chan struct{}{},
+	}
+}
+
+func (h *BackchannelNotificationHandler) subscribe(authReqID string) <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	h.waiting[authReqID] = ch
+	return ch
+}
+
+func (h *BackchannelNotificationHandler) unsubscribe(authReqID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.waiting, authReqID)
+}
+
+// ServeHTTP handles ping notification.
+func (h *BackchannelNotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+h.token)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var notification struct {
+		AuthReqID string `json:"auth_req_id"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&notification); err != nil || notification.AuthReqID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	ch, ok := h.waiting[notification.AuthReqID]
+	h.mu.Unlock()
+	if ok {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
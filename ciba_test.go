@@ -0,0 +1,124 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestBackchannel_Poll() {
+	oldInterval := defaultDeviceInterval
+	defaultDeviceInterval = time.Millisecond
+	defer func() {
+		defaultDeviceInterval = oldInterval
+	}()
+
+	var form url.Values
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal(testDiscovery.BackchannelAuthURL, r.URL.String())
+		s.NoError(r.ParseForm())
+		form = r.PostForm
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{
+			"auth_req_id": "req1",
+			"expires_in": 120
+		}`))(r)
+	})
+
+	cfg := Config{ClientID: "client1", ClientSecret: "secret1", Scopes: []string{ScopeEmail}}
+	b, err := s.client.BackchannelAuth(s.testCtx, cfg, WithLoginHint("user@example.com"), WithBindingMessage("W4SCT"))
+	s.Require().NoError(err)
+	s.Equal("openid email", form.Get("scope"))
+	s.Equal("user@example.com", form.Get("login_hint"))
+	s.Equal("W4SCT", form.Get("binding_message"))
+	s.Equal("req1", b.AuthReqID)
+	s.False(b.Expiry.IsZero())
+
+	idToken, _ := s.validIDToken()
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken: "access1",
+		IDToken:     idToken,
+		TokenType:   "Bearer",
+	})
+	s.NoError(err)
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "authorization_pending"}`)))
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.NoError(r.ParseForm())
+		s.Equal(GrantTypeCIBA, r.PostForm.Get("grant_type"))
+		s.Equal("req1", r.PostForm.Get("auth_req_id"))
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	token, err := s.client.BackchannelToken(s.testCtx, cfg, b)
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+	s.Equal("access1", token.AccessToken)
+	s.Equal(idToken, token.IDToken)
+}
+
+func (s *ClientTestSuite) TestBackchannel_NotSupported() {
+	client := s.client.Provider().Client()
+	client.discovery.BackchannelAuthURL = ""
+
+	_, err := client.BackchannelAuth(s.testCtx, Config{ClientID: "client1"}, WithLoginHint("user@example.com"))
+	s.Require().Error(err)
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestBackchannel_Ping() {
+	h := NewBackchannelNotificationHandler("notify1")
+	b := &BackchannelAuthResponse{AuthReqID: "req1", ExpiresIn: 3600, Expiry: time.Now().Add(time.Hour)}
+
+	idToken, _ := s.validIDToken()
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken: "access1",
+		IDToken:     idToken,
+		TokenType:   "Bearer",
+	})
+	s.NoError(err)
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.NoError(r.ParseForm())
+		s.Equal("req1", r.PostForm.Get("auth_req_id"))
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	type result struct {
+		token *Token
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		token, err := s.client.BackchannelTokenOnPing(s.testCtx, Config{ClientID: "client1"}, b, h)
+		done <- result{token: token, err: err}
+	}()
+
+	// Notification with wrong bearer token is rejected.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/cb", strings.NewReader(`{"auth_req_id": "req1"}`))
+	req.Header.Set("Authorization", "Bearer wrong")
+	h.ServeHTTP(rec, req)
+	s.Equal(http.StatusUnauthorized, rec.Code)
+
+	// Keep notifying until waiter subscribes and picks it up.
+	for {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/cb", strings.NewReader(`{"auth_req_id": "req1"}`))
+		req.Header.Set("Authorization", "Bearer notify1")
+		h.ServeHTTP(rec, req)
+		s.Equal(http.StatusNoContent, rec.Code)
+
+		select {
+		case res := <-done:
+			s.Require().NoError(res.err)
+			s.Equal("access1", res.token.AccessToken)
+			s.Equal(0, s.s.Len())
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,52 @@
+//go:build go1.18
+// +build go1.18
+
+package oidc
+
+import (
+	"context"
+)
+
+// ClaimsAs verifies ID token of given token and unmarshals its claims into new value of type T.
+//
+//	type myClaims struct {
+//		Email  string   `json:"email"`
+//		Groups []string `json:"groups"`
+//	}
+//	claims, err := oidc.ClaimsAs[myClaims](ctx, verifier, token)
+func ClaimsAs[T any](ctx context.Context, verifier Verifier, t Token) (T, error) {
+	var claims T
+	if err := t.Claims(ctx, verifier, &claims); err != nil {
+		return claims, err
+	}
+	return claims, nil
+}
+
+// UnverifiedClaimsAs unmarshals claims of given token's ID token into new value of type T WITHOUT verifying it.
+// See Token.UnverifiedClaims.
+func UnverifiedClaimsAs[T any](t Token) (T, error) {
+	var claims T
+	if err := t.UnverifiedClaims(&claims); err != nil {
+		return claims, err
+	}
+	return claims, nil
+}
+
+// IDTokenClaimsAs unmarshals claims of already verified ID token into new value of type T.
+func IDTokenClaimsAs[T any](idToken *IDToken) (T, error) {
+	var claims T
+	if err := idToken.Claims(&claims); err != nil {
+		return claims, err
+	}
+	return claims, nil
+}
+
+// AccessTokenClaimsAs unmarshals claims of already verified JWT access token (see Verifier.VerifyAccessToken) into new
+// value of type T.
+func AccessTokenClaimsAs[T any](accessToken *AccessToken) (T, error) {
+	var claims T
+	if err := accessToken.Claims(&claims); err != nil {
+		return claims, err
+	}
+	return claims, nil
+}
@@ -0,0 +1,47 @@
+//go:build go1.18
+// +build go1.18
+
+package oidc
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+type testClaims struct {
+	Subject string `json:"sub"`
+	Nonce   string `json:"nonce"`
+}
+
+func (s *ClientTestSuite) TestClaimsAs() {
+	idToken, jwkSetJSON := s.validIDToken()
+	token := Token{
+		AccessToken:       "access1",
+		IDToken:           idToken,
+		AccessTokenExpiry: time.Now().Add(1 * time.Hour),
+	}
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	claims, err := ClaimsAs[testClaims](s.testCtx, s.client.Verifier(VerificationConfig{ClientID: "client1"}), token)
+	s.NoError(err)
+	s.Equal(testClaims{Subject: "subject1", Nonce: "nonce1"}, claims)
+	s.Equal(0, s.s.Len())
+
+	claims, err = UnverifiedClaimsAs[testClaims](token)
+	s.NoError(err)
+	s.Equal(testClaims{Subject: "subject1", Nonce: "nonce1"}, claims)
+
+	_, err = UnverifiedClaimsAs[testClaims](Token{})
+	s.Error(err)
+}
+
+func (s *ClientTestSuite) TestAccessTokenClaimsAs() {
+	claims, err := AccessTokenClaimsAs[testClaims](&AccessToken{claims: []byte(`{"sub": "subject1", "scope": "openid"}`)})
+	s.NoError(err)
+	s.Equal(testClaims{Subject: "subject1"}, claims)
+
+	_, err = AccessTokenClaimsAs[testClaims](&AccessToken{})
+	s.Error(err)
+}
@@ -1,7 +1,6 @@
 package oidc
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -9,10 +8,8 @@ import (
 	"io"
 	"io/ioutil"
 	"mime"
-	"net"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 )
 
@@ -27,6 +24,10 @@ const (
 	GrantTypeRefreshToken = "refresh_token"
 	// GrantTypeServiceAccount is a custom ServiceAccount to support exchanging SA for ID token.
 	GrantTypeServiceAccount = "service_account"
+	// GrantTypeDeviceCode is the device authorization grant. See https://tools.ietf.org/html/rfc8628.
+	GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+	// GrantTypeTokenExchange is the token exchange grant. See https://tools.ietf.org/html/rfc8693.
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
 
 	ResponseTypeCode    = "code"     // Authorization Code flow
 	ResponseTypeToken   = "token"    // Implicit flow for frontend apps.
@@ -37,34 +38,41 @@ const (
 
 // HTTPClientCtxKey is Context key which is used to fetch custom HTTP.Client.
 // Used to pass special HTTP client (e.g with non-default timeout) or for tests.
+//
+// Deprecated: Context value is easy to lose on the way (it is silently ignored when missing) and is invisible in
+// signatures. Use WithHTTPClient option instead. HTTPClientCtxKey is still honored for clients that have no
+// HTTP client set by option, but it will be removed in next release.
 var HTTPClientCtxKey struct{}
 
-// doRequest performs HTTP request using our default client or client given by context like this:
-//
-//     context.WithValue(ctx, oidc.HTTPClientCtxKey, client)
-//
-func doRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
-	// Create new transport before using it with exactly the same params as default one. Don't use it directly, because
-	// we don't want to depend on the default one.
-	newTransport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
-	client := &http.Client{
-		Transport: newTransport,
-	}
-	if c, ok := ctx.Value(HTTPClientCtxKey).(*http.Client); ok {
-		client = c
-	}
-	return client.Do(req.WithContext(ctx))
+// defaultHTTPClient is used when no HTTP client was specified. It has the same transport params as http.DefaultClient,
+// but we create our own, because we don't want to depend on the default one.
+var defaultHTTPClient = NewHTTPClient(HTTPClientConfig{})
+
+// WithHTTPClient sets HTTP client used for all requests to the provider (discovery, keys, token, user info etc).
+// Use it to pass special HTTP client (e.g with non-default timeout, TLS config or proxy, see NewHTTPClient) or for
+// tests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// doRequest performs HTTP request to given endpoint using given client and reports it to the hook. If client is nil, it
+// uses client given by the deprecated context value or our default client.
+func doRequest(ctx context.Context, client *http.Client, hook RequestHook, endpoint RequestEndpoint, req *http.Request) (*http.Response, error) {
+	if client == nil {
+		client = defaultHTTPClient
+		if c, ok := ctx.Value(HTTPClientCtxKey).(*http.Client); ok {
+			client = c
+		}
+	}
+	start := time.Now()
+	resp, err := client.Do(req.WithContext(ctx))
+	reportRequest(hook, endpoint, req, start, resp, err)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+	return resp, nil
 }
 
 // Config is client configuration that contains all required client details to communicate with OIDC server.
@@ -73,19 +81,35 @@ type Config struct {
 	ClientSecret string
 	RedirectURL  string
 	Scopes       []string
+	// Resources are URIs of target services (RFC 8707) added as resource parameters to the authorization URL and
+	// all token requests, e.g refresh and token exchange, so issued tokens are scoped to these APIs.
+	Resources []string
+
+	// ClientAuth authenticates the client to the provider, e.g PrivateKeyJWT. ClientSecretBasic is used if nil.
+	ClientAuth ClientAuth
+	// RefreshRetry configures retries of transient refresh token request failures with exponential backoff. Refresh
+	// is not retried if nil.
+	RefreshRetry *RetryPolicy
 }
 
 // Client represents an OpenID Connect client.
 type Client struct {
-	issuer string
+	provider *Provider
+	issuer   string
 
-	// Raw claims returned by the server on discovery endpoint.
-	rawDiscoveryClaims []byte
-	discovery          DiscoveryJSON
+	// discoveryDoc is the provider's discovery document at the time the client was created. It is used until the
+	// provider refreshes the document, see document.
+	discoveryDoc
+	generation uint64
 
 	keySet keySet
+	// verifications caches results of successful ID token verifications for all verifiers created by this client.
+	verifications *lruCache
+	// retryBudget is shared with all other clients for the same issuer.
+	retryBudget *RetryBudget
 
-	cfg Config
+	opts options
+	cfg  Config
 }
 
 // DiscoveryJSON is structure expected by Discovery endpoint.
@@ -96,65 +120,89 @@ type DiscoveryJSON struct {
 	JWKSURL       string `json:"jwks_uri"`
 	UserInfoURL   string `json:"userinfo_endpoint"`
 	RevocationURL string `json:"revocation_endpoint"`
+	DeviceAuthURL string `json:"device_authorization_endpoint,omitempty"`
+	// IntrospectionURL is the token introspection endpoint (RFC 7662) if supported.
+	IntrospectionURL string `json:"introspection_endpoint,omitempty"`
+	// EndSessionURL is the RP-initiated logout endpoint if supported. See
+	// https://openid.net/specs/openid-connect-rpinitiated-1_0.html.
+	EndSessionURL string `json:"end_session_endpoint,omitempty"`
+	// PARURL is the pushed authorization request endpoint (RFC 9126) if supported.
+	PARURL string `json:"pushed_authorization_request_endpoint,omitempty"`
+	// RequirePAR is true if provider accepts only pushed authorization requests. See Client.PushedAuthCodeURL.
+	RequirePAR bool `json:"require_pushed_authorization_requests,omitempty"`
+	// BackchannelAuthURL is the Client-Initiated Backchannel Authentication endpoint if supported.
+	BackchannelAuthURL string `json:"backchannel_authentication_endpoint,omitempty"`
+	// RegistrationURL is the dynamic client registration endpoint (RFC 7591) if supported.
+	RegistrationURL string `json:"registration_endpoint,omitempty"`
+	// MTLSEndpointAliases are used instead of regular endpoints with WithTLSClientAuth if provider advertises them.
+	MTLSEndpointAliases *MTLSEndpointAliases `json:"mtls_endpoint_aliases,omitempty"`
 }
 
 // NewClient uses the OpenID Connect discovery mechanism to construct a Client.
-func NewClient(ctx context.Context, issuer string) (*Client, error) {
-	wellKnown := strings.TrimSuffix(issuer, "/") + DiscoveryEndpoint
-	req, err := http.NewRequest("GET", wellKnown, nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := doRequest(ctx, req)
+// To create many clients for the same issuer, use NewProvider and Provider.Client instead.
+func NewClient(ctx context.Context, issuer string, opts ...Option) (*Client, error) {
+	p, err := NewProvider(ctx, issuer, opts...)
 	if err != nil {
 		return nil, err
 	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: %s", resp.Status, body)
-	}
-	defer resp.Body.Close()
-	var p DiscoveryJSON
-	if err := json.Unmarshal(body, &p); err != nil {
-		return nil, fmt.Errorf("oidc: failed to decode provider discovery object: %v", err)
-	}
-	if p.Issuer != issuer {
-		return nil, fmt.Errorf("oidc: issuer did not match the issuer returned by provider, expected %q got %q", issuer, p.Issuer)
-	}
-	return &Client{
-		issuer:             p.Issuer,
-		discovery:          p,
-		rawDiscoveryClaims: body,
-		keySet:             newCachedKeySet(newRemoteKeySet(p.JWKSURL), DefaultKeySetExpiration, time.Now),
-	}, nil
+	return p.Client(), nil
+}
+
+// Provider returns provider the client was created from.
+func (c *Client) Provider() *Provider {
+	return c.provider
+}
+
+// RetryBudget returns retry budget shared by all clients and token sources of the client's issuer.
+// Every call to token endpoint is accounted. Anything that retries requests towards the provider should consult
+// RetryBudget.AllowRetry first.
+func (c *Client) RetryBudget() *RetryBudget {
+	return c.retryBudget
 }
 
 // Discovery returns standard discovery fields held by OIDC provider we point to.
 func (c *Client) Discovery() DiscoveryJSON {
-	return c.discovery
+	return c.document().discovery
+}
+
+// document returns discovery document of the client. It is the one client was created with, unless provider
+// refreshed it since (see WithDiscoveryRefreshInterval).
+func (c *Client) document() *discoveryDoc {
+	if c.provider != nil {
+		if doc, generation := c.provider.document(); generation != c.generation {
+			return doc
+		}
+	}
+	return &c.discoveryDoc
+}
+
+// Metadata returns complete provider metadata (endpoints, supported scopes, claims, algorithms, PKCE methods etc.)
+// held by OIDC provider we point to, e.g to check whether the provider supports a feature before using it:
+//
+//	if client.Metadata().SupportsPKCE() {
+//	    opts = append(opts, oidc.WithPKCE(verifier))
+//	}
+//
+// Returned slices are shared with the client and must not be modified.
+func (c *Client) Metadata() ProviderMetadata {
+	return c.document().metadata
 }
 
 // Claims unmarshals raw fields returned by the server during discovery.
 //
-//    var claims struct {
-//        ScopesSupported []string `json:"scopes_supported"`
-//        ClaimsSupported []string `json:"claims_supported"`
-//    }
+//	var claims struct {
+//	    ScopesSupported []string `json:"scopes_supported"`
+//	    ClaimsSupported []string `json:"claims_supported"`
+//	}
 //
-//    if err := client.Claims(&claims); err != nil {
-//        // handle unmarshaling error
-//    }
+//	if err := client.Claims(&claims); err != nil {
+//	    // handle unmarshaling error
+//	}
 //
 // For a list of fields defined by the OpenID Connect spec see:
 // https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
 func (c *Client) Claims(v interface{}) error {
-	if c.rawDiscoveryClaims == nil {
-		return errors.New("oidc: claims not set")
-	}
-	return json.Unmarshal(c.rawDiscoveryClaims, v)
+	return c.document().claims(v)
 }
 
 // UserInfo represents the OpenID Connect userinfo claims.
@@ -175,76 +223,132 @@ func (u *UserInfo) Claims(v interface{}) error {
 	return json.Unmarshal(u.claims, v)
 }
 
-// UserInfo uses the token source to query the provider's user info endpoint.
+// UserInfo uses the token source to query the provider's user info endpoint. If token has ID token, "sub" claim of
+// the response is validated against it and ErrUserInfoSubjectMismatch is returned on mismatch. ID token itself is
+// expected to be verified by the token source.
 func (c *Client) UserInfo(ctx context.Context, tokenSource TokenSource) (*UserInfo, error) {
-	if c.discovery.UserInfoURL == "" {
+	ctx, span := c.StartSpan(ctx, "oidc.UserInfo")
+	userInfo, err := c.userInfo(ctx, tokenSource)
+	endSpan(span, err)
+	return userInfo, err
+}
+
+func (c *Client) userInfo(ctx context.Context, tokenSource TokenSource) (*UserInfo, error) {
+	discovery := c.Discovery()
+	if discovery.UserInfoURL == "" {
 		return nil, errors.New("oidc: user info endpoint is not supported by this provider")
 	}
 
-	req, err := http.NewRequest("GET", c.discovery.UserInfoURL, nil)
+	req, err := http.NewRequest("GET", discovery.UserInfoURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("oidc: create GET request: %v", err)
 	}
 
-	token, err := tokenSource.OIDCToken()
+	token, err := tokenCtx(ctx, tokenSource)
 	if err != nil {
-		return nil, fmt.Errorf("oidc: get access token: %v", err)
+		return nil, wrapErrorf(err, "oidc: get access token: %v", err)
+	}
+	if err := token.SetAuthHeader(req); err != nil {
+		return nil, err
 	}
-	token.SetAuthHeader(req)
 
-	resp, err := doRequest(ctx, req)
+	resp, err := doRequest(ctx, c.opts.httpClient, c.opts.requestHook, RequestUserInfo, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, &NetworkError{Err: err}
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: %s", resp.Status, body)
+		return nil, newHTTPError(resp, body)
 	}
 
 	var userInfo UserInfo
 	if err := json.Unmarshal(body, &userInfo); err != nil {
 		return nil, fmt.Errorf("oidc: failed to decode userinfo: %v", err)
 	}
+	if userInfo.Subject == "" {
+		return nil, errors.New("oidc: user info does not include required \"sub\" claim")
+	}
+	if sub := unverifiedSubject(token.IDToken); sub != "" && sub != userInfo.Subject {
+		return nil, ErrUserInfoSubjectMismatch
+	}
 	userInfo.claims = body
 	return &userInfo, nil
 }
 
+// UserInfoClaims queries the provider's user info endpoint like UserInfo and unmarshals all returned claims into v,
+// e.g struct with profile claims:
+//
+//    var profile struct {
+//        Name    string `json:"name"`
+//        Picture string `json:"picture"`
+//    }
+//    err := client.UserInfoClaims(ctx, tokenSource, &profile)
+func (c *Client) UserInfoClaims(ctx context.Context, tokenSource TokenSource, v interface{}) error {
+	userInfo, err := c.UserInfo(ctx, tokenSource)
+	if err != nil {
+		return err
+	}
+	return userInfo.Claims(v)
+}
+
 // Verifier returns an IDTokenVerifier that uses the provider's key set to verify JWTs.
 //
 // The returned IDTokenVerifier is tied to the Client's context and its behavior is
 // undefined once the Client's context is canceled.
 func (c *Client) Verifier(cfg VerificationConfig) *IDTokenVerifier {
-	return newVerifier(c.keySet, cfg, c.issuer)
+	if cfg.Now == nil {
+		cfg.Now = c.opts.clock
+	}
+	if len(cfg.SupportedSigningAlgs) == 0 {
+		cfg.SupportedSigningAlgs = asymmetricSigningAlgs(c.document().idTokenSigningAlgs)
+	}
+	v := newVerifier(c.keySet, cfg, c.issuer, c.verifications)
+	v.auditHook = c.opts.auditHook
+	v.tracer = c.opts.tracer
+	return v
 }
 
-// Revoke revokes provided token. It can be access token or refresh token. In most, revoking access token will
-// revoke refresh token which can be convenient. (IsValid e.g for Google OIDC).
-func (c *Client) Revoke(ctx context.Context, cfg Config, token string) error {
+// Revoke revokes provided token (see https://tools.ietf.org/html/rfc7009). It can be access token or refresh token.
+// In most, revoking access token will revoke refresh token which can be convenient. (IsValid e.g for Google OIDC).
+// Optional tokenTypeHint (TokenTypeHintAccessToken or TokenTypeHintRefreshToken) helps provider to find the token.
+func (c *Client) Revoke(ctx context.Context, cfg Config, token string, tokenTypeHint ...string) error {
+	discovery := c.Discovery()
+	if discovery.RevocationURL == "" {
+		return errors.New("oidc: revocation endpoint is not supported by this provider")
+	}
+
 	v := url.Values{}
 	v.Set("token", token)
+	if len(tokenTypeHint) > 0 && tokenTypeHint[0] != "" {
+		v.Set("token_type_hint", tokenTypeHint[0])
+	}
 
-	req, err := http.NewRequest("POST", c.discovery.RevocationURL, strings.NewReader(v.Encode()))
+	req, err := c.newClientAuthRequest(discovery.RevocationURL, mtlsRevocationURL, cfg, v)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.SetBasicAuth(cfg.ClientID, cfg.ClientSecret)
 
-	r, err := doRequest(ctx, req)
+	err = c.revoke(ctx, req)
+	c.audit(AuditRevoke, cfg.ClientID, "", err)
+	return err
+}
+
+func (c *Client) revoke(ctx context.Context, req *http.Request) error {
+	r, err := c.doClientAuthRequest(ctx, RequestRevocation, req)
 	if err != nil {
 		return err
 	}
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
 	if err != nil {
-		return fmt.Errorf("oidc: cannot revoke token: %v", err)
+		return wrapErrorf(&NetworkError{Err: err}, "oidc: cannot revoke token: %v", err)
 	}
 	if code := r.StatusCode; code < 200 || code > 299 {
-		return fmt.Errorf("oidc: cannot revoke token: %v\nResponse: %s", r.Status, body)
+		return newOAuth2Error("oidc: cannot revoke token", r, body)
 	}
 	return nil
 }
@@ -255,36 +359,17 @@ func (c *Client) Revoke(ctx context.Context, cfg Config, token string) error {
 // always provide a non-zero string and validate that it matches the
 // the state query parameter on your redirect callback.
 // See http://openid.net/specs/openid-connect-core-1_0.html#AuthRequest for more info.
+//
+// See AuthCodeURLWithOptions for more parameters.
 func (c *Client) AuthCodeURL(cfg Config, state string, extra ...url.Values) string {
-	var buf bytes.Buffer
-	buf.WriteString(c.discovery.AuthURL)
-	v := url.Values{
-		"response_type": {ResponseTypeCode},
-		"client_id":     {cfg.ClientID},
-		"redirect_uri":  {cfg.RedirectURL},
-	}
-
+	var opts []AuthCodeOption
 	if state != "" {
-		v.Set("state", state)
+		opts = append(opts, WithState(state))
 	}
-
-	if len(cfg.Scopes) > 0 {
-		v.Set("scope", strings.Join(cfg.Scopes, " "))
-	}
-
 	for _, e := range extra {
-		for key := range e {
-			v.Set(key, e.Get(key))
-		}
+		opts = append(opts, WithAuthParams(e))
 	}
-
-	if strings.Contains(c.discovery.AuthURL, "?") {
-		buf.WriteByte('&')
-	} else {
-		buf.WriteByte('?')
-	}
-	buf.WriteString(v.Encode())
-	return buf.String()
+	return c.AuthCodeURLWithOptions(cfg, opts...)
 }
 
 // Exchange converts an authorization code into a token.
@@ -304,7 +389,7 @@ func (c *Client) Exchange(ctx context.Context, cfg Config, code string, extra ..
 		}
 	}
 
-	return c.token(ctx, cfg.ClientID, cfg.ClientSecret, v)
+	return c.loginToken(ctx, cfg, v)
 }
 
 // Exchange converts an google service account JSON into a token.
@@ -322,12 +407,12 @@ func (c *Client) ExchangeServiceAccount(ctx context.Context, cfg Config, googleS
 		}
 	}
 
-	return c.token(ctx, cfg.ClientID, cfg.ClientSecret, v)
+	return c.loginToken(ctx, cfg, v)
 }
 
 // TokenSource returns a TokenSource that returns t until t expires,
 // automatically refreshing it as necessary using the provided context.
-func (c *Client) TokenSource(ctx context.Context, cfg Config, t *Token) TokenSource {
+func (c *Client) TokenSource(ctx context.Context, cfg Config, t *Token, opts ...ReuseTokenSourceOption) TokenSource {
 	tkr := &TokenRefresher{
 		ctx: ctx,
 
@@ -337,31 +422,61 @@ func (c *Client) TokenSource(ctx context.Context, cfg Config, t *Token) TokenSou
 	if t != nil {
 		tkr.refreshToken = t.RefreshToken
 	}
-	src, _ := NewReuseTokenSource(ctx, t, tkr)
+	src, _ := NewReuseTokenSource(ctx, t, tkr, append([]ReuseTokenSourceOption{WithTokenSourceLogger(c.opts.log())}, opts...)...)
 	return src
 }
 
-// token fetches token from OIDC token endpoint with provided URL values.
-func (c *Client) token(ctx context.Context, clientID string, clientSecret string, v url.Values) (*Token, error) {
-	req, err := http.NewRequest("POST", c.discovery.TokenURL, strings.NewReader(v.Encode()))
-	if err != nil {
-		return nil, err
+// loginToken fetches token for authorization grant and reports it as login to AuditHook.
+func (c *Client) loginToken(ctx context.Context, cfg Config, v url.Values) (*Token, error) {
+	t, err := c.token(ctx, cfg, v)
+	c.audit(AuditLogin, cfg.ClientID, tokenSubject(t), err)
+	return t, err
+}
+
+// now returns current time of the client's clock.
+func (c *Client) now() time.Time {
+	if c.opts.clock != nil {
+		return c.opts.clock()
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.SetBasicAuth(clientID, clientSecret)
+	return time.Now()
+}
 
-	r, err := doRequest(ctx, req)
-	if err != nil {
-		return nil, err
+func tokenSubject(t *Token) string {
+	if t == nil {
+		return ""
 	}
-	defer r.Body.Close()
-	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	return unverifiedSubject(t.IDToken)
+}
+
+// token fetches token from OIDC token endpoint with provided URL values.
+func (c *Client) token(ctx context.Context, cfg Config, v url.Values) (t *Token, err error) {
+	ctx, span := c.StartSpan(ctx, "oidc.Token",
+		SpanAttribute{Key: SpanAttrClientID, Value: cfg.ClientID},
+		SpanAttribute{Key: SpanAttrGrantType, Value: v.Get("grant_type")},
+	)
+	defer func() {
+		endSpan(span, err)
+		if err != nil {
+			c.opts.log().Warn("oidc: Token request failed.", "issuer", c.issuer, "grant_type", v.Get("grant_type"), "err", err)
+			return
+		}
+		c.opts.log().Debug("oidc: Obtained token.", "issuer", c.issuer, "grant_type", v.Get("grant_type"), "token", t)
+	}()
+
+	setResources(v, cfg.Resources)
+	r, body, err := c.postToken(ctx, cfg, v)
 	if err != nil {
-		return nil, fmt.Errorf("oauth2: cannot fetch token: %v", err)
+		c.retryBudget.OnFailure()
+		return nil, err
 	}
 	if code := r.StatusCode; code < 200 || code > 299 {
-		return nil, fmt.Errorf("oauth2: cannot fetch token: %v\nResponse: %s", r.Status, body)
+		oerr := newOAuth2Error("oauth2: cannot fetch token", r, body)
+		if IsRetryable(oerr) {
+			c.retryBudget.OnFailure()
+		}
+		return nil, oerr
 	}
+	c.retryBudget.OnSuccess()
 
 	var token *Token
 	content, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
@@ -369,7 +484,7 @@ func (c *Client) token(ctx context.Context, clientID string, clientSecret string
 		return nil, fmt.Errorf("Wrong response content-type. Expected application/json, got %s", content)
 	}
 
-	var tr TokenResponse
+	tr := TokenResponse{timeNow: c.now}
 	if err = json.Unmarshal(body, &tr); err != nil {
 		return nil, err
 	}
@@ -379,15 +494,29 @@ func (c *Client) token(ctx context.Context, clientID string, clientSecret string
 		return nil, err
 	}
 
+	var extra map[string]json.RawMessage
+	if err = json.Unmarshal(body, &extra); err != nil {
+		return nil, err
+	}
+	for _, key := range tokenResponseFields {
+		delete(extra, key)
+	}
+	if len(extra) == 0 {
+		extra = nil
+	}
+
 	token = &Token{
 		AccessToken:  tr.AccessToken,
+		TokenType:    tr.TokenType,
 		RefreshToken: tr.RefreshToken,
 		IDToken:      tr.IDToken,
+		Scope:        tr.Scope,
+		extra:        extra,
 	}
 
 	token.AccessTokenExpiry = tr.expiry()
 	if token.AccessTokenExpiry.IsZero() {
-		token.AccessTokenExpiry = br.expiry()
+		token.AccessTokenExpiry = br.expiry(c.now())
 	}
 
 	if token.RefreshToken == "" {
@@ -396,6 +525,41 @@ func (c *Client) token(ctx context.Context, clientID string, clientSecret string
 	return token, nil
 }
 
+// postToken posts token request and reads the response. With DPoP, request rejected for missing nonce is retried
+// once with nonce provided by the provider.
+func (c *Client) postToken(ctx context.Context, cfg Config, v url.Values) (*http.Response, []byte, error) {
+	tokenURL := c.Discovery().TokenURL
+	for retried := false; ; retried = true {
+		req, err := c.newClientAuthRequest(tokenURL, mtlsTokenURL, cfg, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if c.opts.dpop != nil {
+			if err := c.opts.dpop.setProof(req, ""); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		r, err := c.doClientAuthRequest(ctx, RequestToken, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+		r.Body.Close()
+		if err != nil {
+			return nil, nil, wrapErrorf(&NetworkError{Err: err}, "oauth2: cannot fetch token: %v", err)
+		}
+
+		if c.opts.dpop == nil {
+			return r, body, nil
+		}
+		newNonce := c.opts.dpop.recordNonce(req.URL, r)
+		if retried || !newNonce || !isDPoPNonceError(r, body) {
+			return r, body, nil
+		}
+	}
+}
+
 // TokenResponse is the struct representing the HTTP response from OIDC
 // providers returning a token in JSON form.
 type TokenResponse struct {
@@ -429,14 +593,17 @@ func (r *TokenResponse) expiry() time.Time {
 	return time.Time{}
 }
 
+// tokenResponseFields are token response fields parsed into Token fields.
+var tokenResponseFields = []string{"access_token", "token_type", "id_token", "expires_in", "refresh_token", "scope", "expires"}
+
 // brokenTokenResponse represents response that is not compliant with OIDC.
 type brokenTokenResponse struct {
 	Expires expirationTime `json:"expires"` // broken Facebook spelling of expires_in
 }
 
-func (r *brokenTokenResponse) expiry() time.Time {
+func (r *brokenTokenResponse) expiry(now time.Time) time.Time {
 	if v := r.Expires; v != 0 {
-		return time.Now().Add(time.Duration(v) * time.Second)
+		return now.Add(time.Duration(v) * time.Second)
 	}
 	return time.Time{}
 }
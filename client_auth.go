@@ -0,0 +1,215 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// ClientAssertionTypeJWTBearer is the client assertion type of PrivateKeyJWT and ClientSecretJWT. See
+	// https://tools.ietf.org/html/rfc7523#section-2.2.
+	ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// Client authentication methods as in token_endpoint_auth_methods_supported discovery field.
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodClientSecretJWT   = "client_secret_jwt"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+	AuthMethodTLSClientAuth     = "tls_client_auth"
+	AuthMethodNone              = "none"
+)
+
+// ClientAuth authenticates the client to the provider's endpoints that require client authentication: token,
+// revocation, introspection and pushed authorization request endpoints. Set it in Config.ClientAuth.
+// If not set, the method is selected from methods supported by the provider: ClientSecretBasic, ClientSecretPost or
+// ClientSecretJWT, in this order of preference. PublicClient is used if Config.ClientSecret is empty.
+// See https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication.
+type ClientAuth interface {
+	// Authenticate adds credentials of the client to the form or header of the request to given endpoint.
+	Authenticate(cfg Config, endpoint string, form url.Values, header http.Header) error
+}
+
+// ClientSecretBasic authenticates the client with client secret using HTTP basic auth (client_secret_basic). It is
+// the default.
+type ClientSecretBasic struct{}
+
+// Authenticate sets basic auth header.
+func (ClientSecretBasic) Authenticate(cfg Config, _ string, _ url.Values, header http.Header) error {
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cfg.ClientID+":"+cfg.ClientSecret)))
+	return nil
+}
+
+// ClientSecretPost authenticates the client with client secret sent in the request body (client_secret_post).
+type ClientSecretPost struct{}
+
+// Authenticate adds client ID and secret to the form.
+func (ClientSecretPost) Authenticate(cfg Config, _ string, form url.Values, _ http.Header) error {
+	form.Set("client_id", cfg.ClientID)
+	form.Set("client_secret", cfg.ClientSecret)
+	return nil
+}
+
+// PublicClient is used by public clients (e.g CLI or native apps) that have no client secret ("none" method). Only
+// client ID is sent in the form, as required by https://tools.ietf.org/html/rfc6749#section-3.2.1.
+type PublicClient struct{}
+
+// Authenticate adds client ID to the form.
+func (PublicClient) Authenticate(cfg Config, _ string, form url.Values, _ http.Header) error {
+	form.Set("client_id", cfg.ClientID)
+	return nil
+}
+
+// PrivateKeyJWT authenticates the client with JWT assertion signed by the client's private key (private_key_jwt),
+// so no client secret is needed. Public key needs to be registered for the client on the provider, e.g as a
+// certificate in Azure AD. Config.ClientSecret is ignored.
+type PrivateKeyJWT struct {
+	// Key signs the assertion. It needs to be *rsa.PrivateKey (signed with RS256) or *ecdsa.PrivateKey (signed with
+	// ES256, ES384 or ES512 according to its curve).
+	Key crypto.PrivateKey
+	// KeyID if not empty, is set as "kid" header of the assertion.
+	KeyID string
+	// Certificate if not nil, is the registered certificate of Key. Its SHA-1 thumbprint is set as "x5t" header of
+	// the assertion, as required e.g by Azure AD. It is not allowed in FIPS mode.
+	Certificate *x509.Certificate
+	// Audience is the "aud" claim. It is the URL of the endpoint the request is sent to by default.
+	Audience string
+	// Lifetime of the assertion. DefaultAssertionLifetime is used if zero.
+	Lifetime time.Duration
+}
+
+// Authenticate adds signed client assertion to the form.
+func (p PrivateKeyJWT) Authenticate(cfg Config, endpoint string, form url.Values, _ http.Header) error {
+	var headers map[jose.HeaderKey]interface{}
+	if p.Certificate != nil {
+		if FIPSMode() {
+			return &FIPSError{Algorithm: "SHA-1", Reason: "x5t certificate thumbprint uses SHA-1, use KeyID instead of Certificate"}
+		}
+		sum := sha1.Sum(p.Certificate.Raw)
+		headers = map[jose.HeaderKey]interface{}{"x5t": base64.RawURLEncoding.EncodeToString(sum[:])}
+	}
+	assertion, err := signAssertion(JWTAssertionConfig{
+		Issuer:   cfg.ClientID,
+		Audience: p.Audience,
+		Key:      p.Key,
+		KeyID:    p.KeyID,
+		Lifetime: p.Lifetime,
+	}, endpoint, headers)
+	if err != nil {
+		return err
+	}
+	setClientAssertion(form, cfg.ClientID, assertion)
+	return nil
+}
+
+// ClientSecretJWT authenticates the client with JWT assertion signed by HMAC SHA-256 using client secret as the key
+// (client_secret_jwt), so the secret itself is never sent.
+type ClientSecretJWT struct {
+	// Audience is the "aud" claim. It is the URL of the endpoint the request is sent to by default.
+	Audience string
+	// Lifetime of the assertion. DefaultAssertionLifetime is used if zero.
+	Lifetime time.Duration
+}
+
+// Authenticate adds signed client assertion to the form.
+func (s ClientSecretJWT) Authenticate(cfg Config, endpoint string, form url.Values, _ http.Header) error {
+	if cfg.ClientSecret == "" {
+		return errors.New("oidc: client secret is required for client_secret_jwt")
+	}
+	assertion, err := signAssertion(JWTAssertionConfig{
+		Issuer:   cfg.ClientID,
+		Audience: s.Audience,
+		Key:      []byte(cfg.ClientSecret),
+		Lifetime: s.Lifetime,
+	}, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	setClientAssertion(form, cfg.ClientID, assertion)
+	return nil
+}
+
+func setClientAssertion(form url.Values, clientID string, assertion string) {
+	form.Set("client_id", clientID)
+	form.Set("client_assertion_type", ClientAssertionTypeJWTBearer)
+	form.Set("client_assertion", assertion)
+}
+
+// tlsClientAuth authenticates the client with TLS client certificate presented by the HTTP client. Only client ID
+// is sent in the form.
+type tlsClientAuth struct{}
+
+// Authenticate adds client ID to the form.
+func (tlsClientAuth) Authenticate(cfg Config, _ string, form url.Values, _ http.Header) error {
+	form.Set("client_id", cfg.ClientID)
+	return nil
+}
+
+// clientAuth returns client authentication method for given config.
+func (c *Client) clientAuth(cfg Config) ClientAuth {
+	methods := c.document().tokenAuthMethods
+	switch {
+	case cfg.ClientAuth != nil:
+		return cfg.ClientAuth
+	case c.opts.mtlsHTTPClient != nil:
+		return tlsClientAuth{}
+	case cfg.ClientSecret == "":
+		// No secret, so none of the secret based methods can work.
+		return PublicClient{}
+	case len(methods) == 0 || contains(methods, AuthMethodClientSecretBasic):
+		// Basic is the default if provider does not specify supported methods.
+		return ClientSecretBasic{}
+	case contains(methods, AuthMethodClientSecretPost):
+		return ClientSecretPost{}
+	case contains(methods, AuthMethodClientSecretJWT):
+		return ClientSecretJWT{}
+	}
+	return ClientSecretBasic{}
+}
+
+// newClientAuthRequest creates POST request to the provider's endpoint, authenticated as the client with
+// Config.ClientAuth. With WithTLSClientAuth, mTLS alias of the endpoint is used if any. Perform it with
+// doClientAuthRequest.
+func (c *Client) newClientAuthRequest(endpoint string, mtlsAlias func(*MTLSEndpointAliases) string, cfg Config, v url.Values) (*http.Request, error) {
+	if c.opts.mtlsHTTPClient != nil {
+		if aliases := c.Discovery().MTLSEndpointAliases; aliases != nil && mtlsAlias(aliases) != "" {
+			endpoint = mtlsAlias(aliases)
+		}
+	}
+
+	form := url.Values{}
+	for key, values := range v {
+		form[key] = values
+	}
+	header := http.Header{}
+	if err := c.clientAuth(cfg).Authenticate(cfg, endpoint, form, header); err != nil {
+		return nil, wrapErrorf(err, "oidc: failed to authenticate client: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// doClientAuthRequest performs request created by newClientAuthRequest.
+func (c *Client) doClientAuthRequest(ctx context.Context, endpoint RequestEndpoint, req *http.Request) (*http.Response, error) {
+	if c.opts.mtlsHTTPClient != nil {
+		return doRequest(ctx, c.opts.mtlsHTTPClient, c.opts.requestHook, endpoint, req)
+	}
+	return doRequest(ctx, c.opts.httpClient, c.opts.requestHook, endpoint, req)
+}
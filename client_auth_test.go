@@ -0,0 +1,148 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func (s *ClientTestSuite) pushTokenRequestCapture(form *url.Values, header *http.Header) {
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken: "access1",
+		TokenType:   "Bearer",
+	})
+	s.NoError(err)
+
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.NoError(r.ParseForm())
+		*form = r.PostForm
+		*header = r.Header
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+}
+
+func (s *ClientTestSuite) TestClientAuth_ClientSecretPost() {
+	var form url.Values
+	var header http.Header
+	s.pushTokenRequestCapture(&form, &header)
+
+	_, err := s.client.Exchange(s.testCtx, Config{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		ClientAuth:   ClientSecretPost{},
+	}, "code1")
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Empty(header.Get("Authorization"))
+	s.Equal("client1", form.Get("client_id"))
+	s.Equal("secret1", form.Get("client_secret"))
+}
+
+func (s *ClientTestSuite) TestClientAuth_PrivateKeyJWT() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	var form url.Values
+	var header http.Header
+	s.pushTokenRequestCapture(&form, &header)
+
+	_, err = s.client.Exchange(s.testCtx, Config{
+		ClientID:     "client1",
+		ClientSecret: "ignored",
+		ClientAuth:   PrivateKeyJWT{Key: key, KeyID: "key1"},
+	}, "code1")
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Empty(header.Get("Authorization"))
+	s.Empty(form.Get("client_secret"))
+	s.Equal("client1", form.Get("client_id"))
+	s.Equal(ClientAssertionTypeJWTBearer, form.Get("client_assertion_type"))
+
+	jws, err := jose.ParseSigned(form.Get("client_assertion"))
+	s.Require().NoError(err)
+	payload, err := jws.Verify(&key.PublicKey)
+	s.Require().NoError(err)
+
+	var claims map[string]interface{}
+	s.Require().NoError(json.Unmarshal(payload, &claims))
+	s.Equal("client1", claims["iss"])
+	s.Equal("client1", claims["sub"])
+	s.Equal(testDiscovery.TokenURL, claims["aud"])
+	s.NotEmpty(claims["jti"])
+}
+
+func (s *ClientTestSuite) TestClientAuth_ClientSecretJWT() {
+	var form url.Values
+	var header http.Header
+	s.pushTokenRequestCapture(&form, &header)
+
+	_, err := s.client.Exchange(s.testCtx, Config{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		ClientAuth:   ClientSecretJWT{},
+	}, "code1")
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Empty(header.Get("Authorization"))
+	s.Empty(form.Get("client_secret"))
+	s.Equal(ClientAssertionTypeJWTBearer, form.Get("client_assertion_type"))
+
+	jws, err := jose.ParseSigned(form.Get("client_assertion"))
+	s.Require().NoError(err)
+	s.Equal(string(jose.HS256), jws.Signatures[0].Header.Algorithm)
+	payload, err := jws.Verify([]byte("secret1"))
+	s.Require().NoError(err)
+
+	var claims map[string]interface{}
+	s.Require().NoError(json.Unmarshal(payload, &claims))
+	s.Equal("client1", claims["iss"])
+	s.Equal(testDiscovery.TokenURL, claims["aud"])
+}
+
+func (s *ClientTestSuite) TestClientAuth_PublicClient() {
+	var form url.Values
+	var header http.Header
+	s.pushTokenRequestCapture(&form, &header)
+
+	_, err := s.client.Exchange(s.testCtx, Config{ClientID: "client1"}, "code1")
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Empty(header.Get("Authorization"))
+	s.Equal("client1", form.Get("client_id"))
+	_, ok := form["client_secret"]
+	s.False(ok)
+	s.Empty(form.Get("client_assertion"))
+}
+
+func (s *ClientTestSuite) TestClientAuth_SelectedFromDiscovery() {
+	for _, tcase := range []struct {
+		methods  []string
+		expected ClientAuth
+	}{
+		{methods: nil, expected: ClientSecretBasic{}},
+		{methods: []string{AuthMethodPrivateKeyJWT, AuthMethodClientSecretBasic}, expected: ClientSecretBasic{}},
+		{methods: []string{AuthMethodClientSecretJWT, AuthMethodClientSecretPost}, expected: ClientSecretPost{}},
+		{methods: []string{AuthMethodClientSecretJWT}, expected: ClientSecretJWT{}},
+		{methods: []string{AuthMethodPrivateKeyJWT}, expected: ClientSecretBasic{}},
+	} {
+		client := s.client.Provider().Client()
+		client.tokenAuthMethods = tcase.methods
+		s.Equal(tcase.expected, client.clientAuth(Config{ClientSecret: "secret1"}), "methods %v", tcase.methods)
+
+		// Public client never uses secret based methods.
+		s.Equal(PublicClient{}, client.clientAuth(Config{}), "methods %v", tcase.methods)
+	}
+
+	// Explicit one always wins.
+	s.Equal(ClientSecretPost{}, s.client.clientAuth(Config{ClientAuth: ClientSecretPost{}}))
+}
@@ -0,0 +1,170 @@
+package oidc
+
+import (
+	"context"
+	"sync"
+)
+
+// ClientPool lazily creates and caches Clients of many issuers, e.g for services that accept tokens from several
+// identity providers or one provider per tenant. Discovery of an issuer happens on first use, and all its clients
+// share discovery document, key set cache and retry budget. ClientPool is also a Verifier accepting tokens from
+// issuers added with Add, each verified with its own VerificationConfig. ClientPool is safe for concurrent use.
+type ClientPool struct {
+	opts   []Option
+	router *IssuerRouter
+
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+	// aliases are issuer aliases added to router for each issuer, so they can be removed with it.
+	aliases map[string][]string
+}
+
+// poolEntry holds client of a single issuer. Its mutex is held during discovery, so concurrent first uses of the
+// same issuer wait for single discovery request instead of making their own.
+type poolEntry struct {
+	mu     sync.Mutex
+	client *Client
+}
+
+// NewClientPool constructs empty ClientPool. Given options (e.g WithHTTPClient to share HTTP transport) are used for
+// clients of all issuers.
+func NewClientPool(opts ...Option) *ClientPool {
+	return &ClientPool{
+		opts:    opts,
+		router:  NewIssuerRouter(),
+		entries: map[string]*poolEntry{},
+		aliases: map[string][]string{},
+	}
+}
+
+// Client returns client of given issuer, performing discovery if it is the first use of the issuer. Failed discovery
+// is not cached, so next call retries it. Client does not require the issuer to be added with Add.
+func (p *ClientPool) Client(ctx context.Context, issuer string) (*Client, error) {
+	p.mu.Lock()
+	e, ok := p.entries[issuer]
+	if !ok {
+		e = &poolEntry{}
+		p.entries[issuer] = e
+	}
+	p.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.client != nil {
+		return e.client, nil
+	}
+	client, err := NewClient(ctx, issuer, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	e.client = client
+	return client, nil
+}
+
+// Add makes the pool accept tokens of given issuer, verified with given config (e.g VerificationConfig{ClientID:
+// "api"} of the tenant) and claims policies. Tokens with any of VerificationConfig.IssuerAliases in "iss" claim are
+// verified by the same config. Discovery happens on first verified token, not here. Add replaces previous config for
+// the same issuer.
+func (p *ClientPool) Add(issuer string, cfg VerificationConfig, policies ...ClaimsPolicy) {
+	v := &poolVerifier{pool: p, issuer: issuer, cfg: cfg}
+	p.router.Add(issuer, v, policies...)
+	for _, alias := range cfg.IssuerAliases {
+		p.router.Add(alias, v, policies...)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.aliases[issuer] = cfg.IssuerAliases
+}
+
+// Remove stops accepting tokens of given issuer (and its aliases) and drops its cached client.
+func (p *ClientPool) Remove(issuer string) {
+	p.mu.Lock()
+	aliases := p.aliases[issuer]
+	delete(p.aliases, issuer)
+	delete(p.entries, issuer)
+	p.mu.Unlock()
+
+	p.router.Remove(issuer)
+	for _, alias := range aliases {
+		p.router.Remove(alias)
+	}
+}
+
+// Verify is equivalent to VerifyIDToken.
+func (p *ClientPool) Verify(ctx context.Context, rawIDToken string) (*IDToken, error) {
+	return p.router.Verify(ctx, rawIDToken)
+}
+
+// VerifyIDToken verifies ID token using config of its issuer. Tokens of issuers not added with Add are rejected
+// with UnknownIssuerError.
+func (p *ClientPool) VerifyIDToken(ctx context.Context, rawIDToken string) (*IDToken, error) {
+	return p.router.VerifyIDToken(ctx, rawIDToken)
+}
+
+// VerifyAccessToken verifies JWT access token using config of its issuer.
+func (p *ClientPool) VerifyAccessToken(ctx context.Context, rawAccessToken string) (*AccessToken, error) {
+	return p.router.VerifyAccessToken(ctx, rawAccessToken)
+}
+
+// VerifyLogoutToken verifies logout token using config of its issuer.
+func (p *ClientPool) VerifyLogoutToken(ctx context.Context, rawLogoutToken string) (*LogoutToken, error) {
+	return p.router.VerifyLogoutToken(ctx, rawLogoutToken)
+}
+
+// VerifyUserInfo verifies signed user info using config of its issuer.
+func (p *ClientPool) VerifyUserInfo(ctx context.Context, rawUserInfo string) (*UserInfo, error) {
+	return p.router.VerifyUserInfo(ctx, rawUserInfo)
+}
+
+// poolVerifier verifies tokens with verifier of pool's client for the issuer, creating the client on first use.
+type poolVerifier struct {
+	pool   *ClientPool
+	issuer string
+	cfg    VerificationConfig
+}
+
+func (v *poolVerifier) verifier(ctx context.Context) (*IDTokenVerifier, error) {
+	client, err := v.pool.Client(ctx, v.issuer)
+	if err != nil {
+		return nil, err
+	}
+	return client.Verifier(v.cfg), nil
+}
+
+func (v *poolVerifier) Verify(ctx context.Context, rawIDToken string) (*IDToken, error) {
+	return v.VerifyIDToken(ctx, rawIDToken)
+}
+
+func (v *poolVerifier) VerifyIDToken(ctx context.Context, rawIDToken string) (*IDToken, error) {
+	verifier, err := v.verifier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return verifier.VerifyIDToken(ctx, rawIDToken)
+}
+
+func (v *poolVerifier) VerifyAccessToken(ctx context.Context, rawAccessToken string) (*AccessToken, error) {
+	verifier, err := v.verifier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return verifier.VerifyAccessToken(ctx, rawAccessToken)
+}
+
+func (v *poolVerifier) VerifyLogoutToken(ctx context.Context, rawLogoutToken string) (*LogoutToken, error) {
+	verifier, err := v.verifier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return verifier.VerifyLogoutToken(ctx, rawLogoutToken)
+}
+
+func (v *poolVerifier) VerifyUserInfo(ctx context.Context, rawUserInfo string) (*UserInfo, error) {
+	verifier, err := v.verifier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return verifier.VerifyUserInfo(ctx, rawUserInfo)
+}
@@ -0,0 +1,50 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Bplotka/go-httpt"
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestClientPool() {
+	jsonDiscovery, err := json.Marshal(testDiscovery)
+	s.NoError(err)
+	srv := httpt.NewServer(s.T())
+	pool := NewClientPool(WithHTTPClient(srv.HTTPClient()))
+
+	idToken, jwkSetJSON := s.validIDToken()
+	_, err = pool.Verify(context.TODO(), idToken)
+	s.Equal(&UnknownIssuerError{Issuer: exampleIssuer}, err)
+
+	// Failed discovery is retried on next use.
+	pool.Add(exampleIssuer, VerificationConfig{ClientID: "client1"}, RequireClaim("nonce", "nonce1"))
+	srv.Push(rt.JSONResponseFunc(http.StatusInternalServerError, []byte(`{}`)))
+	_, err = pool.Verify(context.TODO(), idToken)
+	s.Error(err)
+
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	token, err := pool.Verify(context.TODO(), idToken)
+	s.Require().NoError(err)
+	s.Equal("subject1", token.Subject)
+	s.Equal(0, srv.Len())
+
+	// Client is cached and shares provider with the verifier.
+	client, err := pool.Client(context.TODO(), exampleIssuer)
+	s.Require().NoError(err)
+	s.Equal(testDiscovery, client.Discovery())
+	s.Equal(uint64(1), client.Provider().KeySetStats().Fetches)
+
+	// Per-issuer config is applied.
+	pool.Add(exampleIssuer, VerificationConfig{ClientID: "other"})
+	_, err = pool.Verify(context.TODO(), idToken)
+	s.Error(err)
+
+	pool.Remove(exampleIssuer)
+	_, err = pool.Verify(context.TODO(), idToken)
+	s.Equal(&UnknownIssuerError{Issuer: exampleIssuer}, err)
+	s.Equal(0, srv.Len())
+}
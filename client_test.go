@@ -3,6 +3,7 @@ package oidc
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
@@ -19,12 +20,19 @@ const (
 )
 
 var testDiscovery = DiscoveryJSON{
-	Issuer:        exampleIssuer,
-	AuthURL:       exampleIssuer + "/auth1",
-	TokenURL:      exampleIssuer + "/token1",
-	JWKSURL:       exampleIssuer + "/jwks1",
-	UserInfoURL:   exampleIssuer + "/info1",
-	RevocationURL: exampleIssuer + "/rev1",
+	Issuer:           exampleIssuer,
+	AuthURL:          exampleIssuer + "/auth1",
+	TokenURL:         exampleIssuer + "/token1",
+	JWKSURL:          exampleIssuer + "/jwks1",
+	UserInfoURL:      exampleIssuer + "/info1",
+	RevocationURL:    exampleIssuer + "/rev1",
+	DeviceAuthURL:    exampleIssuer + "/device1",
+	IntrospectionURL: exampleIssuer + "/introspect1",
+	EndSessionURL:    exampleIssuer + "/logout1",
+	PARURL:           exampleIssuer + "/par1",
+	RegistrationURL:  exampleIssuer + "/register1",
+
+	BackchannelAuthURL: exampleIssuer + "/bc-authorize1",
 }
 
 type ClientTestSuite struct {
@@ -51,7 +59,7 @@ func (s *ClientTestSuite) SetupSuite() {
 	defer func() {
 		DefaultKeySetExpiration = oldKeySetExpiration
 	}()
-	s.client, err = NewClient(context.WithValue(context.TODO(), HTTPClientCtxKey, s.s.HTTPClient()), exampleIssuer)
+	s.client, err = NewClient(context.TODO(), exampleIssuer, WithHTTPClient(s.s.HTTPClient()))
 	s.NoError(err)
 }
 
@@ -91,3 +99,35 @@ func TestTokenResponseSetExpiry(t *testing.T) {
 	assert.Equal(t, expiresIn, int(tr.ExpiresIn))
 	assert.Equal(t, expiry, tr.expiry())
 }
+
+func TestNewClient_DeprecatedHTTPClientCtxKey(t *testing.T) {
+	jsonDiscovery, err := json.Marshal(testDiscovery)
+	assert.NoError(t, err)
+
+	s := httpt.NewServer(t)
+	s.On("GET", exampleIssuer+DiscoveryEndpoint).
+		Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+
+	// Compatibility: HTTP client from context is still used if no client was passed by option.
+	client, err := NewClient(context.WithValue(context.TODO(), HTTPClientCtxKey, s.HTTPClient()), exampleIssuer)
+	assert.NoError(t, err)
+	assert.Equal(t, testDiscovery, client.Discovery())
+	assert.Equal(t, 0, s.Len())
+}
+
+func (s *ClientTestSuite) TestRevoke_WithHint() {
+	var form url.Values
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal(testDiscovery.RevocationURL, r.URL.String())
+		s.NoError(r.ParseForm())
+		form = r.PostForm
+		return rt.StringResponseFunc(http.StatusOK, "")(r)
+	})
+
+	err := s.client.Revoke(s.testCtx, Config{ClientID: "client1"}, "refresh1", TokenTypeHintRefreshToken)
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Equal("refresh1", form.Get("token"))
+	s.Equal(TokenTypeHintRefreshToken, form.Get("token_type_hint"))
+}
@@ -0,0 +1,92 @@
+// Command oidc-exec-credential is a kubectl exec credential plugin that logs in using OIDC and prints ID token as
+// ExecCredential. Tokens are cached on disk and refreshed when possible, so login in browser is needed only when
+// refresh fails. Example kubeconfig user:
+//
+//    users:
+//    - name: oidc
+//      user:
+//        exec:
+//          apiVersion: client.authentication.k8s.io/v1
+//          command: oidc-exec-credential
+//          args: ["--issuer=https://issuer-oidc.org", "--client-id=client1", "--client-secret=secret1"]
+//          interactiveMode: IfAvailable
+//
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Bplotka/oidc"
+	"github.com/Bplotka/oidc/login"
+	"github.com/Bplotka/oidc/login/diskcache"
+	"github.com/Bplotka/oidc/login/execcredential"
+)
+
+func main() {
+	issuer := flag.String("issuer", "", "OIDC issuer URL.")
+	clientID := flag.String("client-id", "", "OIDC client ID.")
+	clientSecret := flag.String("client-secret", "", "OIDC client secret.")
+	scopes := flag.String("scopes", strings.Join([]string{oidc.ScopeOpenID, oidc.ScopeEmail, oidc.ScopeOfflineAccess}, ","), "Comma-separated scopes to request.")
+	bindAddress := flag.String("bind-address", "http://127.0.0.1:8883/callback", "Address of local callback server for login. With port 0, random free port is used for each login, which requires provider to allow any port of loopback redirect URIs.")
+	cachePath := flag.String("cache-path", "$HOME/.kube/cache/oidc/token", "Path to file with cached tokens.")
+	noBrowser := flag.Bool("no-browser", false, "Print login URL instead of opening browser and read the code or redirect URL pasted from stdin, e.g in SSH sessions.")
+	caFile := flag.String("ca-file", "", "Path to PEM file with additional CA certificates trusted for provider connections.")
+	flag.Parse()
+
+	// Stdout is parsed by kubectl, so everything else goes to stderr.
+	logger := log.New(os.Stderr, "", 0)
+	if *issuer == "" || *clientID == "" {
+		logger.Fatal("--issuer and --client-id are required")
+	}
+
+	var clientOpts []oidc.Option
+	if *caFile != "" {
+		pool, err := oidc.LoadCACertPool(os.ExpandEnv(*caFile))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		clientOpts = append(clientOpts, oidc.WithTLSConfig(&tls.Config{RootCAs: pool}))
+	}
+
+	cfg := login.Config{NonceCheck: true, NoBrowser: *noBrowser, ClientOptions: clientOpts}
+	if err := run(logger, *issuer, *clientID, *clientSecret, strings.Split(*scopes, ","), *bindAddress, os.ExpandEnv(*cachePath), cfg); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func run(logger *log.Logger, issuer, clientID, clientSecret string, scopes []string, bindAddress, cachePath string, cfg login.Config) error {
+	callbackSrv, closeSrv, err := newCallbackServer(bindAddress)
+	if err != nil {
+		return fmt.Errorf("failed to start callback server: %v", err)
+	}
+	defer closeSrv()
+
+	cache := disk.NewCache(cachePath, login.OIDCConfig{
+		Provider:     issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+	})
+	src, _, err := login.NewOIDCTokenSource(context.Background(), logger, cfg, cache, callbackSrv)
+	if err != nil {
+		return err
+	}
+	return execcredential.Write(os.Stdout, src)
+}
+
+// newCallbackServer returns callback server listening on bindAddress, or only during login on random free port of
+// loopback interface, if the port is 0.
+func newCallbackServer(bindAddress string) (*login.CallbackServer, func(), error) {
+	u, err := url.Parse(bindAddress)
+	if err == nil && u.Port() == "0" {
+		return login.NewLoopbackServer(u.Path), func() {}, nil
+	}
+	return login.NewServer(bindAddress)
+}
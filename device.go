@@ -0,0 +1,156 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// defaultDeviceInterval is the polling interval used when provider did not specify one.
+	defaultDeviceInterval = 5 * time.Second
+	// deviceSlowDownIncrement is added to the polling interval on every slow_down error.
+	deviceSlowDownIncrement = 5 * time.Second
+)
+
+// DeviceAuthResponse is the response of the device authorization endpoint. See
+// https://tools.ietf.org/html/rfc8628#section-3.2.
+type DeviceAuthResponse struct {
+	DeviceCode string `json:"device_code"`
+	// UserCode is the code that user needs to enter on the VerificationURI.
+	UserCode string `json:"user_code"`
+	// VerificationURI is the page where user authorizes the device.
+	VerificationURI string `json:"verification_uri"`
+	// VerificationURIComplete if not empty, is VerificationURI including the UserCode, e.g to be shown as QR code.
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	// ExpiresIn is lifetime of the codes in seconds.
+	ExpiresIn int `json:"expires_in"`
+	// Interval is minimum polling interval in seconds.
+	Interval int `json:"interval,omitempty"`
+
+	// Expiry is the time codes expire, computed from ExpiresIn.
+	Expiry time.Time `json:"-"`
+}
+
+// UnmarshalJSON accepts also non standard "verification_url" field, used e.g by Google.
+func (d *DeviceAuthResponse) UnmarshalJSON(b []byte) error {
+	type deviceAuthResponse DeviceAuthResponse
+	var resp struct {
+		deviceAuthResponse
+		VerificationURL string `json:"verification_url"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return err
+	}
+	*d = DeviceAuthResponse(resp.deviceAuthResponse)
+	if d.VerificationURI == "" {
+		d.VerificationURI = resp.VerificationURL
+	}
+	return nil
+}
+
+// DeviceAuth starts device authorization grant (see https://tools.ietf.org/html/rfc8628) for devices without browser,
+// e.g SSH sessions. Show returned UserCode and VerificationURI to the user, then call DeviceAccessToken to wait for
+// the user's authorization.
+func (c *Client) DeviceAuth(ctx context.Context, cfg Config) (*DeviceAuthResponse, error) {
+	discovery := c.Discovery()
+	if discovery.DeviceAuthURL == "" {
+		return nil, errors.New("oidc: device authorization endpoint is not supported by this provider")
+	}
+
+	v := url.Values{"client_id": {cfg.ClientID}}
+	if len(cfg.Scopes) > 0 {
+		v.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	req, err := http.NewRequest("POST", discovery.DeviceAuthURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cfg.ClientSecret != "" {
+		req.SetBasicAuth(cfg.ClientID, cfg.ClientSecret)
+	}
+
+	r, err := doRequest(ctx, c.opts.httpClient, c.opts.requestHook, RequestDeviceAuth, req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, wrapErrorf(&NetworkError{Err: err}, "oauth2: cannot authorize device: %v", err)
+	}
+	if code := r.StatusCode; code < 200 || code > 299 {
+		return nil, newOAuth2Error("oauth2: cannot authorize device", r, body)
+	}
+
+	var d DeviceAuthResponse
+	if err := json.Unmarshal(body, &d); err != nil {
+		return nil, fmt.Errorf("oauth2: failed to decode device authorization response: %v", err)
+	}
+	if d.DeviceCode == "" || d.UserCode == "" || d.VerificationURI == "" {
+		return nil, fmt.Errorf("oauth2: incomplete device authorization response: %s", body)
+	}
+	if d.ExpiresIn > 0 {
+		d.Expiry = time.Now().Add(time.Duration(d.ExpiresIn) * time.Second)
+	}
+	return &d, nil
+}
+
+// DeviceAccessToken polls token endpoint until user authorizes the device, denies it, codes expire or the context is
+// done. It respects polling interval, including slow_down requests of the provider. Returned token is not verified.
+func (c *Client) DeviceAccessToken(ctx context.Context, cfg Config, d *DeviceAuthResponse) (*Token, error) {
+	v := url.Values{
+		"grant_type":  {GrantTypeDeviceCode},
+		"device_code": {d.DeviceCode},
+		"client_id":   {cfg.ClientID},
+	}
+
+	interval := defaultDeviceInterval
+	if d.Interval > 0 {
+		interval = time.Duration(d.Interval) * time.Second
+	}
+
+	return c.pollToken(ctx, cfg, v, interval, d.Expiry, nil, "device authorization")
+}
+
+// pollToken polls token endpoint every interval until user authorizes the request, denies it, the expiry passes or
+// the context is done. If wakeup is not nil, token endpoint is also polled on every wakeup.
+func (c *Client) pollToken(ctx context.Context, cfg Config, v url.Values, interval time.Duration, expiry time.Time, wakeup <-chan struct{}, what string) (*Token, error) {
+	if !expiry.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, expiry)
+		defer cancel()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			err := fmt.Errorf("oauth2: %s was not completed: %v", what, ctx.Err())
+			c.audit(AuditLogin, cfg.ClientID, "", err)
+			return nil, err
+		case <-time.After(interval):
+		case <-wakeup:
+		}
+
+		t, err := c.token(ctx, cfg, v)
+		if oerr, ok := err.(*OAuth2Error); ok {
+			switch oerr.Code {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += deviceSlowDownIncrement
+				continue
+			}
+		}
+		c.audit(AuditLogin, cfg.ClientID, tokenSubject(t), err)
+		return t, err
+	}
+}
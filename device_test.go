@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestDevice() {
+	oldInterval, oldIncrement := defaultDeviceInterval, deviceSlowDownIncrement
+	defaultDeviceInterval, deviceSlowDownIncrement = time.Millisecond, time.Millisecond
+	defer func() {
+		defaultDeviceInterval, deviceSlowDownIncrement = oldInterval, oldIncrement
+	}()
+
+	var form url.Values
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal(testDiscovery.DeviceAuthURL, r.URL.String())
+		s.NoError(r.ParseForm())
+		form = r.PostForm
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{
+			"device_code": "device1",
+			"user_code": "ABCD-EFGH",
+			"verification_url": "https://issuer.org/device",
+			"expires_in": 600
+		}`))(r)
+	})
+
+	cfg := Config{ClientID: "client1", Scopes: []string{ScopeOpenID, ScopeEmail}}
+	d, err := s.client.DeviceAuth(s.testCtx, cfg)
+	s.Require().NoError(err)
+	s.Equal("client1", form.Get("client_id"))
+	s.Equal("openid email", form.Get("scope"))
+	s.Equal("ABCD-EFGH", d.UserCode)
+	s.Equal("https://issuer.org/device", d.VerificationURI)
+	s.False(d.Expiry.IsZero())
+
+	idToken, _ := s.validIDToken()
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access1",
+		RefreshToken: "refresh1",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+	})
+	s.NoError(err)
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "authorization_pending"}`)))
+	s.s.Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "slow_down"}`)))
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.NoError(r.ParseForm())
+		s.Equal(GrantTypeDeviceCode, r.PostForm.Get("grant_type"))
+		s.Equal("device1", r.PostForm.Get("device_code"))
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	token, err := s.client.DeviceAccessToken(s.testCtx, cfg, d)
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+	s.Equal("access1", token.AccessToken)
+	s.Equal(idToken, token.IDToken)
+}
+
+func (s *ClientTestSuite) TestDevice_AccessDenied() {
+	oldInterval := defaultDeviceInterval
+	defaultDeviceInterval = time.Millisecond
+	defer func() {
+		defaultDeviceInterval = oldInterval
+	}()
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "access_denied"}`)))
+
+	_, err := s.client.DeviceAccessToken(s.testCtx, Config{ClientID: "client1"}, &DeviceAuthResponse{DeviceCode: "device1"})
+	s.Require().Error(err)
+	s.Equal("access_denied", err.(*OAuth2Error).Code)
+	s.Equal(0, s.s.Len())
+}
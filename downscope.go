@@ -0,0 +1,108 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Downscope obtains new access token limited to given scopes by refreshing t with reduced scope
+// (see https://tools.ietf.org/html/rfc6749#section-6). Scopes must be a subset of scopes originally granted to t.
+// Returned token has no refresh token, so it can't be used to regain dropped scopes and is safe to be forwarded to
+// downstream services.
+//
+// NOTE: Providers that rotate refresh tokens invalidate t.RefreshToken on every refresh. For them use TokenExchange
+// with WithExchangeScopes instead.
+func (c *Client) Downscope(ctx context.Context, cfg Config, t *Token, scopes ...string) (*Token, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("oidc: no scopes to downscope to")
+	}
+	if t.RefreshToken == "" {
+		return nil, ErrNoRefreshToken
+	}
+	if t.Scope != "" {
+		if extra := scopesNotIn(scopes, strings.Fields(t.Scope)); len(extra) > 0 {
+			return nil, fmt.Errorf("oidc: cannot downscope to scopes %q not granted to the token", extra)
+		}
+	}
+
+	v := url.Values{
+		"grant_type":    {GrantTypeRefreshToken},
+		"refresh_token": {t.RefreshToken},
+		"scope":         {strings.Join(scopes, " ")},
+	}
+	tk, err := c.token(ctx, cfg, v)
+	c.audit(AuditRefresh, cfg.ClientID, tokenSubject(tk), err)
+	if err != nil {
+		return nil, err
+	}
+
+	if tk.Scope == "" {
+		tk.Scope = strings.Join(scopes, " ")
+	} else if extra := scopesNotIn(strings.Fields(tk.Scope), scopes); len(extra) > 0 {
+		return nil, fmt.Errorf("oidc: provider granted scopes %q that were not requested", extra)
+	}
+	if tk.IDToken == "" {
+		// Provider is not required to return ID token on refresh.
+		tk.IDToken = t.IDToken
+	}
+	tk.RefreshToken = ""
+	return tk, nil
+}
+
+// scopesNotIn returns elements of scopes that are not in allowed.
+func scopesNotIn(scopes []string, allowed []string) []string {
+	var extra []string
+	for _, s := range scopes {
+		if !contains(allowed, s) {
+			extra = append(extra, s)
+		}
+	}
+	return extra
+}
+
+// DownscopedTokenSource returns a TokenSource that returns tokens limited to given scopes, derived from tokens of the
+// base TokenSource using Downscope. Tokens are reused until they expire.
+func (c *Client) DownscopedTokenSource(ctx context.Context, cfg Config, base TokenSource, scopes ...string) TokenSource {
+	src, _ := NewReuseTokenSource(ctx, nil, &downscopingTokenSource{
+		ctx:    ctx,
+		client: c,
+		cfg:    cfg,
+		base:   base,
+		scopes: scopes,
+	})
+	return src
+}
+
+type downscopingTokenSource struct {
+	ctx    context.Context
+	client *Client
+	cfg    Config
+	base   TokenSource
+	scopes []string
+}
+
+// OIDCToken returns new downscoped token.
+func (s *downscopingTokenSource) OIDCToken() (*Token, error) {
+	t, err := s.base.OIDCToken()
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Downscope(s.ctx, s.cfg, t, s.scopes...)
+}
+
+// OIDCTokenCtx returns new downscoped token using ctx.
+func (s *downscopingTokenSource) OIDCTokenCtx(ctx context.Context) (*Token, error) {
+	t, err := tokenCtx(ctx, s.base)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Downscope(ctx, s.cfg, t, s.scopes...)
+}
+
+// Verifier returns verifier of the base token source.
+func (s *downscopingTokenSource) Verifier() Verifier {
+	return s.base.Verifier()
+}
@@ -0,0 +1,78 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestDownscope() {
+	idToken, _ := s.validIDToken()
+	base := &Token{
+		AccessToken:  "access1",
+		RefreshToken: "refresh1",
+		IDToken:      idToken,
+		Scope:        "openid email profile",
+	}
+
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access2",
+		RefreshToken: "refresh1",
+		TokenType:    "Bearer",
+		Scope:        "email",
+	})
+	s.NoError(err)
+
+	var form url.Values
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.NoError(r.ParseForm())
+		form = r.PostForm
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	tk, err := s.client.Downscope(s.testCtx, Config{ClientID: "client1"}, base, "email")
+	s.NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Equal("refresh1", form.Get("refresh_token"))
+	s.Equal("email", form.Get("scope"))
+
+	s.Equal("access2", tk.AccessToken)
+	s.Equal("email", tk.Scope)
+	s.Empty(tk.RefreshToken)
+	// ID token is kept from the base token if not returned on refresh.
+	s.Equal(idToken, tk.IDToken)
+}
+
+func (s *ClientTestSuite) TestDownscope_NotGrantedScope() {
+	base := &Token{
+		AccessToken:  "access1",
+		RefreshToken: "refresh1",
+		Scope:        "openid email",
+	}
+
+	_, err := s.client.Downscope(s.testCtx, Config{ClientID: "client1"}, base, "email", "admin")
+	s.Error(err)
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestDownscope_BroaderScopeGranted() {
+	base := &Token{
+		AccessToken:  "access1",
+		RefreshToken: "refresh1",
+	}
+
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken: "access2",
+		TokenType:   "Bearer",
+		Scope:       "email admin",
+	})
+	s.NoError(err)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+
+	_, err = s.client.Downscope(s.testCtx, Config{ClientID: "client1"}, base, "email")
+	s.Error(err)
+	s.Equal(0, s.s.Len())
+}
@@ -0,0 +1,196 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// TokenTypeDPoP is the token type of DPoP-bound access tokens.
+	TokenTypeDPoP = "DPoP"
+
+	dpopHeader      = "DPoP"
+	dpopNonceHeader = "DPoP-Nonce"
+	dpopNonceError  = "use_dpop_nonce"
+)
+
+// DPoPKey is a key proving possession of sender-constrained tokens with DPoP proofs (see
+// https://tools.ietf.org/html/rfc9449). The same key needs to be used for token requests (WithDPoP) and for requests
+// to resource servers (WithDPoPProofs), since tokens are bound to it. It remembers nonces provided by servers.
+// DPoPKey is safe for concurrent use.
+type DPoPKey struct {
+	key crypto.PrivateKey
+	alg jose.SignatureAlgorithm
+	jwk jose.JSONWebKey
+
+	mu sync.Mutex
+	// nonces are the last nonces provided by servers, by origin.
+	nonces map[string]string
+}
+
+// NewDPoPKey constructs DPoPKey from *rsa.PrivateKey or *ecdsa.PrivateKey.
+func NewDPoPKey(key crypto.PrivateKey) (*DPoPKey, error) {
+	alg, err := signingAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("oidc: DPoP key of type %T has no public key", key)
+	}
+	return &DPoPKey{
+		key:    key,
+		alg:    alg,
+		jwk:    jose.JSONWebKey{Key: signer.Public(), Algorithm: string(alg)},
+		nonces: map[string]string{},
+	}, nil
+}
+
+// GenerateDPoPKey generates new ECDSA P-256 DPoPKey. Keep using the same key for as long as tokens bound to it live.
+func GenerateDPoPKey() (*DPoPKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewDPoPKey(key)
+}
+
+// Thumbprint returns JWK SHA-256 thumbprint of the public key (see https://tools.ietf.org/html/rfc7638), e.g for
+// dpop_jkt authorization request parameter.
+func (k *DPoPKey) Thumbprint() (string, error) {
+	t, err := k.jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(t), nil
+}
+
+// Proof returns DPoP proof JWT for request with given method and URL. If accessToken is not empty, proof is bound
+// to it, as required for requests to resource servers. Nonce last provided by the server is included.
+func (k *DPoPKey) Proof(method string, u *url.URL, accessToken string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, jti); err != nil {
+		return "", err
+	}
+
+	claims := map[string]interface{}{
+		"jti": base64.RawURLEncoding.EncodeToString(jti),
+		"htm": method,
+		"htu": htu(u),
+		"iat": time.Now().Unix(),
+	}
+	if accessToken != "" {
+		ath := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(ath[:])
+	}
+	if nonce := k.nonce(u); nonce != "" {
+		claims["nonce"] = nonce
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	opts := (&jose.SignerOptions{EmbedJWK: true}).WithType("dpop+jwt")
+	signer, err := newSigner(jose.SigningKey{Algorithm: k.alg, Key: k.key}, opts)
+	if err != nil {
+		return "", wrapErrorf(err, "oidc: failed to create DPoP proof signer: %v", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to sign DPoP proof: %v", err)
+	}
+	return jws.CompactSerialize()
+}
+
+// htu returns URL without query and fragment. See https://tools.ietf.org/html/rfc9449#section-4.2.
+func htu(u *url.URL) string {
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+}
+
+func origin(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+func (k *DPoPKey) nonce(u *url.URL) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.nonces[origin(u)]
+}
+
+// setProof sets DPoP proof header for the request.
+func (k *DPoPKey) setProof(req *http.Request, accessToken string) error {
+	proof, err := k.Proof(req.Method, req.URL, accessToken)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(dpopHeader, proof)
+	return nil
+}
+
+// recordNonce remembers nonce provided in the response. It returns true if it is a new nonce.
+func (k *DPoPKey) recordNonce(u *url.URL, resp *http.Response) bool {
+	nonce := resp.Header.Get(dpopNonceHeader)
+	if nonce == "" {
+		return false
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.nonces[origin(u)] == nonce {
+		return false
+	}
+	k.nonces[origin(u)] = nonce
+	return true
+}
+
+// WithDPoP makes client send DPoP proofs signed with given key on token requests, so provider issues access tokens
+// bound to the key (token type TokenTypeDPoP). Use WithDPoPProofs transport option with the same key to use them.
+// Token requests rejected for missing nonce are retried once with nonce provided by the provider.
+func WithDPoP(key *DPoPKey) Option {
+	return func(o *options) {
+		o.dpop = key
+	}
+}
+
+// isDPoPNonceError returns true if authorization server asks for DPoP nonce. See
+// https://tools.ietf.org/html/rfc9449#section-8.
+func isDPoPNonceError(r *http.Response, body []byte) bool {
+	if r.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	var errResp struct {
+		Code string `json:"error"`
+	}
+	return json.Unmarshal(body, &errResp) == nil && errResp.Code == dpopNonceError
+}
+
+// isDPoPNonceChallenge returns true if resource server asks for DPoP nonce. See
+// https://tools.ietf.org/html/rfc9449#section-9.
+func isDPoPNonceChallenge(r *http.Response) bool {
+	if r.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	for _, v := range r.Header["Www-Authenticate"] {
+		if strings.HasPrefix(v, dpopHeader+" ") && strings.Contains(v, dpopNonceError) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func dpopClaims(t *testing.T, key *DPoPKey, proof string) map[string]interface{} {
+	jws, err := jose.ParseSigned(proof)
+	require.NoError(t, err)
+	require.Len(t, jws.Signatures, 1)
+	require.NotNil(t, jws.Signatures[0].Protected.JSONWebKey, "proof must embed public key")
+
+	payload, err := jws.Verify(&key.key.(*ecdsa.PrivateKey).PublicKey)
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	return claims
+}
+
+func TestTransport_DPoP(t *testing.T) {
+	key, err := GenerateDPoPKey()
+	require.NoError(t, err)
+
+	var proofs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proofs = append(proofs, r.Header.Get("DPoP"))
+		if len(proofs) == 1 {
+			w.Header().Set("DPoP-Nonce", "nonce1")
+			w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: NewTransport(nil, StaticTokenSource(&Token{AccessToken: "access1", TokenType: TokenTypeDPoP}), WithDPoPProofs(key)),
+	}
+	resp, err := client.Get(srv.URL + "/resource1?query=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "DPoP access1", string(body))
+
+	require.Len(t, proofs, 2)
+	first := dpopClaims(t, key, proofs[0])
+	assert.Nil(t, first["nonce"])
+
+	claims := dpopClaims(t, key, proofs[1])
+	assert.Equal(t, "GET", claims["htm"])
+	assert.Equal(t, srv.URL+"/resource1", claims["htu"])
+	assert.Equal(t, "nonce1", claims["nonce"])
+	ath := sha256.Sum256([]byte("access1"))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(ath[:]), claims["ath"])
+	assert.NotEqual(t, first["jti"], claims["jti"])
+}
+
+func (s *ClientTestSuite) TestToken_DPoPNonceRetry() {
+	key, err := GenerateDPoPKey()
+	s.Require().NoError(err)
+	client := s.client.Provider().Client(WithDPoP(key))
+
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken: "access1",
+		TokenType:   TokenTypeDPoP,
+	})
+	s.NoError(err)
+
+	var proofs []string
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		proofs = append(proofs, r.Header.Get("DPoP"))
+		resp, err := rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "use_dpop_nonce"}`))(r)
+		resp.Header.Set("DPoP-Nonce", "nonce1")
+		return resp, err
+	})
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		proofs = append(proofs, r.Header.Get("DPoP"))
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	tk, err := client.Exchange(s.testCtx, Config{ClientID: "client1"}, "code1")
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+	s.Equal(TokenTypeDPoP, tk.TokenType)
+
+	s.Require().Len(proofs, 2)
+	claims := dpopClaims(s.T(), key, proofs[1])
+	s.Equal("POST", claims["htm"])
+	s.Equal(testDiscovery.TokenURL, claims["htu"])
+	s.Equal("nonce1", claims["nonce"])
+	s.Nil(claims["ath"])
+}
@@ -0,0 +1,15 @@
+//go:build go1.13
+// +build go1.13
+
+package oidc
+
+import "crypto/ed25519"
+
+// eddsaSupported is true, since Ed25519 keys are supported by standard library since Go 1.13.
+const eddsaSupported = true
+
+// isEd25519Key returns true if key is Ed25519 public key.
+func isEd25519Key(key interface{}) bool {
+	_, ok := key.(ed25519.PublicKey)
+	return ok
+}
@@ -0,0 +1,47 @@
+//go:build go1.13
+// +build go1.13
+
+package oidc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func (s *ClientTestSuite) TestVerifier_EdDSA() {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.EdDSA, Key: priv}, (&jose.SignerOptions{}).WithHeader("kid", "ed-key"))
+	s.Require().NoError(err)
+	payload, err := json.Marshal(map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	})
+	s.Require().NoError(err)
+	jws, err := signer.Sign(payload)
+	s.Require().NoError(err)
+	rawToken, err := jws.CompactSerialize()
+	s.Require().NoError(err)
+
+	jwkSetJSON, err := json.Marshal(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: pub, KeyID: "ed-key", Use: "sig"}}})
+	s.Require().NoError(err)
+	s.Contains(string(jwkSetJSON), `"kty":"OKP"`)
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	token, err := s.client.Verifier(VerificationConfig{
+		ClientID:             "client1",
+		SupportedSigningAlgs: []string{string(jose.EdDSA)},
+	}).VerifyIDToken(s.testCtx, rawToken)
+	s.Require().NoError(err)
+	s.Equal("subject1", token.Subject)
+	s.Equal(0, s.s.Len())
+}
@@ -0,0 +1,12 @@
+//go:build !go1.13
+// +build !go1.13
+
+package oidc
+
+// eddsaSupported is false, so EdDSA is verified only with Go 1.13+, which has Ed25519 in standard library.
+const eddsaSupported = false
+
+// isEd25519Key returns false, since Ed25519 keys are not supported.
+func isEd25519Key(interface{}) bool {
+	return false
+}
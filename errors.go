@@ -0,0 +1,240 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrNoRefreshToken is returned when token needs to be refreshed, but there is no refresh token to do that.
+var ErrNoRefreshToken = errors.New("oauth2: token expired and refresh token is not set")
+
+// ErrUserInfoSubjectMismatch is returned when "sub" claim of user info response does not match the one of ID token.
+// Such response must not be used, since it might be substituted. See
+// http://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse.
+var ErrUserInfoSubjectMismatch = errors.New("oidc: user info subject does not match ID token subject")
+
+// OAuth2 error codes that mean user needs to log in again. See https://tools.ietf.org/html/rfc6749#section-5.2 and
+// http://openid.net/specs/openid-connect-core-1_0.html#AuthError.
+var authErrorCodes = map[string]struct{}{
+	"invalid_grant":        {},
+	"invalid_token":        {},
+	"login_required":       {},
+	"interaction_required": {},
+	"consent_required":     {},
+}
+
+// OAuth2 error codes that mean the provider had temporary problems.
+var retryableErrorCodes = map[string]struct{}{
+	"server_error":            {},
+	"temporarily_unavailable": {},
+}
+
+// OAuth2Error is an error response returned by OAuth2 endpoints of the provider like token or revocation endpoint.
+type OAuth2Error struct {
+	// Code is the "error" field of the response e.g invalid_grant. Empty if response did not include it.
+	Code string
+	// Description is the optional "error_description" field of the response.
+	Description string
+	// URI is the optional "error_uri" field of the response, pointing to human-readable page about the error.
+	URI string
+	// HTTPStatus is the HTTP status code of the response.
+	HTTPStatus int
+	// Body is the raw response body.
+	Body []byte
+	// RetryAfter is the wait requested by the provider in Retry-After header of 429 or 503 response. Zero if not set.
+	RetryAfter time.Duration
+
+	msg string
+	// sentinel marks errors like ErrInvalidGrant that match any OAuth2Error with the same code.
+	sentinel bool
+}
+
+// Sentinel OAuth2 errors, one per error code of https://tools.ietf.org/html/rfc6749#section-5.2. Any OAuth2Error with
+// the same code matches them via errors.Is, e.g errors.Is(err, oidc.ErrInvalidGrant) means refresh token was revoked
+// or expired and user needs to log in again.
+var (
+	ErrInvalidRequest       = newSentinelOAuth2Error("invalid_request")
+	ErrInvalidClient        = newSentinelOAuth2Error("invalid_client")
+	ErrInvalidGrant         = newSentinelOAuth2Error("invalid_grant")
+	ErrUnauthorizedClient   = newSentinelOAuth2Error("unauthorized_client")
+	ErrUnsupportedGrantType = newSentinelOAuth2Error("unsupported_grant_type")
+	ErrInvalidScope         = newSentinelOAuth2Error("invalid_scope")
+)
+
+func newSentinelOAuth2Error(code string) *OAuth2Error {
+	return &OAuth2Error{Code: code, msg: "oauth2: " + code, sentinel: true}
+}
+
+func newOAuth2Error(msgPrefix string, r *http.Response, body []byte) *OAuth2Error {
+	e := &OAuth2Error{
+		HTTPStatus: r.StatusCode,
+		Body:       body,
+		msg:        fmt.Sprintf("%s: %v\nResponse: %s", msgPrefix, r.Status, body),
+	}
+	if r.StatusCode == http.StatusTooManyRequests || r.StatusCode == http.StatusServiceUnavailable {
+		e.RetryAfter = parseRetryAfter(r.Header.Get("Retry-After"), time.Now())
+	}
+
+	var errResp struct {
+		Code        string `json:"error"`
+		Description string `json:"error_description"`
+		URI         string `json:"error_uri"`
+	}
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		e.Code = errResp.Code
+		e.Description = errResp.Description
+		e.URI = errResp.URI
+	}
+	return e
+}
+
+func (e *OAuth2Error) Error() string {
+	return e.msg
+}
+
+// Is returns true if target is sentinel error (e.g ErrInvalidGrant) with the same code as e. It makes errors.Is work
+// for OAuth2Error, even when wrapped.
+func (e *OAuth2Error) Is(target error) bool {
+	t, ok := target.(*OAuth2Error)
+	if !ok {
+		return false
+	}
+	if t.sentinel {
+		return e.Code != "" && e.Code == t.Code
+	}
+	return e == t
+}
+
+// HTTPError is returned when provider responded with unexpected HTTP status on non-OAuth2 endpoint like discovery,
+// JWKS or user info.
+type HTTPError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Body is the raw response body.
+	Body []byte
+
+	msg string
+}
+
+func newHTTPError(r *http.Response, body []byte) *HTTPError {
+	return &HTTPError{
+		StatusCode: r.StatusCode,
+		Body:       body,
+		msg:        fmt.Sprintf("%s: %s", r.Status, body),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return e.msg
+}
+
+// NetworkError is returned when request to the provider failed without any response.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns underlying error.
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// wrappedError adds context to an error keeping the original one available for classification.
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string {
+	return e.msg
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+// wrapErrorf returns error with formatted message that unwraps to err.
+func wrapErrorf(err error, format string, args ...interface{}) error {
+	return &wrappedError{msg: fmt.Sprintf(format, args...), err: err}
+}
+
+// walkErrors calls fn for err and all errors it wraps until fn returns true. It returns true if fn did.
+func walkErrors(err error, fn func(error) bool) bool {
+	for err != nil {
+		if fn(err) {
+			return true
+		}
+		u, ok := err.(interface {
+			Unwrap() error
+		})
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
+
+// IsNetworkError returns true if err was caused by failure in communication with the provider e.g. connection
+// refused, DNS failure or timeout.
+func IsNetworkError(err error) bool {
+	return walkErrors(err, func(err error) bool {
+		switch err.(type) {
+		case *NetworkError, net.Error:
+			return true
+		}
+		return false
+	})
+}
+
+// IsAuthError returns true if err means that used credentials (e.g refresh token) are no longer valid and
+// user needs to log in again, e.g provider returned invalid_grant.
+func IsAuthError(err error) bool {
+	return walkErrors(err, func(err error) bool {
+		if err == ErrNoRefreshToken {
+			return true
+		}
+		if oerr, ok := err.(*OAuth2Error); ok {
+			_, isAuth := authErrorCodes[oerr.Code]
+			return isAuth
+		}
+		return false
+	})
+}
+
+// IsRetryable returns true if err is transient and the same request might succeed when retried, e.g network error or
+// 5xx or 429 response. Errors caused by context cancellation are not retryable.
+func IsRetryable(err error) bool {
+	if walkErrors(err, func(err error) bool {
+		return err == context.Canceled || err == context.DeadlineExceeded
+	}) {
+		return false
+	}
+
+	return walkErrors(err, func(err error) bool {
+		switch e := err.(type) {
+		case *NetworkError:
+			return true
+		case *OAuth2Error:
+			if _, ok := retryableErrorCodes[e.Code]; ok {
+				return true
+			}
+			return isRetryableStatus(e.HTTPStatus)
+		case *HTTPError:
+			return isRetryableStatus(e.StatusCode)
+		}
+		return false
+	})
+}
+
+func isRetryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests
+}
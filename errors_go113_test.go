@@ -0,0 +1,28 @@
+//go:build go1.13
+// +build go1.13
+
+package oidc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuth2Error_ErrorsIs(t *testing.T) {
+	invalidGrant := newOAuth2Error("oauth2: cannot fetch token", &http.Response{
+		Status:     "400 Bad Request",
+		StatusCode: http.StatusBadRequest,
+	}, []byte(`{"error": "invalid_grant"}`))
+
+	wrapped := wrapErrorf(invalidGrant, "failed to refresh: %v", invalidGrant)
+	assert.True(t, errors.Is(wrapped, ErrInvalidGrant))
+	assert.False(t, errors.Is(wrapped, ErrInvalidClient))
+	assert.False(t, errors.Is(&OAuth2Error{HTTPStatus: http.StatusBadRequest}, ErrInvalidRequest), "error without code should not match")
+
+	var oerr *OAuth2Error
+	assert.True(t, errors.As(wrapped, &oerr))
+	assert.Equal(t, http.StatusBadRequest, oerr.HTTPStatus)
+}
@@ -0,0 +1,69 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClassification(t *testing.T) {
+	invalidGrant := newOAuth2Error("oauth2: cannot fetch token", &http.Response{
+		Status:     "400 Bad Request",
+		StatusCode: http.StatusBadRequest,
+	}, []byte(`{"error": "invalid_grant", "error_description": "refresh token revoked"}`))
+	assert.Equal(t, "invalid_grant", invalidGrant.Code)
+	assert.Equal(t, "refresh token revoked", invalidGrant.Description)
+	assert.Equal(t, "oauth2: cannot fetch token: 400 Bad Request\nResponse: {\"error\": \"invalid_grant\", \"error_description\": \"refresh token revoked\"}", invalidGrant.Error())
+
+	unavailable := newOAuth2Error("oauth2: cannot fetch token", &http.Response{
+		Status:     "503 Service Unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+	}, []byte(`not a json`))
+	assert.Empty(t, unavailable.Code)
+
+	network := &NetworkError{Err: errors.New("connection refused")}
+	canceled := &NetworkError{Err: context.Canceled}
+
+	for _, c := range []struct {
+		err error
+
+		auth, retryable, network bool
+	}{
+		{err: errors.New("some error")},
+		{err: invalidGrant, auth: true},
+		{err: wrapErrorf(invalidGrant, "wrapped: %v", invalidGrant), auth: true},
+		{err: ErrNoRefreshToken, auth: true},
+		{err: unavailable, retryable: true},
+		{err: &HTTPError{StatusCode: http.StatusTooManyRequests}, retryable: true},
+		{err: &HTTPError{StatusCode: http.StatusNotFound}},
+		{err: network, retryable: true, network: true},
+		{err: wrapErrorf(network, "wrapped: %v", network), retryable: true, network: true},
+		{err: canceled, network: true},
+	} {
+		assert.Equal(t, c.auth, IsAuthError(c.err), "IsAuthError(%v)", c.err)
+		assert.Equal(t, c.retryable, IsRetryable(c.err), "IsRetryable(%v)", c.err)
+		assert.Equal(t, c.network, IsNetworkError(c.err), "IsNetworkError(%v)", c.err)
+	}
+}
+
+func TestOAuth2Error_Is(t *testing.T) {
+	invalidGrant := newOAuth2Error("oauth2: cannot fetch token", &http.Response{
+		Status:     "400 Bad Request",
+		StatusCode: http.StatusBadRequest,
+	}, []byte(`{"error": "invalid_grant", "error_uri": "https://example.com/errors/invalid_grant"}`))
+	assert.Equal(t, "https://example.com/errors/invalid_grant", invalidGrant.URI)
+
+	// Methods used by errors.Is and errors.As, see errors_go113_test.go.
+	assert.True(t, invalidGrant.Is(ErrInvalidGrant))
+	assert.False(t, invalidGrant.Is(ErrInvalidClient))
+	assert.True(t, invalidGrant.Is(invalidGrant))
+	assert.False(t, (&OAuth2Error{HTTPStatus: http.StatusBadRequest}).Is(ErrInvalidRequest), "error without code should not match")
+
+	wrapped := wrapErrorf(invalidGrant, "failed to refresh: %v", invalidGrant)
+	assert.Equal(t, invalidGrant, wrapped.(*wrappedError).Unwrap())
+	network := &NetworkError{Err: context.Canceled}
+	assert.Equal(t, context.Canceled, network.Unwrap())
+}
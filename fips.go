@@ -0,0 +1,153 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"sync/atomic"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// minFIPSRSAKeyBits is minimum RSA modulus size allowed by FIPS 186-4 for signatures and by SP 800-56B for key
+// transport.
+const minFIPSRSAKeyBits = 2048
+
+// fipsApprovedSigningAlgs are JWS algorithms built on FIPS-approved primitives only. HMAC with SHA-2 is approved by
+// FIPS 198-1, so client_secret_jwt and HMAC signed ID tokens are allowed.
+var fipsApprovedSigningAlgs = map[string]struct{}{
+	string(jose.HS256): {},
+	string(jose.HS384): {},
+	string(jose.HS512): {},
+	string(jose.RS256): {},
+	string(jose.RS384): {},
+	string(jose.RS512): {},
+	string(jose.PS256): {},
+	string(jose.PS384): {},
+	string(jose.PS512): {},
+	string(jose.ES256): {},
+	string(jose.ES384): {},
+	string(jose.ES512): {},
+}
+
+// fipsApprovedEncryptionAlgs are JWE key management and content encryption algorithms built on FIPS-approved
+// primitives only. RSA-OAEP is not included, since it uses SHA-1.
+var fipsApprovedEncryptionAlgs = map[string]struct{}{
+	string(jose.RSA_OAEP_256):   {},
+	string(jose.ECDH_ES):        {},
+	string(jose.ECDH_ES_A128KW): {},
+	string(jose.ECDH_ES_A256KW): {},
+	string(jose.A128GCM):        {},
+	string(jose.A256GCM):        {},
+}
+
+// fipsMode is 1 if FIPS mode is enabled.
+var fipsMode int32
+
+func init() {
+	if fipsBuild {
+		fipsMode = 1
+	}
+}
+
+// SetFIPSMode enables or disables FIPS mode for whole process. In FIPS mode only FIPS-approved algorithms
+// and key sizes are used and accepted, everything else fails with FIPSError.
+// FIPS mode is always enabled (and cannot be disabled) when built with boringcrypto.
+func SetFIPSMode(enabled bool) {
+	if fipsBuild {
+		return
+	}
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&fipsMode, v)
+}
+
+// FIPSMode returns true if FIPS mode is enabled.
+func FIPSMode() bool {
+	return atomic.LoadInt32(&fipsMode) == 1
+}
+
+// FIPSError is returned in FIPS mode when provider or configuration requires algorithm or key that is not FIPS-approved.
+type FIPSError struct {
+	// Algorithm is the disallowed algorithm or key type.
+	Algorithm string
+	Reason    string
+}
+
+func (e *FIPSError) Error() string {
+	return fmt.Sprintf("oidc: %s is not allowed in FIPS mode: %s", e.Algorithm, e.Reason)
+}
+
+// checkFIPSSigningAlg returns error if FIPS mode is enabled and given JWS algorithm is not approved.
+func checkFIPSSigningAlg(alg string) error {
+	if !FIPSMode() {
+		return nil
+	}
+	if _, ok := fipsApprovedSigningAlgs[alg]; !ok {
+		return &FIPSError{Algorithm: alg, Reason: "not a FIPS-approved signature algorithm"}
+	}
+	return nil
+}
+
+// checkFIPSVerificationKey returns error if FIPS mode is enabled and given public key is not allowed.
+func checkFIPSVerificationKey(key interface{}) error {
+	if !FIPSMode() {
+		return nil
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minFIPSRSAKeyBits {
+			return &FIPSError{
+				Algorithm: fmt.Sprintf("RSA-%d", k.N.BitLen()),
+				Reason:    fmt.Sprintf("RSA keys need at least %d bits", minFIPSRSAKeyBits),
+			}
+		}
+	case *ecdsa.PublicKey:
+		// All curves supported by JWS (P-256, P-384, P-521) are approved.
+	default:
+		return &FIPSError{Algorithm: fmt.Sprintf("%T", key), Reason: "not a FIPS-approved key type"}
+	}
+	return nil
+}
+
+// checkFIPSPrivateKey returns error if FIPS mode is enabled and given signing or decryption key is not allowed.
+func checkFIPSPrivateKey(key interface{}) error {
+	if !FIPSMode() {
+		return nil
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return checkFIPSVerificationKey(&k.PublicKey)
+	case *ecdsa.PrivateKey:
+		return nil
+	case []byte:
+		// HMAC key, e.g client secret.
+		return nil
+	}
+	return &FIPSError{Algorithm: fmt.Sprintf("%T", key), Reason: "not a FIPS-approved key type"}
+}
+
+// checkFIPSEncryptionAlg returns error if FIPS mode is enabled and given JWE algorithm is not approved.
+func checkFIPSEncryptionAlg(alg string) error {
+	if !FIPSMode() {
+		return nil
+	}
+	if _, ok := fipsApprovedEncryptionAlgs[alg]; !ok {
+		return &FIPSError{Algorithm: alg, Reason: "not a FIPS-approved encryption algorithm"}
+	}
+	return nil
+}
+
+// newSigner returns JWS signer for key, checking algorithm and key in FIPS mode. All JWTs signed by the client (JWT
+// assertions, client_secret_jwt and DPoP proofs) need to be signed with it.
+func newSigner(key jose.SigningKey, opts *jose.SignerOptions) (jose.Signer, error) {
+	if err := checkFIPSSigningAlg(string(key.Algorithm)); err != nil {
+		return nil, err
+	}
+	if err := checkFIPSPrivateKey(key.Key); err != nil {
+		return nil, err
+	}
+	return jose.NewSigner(key, opts)
+}
@@ -0,0 +1,7 @@
+//go:build boringcrypto
+// +build boringcrypto
+
+package oidc
+
+// fipsBuild is true, because we are built with BoringCrypto, so FIPS mode needs to be always enabled.
+const fipsBuild = true
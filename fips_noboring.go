@@ -0,0 +1,7 @@
+//go:build !boringcrypto
+// +build !boringcrypto
+
+package oidc
+
+// fipsBuild is false, so FIPS mode is enabled only using SetFIPSMode.
+const fipsBuild = false
@@ -0,0 +1,69 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func TestFIPSChecks(t *testing.T) {
+	if fipsBuild {
+		t.Skip("FIPS mode cannot be disabled in boringcrypto build")
+	}
+	defer SetFIPSMode(false)
+
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	SetFIPSMode(false)
+	assert.NoError(t, checkFIPSSigningAlg("EdDSA"))
+	assert.NoError(t, checkFIPSVerificationKey(&weakKey.PublicKey))
+
+	SetFIPSMode(true)
+	assert.True(t, FIPSMode())
+	assert.NoError(t, checkFIPSSigningAlg("RS256"))
+	assert.NoError(t, checkFIPSSigningAlg("ES384"))
+
+	err = checkFIPSSigningAlg("EdDSA")
+	require.Error(t, err)
+	_, ok := err.(*FIPSError)
+	assert.True(t, ok)
+
+	assert.Error(t, checkFIPSVerificationKey(&weakKey.PublicKey))
+	assert.Error(t, checkFIPSVerificationKey([]byte("secret")))
+
+	// HMAC is approved for both verification and client_secret_jwt signing.
+	assert.NoError(t, checkFIPSSigningAlg("HS256"))
+	_, err = newSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")}, nil)
+	assert.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = newSigner(jose.SigningKey{Algorithm: jose.ES256, Key: ecKey}, nil)
+	assert.NoError(t, err)
+
+	_, err = newSigner(jose.SigningKey{Algorithm: jose.RS256, Key: weakKey}, nil)
+	require.Error(t, err)
+	_, ok = err.(*FIPSError)
+	assert.True(t, ok)
+
+	assert.NoError(t, checkFIPSEncryptionAlg("RSA-OAEP-256"))
+	assert.NoError(t, checkFIPSEncryptionAlg("A256GCM"))
+	assert.Error(t, checkFIPSEncryptionAlg("RSA-OAEP"))
+	assert.Error(t, checkFIPSPrivateKey(weakKey))
+
+	p := PrivateKeyJWT{Key: ecKey, Certificate: &x509.Certificate{Raw: []byte("cert")}}
+	err = p.Authenticate(Config{ClientID: "client"}, "https://issuer.example.com/token", url.Values{}, http.Header{})
+	require.Error(t, err)
+	_, ok = err.(*FIPSError)
+	assert.True(t, ok)
+}
@@ -70,11 +70,16 @@ func NewOIDCTokenSource(ctx context.Context, logger *log.Logger, googleServiceAc
 // No refresh token will be returned, because this is token source is only service Accounts and we don't need login for that anyway.
 // No caching is in place. We base for reuse token source to cache valid tokens in memory.
 func (s *OIDCTokenSource) OIDCToken() (*oidc.Token, error) {
+	return s.OIDCTokenCtx(context.TODO())
+}
+
+// OIDCTokenCtx is like OIDCToken, but the exchange request is limited by ctx as well.
+func (s *OIDCTokenSource) OIDCTokenCtx(ctx context.Context) (*oidc.Token, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Our request for access token was denied, either we had no RefreshToken, it was invalid or expired.
-	newToken, err := s.newToken()
+	newToken, err := s.newToken(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to obtain new token.")
 	}
@@ -91,10 +96,10 @@ func (s *OIDCTokenSource) Verifier() oidc.Verifier {
 }
 
 // newToken calls URL to Provider token endpoint with special grant_type "service_account" to exchange Google SA for ID token.
-func (s *OIDCTokenSource) newToken() (*oidc.Token, error) {
+func (s *OIDCTokenSource) newToken(ctx context.Context) (*oidc.Token, error) {
 	s.logger.Print("Debug: Exchanging SA JWT for IDToken")
 
-	ctx, cancel := context.WithTimeout(context.TODO(), exchangeServiceAccountTimeout)
+	ctx, cancel := context.WithTimeout(ctx, exchangeServiceAccountTimeout)
 	defer cancel()
 
 	var extra []url.Values
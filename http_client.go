@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultHTTPTimeout limits every request to the provider made by HTTP client created by NewHTTPClient (including
+// the default one used when no HTTP client is given), so unresponsive provider does not block callers forever.
+var DefaultHTTPTimeout = 30 * time.Second
+
+// HTTPClientConfig configures HTTP client created by NewHTTPClient. Zero value gives default client.
+type HTTPClientConfig struct {
+	// Timeout limits whole request, including reading the response body. Defaults to DefaultHTTPTimeout.
+	Timeout time.Duration
+	// TLSConfig is used for connections to the provider, e.g with custom RootCAs for providers using private CA.
+	TLSConfig *tls.Config
+	// Proxy returns proxy for given request, e.g http.ProxyURL(proxyURL). Defaults to http.ProxyFromEnvironment.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// NewHTTPClient returns HTTP client with default transport settings changed by cfg. Pass it to WithHTTPClient:
+//
+//	client, err := oidc.NewClient(ctx, issuer, oidc.WithHTTPClient(oidc.NewHTTPClient(oidc.HTTPClientConfig{
+//	    Timeout:   10 * time.Second,
+//	    TLSConfig: &tls.Config{RootCAs: pool},
+//	})))
+func NewHTTPClient(cfg HTTPClientConfig) *http.Client {
+	timeout := DefaultHTTPTimeout
+	if cfg.Timeout > 0 {
+		timeout = cfg.Timeout
+	}
+	proxy := http.ProxyFromEnvironment
+	if cfg.Proxy != nil {
+		proxy = cfg.Proxy
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+				DualStack: true,
+			}).DialContext,
+			TLSClientConfig:       cfg.TLSConfig,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
+// WithTLSConfig sets TLS config used for all connections to the provider (discovery, keys, token etc), e.g with
+// RootCAs from LoadCACertPool for providers with private CA or behind TLS intercepting proxy. It is equivalent to
+// WithHTTPClient(NewHTTPClient(HTTPClientConfig{TLSConfig: cfg})), so it replaces HTTP client given by previous
+// options.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return WithHTTPClient(NewHTTPClient(HTTPClientConfig{TLSConfig: cfg}))
+}
+
+// LoadCACertPool returns system certificate pool with PEM encoded CA certificates from given files added, so both
+// public and private CAs are trusted. If system pool is not available (e.g on Windows), only given certificates are
+// trusted.
+func LoadCACertPool(caFiles ...string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, f := range caFiles {
+		pemData, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to read CA file: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("oidc: no PEM certificates in CA file %s", f)
+		}
+	}
+	return pool, nil
+}
@@ -0,0 +1,69 @@
+package oidc
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	client := NewHTTPClient(HTTPClientConfig{})
+	assert.Equal(t, DefaultHTTPTimeout, client.Timeout)
+	transport := client.Transport.(*http.Transport)
+	assert.Nil(t, transport.TLSClientConfig)
+
+	proxyURL, err := url.Parse("http://proxy.example.com:3128")
+	require.NoError(t, err)
+	tlsConfig := &tls.Config{ServerName: "issuer-oidc.org"}
+	client = NewHTTPClient(HTTPClientConfig{
+		Timeout:   5 * time.Second,
+		TLSConfig: tlsConfig,
+		Proxy:     http.ProxyURL(proxyURL),
+	})
+	assert.Equal(t, 5*time.Second, client.Timeout)
+	transport = client.Transport.(*http.Transport)
+	assert.Equal(t, tlsConfig, transport.TLSClientConfig)
+
+	req, err := http.NewRequest("GET", exampleIssuer, nil)
+	require.NoError(t, err)
+	proxy, err := transport.Proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, proxyURL, proxy)
+}
+
+func TestLoadCACertPool(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "oidc-ca")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600))
+
+	_, err = LoadCACertPool(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+
+	// Server's certificate is trusted only with its CA added.
+	_, err = NewHTTPClient(HTTPClientConfig{}).Get(srv.URL)
+	assert.Error(t, err)
+
+	pool, err := LoadCACertPool(caFile)
+	require.NoError(t, err)
+	var o options
+	WithTLSConfig(&tls.Config{RootCAs: pool})(&o)
+	resp, err := o.httpClient.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,131 @@
+package oidc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Identity is a provider-agnostic representation of the authenticated user.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+
+	// Extra contains all claims that were not mapped to any of the fields above.
+	Extra map[string]interface{}
+}
+
+// ClaimsMapping specifies claim names used to build Identity. Names can point to nested claims using dots,
+// e.g "realm_access.roles". Empty name means the field is not mapped.
+type ClaimsMapping struct {
+	Subject       string
+	Email         string
+	EmailVerified string
+	Name          string
+	// Groups claim can be either a list of strings or a single string.
+	Groups string
+}
+
+// DefaultClaimsMapping maps standard OpenID Connect claims. Groups claim is not standard, but "groups" is used by most
+// providers.
+var DefaultClaimsMapping = ClaimsMapping{
+	Subject:       "sub",
+	Email:         "email",
+	EmailVerified: "email_verified",
+	Name:          "name",
+	Groups:        "groups",
+}
+
+// Identity builds Identity from given ID token using the mapping.
+func (m ClaimsMapping) Identity(idToken *IDToken) (*Identity, error) {
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	return m.IdentityFromClaims(claims)
+}
+
+// IdentityFromClaims builds Identity from given decoded claims using the mapping.
+func (m ClaimsMapping) IdentityFromClaims(claims map[string]interface{}) (*Identity, error) {
+	extra := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		extra[k] = v
+	}
+
+	i := &Identity{Extra: extra}
+	for _, field := range []struct {
+		claim string
+		set   func(v interface{}) bool
+	}{
+		{claim: m.Subject, set: func(v interface{}) bool { s, ok := v.(string); i.Subject = s; return ok }},
+		{claim: m.Email, set: func(v interface{}) bool { s, ok := v.(string); i.Email = s; return ok }},
+		{claim: m.EmailVerified, set: func(v interface{}) bool { b, ok := parseBoolClaim(v); i.EmailVerified = b; return ok }},
+		{claim: m.Name, set: func(v interface{}) bool { s, ok := v.(string); i.Name = s; return ok }},
+		{claim: m.Groups, set: func(v interface{}) bool { g, ok := parseGroupsClaim(v); i.Groups = g; return ok }},
+	} {
+		if field.claim == "" {
+			continue
+		}
+		v, ok := lookupClaim(claims, field.claim)
+		if !ok {
+			continue
+		}
+		if !field.set(v) {
+			return nil, fmt.Errorf("oidc: unexpected type of %q claim: %T", field.claim, v)
+		}
+		// Top-level mapped claims are not extra anymore. Nested ones stay, since their parent can hold more.
+		delete(extra, field.claim)
+	}
+	return i, nil
+}
+
+func lookupClaim(claims map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := claims[name]; ok {
+		return v, true
+	}
+
+	path := strings.Split(name, ".")
+	var current interface{} = claims
+	for _, p := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[p]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func parseBoolClaim(v interface{}) (bool, bool) {
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case string:
+		// Some providers (e.g AWS Cognito) return booleans as strings.
+		return b == "true", b == "true" || b == "false"
+	}
+	return false, false
+}
+
+func parseGroupsClaim(v interface{}) ([]string, bool) {
+	switch g := v.(type) {
+	case string:
+		return []string{g}, true
+	case []interface{}:
+		groups := make([]string, 0, len(g))
+		for _, e := range g {
+			s, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+			groups = append(groups, s)
+		}
+		return groups, true
+	}
+	return nil, false
+}
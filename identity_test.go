@@ -0,0 +1,44 @@
+package oidc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimsMapping_Identity(t *testing.T) {
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"sub": "subject1",
+		"email": "user@example.com",
+		"email_verified": "true",
+		"name": "User",
+		"realm_access": {"roles": ["admin", "dev"]},
+		"custom": 1
+	}`), &claims))
+
+	identity, err := DefaultClaimsMapping.IdentityFromClaims(claims)
+	require.NoError(t, err)
+	assert.Equal(t, "subject1", identity.Subject)
+	assert.Equal(t, "user@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, "User", identity.Name)
+	assert.Empty(t, identity.Groups)
+	assert.Equal(t, map[string]interface{}{
+		"realm_access": map[string]interface{}{"roles": []interface{}{"admin", "dev"}},
+		"custom":       float64(1),
+	}, identity.Extra)
+
+	keycloak := DefaultClaimsMapping
+	keycloak.Groups = "realm_access.roles"
+	identity, err = keycloak.IdentityFromClaims(claims)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin", "dev"}, identity.Groups)
+
+	invalid := DefaultClaimsMapping
+	invalid.Name = "custom"
+	_, err = invalid.IdentityFromClaims(claims)
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Token type hints for Introspect and Revoke. See https://tools.ietf.org/html/rfc7009#section-2.1.
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// IntrospectionResponse is the response of the token introspection endpoint. See
+// https://tools.ietf.org/html/rfc7662#section-2.2. All fields except Active are optional.
+type IntrospectionResponse struct {
+	// Active is true if the token is currently valid. Inactive token might be expired, revoked or unknown to
+	// the provider, but an error is not returned in that case.
+	Active    bool        `json:"active"`
+	Scope     string      `json:"scope,omitempty"`
+	ClientID  string      `json:"client_id,omitempty"`
+	Username  string      `json:"username,omitempty"`
+	TokenType string      `json:"token_type,omitempty"`
+	Expiry    NumericDate `json:"exp,omitempty"`
+	IssuedAt  NumericDate `json:"iat,omitempty"`
+	NotBefore NumericDate `json:"nbf,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  Audience    `json:"aud,omitempty"`
+	Issuer    string      `json:"iss,omitempty"`
+	JWTID     string      `json:"jti,omitempty"`
+
+	// Raw response.
+	claims []byte
+}
+
+// Claims unmarshals the raw JSON introspection response into a provided struct, e.g for provider specific fields.
+func (i *IntrospectionResponse) Claims(v interface{}) error {
+	if i.claims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return json.Unmarshal(i.claims, v)
+}
+
+// Scopes returns granted scopes as a list.
+func (i *IntrospectionResponse) Scopes() []string {
+	return strings.Fields(i.Scope)
+}
+
+// Introspect asks the provider about state of given token (see https://tools.ietf.org/html/rfc7662), e.g to validate
+// opaque access tokens in resource servers. tokenTypeHint is optional and can be TokenTypeHintAccessToken or
+// TokenTypeHintRefreshToken. cfg authenticates the resource server to the provider.
+//
+// Inactive tokens are not an error: always check the Active field of the response.
+func (c *Client) Introspect(ctx context.Context, cfg Config, token string, tokenTypeHint string) (*IntrospectionResponse, error) {
+	discovery := c.Discovery()
+	if discovery.IntrospectionURL == "" {
+		return nil, errors.New("oidc: introspection endpoint is not supported by this provider")
+	}
+
+	v := url.Values{"token": {token}}
+	if tokenTypeHint != "" {
+		v.Set("token_type_hint", tokenTypeHint)
+	}
+	req, err := c.newClientAuthRequest(discovery.IntrospectionURL, mtlsIntrospectionURL, cfg, v)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	r, err := c.doClientAuthRequest(ctx, RequestIntrospection, req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, wrapErrorf(&NetworkError{Err: err}, "oidc: cannot introspect token: %v", err)
+	}
+	if code := r.StatusCode; code < 200 || code > 299 {
+		return nil, newOAuth2Error("oidc: cannot introspect token", r, body)
+	}
+
+	resp := &IntrospectionResponse{claims: body}
+	if err := json.Unmarshal(body, resp); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode introspection response: %v", err)
+	}
+	return resp, nil
+}
+
+// IsActive returns true if the token is active and not expired according to the response. It guards against
+// providers that report expired tokens as active.
+func (i *IntrospectionResponse) IsActive() bool {
+	if !i.Active {
+		return false
+	}
+	return i.Expiry == 0 || time.Now().Before(i.Expiry.Time())
+}
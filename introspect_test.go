@@ -0,0 +1,72 @@
+package oidc
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestIntrospect() {
+	exp := time.Now().Add(1 * time.Hour).Unix()
+
+	var form url.Values
+	var user, password string
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal(testDiscovery.IntrospectionURL, r.URL.String())
+		s.NoError(r.ParseForm())
+		form = r.PostForm
+		user, password, _ = r.BasicAuth()
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{
+			"active": true,
+			"scope": "openid email",
+			"client_id": "client2",
+			"sub": "user1",
+			"aud": "api1",
+			"exp": `+strconv.FormatInt(exp, 10)+`,
+			"custom": "value1"
+		}`))(r)
+	})
+
+	resp, err := s.client.Introspect(s.testCtx, Config{ClientID: "api1", ClientSecret: "secret1"}, "access1", TokenTypeHintAccessToken)
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Equal("access1", form.Get("token"))
+	s.Equal(TokenTypeHintAccessToken, form.Get("token_type_hint"))
+	s.Equal("api1", user)
+	s.Equal("secret1", password)
+
+	s.True(resp.Active)
+	s.Equal([]string{"openid", "email"}, resp.Scopes())
+	s.Equal("client2", resp.ClientID)
+	s.Equal("user1", resp.Subject)
+	s.Equal(Audience{"api1"}, resp.Audience)
+
+	var claims struct {
+		Custom string `json:"custom"`
+	}
+	s.NoError(resp.Claims(&claims))
+	s.Equal("value1", claims.Custom)
+}
+
+func (s *ClientTestSuite) TestIntrospect_Inactive() {
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{"active": false}`)))
+
+	resp, err := s.client.Introspect(s.testCtx, Config{ClientID: "api1"}, "access1", "")
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+	s.False(resp.Active)
+	s.False(resp.IsActive())
+}
+
+func (s *ClientTestSuite) TestIntrospect_ExpiredButActive() {
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{"active": true, "exp": 1}`)))
+
+	resp, err := s.client.Introspect(s.testCtx, Config{ClientID: "api1"}, "access1", "")
+	s.Require().NoError(err)
+	s.True(resp.Active)
+	s.False(resp.IsActive())
+}
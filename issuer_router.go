@@ -0,0 +1,186 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// UnknownIssuerError is returned by IssuerRouter when token was issued by issuer that is not configured.
+type UnknownIssuerError struct {
+	Issuer string
+}
+
+func (e *UnknownIssuerError) Error() string {
+	return fmt.Sprintf("oidc: token issued by unknown issuer %q", e.Issuer)
+}
+
+// ClaimsPolicy checks claims of verified token. It returns error if token should be rejected.
+type ClaimsPolicy func(claims map[string]interface{}) error
+
+// RequireClaim returns ClaimsPolicy that requires claim to be equal to one of values. If claim is a list, one of its
+// elements needs to be equal to one of values.
+func RequireClaim(name string, values ...string) ClaimsPolicy {
+	return func(claims map[string]interface{}) error {
+		switch v := claims[name].(type) {
+		case string:
+			if contains(values, v) {
+				return nil
+			}
+		case []interface{}:
+			for _, e := range v {
+				if s, ok := e.(string); ok && contains(values, s) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("oidc: claim %q does not match any of required values %q", name, values)
+	}
+}
+
+type issuerRoute struct {
+	verifier Verifier
+	policies []ClaimsPolicy
+}
+
+// IssuerRouter is a Verifier that accepts tokens from many issuers. It selects verifier configured for the "iss" claim
+// of the token, verifies the token with it and applies issuer's claims policies. Tokens from unknown issuers are
+// rejected with UnknownIssuerError. IssuerRouter is safe for concurrent use.
+type IssuerRouter struct {
+	mu     sync.RWMutex
+	routes map[string]issuerRoute
+}
+
+// NewIssuerRouter constructs empty IssuerRouter.
+func NewIssuerRouter() *IssuerRouter {
+	return &IssuerRouter{routes: map[string]issuerRoute{}}
+}
+
+// Add configures verifier and claims policies for given issuer. Verifier decides about audience and other
+// verification rules, e.g Client.Verifier(VerificationConfig{ClientID: "api"}). Add replaces previous route for
+// the same issuer.
+func (r *IssuerRouter) Add(issuer string, verifier Verifier, policies ...ClaimsPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[issuer] = issuerRoute{verifier: verifier, policies: policies}
+}
+
+// Remove removes route for given issuer.
+func (r *IssuerRouter) Remove(issuer string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.routes, issuer)
+}
+
+// route returns route for unverified issuer of the token. It is only used to pick the verifier; the verifier checks
+// the issuer again.
+func (r *IssuerRouter) route(rawJWT string) (issuerRoute, error) {
+	payload, err := parseJWT(rawJWT)
+	if err != nil {
+		return issuerRoute{}, err
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return issuerRoute{}, fmt.Errorf("oidc: failed to unmarshal claims: %v", err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	route, ok := r.routes[claims.Issuer]
+	if !ok {
+		return issuerRoute{}, &UnknownIssuerError{Issuer: claims.Issuer}
+	}
+	return route, nil
+}
+
+func (route issuerRoute) check(claimsFn func(v interface{}) error) error {
+	if len(route.policies) == 0 {
+		return nil
+	}
+	claims := map[string]interface{}{}
+	if err := claimsFn(&claims); err != nil {
+		return err
+	}
+	for _, p := range route.policies {
+		if err := p(claims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify is equivalent to VerifyIDToken.
+func (r *IssuerRouter) Verify(ctx context.Context, rawIDToken string) (*IDToken, error) {
+	return r.VerifyIDToken(ctx, rawIDToken)
+}
+
+// VerifyIDToken verifies ID token using verifier of its issuer.
+func (r *IssuerRouter) VerifyIDToken(ctx context.Context, rawIDToken string) (*IDToken, error) {
+	route, err := r.route(rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	token, err := route.verifier.VerifyIDToken(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := route.check(token.Claims); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// VerifyAccessToken verifies JWT access token using verifier of its issuer.
+func (r *IssuerRouter) VerifyAccessToken(ctx context.Context, rawAccessToken string) (*AccessToken, error) {
+	route, err := r.route(rawAccessToken)
+	if err != nil {
+		return nil, err
+	}
+	token, err := route.verifier.VerifyAccessToken(ctx, rawAccessToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := route.check(token.Claims); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// VerifyLogoutToken verifies logout token using verifier of its issuer.
+func (r *IssuerRouter) VerifyLogoutToken(ctx context.Context, rawLogoutToken string) (*LogoutToken, error) {
+	route, err := r.route(rawLogoutToken)
+	if err != nil {
+		return nil, err
+	}
+	token, err := route.verifier.VerifyLogoutToken(ctx, rawLogoutToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := route.check(token.Claims); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// VerifyUserInfo verifies signed user info using verifier of its issuer. User info without "iss" claim is rejected,
+// since its issuer can't be determined.
+func (r *IssuerRouter) VerifyUserInfo(ctx context.Context, rawUserInfo string) (*UserInfo, error) {
+	route, err := r.route(rawUserInfo)
+	if err != nil {
+		return nil, err
+	}
+	userInfo, err := route.verifier.VerifyUserInfo(ctx, rawUserInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err := route.check(userInfo.Claims); err != nil {
+		return nil, err
+	}
+	return userInfo, nil
+}
@@ -0,0 +1,44 @@
+package oidc
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestIssuerRouter() {
+	router := NewIssuerRouter()
+
+	idToken, jwkSetJSON := s.validIDToken()
+	_, err := router.Verify(s.testCtx, idToken)
+	s.Equal(&UnknownIssuerError{Issuer: exampleIssuer}, err)
+
+	router.Add(exampleIssuer, s.client.Verifier(VerificationConfig{ClientID: "client1"}), RequireClaim("nonce", "nonce1"))
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	token, err := router.Verify(s.testCtx, idToken)
+	s.Require().NoError(err)
+	s.Equal("subject1", token.Subject)
+
+	// Policy violation.
+	router.Add(exampleIssuer, s.client.Verifier(VerificationConfig{ClientID: "client1"}), RequireClaim("groups", "admin"))
+	_, err = router.Verify(s.testCtx, idToken)
+	s.Error(err)
+
+	accessToken, jwkSetJSON := s.signedJWT(map[string]interface{}{
+		"iss":    exampleIssuer,
+		"aud":    "client1",
+		"sub":    "subject1",
+		"exp":    time.Now().Add(1 * time.Hour).Unix(),
+		"groups": []string{"dev", "admin"},
+	})
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = router.VerifyAccessToken(s.testCtx, accessToken)
+	s.NoError(err)
+
+	router.Remove(exampleIssuer)
+	_, err = router.VerifyAccessToken(s.testCtx, accessToken)
+	s.Equal(&UnknownIssuerError{Issuer: exampleIssuer}, err)
+
+	s.Equal(0, s.s.Len())
+}
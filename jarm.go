@@ -0,0 +1,73 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ResponseModeJWT makes provider return authorization response parameters inside a signed JWT (JARM, see
+// https://openid.net/specs/oauth-v2-jarm.html), so response can't be tampered with on its way through the browser.
+const ResponseModeJWT = "jwt"
+
+// WithResponseMode sets response_mode of the authorization request, e.g ResponseModeJWT.
+func WithResponseMode(mode string) AuthCodeOption {
+	return func(v url.Values) {
+		v.Set("response_mode", mode)
+	}
+}
+
+// AuthorizationResponse is the verified JWT-secured authorization response. Either Code or Error is set.
+type AuthorizationResponse struct {
+	Issuer string `json:"iss"`
+	State  string `json:"state"`
+	Code   string `json:"code,omitempty"`
+
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	ErrorURI         string `json:"error_uri,omitempty"`
+
+	// Raw payload of the response JWT.
+	claims []byte
+}
+
+// Claims unmarshals the raw JSON payload of the response JWT into a provided struct.
+func (r *AuthorizationResponse) Claims(v interface{}) error {
+	if r.claims == nil {
+		return errors.New("oidc: authorization response not signed by the provider")
+	}
+	return json.Unmarshal(r.claims, v)
+}
+
+var authorizationResponseRules = tokenRules{name: "authorization response"}
+
+// VerifyAuthorizationResponse parses JWT-secured authorization response (value of "response" callback parameter when
+// authorization request used ResponseModeJWT), verifies it's been signed by the provider, issued for Config.ClientID
+// and is not expired. It returns response parameters, e.g code and state, from its claims. Encrypted responses are
+// not supported.
+func (v *IDTokenVerifier) VerifyAuthorizationResponse(ctx context.Context, rawResponse string) (*AuthorizationResponse, error) {
+	resp, err := v.verifyAuthorizationResponse(ctx, rawResponse)
+	if err != nil {
+		v.auditFailure(rawResponse, err)
+	}
+	return resp, err
+}
+
+func (v *IDTokenVerifier) verifyAuthorizationResponse(ctx context.Context, rawResponse string) (*AuthorizationResponse, error) {
+	payload, err := v.verifyJWT(ctx, rawResponse, authorizationResponseRules)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp AuthorizationResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal authorization response: %v", err)
+	}
+	if resp.Code == "" && resp.Error == "" {
+		return nil, errors.New("oidc: authorization response includes neither code nor error")
+	}
+	resp.claims = payload
+	return &resp, nil
+}
@@ -0,0 +1,40 @@
+package oidc
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestVerifier_VerifyAuthorizationResponse() {
+	authURL, err := url.Parse(s.client.AuthCodeURLWithOptions(Config{ClientID: "client1"}, WithResponseMode(ResponseModeJWT)))
+	s.Require().NoError(err)
+	s.Equal(ResponseModeJWT, authURL.Query().Get("response_mode"))
+
+	rawResponse, jwkSetJSON := s.signedJWT(map[string]interface{}{
+		"iss":   exampleIssuer,
+		"aud":   "client1",
+		"exp":   time.Now().Add(10 * time.Minute).Unix(),
+		"state": "state1",
+		"code":  "code1",
+	})
+
+	// Response for other client is rejected before fetching keys.
+	_, err = s.client.Verifier(VerificationConfig{ClientID: "client2"}).VerifyAuthorizationResponse(s.testCtx, rawResponse)
+	s.Error(err)
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	resp, err := s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyAuthorizationResponse(s.testCtx, rawResponse)
+	s.Require().NoError(err)
+	s.Equal(exampleIssuer, resp.Issuer)
+	s.Equal("state1", resp.State)
+	s.Equal("code1", resp.Code)
+	s.Empty(resp.Error)
+	s.Equal(0, s.s.Len())
+
+	// Tampered response is rejected.
+	_, err = s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyAuthorizationResponse(s.testCtx, rawResponse[:len(rawResponse)-4]+"AAAA")
+	s.Error(err)
+}
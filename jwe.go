@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// supportedKeyEncryptionAlgs are JWE key management algorithms accepted for encrypted ID tokens. RSA1_5 is not
+// accepted, since it is vulnerable to padding oracle attacks.
+var supportedKeyEncryptionAlgs = []string{
+	string(jose.RSA_OAEP),
+	string(jose.RSA_OAEP_256),
+	string(jose.ECDH_ES),
+	string(jose.ECDH_ES_A128KW),
+	string(jose.ECDH_ES_A256KW),
+}
+
+// supportedContentEncryptionAlgs are JWE content encryption algorithms accepted for encrypted ID tokens.
+var supportedContentEncryptionAlgs = []string{
+	string(jose.A128GCM),
+	string(jose.A256GCM),
+}
+
+// isJWE returns true if raw token is in JWE compact serialization.
+func isJWE(rawToken string) bool {
+	return strings.Count(rawToken, ".") == 4
+}
+
+// decryptJWE decrypts ID token encrypted by the provider with client's public key and returns nested JWT, which
+// still needs signature verification. Tokens that are not encrypted are returned as they are.
+func (v *IDTokenVerifier) decryptJWE(rawToken string) (string, error) {
+	if !isJWE(rawToken) {
+		return rawToken, nil
+	}
+	if v.cfg.DecryptionKey == nil {
+		return "", errors.New("oidc: token is encrypted, but no DecryptionKey is configured")
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(rawToken[:strings.Index(rawToken, ".")])
+	if err != nil {
+		return "", fmt.Errorf("oidc: malformed jwe header: %v", err)
+	}
+	var header struct {
+		Algorithm  string `json:"alg"`
+		Encryption string `json:"enc"`
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return "", fmt.Errorf("oidc: malformed jwe header: %v", err)
+	}
+	if !contains(supportedKeyEncryptionAlgs, header.Algorithm) {
+		return "", fmt.Errorf("oidc: unsupported jwe key encryption algorithm, expected one of %q got %q", supportedKeyEncryptionAlgs, header.Algorithm)
+	}
+	if !contains(supportedContentEncryptionAlgs, header.Encryption) {
+		return "", fmt.Errorf("oidc: unsupported jwe content encryption algorithm, expected one of %q got %q", supportedContentEncryptionAlgs, header.Encryption)
+	}
+
+	for _, alg := range []string{header.Algorithm, header.Encryption} {
+		if err := checkFIPSEncryptionAlg(alg); err != nil {
+			return "", err
+		}
+	}
+	if err := checkFIPSPrivateKey(v.cfg.DecryptionKey); err != nil {
+		return "", err
+	}
+
+	jwe, err := jose.ParseEncrypted(rawToken)
+	if err != nil {
+		return "", fmt.Errorf("oidc: malformed jwe: %v", err)
+	}
+	nested, err := jwe.Decrypt(v.cfg.DecryptionKey)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to decrypt token: %v", err)
+	}
+	// Unsigned claims are not accepted, nested token needs to be JWS.
+	rawJWS := string(nested)
+	if strings.Count(rawJWS, ".") != 2 {
+		return "", errors.New("oidc: encrypted token does not contain signed JWT")
+	}
+	return rawJWS, nil
+}
@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func (s *ClientTestSuite) encryptedJWT(rawJWT string, alg jose.KeyAlgorithm, enc jose.ContentEncryption, key interface{}) string {
+	encrypter, err := jose.NewEncrypter(enc, jose.Recipient{Algorithm: alg, Key: key}, (&jose.EncrypterOptions{}).WithContentType("JWT"))
+	s.Require().NoError(err)
+	jwe, err := encrypter.Encrypt([]byte(rawJWT))
+	s.Require().NoError(err)
+	raw, err := jwe.CompactSerialize()
+	s.Require().NoError(err)
+	return raw
+}
+
+func (s *ClientTestSuite) TestVerifier_EncryptedIDToken() {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	for _, tcase := range []struct {
+		alg           jose.KeyAlgorithm
+		enc           jose.ContentEncryption
+		publicKey     interface{}
+		decryptionKey interface{}
+	}{
+		{alg: jose.RSA_OAEP, enc: jose.A128GCM, publicKey: &rsaKey.PublicKey, decryptionKey: rsaKey},
+		{alg: jose.RSA_OAEP_256, enc: jose.A256GCM, publicKey: &rsaKey.PublicKey, decryptionKey: rsaKey},
+		{alg: jose.ECDH_ES, enc: jose.A256GCM, publicKey: &ecKey.PublicKey, decryptionKey: ecKey},
+		{alg: jose.ECDH_ES_A128KW, enc: jose.A128GCM, publicKey: &ecKey.PublicKey, decryptionKey: ecKey},
+	} {
+		idToken, jwkSetJSON := s.signedJWT(map[string]interface{}{
+			"iss": exampleIssuer,
+			"aud": "client1",
+			"sub": "subject1",
+			"exp": time.Now().Add(1 * time.Hour).Unix(),
+		})
+		rawToken := s.encryptedJWT(idToken, tcase.alg, tcase.enc, tcase.publicKey)
+
+		_, err := s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyIDToken(s.testCtx, rawToken)
+		s.Error(err, "alg %s", tcase.alg)
+
+		s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+		token, err := s.client.Verifier(VerificationConfig{
+			ClientID:      "client1",
+			DecryptionKey: tcase.decryptionKey,
+		}).VerifyIDToken(s.testCtx, rawToken)
+		s.Require().NoError(err, "alg %s", tcase.alg)
+		s.Equal("subject1", token.Subject)
+		s.Equal(rawToken, token.Raw())
+		s.Equal(0, s.s.Len())
+	}
+}
+
+func (s *ClientTestSuite) TestVerifier_EncryptedIDToken_Rejected() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+
+	idToken, jwkSetJSON := s.signedJWT(map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	})
+	v := s.client.Verifier(VerificationConfig{ClientID: "client1", DecryptionKey: key})
+
+	// RSA1_5 is not accepted.
+	_, err = v.VerifyIDToken(s.testCtx, s.encryptedJWT(idToken, jose.RSA1_5, jose.A128GCM, &key.PublicKey))
+	s.Error(err)
+
+	// Encrypted for someone else.
+	_, err = v.VerifyIDToken(s.testCtx, s.encryptedJWT(idToken, jose.RSA_OAEP, jose.A128GCM, &otherKey.PublicKey))
+	s.Error(err)
+
+	// Result of verification with the right key is not reused for other key.
+	rawToken := s.encryptedJWT(idToken, jose.RSA_OAEP_256, jose.A128GCM, &key.PublicKey)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = v.VerifyIDToken(s.testCtx, rawToken)
+	s.Require().NoError(err)
+	_, err = s.client.Verifier(VerificationConfig{ClientID: "client1", DecryptionKey: otherKey}).VerifyIDToken(s.testCtx, rawToken)
+	s.Error(err)
+
+	// Unsigned claims are not accepted.
+	_, err = v.VerifyIDToken(s.testCtx, s.encryptedJWT(`{"iss":"`+exampleIssuer+`","aud":"client1"}`, jose.RSA_OAEP, jose.A128GCM, &key.PublicKey))
+	s.Error(err)
+	s.Equal(0, s.s.Len())
+}
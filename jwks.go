@@ -7,8 +7,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,57 +22,235 @@ type keySet interface {
 	Keys(ctx context.Context) ([]jose.JSONWebKey, error)
 }
 
-// DefaultKeySetExpiration specifies the time after which keys are expired and we need to refetch them.
+// DefaultKeySetExpiration specifies the time after which keys are expired and we need to refetch them, unless provider
+// specifies it with Cache-Control max-age directive of the JWKS response. Zero disables caching, so keys are fetched
+// for every verification.
 var DefaultKeySetExpiration = 30 * time.Second
 
+// DefaultKeySetMinRefreshInterval specifies minimum time between key fetches triggered by tokens signed with key that
+// is not in the cached key set, e.g after key rotation. It prevents tokens with random key IDs from flooding provider
+// with requests. See WithKeySetMinRefreshInterval.
+var DefaultKeySetMinRefreshInterval = 5 * time.Second
+
+// DefaultMaxKeySetKeys specifies maximum number of keys kept from single JWKS response. Keys above this number are
+// dropped (encryption keys first) with a warning logged, see limitKeys.
+var DefaultMaxKeySetKeys = 100
+
+// maxKeySetResponseSize limits the size of JWKS response we are willing to read.
+const maxKeySetResponseSize = 1 << 20
+
+// maxKeySetMaxAge caps key set lifetime advertised by provider, so rotated out keys are not trusted for too long.
+const maxKeySetMaxAge = 24 * time.Hour
+
+// keySetRefreshTimeout limits background key set refresh, which is not bound to any caller's context.
+const keySetRefreshTimeout = 1 * time.Minute
+
+// maxAgeKeySet is keySet that returns how long fetched keys can be cached, as specified by the server.
+type maxAgeKeySet interface {
+	// KeysWithMaxAge returns keys and their max age. If server did not specify it, ok is false.
+	KeysWithMaxAge(ctx context.Context) (keys []jose.JSONWebKey, maxAge time.Duration, ok bool, err error)
+}
+
+// keySetRefresher is keySet that refetches keys on demand, ignoring cache expiry.
+type keySetRefresher interface {
+	// Refresh returns refetched keys. It returns cached keys if they were fetched recently.
+	Refresh(ctx context.Context) ([]jose.JSONWebKey, error)
+}
+
+// cachedKeySet caches keys of parent keySet. Once keys are fetched, they are never refetched on the caller's path:
+// expired keys are still returned while they are refreshed in the background, so verification does not block on
+// JWKS request. Only tokens signed with unknown key trigger synchronous refresh, see Refresh.
 type cachedKeySet struct {
 	sync.Mutex
 
 	parent        keySet
 	expirationDur time.Duration
+	minRefreshDur time.Duration
 	timeNow       func() time.Time
 
-	keys   []jose.JSONWebKey
-	expiry time.Time
+	keys      []jose.JSONWebKey
+	fetchedAt time.Time
+	expiry    time.Time
+	// cached is false if keys should not be reused, since their lifetime is zero.
+	cached bool
+	// refreshing is true while background refresh is in progress.
+	refreshing bool
+
+	stats KeySetStats
+}
+
+// KeySetStats are counters of provider's key set fetches, e.g to be exported as metrics. Counters only grow.
+type KeySetStats struct {
+	// Fetches is the number of successful key set fetches, including the ones with unchanged keys.
+	Fetches uint64
+	// FetchFailures is the number of failed key set fetches.
+	FetchFailures uint64
+	// Rotations is the number of fetches that returned different set of key IDs than previously cached.
+	Rotations uint64
+	// UnknownKeyRefreshes is the number of fetches triggered by tokens signed with unknown key.
+	UnknownKeyRefreshes uint64
+	// RateLimitedRefreshes is the number of tokens signed with unknown key for which fetch was skipped, since keys
+	// were fetched less than minimum refresh interval ago.
+	RateLimitedRefreshes uint64
 }
 
-func newCachedKeySet(parent keySet, expirationTime time.Duration, now func() time.Time) keySet {
+func newCachedKeySet(parent keySet, expirationTime time.Duration, minRefreshInterval time.Duration, now func() time.Time) keySet {
 	if now == nil {
 		now = time.Now
 	}
-	return &cachedKeySet{parent: parent, expirationDur: expirationTime, timeNow: now}
+	return &cachedKeySet{
+		parent:        parent,
+		expirationDur: expirationTime,
+		minRefreshDur: minRefreshInterval,
+		timeNow:       now,
+	}
 }
 
-// Keys returns public Keys from cache or from parent keySet if expired.
+// Keys returns public Keys from cache. Keys are fetched from parent keySet only if there are no cached keys yet (or
+// caching is disabled). Expired keys are returned and refreshed in the background.
 func (r *cachedKeySet) Keys(ctx context.Context) ([]jose.JSONWebKey, error) {
 	r.Lock()
+	if !r.cached {
+		r.Unlock()
+		return r.fetch(ctx)
+	}
 	defer r.Unlock()
 
-	if r.timeNow().After(r.expiry) {
+	if r.timeNow().After(r.expiry) && !r.refreshing {
 		// Keys expired.
-		keys, err := r.parent.Keys(ctx)
-		if err != nil {
-			return nil, err
+		r.refreshing = true
+		go r.refreshInBackground(detachedContext{ctx})
+	}
+	return r.keys, nil
+}
+
+// Refresh refetches keys, unless they were fetched less than minimum refresh interval ago.
+func (r *cachedKeySet) Refresh(ctx context.Context) ([]jose.JSONWebKey, error) {
+	r.Lock()
+	if !r.fetchedAt.IsZero() && r.timeNow().Before(r.fetchedAt.Add(r.minRefreshDur)) {
+		defer r.Unlock()
+		r.stats.RateLimitedRefreshes++
+		return r.keys, nil
+	}
+	r.stats.UnknownKeyRefreshes++
+	r.Unlock()
+	return r.fetch(ctx)
+}
+
+// expire makes next Keys call refresh cached keys in the background, e.g after JWKS URL changed.
+func (r *cachedKeySet) expire() {
+	r.Lock()
+	defer r.Unlock()
+	r.expiry = time.Time{}
+}
+
+// Stats returns current counters of the key set.
+func (r *cachedKeySet) Stats() KeySetStats {
+	r.Lock()
+	defer r.Unlock()
+	return r.stats
+}
+
+func (r *cachedKeySet) refreshInBackground(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, keySetRefreshTimeout)
+	defer cancel()
+
+	_, err := r.fetch(ctx)
+
+	r.Lock()
+	defer r.Unlock()
+	r.refreshing = false
+	if err != nil {
+		// Keep serving old keys, but don't retry on every call.
+		r.expiry = r.timeNow().Add(r.minRefreshDur)
+	}
+}
+
+// fetch gets keys from parent keySet and caches them. Lifetime of keys is taken from the server if specified,
+// otherwise it is expiration time of cachedKeySet. Lock is not held while fetching, so cached keys can be used in the
+// meantime. Concurrent fetches are deduplicated by remoteKeySet.
+func (r *cachedKeySet) fetch(ctx context.Context) ([]jose.JSONWebKey, error) {
+	var (
+		keys   []jose.JSONWebKey
+		maxAge = r.expirationDur
+		err    error
+	)
+	if p, ok := r.parent.(maxAgeKeySet); ok {
+		var (
+			serverMaxAge time.Duration
+			hasMaxAge    bool
+		)
+		keys, serverMaxAge, hasMaxAge, err = p.KeysWithMaxAge(ctx)
+		if hasMaxAge {
+			maxAge = serverMaxAge
 		}
+	} else {
+		keys, err = r.parent.Keys(ctx)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if err != nil {
+		r.stats.FetchFailures++
+		return nil, err
+	}
 
-		r.keys = keys
-		r.expiry = r.timeNow().Add(r.expirationDur)
+	r.stats.Fetches++
+	if !r.fetchedAt.IsZero() && !sameKeyIDs(r.keys, keys) {
+		r.stats.Rotations++
 	}
+	r.keys = keys
+	r.fetchedAt = r.timeNow()
+	r.expiry = r.fetchedAt.Add(maxAge)
+	r.cached = maxAge > 0
+	return keys, nil
+}
 
-	return r.keys, nil
+// sameKeyIDs returns true if both key sets have the same key IDs.
+func sameKeyIDs(a, b []jose.JSONWebKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := make(map[string]int, len(a))
+	for _, k := range a {
+		ids[k.KeyID]++
+	}
+	for _, k := range b {
+		if ids[k.KeyID] == 0 {
+			return false
+		}
+		ids[k.KeyID]--
+	}
+	return true
+}
 
+// detachedContext keeps values of the parent context (e.g HTTP client), but is not canceled with it. It is used for
+// background work started on behalf of the caller that should outlive its request.
+type detachedContext struct {
+	parent context.Context
 }
 
-func newRemoteKeySet(jwksURL string) keySet {
-	return &remoteKeySet{jwksURL: jwksURL}
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+func newRemoteKeySet(jwksURL string, httpClient *http.Client) *remoteKeySet {
+	return &remoteKeySet{jwksURL: jwksURL, httpClient: httpClient, logger: nopLogger{}, maxKeys: DefaultMaxKeySetKeys}
 }
 
 type remoteKeySet struct {
-	jwksURL string
+	// httpClient is optional, see doRequest.
+	httpClient  *http.Client
+	requestHook RequestHook
+	logger      Logger
+	maxKeys     int
 
 	// guard all other fields
 	mutex sync.Mutex
 
+	// jwksURL can change when provider's discovery document is refreshed, see setURL.
+	jwksURL string
+
 	// inflightCtx suppresses parallel execution of getKeys and allows
 	// multiple goroutines to wait for its result.
 	// Its Err() method returns any errors encountered during getKeys.
@@ -78,6 +259,11 @@ type remoteKeySet struct {
 	inflightCtx *inflight
 
 	keys []jose.JSONWebKey
+	// etag of the last response, sent in If-None-Match header to avoid downloading unchanged keys.
+	etag string
+	// maxAge of the keys, as specified by Cache-Control header of the last response, if hasMaxAge is true.
+	maxAge    time.Duration
+	hasMaxAge bool
 }
 
 // inflight is used to wait on some in-flight request from multiple goroutines
@@ -105,6 +291,12 @@ func (i *inflight) Cancel(err error) {
 
 // Keys returns public Keys from remote source.
 func (r *remoteKeySet) Keys(ctx context.Context) ([]jose.JSONWebKey, error) {
+	keys, _, _, err := r.KeysWithMaxAge(ctx)
+	return keys, err
+}
+
+// KeysWithMaxAge returns public Keys from remote source together with their max age from Cache-Control header.
+func (r *remoteKeySet) KeysWithMaxAge(ctx context.Context) ([]jose.JSONWebKey, time.Duration, bool, error) {
 	var inflightCtx *inflight
 	func() {
 		r.mutex.Lock()
@@ -116,7 +308,11 @@ func (r *remoteKeySet) Keys(ctx context.Context) ([]jose.JSONWebKey, error) {
 			r.inflightCtx = inflightCtx
 
 			go func() {
-				inflightCtx.Cancel(r.updateKeys(ctx))
+				err := r.updateKeys(ctx)
+				if err != nil {
+					r.logger.Warn("oidc: Failed to fetch provider keys.", "err", err)
+				}
+				inflightCtx.Cancel(err)
 
 				r.mutex.Lock()
 				defer r.mutex.Unlock()
@@ -129,44 +325,158 @@ func (r *remoteKeySet) Keys(ctx context.Context) ([]jose.JSONWebKey, error) {
 
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return nil, 0, false, ctx.Err()
 	case <-inflightCtx.Done():
 		if err := inflightCtx.Err(); err != nil {
-			return nil, err
+			return nil, 0, false, err
 		}
 	}
 
-	return r.keys, nil
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.keys, r.maxAge, r.hasMaxAge, nil
+}
+
+// setURL makes next fetches use given JWKS URL. ETag of the previous URL is dropped, since it is meaningless there.
+func (r *remoteKeySet) setURL(jwksURL string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.jwksURL = jwksURL
+	r.etag = ""
 }
 
 func (r *remoteKeySet) updateKeys(ctx context.Context) error {
+	r.mutex.Lock()
 	req, err := http.NewRequest("GET", r.jwksURL, nil)
 	if err != nil {
+		r.mutex.Unlock()
 		return fmt.Errorf("oidc: can't create request: %v", err)
 	}
+	if r.etag != "" && r.keys != nil {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	r.mutex.Unlock()
 
-	resp, err := doRequest(ctx, req)
+	resp, err := doRequest(ctx, r.httpClient, r.requestHook, RequestJWKS, req)
 	if err != nil {
-		return fmt.Errorf("oidc: get keys failed %v", err)
+		return wrapErrorf(err, "oidc: get keys failed %v", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxKeySetResponseSize))
 	if err != nil {
-		return fmt.Errorf("oidc: read response body: %v", err)
+		return wrapErrorf(&NetworkError{Err: err}, "oidc: read response body: %v", err)
+	}
+	maxAge, hasMaxAge := parseMaxAge(resp.Header.Get("Cache-Control"))
+	if resp.StatusCode == http.StatusNotModified {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.maxAge, r.hasMaxAge = maxAge, hasMaxAge
+		return nil
 	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("oidc: get keys failed: %s %s", resp.Status, body)
+		return &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       body,
+			msg:        fmt.Sprintf("oidc: get keys failed: %s %s", resp.Status, body),
+		}
 	}
 
-	var keySet jose.JSONWebKeySet
-	if err := json.Unmarshal(body, &keySet); err != nil {
-		return fmt.Errorf("oidc: failed to decode keys: %v %s", err, body)
+	keys, err := decodeKeySet(body)
+	if err != nil {
+		return err
+	}
+	if r.maxKeys > 0 && len(keys) > r.maxKeys {
+		var dropped []string
+		keys, dropped = limitKeys(keys, r.maxKeys)
+		r.logger.Warn("oidc: Provider key set has too many keys, some were dropped. Tokens signed with them will fail verification.",
+			"url", req.URL.String(), "max_keys", r.maxKeys, "dropped_key_ids", strings.Join(dropped, ","))
 	}
 
+	r.logger.Debug("oidc: Fetched provider keys.", "url", req.URL.String(), "keys", len(keys))
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	r.keys = keySet.Keys
+	r.keys = keys
+	r.etag = resp.Header.Get("ETag")
+	r.maxAge, r.hasMaxAge = maxAge, hasMaxAge
 
 	return nil
 }
+
+// limitKeys returns at most max keys, preferring keys that can verify signatures (with "sig" or no "use"), so
+// encryption keys listed before newly rotated signing key don't push it out. Order of kept keys is preserved. It also
+// returns IDs of dropped keys.
+func limitKeys(keys []jose.JSONWebKey, max int) (kept []jose.JSONWebKey, droppedIDs []string) {
+	sigKeys := 0
+	for _, k := range keys {
+		if k.Use == "" || k.Use == "sig" {
+			sigKeys++
+		}
+	}
+	// Non-signing keys are kept only if there is room left after all signing ones.
+	otherRoom := max - sigKeys
+	for _, k := range keys {
+		isSig := k.Use == "" || k.Use == "sig"
+		if len(kept) < max && (isSig || otherRoom > 0) {
+			if !isSig {
+				otherRoom--
+			}
+			kept = append(kept, k)
+			continue
+		}
+		droppedIDs = append(droppedIDs, k.KeyID)
+	}
+	return kept, droppedIDs
+}
+
+// parseMaxAge returns how long response can be cached according to Cache-Control header value. no-cache and no-store
+// directives mean it can't be cached at all. Max age is capped to maxKeySetMaxAge.
+func parseMaxAge(cacheControl string) (time.Duration, bool) {
+	var (
+		maxAge    time.Duration
+		hasMaxAge bool
+	)
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return 0, true
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(directive, "max-age="), `"`), 10, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			maxAge, hasMaxAge = time.Duration(seconds)*time.Second, true
+			if seconds > int64(maxKeySetMaxAge/time.Second) {
+				maxAge = maxKeySetMaxAge
+			}
+		}
+	}
+	return maxAge, hasMaxAge
+}
+
+// decodeKeySet decodes JWKS. Keys that can't be parsed, e.g of unsupported type or curve, are skipped, so single
+// exotic key does not break verification with all other keys of the provider.
+func decodeKeySet(body []byte) ([]jose.JSONWebKey, error) {
+	var rawKeySet struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &rawKeySet); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode keys: %v %s", err, body)
+	}
+
+	var keys []jose.JSONWebKey
+	for _, raw := range rawKeySet.Keys {
+		var k jose.JSONWebKey
+		if err := json.Unmarshal(raw, &k); err != nil {
+			continue
+		}
+		if !k.Valid() {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
@@ -0,0 +1,272 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func (s *ClientTestSuite) cachingKeySet(ttl time.Duration, now func() time.Time) *cachedKeySet {
+	return newCachedKeySet(newRemoteKeySet(testDiscovery.JWKSURL, s.s.HTTPClient()), ttl, DefaultKeySetMinRefreshInterval, now).(*cachedKeySet)
+}
+
+// keyIDSignedJWT signs claims with new ES256 key with given key ID. It returns token and JWKS with the key.
+func (s *ClientTestSuite) keyIDSignedJWT(keyID string, claims map[string]interface{}) (token string, jwkSetJSON []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", keyID))
+	s.Require().NoError(err)
+	payload, err := json.Marshal(claims)
+	s.Require().NoError(err)
+	jws, err := signer.Sign(payload)
+	s.Require().NoError(err)
+	token, err = jws.CompactSerialize()
+	s.Require().NoError(err)
+
+	jwkSetJSON, err = json.Marshal(&jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: keyID, Algorithm: string(jose.ES256), Use: "sig"}},
+	})
+	s.Require().NoError(err)
+	return token, jwkSetJSON
+}
+
+// waitForBackgroundRefresh waits until background refresh of the key set finishes.
+func (s *ClientTestSuite) waitForBackgroundRefresh(ks *cachedKeySet) {
+	for i := 0; i < 100; i++ {
+		ks.Lock()
+		refreshing := ks.refreshing
+		ks.Unlock()
+		if !refreshing {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.FailNow("background refresh of keys did not finish")
+}
+
+func (s *ClientTestSuite) TestKeySet_CacheControlAndETag() {
+	currTime := time.Now()
+	ks := s.cachingKeySet(DefaultKeySetExpiration, func() time.Time { return currTime })
+
+	_, jwkSetJSON := s.signedJWT(map[string]interface{}{"sub": "subject1"})
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal("", r.Header.Get("If-None-Match"))
+		resp, err := rt.JSONResponseFunc(http.StatusOK, jwkSetJSON)(r)
+		resp.Header.Set("ETag", `"v1"`)
+		resp.Header.Set("Cache-Control", "public, max-age=600")
+		return resp, err
+	})
+	keys, err := ks.Keys(s.testCtx)
+	s.Require().NoError(err)
+	s.Len(keys, 1)
+	s.Equal(0, s.s.Len())
+
+	// Cached for max-age, not for DefaultKeySetExpiration.
+	currTime = currTime.Add(5 * time.Minute)
+	cachedKeys, err := ks.Keys(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal(keys, cachedKeys)
+
+	// Expired keys are returned while refreshed in the background with conditional request.
+	currTime = currTime.Add(6 * time.Minute)
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal(`"v1"`, r.Header.Get("If-None-Match"))
+		return &http.Response{
+			StatusCode: http.StatusNotModified,
+			Header:     http.Header{"Cache-Control": {"max-age=60"}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	})
+	cachedKeys, err = ks.Keys(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal(keys, cachedKeys)
+	s.waitForBackgroundRefresh(ks)
+	s.Equal(0, s.s.Len())
+
+	ks.Lock()
+	s.Equal(keys, ks.keys)
+	s.Equal(currTime.Add(1*time.Minute), ks.expiry)
+	ks.Unlock()
+}
+
+func (s *ClientTestSuite) TestKeySet_NoCache() {
+	ks := s.cachingKeySet(DefaultKeySetExpiration, nil)
+
+	for i := 0; i < 2; i++ {
+		_, jwkSetJSON := s.signedJWT(map[string]interface{}{"sub": "subject1"})
+		s.s.Push(func(r *http.Request) (*http.Response, error) {
+			resp, err := rt.JSONResponseFunc(http.StatusOK, jwkSetJSON)(r)
+			resp.Header.Set("Cache-Control", "no-store")
+			return resp, err
+		})
+		_, err := ks.Keys(s.testCtx)
+		s.Require().NoError(err)
+		s.Equal(0, s.s.Len(), "keys should be fetched on every call")
+	}
+}
+
+func (s *ClientTestSuite) TestVerifier_RefreshesKeysOnUnknownKeyID() {
+	currTime := time.Now()
+	ks := s.cachingKeySet(1*time.Hour, func() time.Time { return currTime })
+	v := newVerifier(ks, VerificationConfig{ClientID: "client1", SupportedSigningAlgs: []string{"ES256"}}, exampleIssuer, nil)
+
+	claims := map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": currTime.Add(2 * time.Hour).Unix(),
+	}
+	rawToken1, jwkSetJSON1 := s.keyIDSignedJWT("key1", claims)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON1))
+	_, err := v.VerifyIDToken(s.testCtx, rawToken1)
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	// Provider rotated keys. Refresh is not allowed right after previous fetch.
+	rawToken2, jwkSetJSON2 := s.keyIDSignedJWT("key2", claims)
+	_, err = v.VerifyIDToken(s.testCtx, rawToken2)
+	s.Error(err)
+	s.Equal(KeySetStats{Fetches: 1, RateLimitedRefreshes: 1}, ks.Stats())
+
+	currTime = currTime.Add(DefaultKeySetMinRefreshInterval + time.Second)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON2))
+	_, err = v.VerifyIDToken(s.testCtx, rawToken2)
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+	s.Equal(KeySetStats{Fetches: 2, Rotations: 1, UnknownKeyRefreshes: 1, RateLimitedRefreshes: 1}, ks.Stats())
+
+	// Tokens with random key IDs don't trigger more than one fetch per interval.
+	currTime = currTime.Add(DefaultKeySetMinRefreshInterval + time.Second)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON2))
+	for i := 0; i < 5; i++ {
+		rawToken, _ := s.keyIDSignedJWT(fmt.Sprintf("random%d", i), claims)
+		_, err = v.VerifyIDToken(s.testCtx, rawToken)
+		s.Error(err)
+	}
+	s.Equal(0, s.s.Len())
+	s.Equal(KeySetStats{Fetches: 3, Rotations: 1, UnknownKeyRefreshes: 2, RateLimitedRefreshes: 5}, ks.Stats())
+}
+
+type blockingKeySet struct {
+	keys    []jose.JSONWebKey
+	release chan struct{}
+}
+
+func (b *blockingKeySet) Keys(ctx context.Context) ([]jose.JSONWebKey, error) {
+	<-b.release
+	return b.keys, nil
+}
+
+func TestCachedKeySet_RefreshDoesNotBlock(t *testing.T) {
+	currTime := time.Now()
+	parent := &blockingKeySet{keys: []jose.JSONWebKey{{KeyID: "key1"}}, release: make(chan struct{}, 1)}
+	ks := newCachedKeySet(parent, 10*time.Second, DefaultKeySetMinRefreshInterval, func() time.Time { return currTime }).(*cachedKeySet)
+
+	parent.release <- struct{}{}
+	keys, err := ks.Keys(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", keys[0].KeyID)
+
+	currTime = currTime.Add(11 * time.Second)
+	parent.keys = []jose.JSONWebKey{{KeyID: "key2"}}
+	for i := 0; i < 3; i++ {
+		keys, err = ks.Keys(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "key1", keys[0].KeyID, "stale keys should be returned while refreshing")
+	}
+
+	parent.release <- struct{}{}
+	for i := 0; i < 100; i++ {
+		ks.Lock()
+		refreshing := ks.refreshing
+		ks.Unlock()
+		if !refreshing {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	keys, err = ks.Keys(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "key2", keys[0].KeyID)
+}
+
+func TestParseMaxAge(t *testing.T) {
+	for _, tcase := range []struct {
+		cacheControl string
+		maxAge       time.Duration
+		ok           bool
+	}{
+		{cacheControl: ""},
+		{cacheControl: "public"},
+		{cacheControl: "public, max-age=3600", maxAge: 1 * time.Hour, ok: true},
+		{cacheControl: "Max-Age=60, must-revalidate", maxAge: 1 * time.Minute, ok: true},
+		{cacheControl: "max-age=60, no-cache", ok: true},
+		{cacheControl: "no-store", ok: true},
+		{cacheControl: "max-age=invalid"},
+		{cacheControl: "max-age=31536000", maxAge: maxKeySetMaxAge, ok: true},
+	} {
+		maxAge, ok := parseMaxAge(tcase.cacheControl)
+		assert.Equal(t, tcase.ok, ok, tcase.cacheControl)
+		assert.Equal(t, tcase.maxAge, maxAge, tcase.cacheControl)
+	}
+}
+
+func TestLimitKeys(t *testing.T) {
+	enc := jose.JSONWebKey{KeyID: "enc1", Use: "enc"}
+	sig1 := jose.JSONWebKey{KeyID: "sig1", Use: "sig"}
+	sig2 := jose.JSONWebKey{KeyID: "sig2"}
+	sig3 := jose.JSONWebKey{KeyID: "sig3", Use: "sig"}
+
+	// Newly rotated signing key listed last is kept instead of encryption key.
+	kept, dropped := limitKeys([]jose.JSONWebKey{enc, sig1, sig2}, 2)
+	assert.Equal(t, []jose.JSONWebKey{sig1, sig2}, kept)
+	assert.Equal(t, []string{"enc1"}, dropped)
+
+	kept, dropped = limitKeys([]jose.JSONWebKey{sig1, enc, sig2, sig3}, 3)
+	assert.Equal(t, []jose.JSONWebKey{sig1, sig2, sig3}, kept)
+	assert.Equal(t, []string{"enc1"}, dropped)
+
+	kept, dropped = limitKeys([]jose.JSONWebKey{sig1, enc, sig2, sig3}, 2)
+	assert.Equal(t, []jose.JSONWebKey{sig1, sig2}, kept)
+	assert.Equal(t, []string{"enc1", "sig3"}, dropped)
+}
+
+func (s *ClientTestSuite) TestKeySet_TooManyKeys() {
+	_, jwkSetJSON := s.keyIDSignedJWT("sig1", map[string]interface{}{"sub": "subject1"})
+	var jwks jose.JSONWebKeySet
+	s.Require().NoError(json.Unmarshal(jwkSetJSON, &jwks))
+	// Valid key, so it is not dropped by decodeKeySet already.
+	encKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	jwks.Keys = append([]jose.JSONWebKey{{Key: &encKey.PublicKey, KeyID: "enc1", Use: "enc"}}, jwks.Keys...)
+	jwkSetJSON, err = json.Marshal(&jwks)
+	s.Require().NoError(err)
+
+	logger := &recordingLogger{}
+	ks := newRemoteKeySet(testDiscovery.JWKSURL, s.s.HTTPClient())
+	ks.logger = logger
+	ks.maxKeys = 1
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	s.Require().NoError(ks.updateKeys(s.testCtx))
+	s.Equal(0, s.s.Len())
+
+	s.Require().Len(ks.keys, 1)
+	s.Equal("sig1", ks.keys[0].KeyID)
+
+	s.Require().Len(logger.entries, 2)
+	s.Equal("warn", logger.entries[0].level)
+	s.Contains(fmt.Sprint(logger.entries[0].keyvals...), "enc1")
+}
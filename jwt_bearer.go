@@ -0,0 +1,226 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// GrantTypeJWTBearer is the JWT bearer assertion grant. See https://tools.ietf.org/html/rfc7523.
+const GrantTypeJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// DefaultAssertionLifetime is lifetime of JWT assertion if not specified.
+const DefaultAssertionLifetime = 5 * time.Minute
+
+// JWTAssertionConfig configures JWT assertion signed by the client for JWT bearer assertion grant.
+type JWTAssertionConfig struct {
+	// Issuer is the "iss" claim, e.g service account email.
+	Issuer string
+	// Subject is the "sub" claim, the principal token is requested for. It is Issuer by default.
+	Subject string
+	// Audience is the "aud" claim. It is the provider's token endpoint by default.
+	Audience string
+	// Scopes are sent as scope parameter of the token request.
+	Scopes []string
+	// ExtraClaims are added to the assertion, e.g "scope" or "target_audience" for Google.
+	ExtraClaims map[string]interface{}
+
+	// Key signs the assertion. It needs to be *rsa.PrivateKey (signed with RS256) or *ecdsa.PrivateKey (signed with
+	// ES256, ES384 or ES512 according to its curve).
+	Key crypto.PrivateKey
+	// KeyID if not empty, is set as "kid" header of the assertion.
+	KeyID string
+	// Lifetime of the assertion. DefaultAssertionLifetime is used if zero.
+	Lifetime time.Duration
+}
+
+// JWTBearerToken obtains token by exchanging JWT assertion signed by the client's private key (see
+// https://tools.ietf.org/html/rfc7523) e.g for Google service accounts. No user interaction or refresh token is
+// needed, so new token can be obtained anytime. Returned token is not verified.
+func (c *Client) JWTBearerToken(ctx context.Context, cfg Config, a JWTAssertionConfig) (*Token, error) {
+	assertion, err := signAssertion(a, c.Discovery().TokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	v := url.Values{
+		"grant_type": {GrantTypeJWTBearer},
+		"assertion":  {assertion},
+	}
+	if len(a.Scopes) > 0 {
+		v.Set("scope", strings.Join(a.Scopes, " "))
+	}
+	return c.loginToken(ctx, cfg, v)
+}
+
+// JWTBearerTokenSource returns a TokenSource that obtains new token with JWTBearerToken when access token of the
+// previous one expires. Returned tokens are not verified and might have no ID token.
+func (c *Client) JWTBearerTokenSource(ctx context.Context, cfg Config, a JWTAssertionConfig) TokenSource {
+	return newAccessTokenReuseSource(&jwtBearerTokenSource{
+		ctx:    ctx,
+		client: c,
+		cfg:    cfg,
+		a:      a,
+	})
+}
+
+type jwtBearerTokenSource struct {
+	ctx    context.Context
+	client *Client
+	cfg    Config
+	a      JWTAssertionConfig
+}
+
+// OIDCToken obtains new token.
+func (s *jwtBearerTokenSource) OIDCToken() (*Token, error) {
+	return s.OIDCTokenCtx(s.ctx)
+}
+
+// OIDCTokenCtx obtains new token using ctx.
+func (s *jwtBearerTokenSource) OIDCTokenCtx(ctx context.Context) (*Token, error) {
+	return s.client.JWTBearerToken(ctx, s.cfg, s.a)
+}
+
+// Verifier returns verifier for ID Token.
+func (s *jwtBearerTokenSource) Verifier() Verifier {
+	return s.client.Verifier(VerificationConfig{ClientID: s.cfg.ClientID})
+}
+
+// signAssertion returns signed JWT assertion. defaultAudience is used if assertion has no audience configured.
+// headers are added to the JWS header.
+func signAssertion(a JWTAssertionConfig, defaultAudience string, headers map[jose.HeaderKey]interface{}) (string, error) {
+	alg, err := signingAlgorithm(a.Key)
+	if err != nil {
+		return "", err
+	}
+	if a.Issuer == "" {
+		return "", errors.New("oidc: JWT assertion issuer is required")
+	}
+
+	jti := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, jti); err != nil {
+		return "", err
+	}
+	lifetime := a.Lifetime
+	if lifetime == 0 {
+		lifetime = DefaultAssertionLifetime
+	}
+	now := time.Now()
+
+	claims := map[string]interface{}{}
+	for k, v := range a.ExtraClaims {
+		claims[k] = v
+	}
+	claims["iss"] = a.Issuer
+	claims["sub"] = a.Issuer
+	if a.Subject != "" {
+		claims["sub"] = a.Subject
+	}
+	claims["aud"] = defaultAudience
+	if a.Audience != "" {
+		claims["aud"] = a.Audience
+	}
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(lifetime).Unix()
+	claims["jti"] = base64.RawURLEncoding.EncodeToString(jti)
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	opts := (&jose.SignerOptions{}).WithType("JWT")
+	if a.KeyID != "" {
+		opts = opts.WithHeader("kid", a.KeyID)
+	}
+	for k, v := range headers {
+		opts = opts.WithHeader(k, v)
+	}
+	signer, err := newSigner(jose.SigningKey{Algorithm: alg, Key: a.Key}, opts)
+	if err != nil {
+		return "", wrapErrorf(err, "oidc: failed to create JWT assertion signer: %v", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to sign JWT assertion: %v", err)
+	}
+	return jws.CompactSerialize()
+}
+
+// signingAlgorithm returns JWS algorithm for given private key.
+func signingAlgorithm(key crypto.PrivateKey) (jose.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case []byte:
+		return jose.HS256, nil
+	case *rsa.PrivateKey:
+		return jose.RS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+		return "", errors.New("oidc: unsupported ECDSA curve of signing key")
+	}
+	return "", fmt.Errorf("oidc: unsupported signing key type %T", key)
+}
+
+// GoogleServiceAccountAssertion returns JWTAssertionConfig for Google service account JSON key file. Use ExtraClaims
+// to request Google specific claims like "scope" or "target_audience".
+func GoogleServiceAccountAssertion(serviceAccountJSON []byte) (JWTAssertionConfig, error) {
+	var sa struct {
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(serviceAccountJSON, &sa); err != nil {
+		return JWTAssertionConfig{}, fmt.Errorf("oidc: failed to parse service account JSON: %v", err)
+	}
+
+	key, err := parsePrivateKeyPEM([]byte(sa.PrivateKey))
+	if err != nil {
+		return JWTAssertionConfig{}, err
+	}
+	return JWTAssertionConfig{
+		Issuer:   sa.ClientEmail,
+		Audience: sa.TokenURI,
+		Key:      key,
+		KeyID:    sa.PrivateKeyID,
+	}, nil
+}
+
+func parsePrivateKeyPEM(b []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("oidc: private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("oidc: failed to parse private key")
+}
@@ -0,0 +1,67 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func (s *ClientTestSuite) TestJWTBearerToken() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken: "access1",
+		TokenType:   "Bearer",
+	})
+	s.NoError(err)
+
+	var form url.Values
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.NoError(r.ParseForm())
+		form = r.PostForm
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	tk, err := s.client.JWTBearerToken(s.testCtx, Config{ClientID: "client1"}, JWTAssertionConfig{
+		Issuer:      "sa@example.org",
+		Scopes:      []string{"scope1", "scope2"},
+		ExtraClaims: map[string]interface{}{"target_audience": "service1"},
+		Key:         key,
+		KeyID:       "key1",
+	})
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+	s.Equal("access1", tk.AccessToken)
+
+	s.Equal(GrantTypeJWTBearer, form.Get("grant_type"))
+	s.Equal("scope1 scope2", form.Get("scope"))
+
+	jws, err := jose.ParseSigned(form.Get("assertion"))
+	s.Require().NoError(err)
+	payload, err := jws.Verify(&key.PublicKey)
+	s.Require().NoError(err)
+
+	var claims map[string]interface{}
+	s.Require().NoError(json.Unmarshal(payload, &claims))
+	s.Equal("sa@example.org", claims["iss"])
+	s.Equal("sa@example.org", claims["sub"])
+	s.Equal(testDiscovery.TokenURL, claims["aud"])
+	s.Equal("service1", claims["target_audience"])
+	s.NotEmpty(claims["jti"])
+	s.Equal(float64(DefaultAssertionLifetime.Seconds()), claims["exp"].(float64)-claims["iat"].(float64))
+}
+
+func (s *ClientTestSuite) TestJWTBearerToken_UnsupportedKey() {
+	_, err := s.client.JWTBearerToken(s.testCtx, Config{ClientID: "client1"}, JWTAssertionConfig{
+		Issuer: "sa@example.org",
+		Key:    "not-a-key",
+	})
+	s.Error(err)
+	s.Equal(0, s.s.Len())
+}
@@ -0,0 +1,54 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultKubernetesTokenPath is the path of service account token mounted into Kubernetes pods by default. Projected
+// service account tokens (with custom audience and expiry) are mounted at path configured in the pod spec instead.
+const DefaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// KubernetesTokenSource returns a TokenSource that returns Kubernetes service account token read from the file at
+// given path (DefaultKubernetesTokenPath if empty), so in-cluster workloads can exchange it for tokens of external
+// STS, e.g:
+//
+//    src, err := oidc.KubernetesTokenSource("/var/run/secrets/tokens/sts-token")
+//    ...
+//    client.ExchangeTokenSource(ctx, cfg, src, "api", oidc.WithSubjectTokenType(oidc.TokenTypeJWT))
+//
+// The service account JWT is returned as access token with expiry taken from its (unverified) "exp" claim. The file is
+// read again whenever kubelet rotates it. Token is not verified, so Verifier of the returned source is nil.
+func KubernetesTokenSource(path string) (TokenSource, error) {
+	if path == "" {
+		path = DefaultKubernetesTokenPath
+	}
+	s := &fileTokenSource{path: path, reload: true, parse: parseServiceAccountToken}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseServiceAccountToken returns service account JWT as access token with its expiry.
+func parseServiceAccountToken(b []byte) (*Token, error) {
+	t, err := parseStaticToken(b)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := parseJWT(t.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("service account token is not a JWT: %v", err)
+	}
+	var claims struct {
+		Expiry *NumericDate `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal service account token claims: %v", err)
+	}
+	if claims.Expiry != nil {
+		// Legacy secret-based tokens never expire.
+		t.AccessTokenExpiry = claims.Expiry.Time()
+	}
+	return t, nil
+}
@@ -0,0 +1,50 @@
+package oidc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (s *ClientTestSuite) TestKubernetesTokenSource() {
+	dir, err := ioutil.TempDir("", "oidc-k8s-token")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	s.Require().NoError(ioutil.WriteFile(path, []byte("not-a-jwt"), 0600))
+	_, err = KubernetesTokenSource(path)
+	s.Error(err)
+
+	expiry := time.Now().Add(1 * time.Hour).Truncate(time.Second)
+	jwt1, _ := s.keyIDSignedJWT("key1", map[string]interface{}{
+		"iss": "https://kubernetes.default.svc",
+		"sub": "system:serviceaccount:default:app",
+		"exp": expiry.Unix(),
+	})
+	s.Require().NoError(ioutil.WriteFile(path, []byte(jwt1+"\n"), 0600))
+	src, err := KubernetesTokenSource(path)
+	s.Require().NoError(err)
+	s.Nil(src.Verifier())
+
+	token, err := src.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal(jwt1, token.AccessToken)
+	s.True(expiry.Equal(token.AccessTokenExpiry))
+
+	// Kubelet rotates the token.
+	jwt2, _ := s.keyIDSignedJWT("key1", map[string]interface{}{
+		"iss": "https://kubernetes.default.svc",
+		"sub": "system:serviceaccount:default:app",
+		"exp": expiry.Add(1 * time.Hour).Unix(),
+	})
+	s.Require().NoError(ioutil.WriteFile(path, []byte(jwt2), 0600))
+	future := time.Now().Add(1 * time.Minute)
+	s.Require().NoError(os.Chtimes(path, future, future))
+
+	token, err = src.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal(jwt2, token.AccessToken)
+	s.True(expiry.Add(1 * time.Hour).Equal(token.AccessTokenExpiry))
+}
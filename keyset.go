@@ -0,0 +1,58 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// KeySet is a source of keys verifying token signatures. Implement it to plug in custom key backends, e.g HSM, Vault
+// transit or keys aggregated from many providers, and use it with NewVerifier.
+type KeySet interface {
+	// Keys returns public keys of the set. Key sets that can't expose their keys may return an error, since
+	// verifiers only use VerifySignature.
+	Keys(ctx context.Context) ([]jose.JSONWebKey, error)
+	// VerifySignature verifies signature of compact serialized JWT and returns its payload. Verifier checks issuer,
+	// audience, expiry and signing algorithm against VerificationConfig.SupportedSigningAlgs on its own.
+	VerifySignature(ctx context.Context, jwt string) (payload []byte, err error)
+}
+
+// NewVerifier constructs verifier of tokens issued by given issuer with signatures verified by given key set. It does
+// not use discovery, so cfg.SupportedSigningAlgs defaults to RS256.
+func NewVerifier(issuer string, keySet KeySet, cfg VerificationConfig) *IDTokenVerifier {
+	return newVerifier(keySet, cfg, issuer, nil)
+}
+
+// KeySet returns provider's key set, the one used by verifiers of all its clients. Keys are cached and refreshed as
+// described in WithKeySetCacheTTL.
+func (p *Provider) KeySet() KeySet {
+	return &keysVerifier{keySet: p.keySet}
+}
+
+// keysVerifier implements KeySet for internal key sets by verifying signatures with their public keys.
+type keysVerifier struct {
+	keySet
+}
+
+// VerifySignature verifies signature with key matching key ID and algorithm of the signature.
+func (k *keysVerifier) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
+	}
+
+	keyIDs := make(map[string]string)
+	for _, sig := range jws.Signatures {
+		if isHMACAlg(sig.Header.Algorithm) {
+			// HMAC keys are not part of any key set.
+			continue
+		}
+		keyIDs[sig.Header.KeyID] = sig.Header.Algorithm
+	}
+	if len(keyIDs) == 0 {
+		return nil, errors.New("oidc: no signatures can be verified with public keys")
+	}
+	return verifyWithKeys(ctx, k.keySet, jws, keyIDs)
+}
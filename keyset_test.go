@@ -0,0 +1,86 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// hsmKeySet is a KeySet that does not expose its keys, e.g HSM.
+type hsmKeySet struct {
+	key   jose.JSONWebKey
+	calls int
+}
+
+func (h *hsmKeySet) Keys(_ context.Context) ([]jose.JSONWebKey, error) {
+	return nil, errors.New("keys are not exportable")
+}
+
+func (h *hsmKeySet) VerifySignature(_ context.Context, raw string) ([]byte, error) {
+	h.calls++
+	jws, err := jose.ParseSigned(raw)
+	if err != nil {
+		return nil, err
+	}
+	return jws.Verify(&h.key)
+}
+
+func (s *ClientTestSuite) TestNewVerifier_CustomKeySet() {
+	claims := map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	}
+	rawToken, jwkSetJSON := s.signedJWT(claims)
+	keys, err := decodeKeySet(jwkSetJSON)
+	s.Require().NoError(err)
+	ks := &hsmKeySet{key: keys[0]}
+
+	token, err := NewVerifier(exampleIssuer, ks, VerificationConfig{ClientID: "client1"}).VerifyIDToken(s.testCtx, rawToken)
+	s.Require().NoError(err)
+	s.Equal("subject1", token.Subject)
+	s.Equal(1, ks.calls)
+
+	otherToken, _ := s.signedJWT(claims)
+	_, err = NewVerifier(exampleIssuer, ks, VerificationConfig{ClientID: "client1"}).VerifyIDToken(s.testCtx, otherToken)
+	s.Error(err)
+	s.Equal(2, ks.calls)
+
+	// Registered claims and algorithms are checked before signature.
+	_, err = NewVerifier(exampleIssuer, ks, VerificationConfig{ClientID: "client2"}).VerifyIDToken(s.testCtx, rawToken)
+	s.Error(err)
+	_, err = NewVerifier(exampleIssuer, ks, VerificationConfig{
+		ClientID:             "client1",
+		SupportedSigningAlgs: []string{string(jose.ES256)},
+	}).VerifyIDToken(s.testCtx, rawToken)
+	s.Error(err)
+	s.Equal(2, ks.calls)
+}
+
+func (s *ClientTestSuite) TestProvider_KeySet() {
+	rawToken, jwkSetJSON := s.signedJWT(map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	})
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	payload, err := s.client.Provider().KeySet().VerifySignature(s.testCtx, rawToken)
+	s.Require().NoError(err)
+	s.Contains(string(payload), "subject1")
+	s.Equal(0, s.s.Len())
+
+	// Provider's key set can back custom verifiers.
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	token, err := NewVerifier(exampleIssuer, s.client.Provider().KeySet(), VerificationConfig{ClientID: "client1"}).
+		VerifyIDToken(s.testCtx, rawToken)
+	s.Require().NoError(err)
+	s.Equal("subject1", token.Subject)
+	s.Equal(0, s.s.Len())
+}
@@ -0,0 +1,176 @@
+package oidc
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Logger is a minimal structured logger. Keyvals are alternating keys and values, as in AuditEvent.Fields, so it can
+// be implemented on top of most structured loggers (e.g go-kit log, zap's SugaredLogger or slog). Logger must be safe
+// for concurrent use.
+//
+// Loggers are always wrapped with RedactLogger before use, so implementations never receive tokens or secrets.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+}
+
+// WithLogger sets logger for discovery, key set and token requests of the Client and its token sources. Nothing is
+// logged without it.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = RedactLogger(logger)
+	}
+}
+
+// WithTokenSourceLogger sets logger of ReuseTokenSource. Token sources created by Client.TokenSource use logger given
+// by WithLogger by default.
+func WithTokenSourceLogger(logger Logger) ReuseTokenSourceOption {
+	return func(s *ReuseTokenSource) {
+		s.logger = RedactLogger(logger)
+	}
+}
+
+// log returns logger given by WithLogger, or no-op one.
+func (o options) log() Logger {
+	if o.logger == nil {
+		return nopLogger{}
+	}
+	return o.logger
+}
+
+// NewStdLogger returns Logger that prints to std logger as "<Level>: <msg> key=value ...", e.g for CLI tools.
+func NewStdLogger(logger *log.Logger) Logger {
+	return RedactLogger(&stdLogger{logger: logger})
+}
+
+type stdLogger struct {
+	logger *log.Logger
+}
+
+func (l *stdLogger) Debug(msg string, keyvals ...interface{}) { l.print("Debug", msg, keyvals) }
+func (l *stdLogger) Info(msg string, keyvals ...interface{})  { l.print("Info", msg, keyvals) }
+func (l *stdLogger) Warn(msg string, keyvals ...interface{})  { l.print("Warn", msg, keyvals) }
+
+func (l *stdLogger) print(level string, msg string, keyvals []interface{}) {
+	var b bytes.Buffer
+	b.WriteString(level)
+	b.WriteString(": ")
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var v interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%v", keyvals[i], v)
+	}
+	l.logger.Println(b.String())
+}
+
+// redactedValue replaces values of sensitive keys.
+const redactedValue = "[REDACTED]"
+
+// sensitiveLogKeys are keys whose values are never logged.
+var sensitiveLogKeys = map[string]struct{}{
+	"access_token":     {},
+	"refresh_token":    {},
+	"id_token":         {},
+	"logout_token":     {},
+	"subject_token":    {},
+	"actor_token":      {},
+	"assertion":        {},
+	"client_assertion": {},
+	"client_secret":    {},
+	"secret":           {},
+	"password":         {},
+	"code":             {},
+	"code_verifier":    {},
+	"device_code":      {},
+	"auth_req_id":      {},
+	"authorization":    {},
+	"dpop":             {},
+}
+
+// RedactLogger returns logger that passes key-values to logger with tokens and secrets removed:
+//  * values of sensitive keys (e.g "access_token", "client_secret" or "code") are replaced with "[REDACTED]",
+//  * Token values are replaced with their non-secret summary (type, expiry, scopes),
+//  * string values that look like JWTs are replaced with "[REDACTED]".
+// Loggers given to WithLogger and WithTokenSourceLogger are wrapped automatically.
+func RedactLogger(logger Logger) Logger {
+	switch logger.(type) {
+	case nil:
+		return nil
+	case *redactingLogger, nopLogger:
+		return logger
+	}
+	return &redactingLogger{logger: logger}
+}
+
+type redactingLogger struct {
+	logger Logger
+}
+
+func (l *redactingLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger.Debug(msg, redactKeyvals(keyvals)...)
+}
+
+func (l *redactingLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger.Info(msg, redactKeyvals(keyvals)...)
+}
+
+func (l *redactingLogger) Warn(msg string, keyvals ...interface{}) {
+	l.logger.Warn(msg, redactKeyvals(keyvals)...)
+}
+
+func redactKeyvals(keyvals []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keyvals))
+	for i := 0; i < len(keyvals); i += 2 {
+		redacted[i] = keyvals[i]
+		if i+1 == len(keyvals) {
+			break
+		}
+		key, _ := keyvals[i].(string)
+		redacted[i+1] = redactValue(key, keyvals[i+1])
+	}
+	return redacted
+}
+
+func redactValue(key string, v interface{}) interface{} {
+	switch t := v.(type) {
+	case *Token:
+		if t == nil {
+			return nil
+		}
+		return tokenSummary(t)
+	case Token:
+		return tokenSummary(&t)
+	}
+	if _, ok := sensitiveLogKeys[strings.ToLower(key)]; ok {
+		return redactedValue
+	}
+	if s, ok := v.(string); ok && looksLikeJWT(s) {
+		return redactedValue
+	}
+	return v
+}
+
+// tokenSummary describes t without any of its tokens.
+func tokenSummary(t *Token) string {
+	return fmt.Sprintf("{type: %s, expiry: %s, scope: %q, id_token: %t, refresh_token: %t}",
+		t.TokenType, t.AccessTokenExpiry.Format(time.RFC3339), t.Scope, t.IDToken != "", t.RefreshToken != "")
+}
+
+// looksLikeJWT returns true for compact serialized JWS or JWE, which starts with base64url encoded JSON header.
+func looksLikeJWT(s string) bool {
+	return strings.HasPrefix(s, "eyJ") && strings.Count(s, ".") >= 2
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
@@ -0,0 +1,116 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Bplotka/go-httpt"
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+)
+
+type logEntry struct {
+	level   string
+	msg     string
+	keyvals []interface{}
+}
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) record(level string, msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, keyvals: keyvals})
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...interface{}) { l.record("debug", msg, keyvals) }
+func (l *recordingLogger) Info(msg string, keyvals ...interface{})  { l.record("info", msg, keyvals) }
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{})  { l.record("warn", msg, keyvals) }
+
+func TestRedactLogger(t *testing.T) {
+	l := &recordingLogger{}
+	expiry := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	RedactLogger(l).Info("msg",
+		"issuer", exampleIssuer,
+		"client_secret", "secret1",
+		"Refresh_Token", "refresh1",
+		"raw", "eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln",
+		"token", &Token{AccessToken: "access1", RefreshToken: "refresh1", TokenType: "Bearer", Scope: "openid", AccessTokenExpiry: expiry},
+		"odd",
+	)
+
+	assert.Equal(t, []logEntry{{level: "info", msg: "msg", keyvals: []interface{}{
+		"issuer", exampleIssuer,
+		"client_secret", "[REDACTED]",
+		"Refresh_Token", "[REDACTED]",
+		"raw", "[REDACTED]",
+		"token", `{type: Bearer, expiry: 2026-01-02T03:04:05Z, scope: "openid", id_token: false, refresh_token: true}`,
+		"odd",
+	}}}, l.entries)
+	redacting := RedactLogger(l)
+	assert.True(t, redacting == RedactLogger(redacting), "logger should not be wrapped twice")
+	assert.Nil(t, RedactLogger(nil))
+}
+
+func TestNewStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0))
+	logger.Debug("Fetched keys.", "keys", 2)
+	logger.Warn("Cannot cache token.", "err", fmt.Errorf("disk full"), "access_token", "access1")
+
+	assert.Equal(t, "Debug: Fetched keys. keys=2\nWarn: Cannot cache token. err=disk full access_token=[REDACTED]\n", buf.String())
+}
+
+func (s *ClientTestSuite) TestLogger() {
+	jsonDiscovery, err := json.Marshal(testDiscovery)
+	s.NoError(err)
+	idToken, _ := s.validIDToken()
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access1",
+		RefreshToken: "refresh2",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+	})
+	s.NoError(err)
+
+	logger := &recordingLogger{}
+	srv := httpt.NewServer(s.T())
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+	client, err := NewClient(context.TODO(), exampleIssuer, WithHTTPClient(srv.HTTPClient()), WithLogger(logger))
+	s.Require().NoError(err)
+
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+	_, err = NewTokenRefresher(context.TODO(), client, Config{ClientID: "client1"}, "refresh1").OIDCToken()
+	s.NoError(err)
+	srv.Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error":"invalid_grant"}`)))
+	_, err = NewTokenRefresher(context.TODO(), client, Config{ClientID: "client1"}, "refresh2").OIDCToken()
+	s.Error(err)
+	s.Equal(0, srv.Len())
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	s.Require().Len(logger.entries, 3)
+	s.Equal("debug", logger.entries[0].level)
+	s.Equal("oidc: Fetched discovery document.", logger.entries[0].msg)
+	s.Equal("debug", logger.entries[1].level)
+	s.Equal("oidc: Obtained token.", logger.entries[1].msg)
+	s.Equal("warn", logger.entries[2].level)
+	s.Equal("oidc: Token request failed.", logger.entries[2].msg)
+	for _, e := range logger.entries {
+		line := fmt.Sprint(e.keyvals...)
+		for _, secret := range []string{"access1", "refresh1", "refresh2", idToken} {
+			s.False(strings.Contains(line, secret), "%s logged %s", e.msg, secret)
+		}
+	}
+}
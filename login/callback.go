@@ -12,8 +12,10 @@ import (
 	"net/url"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Bplotka/oidc"
 )
@@ -24,8 +26,26 @@ const (
 
 	errParam     = "error"
 	errDescParam = "error_description"
+	errURIParam  = "error_uri"
+
+	responseParam = "response"
 )
 
+// ProviderError is an error returned by the provider in the login callback redirect.
+// See https://tools.ietf.org/html/rfc6749#section-4.1.2.1.
+type ProviderError struct {
+	// Code is the error code e.g access_denied.
+	Code string
+	// Description is human-readable description of the error, if provided.
+	Description string
+	// URI is the URI of page with information about the error, if provided.
+	URI string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("Got error from provider: %s Desc: %s", e.Code, e.Description)
+}
+
 func rand128Bits() string {
 	buff := make([]byte, 16) // 128 bit random ID.
 	if _, err := io.ReadFull(rand.Reader, buff); err != nil {
@@ -56,6 +76,8 @@ func openBrowser(url string) error {
 type callbackResponse struct {
 	token *oidc.Token
 	err   error
+	// codeReceived means that valid callback was received and its code is being exchanged. Final response follows.
+	codeReceived bool
 }
 
 // callbackRequest specifies values that are needed for expected callback handling.
@@ -65,70 +87,202 @@ type callbackRequest struct {
 
 	cfg    oidc.Config
 	client *oidc.Client
+	// responseVerifier if not nil, makes callback expect JWT-secured authorization response verified with it.
+	responseVerifier *oidc.IDTokenVerifier
+
+	// result receives the codeReceived notification and the final response for this request, so user callbacks are
+	// invoked by the flow instead of the server's handler. It needs to be buffered for both.
+	result chan *callbackResponse
+
+	// respondOK and respondErr if not nil, override OKCallbackResponse and ErrCallbackResponse for this request.
+	respondOK  func(w http.ResponseWriter, r *http.Request)
+	respondErr func(w http.ResponseWriter, r *http.Request, err error)
+	// assets if not nil, serves static assets of callback pages under callback path + "/assets/".
+	assets http.Handler
 }
 
 // CallbackServer carries a callback handler for OIDC auth code flow.
 // NOTE: This is not thread-safe in terms of multiple logins in the same time.
 type CallbackServer struct {
 	redirectURL string
-	callbackCh  chan *callbackResponse
+	// ephemeral if not nil, makes server listen only during login, see NewLoopbackServer.
+	ephemeral *ServerConfig
+	// responses are used for flows that do not set their own ones.
+	responses CallbackResponses
 
 	// CallbackReq is written in separate thread so guard that.
 	callbackReqMu sync.Mutex
 	// If empty, nothing is expected, so callback should immediately return err.
 	callbackReq *callbackRequest
+
+	// assets are served also after callback is handled, since the callback page loads them afterwards.
+	assetsMu sync.Mutex
+	assets   http.Handler
 }
 
 // NewServer creates HTTP server with OIDC callback on the bindAddress an argument. BindAddress is the ultimately a redirectURL that all clients MUST register
 // first on the OIDC server. It can (and is recommended) to point to localhost. Bind Address must include port. You can specify 0 if your
-// OIDC provider support wildcard on port (almost all server does NOT).
+// OIDC provider support wildcard on port (almost all server does NOT). Host and path of the redirect URL are kept as
+// given, so e.g http://localhost:8085/callback matches the registered URI exactly. See NewServerWithConfig.
 func NewServer(bindAddress string) (srv *CallbackServer, closeSrv func(), err error) {
 	bindURL, err := url.Parse(bindAddress)
 	if err != nil {
 		return nil, nil, fmt.Errorf("BindAddress is not in a form of URL. Err: %v", err)
 	}
+	host, port, err := net.SplitHostPort(bindURL.Host)
+	if err != nil {
+		return nil, nil, fmt.Errorf("BindAddress must include port. Err: %v", err)
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, nil, fmt.Errorf("BindAddress port %q is not a number", port)
+	}
 
-	listener, err := net.Listen("tcp", bindURL.Host)
+	path := bindURL.Path
+	if path == "" {
+		path = "/"
+	}
+	return NewServerWithConfig(ServerConfig{Host: host, Port: portNum, Path: path})
+}
+
+// DefaultCallbackPath is the path of callback used by NewServerWithConfig if none is given.
+const DefaultCallbackPath = "/callback"
+
+// ServerConfig configures callback server created by NewServerWithConfig. Redirect URL of the server is
+// http://<Host>:<Port><Path>, which must exactly match redirect URI registered for the client at strict providers
+// (e.g Azure AD), so none of the parts is changed by the server.
+type ServerConfig struct {
+	// Host is the host (name or IP) the server listens on and the host of the redirect URL. Defaults to 127.0.0.1.
+	// Use "localhost" if the provider only accepts redirect URIs with it.
+	Host string
+	// Port the server listens on. 0 means random free port, which is only accepted by providers allowing any port
+	// of loopback redirect URIs (see https://tools.ietf.org/html/rfc8252#section-7.3).
+	Port int
+	// Path of the callback. Defaults to DefaultCallbackPath.
+	Path string
+	// Responses are default responses of the server for flows without WithCallbackResponses or WithCallbackPages.
+	Responses CallbackResponses
+}
+
+// CallbackResponses write HTTP responses shown in the browser after the login callback. Nil fields fall back to the
+// server's responses (see ServerConfig.Responses) and then to package-wide OKCallbackResponse and
+// ErrCallbackResponse.
+type CallbackResponses struct {
+	// OK responds to callback of successful login.
+	OK func(w http.ResponseWriter, r *http.Request)
+	// Error responds to callback of failed login. Note that err may reveal internals of the application, so by
+	// default it is not shown to the user; it is returned from the flow anyway.
+	Error func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewServerWithConfig creates HTTP server with OIDC callback on given host, port and path. It fails if the port is
+// already in use, instead of picking another one, since redirect URL would no longer match the registered one.
+func NewServerWithConfig(cfg ServerConfig) (srv *CallbackServer, closeSrv func(), err error) {
+	s := &CallbackServer{responses: cfg.Responses}
+	closeSrv, err = s.serve(cfg)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Failed to Listen for tcp on: %s. Err: %v", bindURL.Host, err)
+		return nil, nil, err
 	}
+	return s, closeSrv, nil
+}
 
-	s := &CallbackServer{
-		redirectURL: fmt.Sprintf("http://%s%s", listener.Addr().String(), bindURL.Path),
-		callbackCh:  make(chan *callbackResponse),
+// NewLoopbackServer creates callback server that listens only while login is in progress, each time on a new random
+// free port of 127.0.0.1, as recommended by https://tools.ietf.org/html/rfc8252#section-7.3. Port is substituted into
+// redirect URI sent to the provider, so there are no port conflicts e.g on shared machines. Client must be registered
+// with loopback redirect URI without port (e.g http://127.0.0.1/callback) at provider that allows any port of it, like
+// Google. Path defaults to DefaultCallbackPath. RedirectURL is empty when no login is in progress.
+func NewLoopbackServer(path string) *CallbackServer {
+	return &CallbackServer{ephemeral: &ServerConfig{Host: "127.0.0.1", Path: path}}
+}
+
+// start makes server ready to receive callback and returns redirect URL to use. Stop must be called once callback is
+// no longer expected.
+func (s *CallbackServer) start() (redirectURL string, stop func(), err error) {
+	if s.ephemeral == nil {
+		return s.redirectURL, func() {}, nil
+	}
+
+	closeSrv, err := s.serve(*s.ephemeral)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.redirectURL, func() {
+		closeSrv()
+		s.redirectURL = ""
+	}, nil
+}
+
+// serve listens on address given by cfg and serves callback there.
+func (s *CallbackServer) serve(cfg ServerConfig) (closeSrv func(), err error) {
+	host := cfg.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	path := cfg.Path
+	if path == "" {
+		path = DefaultCallbackPath
 	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("Invalid callback port %d", cfg.Port)
+	}
+
+	listenAddress := net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to Listen for tcp on: %s. Err: %v", listenAddress, err)
+	}
+	redirectURL := url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort(host, strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)),
+		Path:   path,
+	}
+	s.redirectURL = redirectURL.String()
+
 	mux := http.NewServeMux()
-	mux.HandleFunc(bindURL.Path, s.callbackHandler)
+	mux.HandleFunc(path, s.callbackHandler)
+	mux.Handle(assetsPath(path), http.StripPrefix(assetsPath(path), http.HandlerFunc(s.assetsHandler)))
 
+	server := &http.Server{Handler: mux}
 	go func() {
-		http.Serve(listener, mux)
+		server.Serve(listener)
 	}()
 
-	return s, func() {
-		listener.Close()
-		close(s.callbackCh)
+	return func() {
+		// Let the callback page that is being served finish, but don't wait for idle browser connections.
+		ctx, cancel := context.WithTimeout(context.Background(), callbackShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			server.Close()
+		}
 	}, nil
 }
 
+// callbackShutdownTimeout is how long closing callback server waits for responses in progress.
+const callbackShutdownTimeout = 5 * time.Second
+
 // NewReuseServer creates HTTP server with OIDC callback registered on given HTTP mux. Server constructed in such way
 // is not responsible for serving the callback. This is responsibility of the caller.
 func NewReuseServer(pattern string, listenAddress string, mux *http.ServeMux) *CallbackServer {
 	s := &CallbackServer{
 		redirectURL: fmt.Sprintf("http://%s%s", listenAddress, pattern),
-		callbackCh:  make(chan *callbackResponse),
 	}
 	mux.HandleFunc(pattern, s.callbackHandler)
+	mux.Handle(assetsPath(pattern), http.StripPrefix(assetsPath(pattern), http.HandlerFunc(s.assetsHandler)))
 	return s
 }
 
 // callbackHandler handles redirect from OIDC provider with either code or error parameters.
 // If none callback is expected it will return error.
 // In case of valid code with corresponded state it will perform token exchange with OIDC provider.
-// Any message is propagated via Go channel if the callback was expected.
+// Any message is propagated to the login Flow that expects the callback.
 // NOTE: This is not thread-safe in terms of multiple logins in the same time.
 func (s *CallbackServer) callbackHandler(w http.ResponseWriter, r *http.Request) {
 	s.callbackReqMu.Lock()
 	if s.callbackReq == nil {
+		s.callbackReqMu.Unlock()
 		w.WriteHeader(http.StatusPreconditionFailed)
 		w.Write([]byte("Did not expect OIDC callback"))
 		return
@@ -145,34 +299,52 @@ func (s *CallbackServer) callbackHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	code, state, err := parseCallbackRequest(r.Form)
+	callbackReq := s.callbackReq
+	oidcToken, err := callbackReq.exchange(mergeContexts(r.Context(), callbackReq.ctx), r.Form, func() {
+		callbackReq.result <- &callbackResponse{codeReceived: true}
+	})
 	if err != nil {
 		s.errRespond(w, r, err)
 		return
 	}
 
-	if state != s.callbackReq.expectedState {
-		err := fmt.Errorf("Invalid state parameter. Got %s, expected: %s", state, s.callbackReq.expectedState)
-		s.errRespond(w, r, err)
-		return
+	callbackResponse := &callbackResponse{
+		token: oidcToken,
+	}
+	switch {
+	case s.callbackReq.respondOK != nil:
+		s.callbackReq.respondOK(w, r)
+	case s.responses.OK != nil:
+		s.responses.OK(w, r)
+	default:
+		OKCallbackResponse(w, r)
 	}
+	s.callbackReq.result <- callbackResponse
+	return
+}
 
-	ctx := mergeContexts(r.Context(), s.callbackReq.ctx)
-	oidcToken, err := s.callbackReq.client.Exchange(ctx, s.callbackReq.cfg, code)
-	if err != nil {
-		s.errRespond(w, r, err)
-		return
+// exchange validates parameters of authorization response and exchanges its code for token. OnCode is invoked
+// before the exchange.
+func (c *callbackRequest) exchange(ctx context.Context, form url.Values, onCode func()) (*oidc.Token, error) {
+	if c.responseVerifier != nil {
+		var err error
+		form, err = verifyJWTResponse(ctx, c.responseVerifier, form)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	callbackResponse := &callbackResponse{
-		token: oidcToken,
+	code, state, err := parseCallbackRequest(form)
+	if err != nil {
+		return nil, err
 	}
-	OKCallbackResponse(w, r)
-	select {
-	case <-s.callbackReq.ctx.Done():
-	case s.callbackCh <- callbackResponse:
+
+	if state != c.expectedState {
+		return nil, fmt.Errorf("Invalid state parameter. Got %s, expected: %s", state, c.expectedState)
 	}
-	return
+
+	onCode()
+	return c.client.Exchange(ctx, c.cfg, code)
 }
 
 func parseCallbackRequest(form url.Values) (code string, state string, err error) {
@@ -183,7 +355,11 @@ func parseCallbackRequest(form url.Values) (code string, state string, err error
 
 	if errorCode := form.Get(errParam); errorCode != "" {
 		// Got error from provider. Passing through.
-		return "", "", fmt.Errorf("Got error from provider: %s Desc: %s", errorCode, form.Get(errDescParam))
+		return "", "", &ProviderError{
+			Code:        errorCode,
+			Description: form.Get(errDescParam),
+			URI:         form.Get(errURIParam),
+		}
 	}
 
 	code = form.Get(codeParam)
@@ -194,7 +370,37 @@ func parseCallbackRequest(form url.Values) (code string, state string, err error
 	return code, state, nil
 }
 
+// verifyJWTResponse verifies JWT-secured authorization response and returns its parameters. Plain code or error
+// parameters are rejected, so response can't be downgraded to unsigned one.
+func verifyJWTResponse(ctx context.Context, verifier *oidc.IDTokenVerifier, form url.Values) (url.Values, error) {
+	rawResponse := form.Get(responseParam)
+	if rawResponse == "" {
+		return nil, errors.New("Missing JWT-secured authorization response.")
+	}
+	resp, err := verifier.VerifyAuthorizationResponse(ctx, rawResponse)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid JWT-secured authorization response. Err: %v", err)
+	}
+
+	v := url.Values{}
+	for param, value := range map[string]string{
+		stateParam:   resp.State,
+		codeParam:    resp.Code,
+		errParam:     resp.Error,
+		errDescParam: resp.ErrorDescription,
+		errURIParam:  resp.ErrorURI,
+	} {
+		if value != "" {
+			v.Set(param, value)
+		}
+	}
+	return v, nil
+}
+
 // OKCallbackResponse is package wide function variable that returns HTTP response on successful OIDC `code` flow.
+//
+// Deprecated: Overriding it affects all flows in the binary and is racy if done while flows are running. Use
+// WithCallbackResponses or WithCallbackPages per flow, or ServerConfig.Responses per callback server instead.
 var OKCallbackResponse = func(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OIDC authentication flow is completed. You can close browser tab."))
@@ -202,7 +408,8 @@ var OKCallbackResponse = func(w http.ResponseWriter, _ *http.Request) {
 
 // ErrCallbackResponse is package wide function variable that returns HTTP response on failed OIDC `code` flow.
 // Note that, by default we don't want user to see anything wrong on browser side. All errors are propagated to command.
-// If it is required otherwise, override this function.
+//
+// Deprecated: Same as OKCallbackResponse, use WithCallbackResponses, WithCallbackPages or ServerConfig.Responses.
 var ErrCallbackResponse = func(w http.ResponseWriter, _ *http.Request, _ error) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OIDC authentication flow is completed. You can close browser tab."))
@@ -212,15 +419,20 @@ func (s *CallbackServer) errRespond(w http.ResponseWriter, r *http.Request, err
 	callbackResponse := &callbackResponse{
 		err: err,
 	}
-	ErrCallbackResponse(w, r, err)
-
-	select {
-	case <-s.callbackReq.ctx.Done():
-	case s.callbackCh <- callbackResponse:
+	switch {
+	case s.callbackReq.respondErr != nil:
+		s.callbackReq.respondErr(w, r, err)
+	case s.responses.Error != nil:
+		s.responses.Error(w, r, err)
+	default:
+		ErrCallbackResponse(w, r, err)
 	}
+	s.callbackReq.result <- callbackResponse
 	return
 }
 
+// mergeContexts propagates HTTP client passed via deprecated oidc.HTTPClientCtxKey from oidcCtx into originalCtx.
+// Clients constructed with oidc.WithHTTPClient option do not depend on it.
 func mergeContexts(originalCtx context.Context, oidcCtx context.Context) context.Context {
 	if customClient := originalCtx.Value(oidc.HTTPClientCtxKey); customClient != nil {
 		return originalCtx
@@ -232,10 +444,39 @@ func (s *CallbackServer) ExpectCallback(callbackReq *callbackRequest) {
 	s.callbackReqMu.Lock()
 	defer s.callbackReqMu.Unlock()
 	s.callbackReq = callbackReq
+
+	if callbackReq != nil {
+		s.assetsMu.Lock()
+		s.assets = callbackReq.assets
+		s.assetsMu.Unlock()
+	}
+}
+
+// assetsPath returns path under which assets of callback pages are served for given callback path.
+func assetsPath(callbackPath string) string {
+	return strings.TrimSuffix(callbackPath, "/") + "/assets/"
 }
 
-func (s *CallbackServer) Callback() <-chan *callbackResponse {
-	return s.callbackCh
+// assetsHandler serves assets of the callback pages of the last expected callback.
+func (s *CallbackServer) assetsHandler(w http.ResponseWriter, r *http.Request) {
+	s.assetsMu.Lock()
+	assets := s.assets
+	s.assetsMu.Unlock()
+
+	if assets == nil {
+		http.NotFound(w, r)
+		return
+	}
+	assets.ServeHTTP(w, r)
+}
+
+// cancelCallback stops expecting callback for given request, if it still is expected.
+func (s *CallbackServer) cancelCallback(callbackReq *callbackRequest) {
+	s.callbackReqMu.Lock()
+	defer s.callbackReqMu.Unlock()
+	if s.callbackReq == callbackReq {
+		s.callbackReq = nil
+	}
 }
 
 func (s *CallbackServer) RedirectURL() string {
@@ -2,13 +2,73 @@ package login
 
 import (
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/Bplotka/oidc"
 	"github.com/ghodss/yaml"
 )
 
 // Config is a login configuration. It does not contain oidc configuration.
 type Config struct {
 	NonceCheck bool `json:"include_nonce"`
+
+	// IncrementalConsent if true, makes token source treat scopes from OIDCConfig as required: cached token that was
+	// not granted all of them is not used (and not refreshed, since refresh cannot add scopes). Instead, user logs in
+	// again, asking for required scopes merged with scopes already granted, so the cached token accumulates scopes
+	// over time. This allows CLIs to unlock features progressively by constructing token source with more scopes
+	// for the same cache.
+	//
+	// Cache key intentionally does not include scopes (e.g disk cache file is named after the client ID only): token
+	// sources with different scopes share single token, which has the union of their scopes, instead of logging in
+	// separately with each scope set. Without IncrementalConsent, scopes of the cached token are not checked, so use
+	// separate caches for token sources that need different scopes.
+	IncrementalConsent bool `json:"incremental_consent"`
+
+	// NoBrowser if true, makes login print auth URL to stderr instead of opening browser and read the code or redirect
+	// URL pasted by the user from stdin (see WithManualCodeEntry), e.g in SSH sessions and containers. Callback server
+	// is optional then; without it, OOBRedirectURL is used.
+	NoBrowser bool `json:"no_browser"`
+
+	// ClientOptions are passed to the oidc.Client used by the token source e.g oidc.WithAuditHook.
+	ClientOptions []oidc.Option `json:"-"`
+
+	// LoginTimeout if specified, limits how long login waits for the user to finish it in the browser, instead of
+	// DefaultLoginTimeout (or DefaultManualLoginTimeout with NoBrowser). Token source returns *TimeoutError then.
+	LoginTimeout time.Duration `json:"-"`
+
+	// FlowOptions are passed to the login Flow used by the token source, e.g WithCallbackPages to render callback
+	// pages of this token source instead of the package-wide OKCallbackResponse and ErrCallbackResponse.
+	FlowOptions []FlowOption `json:"-"`
+
+	// MinAccessTokenValidity if specified, makes token source refuse to use access tokens that expire in less than
+	// given duration. Such tokens are refreshed instead.
+	MinAccessTokenValidity time.Duration `json:"-"`
+
+	// OnNewToken if not nil, is called with every new token obtained by login or refresh, after it is cached, e.g to
+	// update metrics or rotate downstream credentials. It is not called for tokens read from cache. It is called while
+	// token source is locked, so it must not use the token source.
+	OnNewToken func(*oidc.Token) `json:"-"`
+
+	// Logger if not nil, is used instead of std logger given to token source constructors. It is also passed to the
+	// oidc.Client with oidc.WithLogger. Tokens and secrets are redacted before they reach it.
+	Logger oidc.Logger `json:"-"`
+}
+
+// clientOptions returns options of the oidc.Client used by the token source.
+func (c Config) clientOptions() []oidc.Option {
+	if c.Logger == nil {
+		return c.ClientOptions
+	}
+	return append([]oidc.Option{oidc.WithLogger(c.Logger)}, c.ClientOptions...)
+}
+
+// loggerOr returns Logger if set, otherwise std logger.
+func (c Config) loggerOr(std *log.Logger) oidc.Logger {
+	if c.Logger != nil {
+		return oidc.RedactLogger(c.Logger)
+	}
+	return oidc.NewStdLogger(std)
 }
 
 // ConfigFromYaml parses config from yaml file.
@@ -28,6 +88,10 @@ type OIDCConfig struct {
 	ClientID     string   `json:"client_id"`
 	ClientSecret string   `json:"secret"`
 	Scopes       []string `json:"scopes"`
+	// Resources are URIs of target APIs that tokens are requested for (RFC 8707).
+	Resources []string `json:"resources,omitempty"`
+	// RedirectURIs are redirect URIs registered for the client. See NewServerForRedirectURIs.
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
 }
 
 // OIDCConfigFromYaml parses config from yaml file.
@@ -21,6 +21,9 @@ const DefaultCachePath = "$HOME/.oidc_keys"
 type Cache struct {
 	cfg       login.OIDCConfig
 	storePath string
+
+	// protector if not nil, protects the key that tokens are encrypted with.
+	protector KeyProtector
 }
 
 // NewTokenCache constructs disk cache.
@@ -28,6 +31,14 @@ func NewCache(path string, cfg login.OIDCConfig) *Cache {
 	return &Cache{cfg: cfg, storePath: os.ExpandEnv(path)}
 }
 
+// NewEncryptedCache constructs disk cache that encrypts tokens with AES-GCM using fresh key on every save. The key is
+// protected with given KeyProtector and stored alongside, e.g NewNativeKeyProtector(nil) gives at-rest protection
+// bound to the OS user without user-managed secrets. Unencrypted token cached before, or token encrypted in older
+// format, is still read and gets migrated to the current format right away.
+func NewEncryptedCache(path string, cfg login.OIDCConfig, protector KeyProtector) *Cache {
+	return &Cache{cfg: cfg, storePath: os.ExpandEnv(path), protector: protector}
+}
+
 func (c *Cache) getOrCreateStoreDir() (string, error) {
 	err := os.MkdirAll(c.storePath, os.ModeDir|0700)
 	return c.storePath, err
@@ -53,11 +64,24 @@ func (c *Cache) Token() (*oidc.Token, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get cached token code. Err: %v", err)
 	}
+	var outdated bool
+	if c.protector != nil {
+		bytes, outdated, err = c.decrypt(bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decrypt cached token. Err: %v", err)
+		}
+	}
 	token := &oidc.Token{}
 	if err := json.Unmarshal(bytes, token); err != nil {
 		return nil, fmt.Errorf("Failed to unmarshal token JSON. Err: %v", err)
 	}
 
+	if outdated {
+		// Migrate to the current format right away. If it fails, token is still usable and migration is repeated on
+		// next save.
+		_ = c.SaveToken(token)
+	}
+
 	return token, nil
 }
 
@@ -72,6 +96,12 @@ func (c *Cache) SaveToken(token *oidc.Token) error {
 	if err != nil {
 		return err
 	}
+	if c.protector != nil {
+		marshaledToken, err = c.encrypt(marshaledToken)
+		if err != nil {
+			return fmt.Errorf("Failed to encrypt token. Err: %v", err)
+		}
+	}
 
 	err = ioutil.WriteFile(filepath.Join(storeDir, c.tokenCacheFileName()), marshaledToken, 0600)
 	if err != nil {
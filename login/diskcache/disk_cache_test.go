@@ -0,0 +1,166 @@
+package disk
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bplotka/oidc"
+	"github.com/Bplotka/oidc/login"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// Test vector from https://tools.ietf.org/html/rfc7914#section-11.
+	assert.Equal(t,
+		"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783",
+		hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)),
+	)
+}
+
+type failingKeyProtector struct{}
+
+func (failingKeyProtector) Protect(string, []byte) ([]byte, error) {
+	return nil, errors.New("not available")
+}
+
+func (failingKeyProtector) Unprotect(string, []byte) ([]byte, error) {
+	return nil, errors.New("not available")
+}
+
+func TestEncryptedCache(t *testing.T) {
+	oldIterations := pbkdf2Iterations
+	pbkdf2Iterations = 1000
+	defer func() {
+		pbkdf2Iterations = oldIterations
+	}()
+
+	dir, err := ioutil.TempDir("", "oidc-disk-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := login.OIDCConfig{ClientID: "client1"}
+	token := &oidc.Token{
+		AccessToken:  "access1",
+		RefreshToken: "refresh1",
+		IDToken:      "id1",
+	}
+
+	// Unencrypted token cached before encryption was enabled is still read.
+	require.NoError(t, NewCache(dir, cfg).SaveToken(token))
+
+	passphrase := NewPassphraseKeyProtector(func() ([]byte, error) { return []byte("pass1"), nil })
+	cache := NewEncryptedCache(dir, cfg, &fallbackKeyProtector{native: failingKeyProtector{}, fallback: passphrase})
+
+	cached, err := cache.Token()
+	require.NoError(t, err)
+	assert.Equal(t, token, cached)
+
+	require.NoError(t, cache.SaveToken(token))
+
+	raw, err := ioutil.ReadFile(filepath.Join(dir, cache.tokenCacheFileName()))
+	require.NoError(t, err)
+	assert.False(t, strings.Contains(string(raw), "refresh1"))
+	var e encryptedToken
+	require.NoError(t, json.Unmarshal(raw, &e))
+	assert.Equal(t, protectedByFallback, e.ProtectedKey[0])
+
+	cached, err = cache.Token()
+	require.NoError(t, err)
+	assert.Equal(t, token, cached)
+
+	// Wrong passphrase.
+	wrong := NewPassphraseKeyProtector(func() ([]byte, error) { return []byte("pass2"), nil })
+	_, err = NewEncryptedCache(dir, cfg, &fallbackKeyProtector{native: failingKeyProtector{}, fallback: wrong}).Token()
+	assert.Error(t, err)
+
+	// No fallback.
+	err = NewEncryptedCache(dir, cfg, &fallbackKeyProtector{native: failingKeyProtector{}}).SaveToken(token)
+	assert.Error(t, err)
+}
+
+func TestEncryptedCache_MigratesV1Format(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oidc-disk-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.Setenv("TEST_OIDC_CACHE_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32))))
+	defer os.Unsetenv("TEST_OIDC_CACHE_KEY")
+	cache := NewEncryptedCache(dir, login.OIDCConfig{ClientID: "client1"}, NewEnvKeyProtector("TEST_OIDC_CACHE_KEY"))
+	token := &oidc.Token{AccessToken: "access1", RefreshToken: "refresh1"}
+
+	// Write token the way v1 format did.
+	plaintext, err := json.Marshal(token)
+	require.NoError(t, err)
+	key := make([]byte, 32)
+	aead, err := newAEAD(key)
+	require.NoError(t, err)
+	nonce := make([]byte, aead.NonceSize())
+	protectedKey, err := cache.protector.Protect(cache.tokenCacheFileName(), key)
+	require.NoError(t, err)
+	raw, err := json.Marshal(encryptedToken{
+		Version:      encryptedFormatV1,
+		ProtectedKey: protectedKey,
+		Nonce:        nonce,
+		Ciphertext:   aead.Seal(nil, nonce, plaintext, []byte(cache.tokenCacheFileName())),
+	})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, cache.tokenCacheFileName()), raw, 0600))
+
+	cached, err := cache.Token()
+	require.NoError(t, err)
+	assert.Equal(t, token, cached)
+
+	raw, err = ioutil.ReadFile(filepath.Join(dir, cache.tokenCacheFileName()))
+	require.NoError(t, err)
+	var e encryptedToken
+	require.NoError(t, json.Unmarshal(raw, &e))
+	assert.Equal(t, encryptedFormatV2, e.Version, "token should be migrated on read")
+
+	cached, err = cache.Token()
+	require.NoError(t, err)
+	assert.Equal(t, token, cached)
+
+	// Unknown future format is rejected.
+	e.Version = 3
+	raw, err = json.Marshal(e)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, cache.tokenCacheFileName()), raw, 0600))
+	_, err = cache.Token()
+	assert.Error(t, err)
+}
+
+func TestEnvKeyProtector(t *testing.T) {
+	p := NewEnvKeyProtector("TEST_OIDC_CACHE_KEY")
+	_, err := p.Protect("name1", []byte("key1"))
+	assert.Error(t, err, "no key in env")
+
+	require.NoError(t, os.Setenv("TEST_OIDC_CACHE_KEY", base64.StdEncoding.EncodeToString([]byte("short"))))
+	defer os.Unsetenv("TEST_OIDC_CACHE_KEY")
+	_, err = p.Protect("name1", []byte("key1"))
+	assert.Error(t, err, "key too short")
+
+	envKey := make([]byte, 32)
+	envKey[0] = 1
+	require.NoError(t, os.Setenv("TEST_OIDC_CACHE_KEY", base64.StdEncoding.EncodeToString(envKey)))
+	protected, err := p.Protect("name1", []byte("key1"))
+	require.NoError(t, err)
+	key, err := p.Unprotect("name1", protected)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key1"), key)
+
+	_, err = p.Unprotect("name2", protected)
+	assert.Error(t, err, "name is authenticated")
+
+	envKey[0] = 2
+	require.NoError(t, os.Setenv("TEST_OIDC_CACHE_KEY", base64.StdEncoding.EncodeToString(envKey)))
+	_, err = p.Unprotect("name1", protected)
+	assert.Error(t, err, "wrong key")
+}
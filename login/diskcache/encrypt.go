@@ -0,0 +1,96 @@
+package disk
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// encryptedFormatV1 authenticates only the cache file name with the ciphertext.
+	encryptedFormatV1 = 1
+	// encryptedFormatV2 authenticates format version as well, so the file can't be passed as other format.
+	encryptedFormatV2 = 2
+
+	encryptedFormatVersion = encryptedFormatV2
+)
+
+// encryptedToken is the format of the encrypted cache file.
+type encryptedToken struct {
+	Version      int    `json:"version"`
+	ProtectedKey []byte `json:"protected_key"`
+	Nonce        []byte `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext"`
+}
+
+// additionalData returns data authenticated with the ciphertext in given format version.
+func additionalData(version int, name string) []byte {
+	if version == encryptedFormatV1 {
+		return []byte(name)
+	}
+	return []byte(fmt.Sprintf("oidc-token-cache/v%d/%s", version, name))
+}
+
+func (c *Cache) encrypt(plaintext []byte) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	name := c.tokenCacheFileName()
+	protectedKey, err := c.protector.Protect(name, key)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encryptedToken{
+		Version:      encryptedFormatVersion,
+		ProtectedKey: protectedKey,
+		Nonce:        nonce,
+		Ciphertext:   aead.Seal(nil, nonce, plaintext, additionalData(encryptedFormatVersion, name)),
+	})
+}
+
+// decrypt returns plaintext of the cache file. Outdated reports if the file is not in the current format (including
+// unencrypted token cached before encryption was enabled), so it should be migrated by saving the token again.
+func (c *Cache) decrypt(b []byte) (plaintext []byte, outdated bool, err error) {
+	var e encryptedToken
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, false, err
+	}
+	if e.ProtectedKey == nil && e.Ciphertext == nil {
+		// Token cached before encryption was enabled. Its JSON has "version" field as well (see
+		// oidc.TokenJSONVersion), so the format is told apart by fields that only encrypted cache file has.
+		return b, true, nil
+	}
+	if e.Version != encryptedFormatV1 && e.Version != encryptedFormatV2 {
+		return nil, false, fmt.Errorf("unsupported encrypted cache format version %d", e.Version)
+	}
+
+	name := c.tokenCacheFileName()
+	key, err := c.protector.Unprotect(name, e.ProtectedKey)
+	if err != nil {
+		return nil, false, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(e.Nonce) != aead.NonceSize() {
+		return nil, false, errors.New("invalid nonce")
+	}
+	plaintext, err = aead.Open(nil, e.Nonce, e.Ciphertext, additionalData(e.Version, name))
+	if err != nil {
+		return nil, false, err
+	}
+	return plaintext, e.Version != encryptedFormatVersion, nil
+}
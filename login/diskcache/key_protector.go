@@ -0,0 +1,236 @@
+package disk
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyProtector protects the key that cached tokens are encrypted with, so tokens are protected at rest.
+type KeyProtector interface {
+	// Protect returns protected form of the key that is stored alongside encrypted tokens. Name identifies the cache.
+	Protect(name string, key []byte) ([]byte, error)
+	// Unprotect returns the key from its protected form.
+	Unprotect(name string, protected []byte) ([]byte, error)
+}
+
+// NewNativeKeyProtector returns KeyProtector backed by the OS: key is wrapped with DPAPI on Windows, stored in Keychain
+// on macOS and in Secret Service (via secret-tool from libsecret) on Linux. Native protection is bound to the OS user
+// and requires no user-managed secrets. If native protection is not available (e.g no Secret Service is running),
+// fallback is used instead, unless it is nil.
+func NewNativeKeyProtector(fallback KeyProtector) KeyProtector {
+	return &fallbackKeyProtector{native: nativeKeyProtector(), fallback: fallback}
+}
+
+const (
+	protectedByNative   byte = 1
+	protectedByFallback byte = 2
+)
+
+// fallbackKeyProtector prefixes protected key with the protector that was used, so it can be unprotected by the same
+// one, no matter if native protection is available now.
+type fallbackKeyProtector struct {
+	native   KeyProtector
+	fallback KeyProtector
+}
+
+func (p *fallbackKeyProtector) Protect(name string, key []byte) ([]byte, error) {
+	protected, err := p.native.Protect(name, key)
+	if err == nil {
+		return append([]byte{protectedByNative}, protected...), nil
+	}
+	if p.fallback == nil {
+		return nil, err
+	}
+
+	protected, fErr := p.fallback.Protect(name, key)
+	if fErr != nil {
+		return nil, fmt.Errorf("native key protection failed: %v; fallback failed: %v", err, fErr)
+	}
+	return append([]byte{protectedByFallback}, protected...), nil
+}
+
+func (p *fallbackKeyProtector) Unprotect(name string, protected []byte) ([]byte, error) {
+	if len(protected) == 0 {
+		return nil, errors.New("empty protected key")
+	}
+	switch protected[0] {
+	case protectedByNative:
+		return p.native.Unprotect(name, protected[1:])
+	case protectedByFallback:
+		if p.fallback == nil {
+			return nil, errors.New("key was protected by fallback protector, but none is configured")
+		}
+		return p.fallback.Unprotect(name, protected[1:])
+	}
+	return nil, fmt.Errorf("unknown key protector %d", protected[0])
+}
+
+// pbkdf2Iterations is the number of PBKDF2-HMAC-SHA256 iterations used to derive key from passphrase.
+var pbkdf2Iterations = 600000
+
+const saltSize = 16
+
+// PassphraseKeyProtector wraps the key with AES-GCM key derived from the passphrase with PBKDF2-HMAC-SHA256.
+type PassphraseKeyProtector struct {
+	passphrase func() ([]byte, error)
+}
+
+// NewPassphraseKeyProtector constructs PassphraseKeyProtector. Passphrase is called on every Protect and Unprotect,
+// e.g to prompt the user.
+func NewPassphraseKeyProtector(passphrase func() ([]byte, error)) *PassphraseKeyProtector {
+	return &PassphraseKeyProtector{passphrase: passphrase}
+}
+
+// Protect wraps the key. Protected form is salt, nonce and the encrypted key. Name is authenticated as well.
+func (p *PassphraseKeyProtector) Protect(name string, key []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := p.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	return sealKey(aead, salt, name, key)
+}
+
+// Unprotect unwraps the key.
+func (p *PassphraseKeyProtector) Unprotect(name string, protected []byte) ([]byte, error) {
+	if len(protected) < saltSize {
+		return nil, errors.New("protected key is too short")
+	}
+	aead, err := p.aead(protected[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	key, err := openKey(aead, protected[saltSize:], name)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted key")
+	}
+	return key, nil
+}
+
+func (p *PassphraseKeyProtector) aead(salt []byte) (cipher.AEAD, error) {
+	passphrase, err := p.passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passphrase. Err: %v", err)
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	return newAEAD(pbkdf2SHA256(passphrase, salt, pbkdf2Iterations, 32))
+}
+
+// EnvKeyProtector wraps the key with AES-GCM using 256-bit key given, base64 encoded, in environment variable. It is
+// meant for environments without keyring and without user to prompt for passphrase, e.g CI, where the key comes from
+// the secret store of the environment.
+type EnvKeyProtector struct {
+	envVar string
+}
+
+// NewEnvKeyProtector constructs EnvKeyProtector. Variable is read on every Protect and Unprotect. Key can be generated
+// with e.g `openssl rand -base64 32`.
+func NewEnvKeyProtector(envVar string) *EnvKeyProtector {
+	return &EnvKeyProtector{envVar: envVar}
+}
+
+// Protect wraps the key. Protected form is nonce and the encrypted key. Name is authenticated as well.
+func (p *EnvKeyProtector) Protect(name string, key []byte) ([]byte, error) {
+	aead, err := p.aead()
+	if err != nil {
+		return nil, err
+	}
+	return sealKey(aead, nil, name, key)
+}
+
+// Unprotect unwraps the key.
+func (p *EnvKeyProtector) Unprotect(name string, protected []byte) ([]byte, error) {
+	aead, err := p.aead()
+	if err != nil {
+		return nil, err
+	}
+	key, err := openKey(aead, protected, name)
+	if err != nil {
+		return nil, fmt.Errorf("wrong key in %s or corrupted key", p.envVar)
+	}
+	return key, nil
+}
+
+func (p *EnvKeyProtector) aead() (cipher.AEAD, error) {
+	encoded := os.Getenv(p.envVar)
+	if encoded == "" {
+		return nil, fmt.Errorf("no key in %s environment variable", p.envVar)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key in %s is not valid base64. Err: %v", p.envVar, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key in %s must have 32 bytes, got %d", p.envVar, len(key))
+	}
+	return newAEAD(key)
+}
+
+// sealKey encrypts the key with random nonce, authenticating name. Result is prefix, nonce and the encrypted key.
+func sealKey(aead cipher.AEAD, prefix []byte, name string, key []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(prefix, nonce...)
+	return aead.Seal(out, nonce, key, []byte(name)), nil
+}
+
+// openKey decrypts the key sealed with sealKey, without prefix.
+func openKey(aead cipher.AEAD, protected []byte, name string) ([]byte, error) {
+	if len(protected) < aead.NonceSize() {
+		return nil, errors.New("protected key is too short")
+	}
+	return aead.Open(nil, protected[:aead.NonceSize()], protected[aead.NonceSize():], []byte(name))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 implements PBKDF2 with HMAC-SHA256 as PRF. See https://tools.ietf.org/html/rfc8018#section-5.2.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var (
+		dk  []byte
+		buf [4]byte
+	)
+	for block := uint32(1); len(dk) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], block)
+		prf.Write(buf[:])
+		u := prf.Sum(nil)
+
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		dk = append(dk, t...)
+	}
+	return dk[:keyLen]
+}
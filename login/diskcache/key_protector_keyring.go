@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package disk
+
+import (
+	"fmt"
+
+	"github.com/Bplotka/oidc/login/internal/oskeyring"
+)
+
+// keyringKeyProtector stores the key in the OS keyring: Keychain on macOS and Secret Service on Linux. Protected form
+// is just the reference to the keyring item. On other platforms it always fails, so fallback is used.
+type keyringKeyProtector struct {
+	store oskeyring.Store
+}
+
+func nativeKeyProtector() KeyProtector {
+	return keyringKeyProtector{store: oskeyring.Store{Service: "oidc-token-cache", Label: "OIDC token cache key"}}
+}
+
+func (p keyringKeyProtector) Protect(name string, key []byte) ([]byte, error) {
+	if err := p.store.Set(name, key); err != nil {
+		return nil, fmt.Errorf("failed to store key in keyring. Err: %v", err)
+	}
+	return []byte(name), nil
+}
+
+func (p keyringKeyProtector) Unprotect(name string, protected []byte) ([]byte, error) {
+	key, err := p.store.Get(string(protected))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key from keyring. Err: %v", err)
+	}
+	return key, nil
+}
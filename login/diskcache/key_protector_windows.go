@@ -0,0 +1,74 @@
+package disk
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	crypt32  = syscall.NewLazyDLL("crypt32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = kernel32.NewProc("LocalFree")
+)
+
+const cryptProtectUIForbidden = 0x1
+
+// dataBlob is DATA_BLOB structure of the DPAPI.
+type dataBlob struct {
+	cbData uint32
+	pbData *byte
+}
+
+func newDataBlob(d []byte) *dataBlob {
+	if len(d) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{cbData: uint32(len(d)), pbData: &d[0]}
+}
+
+func (b *dataBlob) bytes() []byte {
+	d := make([]byte, b.cbData)
+	copy(d, (*[1 << 30]byte)(unsafe.Pointer(b.pbData))[:b.cbData:b.cbData])
+	return d
+}
+
+// dpapiKeyProtector wraps the key with DPAPI, using the current user's credentials. Name is used as additional entropy.
+type dpapiKeyProtector struct{}
+
+func nativeKeyProtector() KeyProtector {
+	return dpapiKeyProtector{}
+}
+
+func (dpapiKeyProtector) Protect(name string, key []byte) ([]byte, error) {
+	return dpapiCall(procCryptProtectData, name, key)
+}
+
+func (dpapiKeyProtector) Unprotect(name string, protected []byte) ([]byte, error) {
+	return dpapiCall(procCryptUnprotectData, name, protected)
+}
+
+func dpapiCall(proc *syscall.LazyProc, name string, in []byte) ([]byte, error) {
+	if err := proc.Find(); err != nil {
+		return nil, fmt.Errorf("DPAPI is not available. Err: %v", err)
+	}
+
+	var out dataBlob
+	r, _, err := proc.Call(
+		uintptr(unsafe.Pointer(newDataBlob(in))),
+		0,
+		uintptr(unsafe.Pointer(newDataBlob([]byte(name)))),
+		0,
+		0,
+		cryptProtectUIForbidden,
+		uintptr(unsafe.Pointer(&out)),
+	)
+	if r == 0 {
+		return nil, fmt.Errorf("%s failed. Err: %v", proc.Name, err)
+	}
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(out.pbData)))
+	return out.bytes(), nil
+}
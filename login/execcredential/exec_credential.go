@@ -0,0 +1,102 @@
+// Package execcredential prints tokens as Kubernetes ExecCredential, so login token sources can back kubectl exec
+// credential plugins (see https://kubernetes.io/docs/reference/access-authn-authz/authentication/#client-go-credential-plugins).
+package execcredential
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Bplotka/oidc"
+)
+
+const (
+	// DefaultAPIVersion is the ExecCredential API version used when kubectl does not specify one.
+	DefaultAPIVersion = "client.authentication.k8s.io/v1"
+
+	// execInfoEnv is the environment variable kubectl passes ExecCredential with requested API version in.
+	execInfoEnv = "KUBERNETES_EXEC_INFO"
+)
+
+// ExecCredential is the client.authentication.k8s.io ExecCredential object read by kubectl from plugin's stdout.
+type ExecCredential struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Status     *ExecCredentialStatus `json:"status,omitempty"`
+}
+
+// ExecCredentialStatus holds the credential itself.
+type ExecCredentialStatus struct {
+	// ExpirationTimestamp is when the token expires. kubectl runs the plugin again after that time.
+	ExpirationTimestamp *time.Time `json:"expirationTimestamp,omitempty"`
+	// Token is the bearer token sent to the API server.
+	Token string `json:"token"`
+}
+
+// New builds ExecCredential of given API version with ID token of t, since Kubernetes OIDC authenticator accepts ID
+// tokens. Expiration timestamp is taken from "exp" claim of the ID token, so kubectl does not use the token after the
+// API server starts rejecting it.
+func New(apiVersion string, t *oidc.Token) (*ExecCredential, error) {
+	if t.IDToken == "" {
+		return nil, errors.New("execcredential: token has no ID token")
+	}
+
+	var claims struct {
+		Expiry oidc.NumericDate `json:"exp"`
+	}
+	if err := t.UnverifiedClaims(&claims); err != nil {
+		return nil, fmt.Errorf("execcredential: failed to parse ID token claims: %v", err)
+	}
+
+	status := &ExecCredentialStatus{Token: t.IDToken}
+	if claims.Expiry != 0 {
+		// Timestamp is RFC 3339 in UTC, as written by Kubernetes itself.
+		expiry := claims.Expiry.Time().UTC()
+		status.ExpirationTimestamp = &expiry
+	}
+	return &ExecCredential{
+		APIVersion: apiVersion,
+		Kind:       "ExecCredential",
+		Status:     status,
+	}, nil
+}
+
+// APIVersion returns ExecCredential API version requested by kubectl in KUBERNETES_EXEC_INFO environment variable, or
+// DefaultAPIVersion if it is not set.
+func APIVersion() (string, error) {
+	info := os.Getenv(execInfoEnv)
+	if info == "" {
+		return DefaultAPIVersion, nil
+	}
+
+	var c ExecCredential
+	if err := json.Unmarshal([]byte(info), &c); err != nil {
+		return "", fmt.Errorf("execcredential: failed to parse %s: %v", execInfoEnv, err)
+	}
+	if c.APIVersion == "" {
+		return DefaultAPIVersion, nil
+	}
+	return c.APIVersion, nil
+}
+
+// Write obtains token from src (running login flow if needed) and writes it to w as ExecCredential of API version
+// requested by kubectl. Anything else the plugin prints must go to stderr, since kubectl parses whole stdout.
+func Write(w io.Writer, src oidc.TokenSource) error {
+	apiVersion, err := APIVersion()
+	if err != nil {
+		return err
+	}
+
+	t, err := src.OIDCToken()
+	if err != nil {
+		return fmt.Errorf("execcredential: failed to obtain token: %v", err)
+	}
+	c, err := New(apiVersion, t)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(c)
+}
@@ -0,0 +1,36 @@
+package execcredential
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/Bplotka/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	idToken := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"subject1","exp":1507820645}`)) + ".sig"
+	src := oidc.StaticTokenSource(&oidc.Token{AccessToken: "access1", IDToken: idToken})
+
+	os.Unsetenv(execInfoEnv)
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, src))
+	assert.JSONEq(t, `{
+		"apiVersion": "client.authentication.k8s.io/v1",
+		"kind": "ExecCredential",
+		"status": {"expirationTimestamp": "2017-10-12T15:04:05Z", "token": "`+idToken+`"}
+	}`, out.String())
+
+	// API version requested by kubectl is used.
+	os.Setenv(execInfoEnv, `{"apiVersion": "client.authentication.k8s.io/v1beta1", "kind": "ExecCredential", "spec": {"interactive": true}}`)
+	defer os.Unsetenv(execInfoEnv)
+	out.Reset()
+	require.NoError(t, Write(&out, src))
+	assert.Contains(t, out.String(), `"apiVersion":"client.authentication.k8s.io/v1beta1"`)
+
+	_, err := New(DefaultAPIVersion, &oidc.Token{AccessToken: "access1"})
+	assert.Error(t, err)
+}
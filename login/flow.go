@@ -0,0 +1,321 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Bplotka/oidc"
+)
+
+// FlowStage is a stage of the login Flow reported to progress callback.
+type FlowStage int
+
+const (
+	// StageWaitingForCallback means that browser is being pointed to the provider's auth URL (or the URL is shown in
+	// manual code entry mode) and flow waits for the redirect to the callback server.
+	StageWaitingForCallback FlowStage = iota
+	// StageExchangingCode means that valid callback was received and code is being exchanged for token.
+	StageExchangingCode
+	// StageDone means that token was obtained.
+	StageDone
+)
+
+// LoginResult is the outcome of successful login Flow.
+type LoginResult struct {
+	// Token is the token obtained from provider. It is not verified.
+	Token *oidc.Token
+	// Nonce is the nonce that ID token was requested with. It is empty if nonce check is disabled.
+	Nonce string
+}
+
+// TimeoutError is returned by Flow.Start when login was not finished in time, e.g because the user abandoned the
+// browser flow. Timeout is set by WithTimeout or deadline of the context given to Start.
+type TimeoutError struct {
+	// After is how long the flow waited for login until its deadline, with millisecond precision.
+	After time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("oidc: login timed out after %v waiting for callback. Please retry and open the URL printed above in a browser if it doesn't open automatically", e.After)
+}
+
+// Timeout returns true, so the error can be checked like net.Error.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// FlowOption configures Flow.
+type FlowOption func(*Flow)
+
+// WithNonceCheck makes flow request ID token with random nonce returned in LoginResult.
+func WithNonceCheck() FlowOption {
+	return func(f *Flow) {
+		f.nonceCheck = true
+	}
+}
+
+// WithTimeout limits how long flow waits for the user to finish login. Start then returns *TimeoutError. Flow without
+// timeout waits until context given to Start is done.
+func WithTimeout(timeout time.Duration) FlowOption {
+	return func(f *Flow) {
+		f.timeout = timeout
+	}
+}
+
+// WithOpenBrowser overrides function that opens auth URL in the user's default browser.
+func WithOpenBrowser(openBrowser func(authURL string) error) FlowOption {
+	return func(f *Flow) {
+		f.openBrowser = openBrowser
+	}
+}
+
+// WithProgressCallback sets callback invoked on every stage of the flow, e.g to display progress. Like other flow
+// callbacks, it is invoked in order on the goroutine calling Flow.Start.
+func WithProgressCallback(onProgress func(FlowStage)) FlowOption {
+	return func(f *Flow) {
+		f.onProgress = onProgress
+	}
+}
+
+// WithOnAuthURL sets callback invoked with the provider's auth URL before it is opened in browser, e.g to show it in a
+// dialog in case browser does not open.
+func WithOnAuthURL(onAuthURL func(authURL string)) FlowOption {
+	return func(f *Flow) {
+		f.onAuthURL = onAuthURL
+	}
+}
+
+// WithOnCode sets callback invoked when valid callback with code was received, before code is exchanged for token,
+// e.g to display a spinner.
+func WithOnCode(onCode func()) FlowOption {
+	return func(f *Flow) {
+		f.onCode = onCode
+	}
+}
+
+// WithOnToken sets callback invoked with token obtained from the provider.
+func WithOnToken(onToken func(*oidc.Token)) FlowOption {
+	return func(f *Flow) {
+		f.onToken = onToken
+	}
+}
+
+// WithOnCallbackError sets callback invoked when callback failed. For errors returned by the provider in the
+// redirect, err is *ProviderError with provider's error description.
+func WithOnCallbackError(onError func(err error)) FlowOption {
+	return func(f *Flow) {
+		f.onError = onError
+	}
+}
+
+// WithAuthCodeOptions adds parameters to the authorization request e.g oidc.WithPrompt.
+func WithAuthCodeOptions(opts ...oidc.AuthCodeOption) FlowOption {
+	return func(f *Flow) {
+		f.authOpts = append(f.authOpts, opts...)
+	}
+}
+
+// WithJWTResponseMode makes flow request JWT-secured authorization response (JARM), as required e.g by FAPI 2.0
+// profiles. Callback verifies the response JWT and rejects unsigned or tampered responses. supportedSigningAlgs are
+// accepted signing algorithms of the response, RS256 if not specified.
+func WithJWTResponseMode(supportedSigningAlgs ...string) FlowOption {
+	return func(f *Flow) {
+		f.jarm = true
+		f.jarmSigningAlgs = supportedSigningAlgs
+	}
+}
+
+// WithCallbackResponses makes flow respond on callback with given responses instead of the callback server's or
+// package-wide ones, so libraries sharing a binary can customize them without clobbering each other. See
+// WithCallbackPages for templated pages.
+func WithCallbackResponses(responses CallbackResponses) FlowOption {
+	return func(f *Flow) {
+		f.respondOK = responses.OK
+		f.respondErr = responses.Error
+	}
+}
+
+// Flow is a single browser-based OIDC auth code login. Unlike OIDCTokenSource, it does not cache tokens, so it is
+// meant to be composed into applications that manage tokens on their own.
+// NOTE: Flows sharing the same CallbackServer cannot be started concurrently.
+type Flow struct {
+	client      *oidc.Client
+	cfg         oidc.Config
+	callbackSrv *CallbackServer
+
+	nonceCheck   bool
+	timeout      time.Duration
+	authOpts     []oidc.AuthCodeOption
+	openBrowser  func(string) error
+	onProgress   func(FlowStage)
+	onAuthURL    func(string)
+	onCode       func()
+	onToken      func(*oidc.Token)
+	onError      func(error)
+	genRandToken func() string
+
+	// Set by WithJWTResponseMode.
+	jarm            bool
+	jarmSigningAlgs []string
+
+	// Set by WithManualCodeEntry.
+	manualIn  io.Reader
+	manualOut io.Writer
+
+	// Set by WithCallbackPages or WithCallbackResponses.
+	respondOK  func(w http.ResponseWriter, r *http.Request)
+	respondErr func(w http.ResponseWriter, r *http.Request, err error)
+	assets     http.Handler
+}
+
+// NewFlow constructs login Flow. Redirect URL of the cfg is set to the callbackSrv one.
+func NewFlow(client *oidc.Client, cfg oidc.Config, callbackSrv *CallbackServer, opts ...FlowOption) *Flow {
+	f := &Flow{
+		client:       client,
+		cfg:          cfg,
+		callbackSrv:  callbackSrv,
+		openBrowser:  openBrowser,
+		onProgress:   func(FlowStage) {},
+		onAuthURL:    func(string) {},
+		onCode:       func() {},
+		onToken:      func(*oidc.Token) {},
+		onError:      func(error) {},
+		genRandToken: rand128Bits,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Start opens browser with the provider's auth URL and blocks until callback with code is received and exchanged for
+// token, or the context is done. Cancel the context to abort the flow. If it times out (see WithTimeout),
+// *TimeoutError is returned. Callback server started by the flow (see NewLoopbackServer) is shut down before Start
+// returns.
+func (f *Flow) Start(ctx context.Context) (LoginResult, error) {
+	if f.callbackSrv == nil && f.manualIn == nil {
+		return LoginResult{}, errors.New("oidc: no callback server to receive login callback")
+	}
+
+	started := time.Now()
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+
+	state := f.genRandToken()
+	nonce := ""
+	authOpts := []oidc.AuthCodeOption{oidc.WithState(state)}
+	if f.nonceCheck {
+		nonce = f.genRandToken()
+		authOpts = append(authOpts, oidc.WithNonce(nonce))
+	}
+	if f.jarm {
+		authOpts = append(authOpts, oidc.WithResponseMode(oidc.ResponseModeJWT))
+	}
+	authOpts = append(authOpts, f.authOpts...)
+
+	redirectURL := OOBRedirectURL
+	if f.callbackSrv != nil {
+		var (
+			stopSrv func()
+			err     error
+		)
+		redirectURL, stopSrv, err = f.callbackSrv.start()
+		if err != nil {
+			return LoginResult{}, fmt.Errorf("oidc: failed to start callback server: %v", err)
+		}
+		defer stopSrv()
+	}
+
+	cfg := f.cfg
+	cfg.RedirectURL = redirectURL
+
+	callbackReq := &callbackRequest{
+		ctx:           ctx,
+		expectedState: state,
+		client:        f.client,
+		cfg:           cfg,
+		result:        make(chan *callbackResponse, 2),
+		respondOK:     f.respondOK,
+		respondErr:    f.respondErr,
+		assets:        f.assets,
+	}
+	if f.jarm {
+		callbackReq.responseVerifier = f.client.Verifier(oidc.VerificationConfig{
+			ClientID:             cfg.ClientID,
+			SupportedSigningAlgs: f.jarmSigningAlgs,
+		})
+	}
+	if f.callbackSrv != nil {
+		f.callbackSrv.ExpectCallback(callbackReq)
+		defer f.callbackSrv.cancelCallback(callbackReq)
+	}
+
+	authURL := f.client.AuthCodeURLWithOptions(cfg, authOpts...)
+	if f.client.Discovery().RequirePAR {
+		var err error
+		authURL, err = f.client.PushedAuthCodeURL(ctx, cfg, authOpts...)
+		if err != nil {
+			return LoginResult{}, err
+		}
+	}
+	f.onAuthURL(authURL)
+	// Reported before the URL is opened, so it always precedes StageExchangingCode.
+	f.onProgress(StageWaitingForCallback)
+	var manual <-chan *manualResponse
+	if f.manualIn != nil {
+		manual = promptManualResponse(f.manualIn, f.manualOut, authURL, state)
+	} else if err := f.openBrowser(authURL); err != nil {
+		return LoginResult{}, fmt.Errorf("oidc: Failed to open browser. Please open this URL in browser: %s Err: %v", authURL, err)
+	}
+
+	var msg *callbackResponse
+	for msg == nil {
+		select {
+		// TODO(bplotka): What if someone will scan our callback endpoint?
+		case m := <-callbackReq.result:
+			if m.codeReceived {
+				// Callback server is exchanging the code.
+				f.exchangingCode()
+				continue
+			}
+			msg = m
+			// Give some time for server to finish request.
+			time.Sleep(200 * time.Millisecond)
+		case in := <-manual:
+			msg = &callbackResponse{err: in.err}
+			if in.err == nil {
+				msg.token, msg.err = callbackReq.exchange(ctx, in.form, f.exchangingCode)
+			}
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				// Deadline is either the flow's timeout or the caller's one, if earlier.
+				after := f.timeout
+				if deadline, ok := ctx.Deadline(); ok {
+					after = deadline.Sub(started)
+				}
+				return LoginResult{}, &TimeoutError{After: after - after%time.Millisecond}
+			}
+			return LoginResult{}, fmt.Errorf("oidc: login flow aborted: %v", ctx.Err())
+		}
+	}
+	if msg.err != nil {
+		f.onError(msg.err)
+		return LoginResult{}, fmt.Errorf("oidc: Callback error: %v", msg.err)
+	}
+	f.onToken(msg.token)
+	f.onProgress(StageDone)
+	return LoginResult{Token: msg.token, Nonce: nonce}, nil
+}
+
+// exchangingCode invokes callbacks for valid callback received, before its code is exchanged for token.
+func (f *Flow) exchangingCode() {
+	f.onCode()
+	f.onProgress(StageExchangingCode)
+}
@@ -0,0 +1,292 @@
+package login
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/Bplotka/oidc"
+)
+
+func (s *TokenSourceTestSuite) Test_Flow_OKCallback() {
+	const expectedWord = "secret_token"
+
+	var stages []FlowStage
+	flow := NewFlow(
+		s.oidcSource.oidcClient,
+		oidc.Config{
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+			Scopes:       s.testOIDCCfg.Scopes,
+		},
+		s.oidcSource.callbackSrv,
+		WithNonceCheck(),
+		WithOpenBrowser(s.callSuccessfulCallback(expectedWord)),
+		WithProgressCallback(func(stage FlowStage) {
+			stages = append(stages, stage)
+		}),
+	)
+	flow.genRandToken = func() string {
+		return expectedWord
+	}
+
+	res, err := flow.Start(s.provider.Context())
+	s.Require().NoError(err)
+
+	s.Equal(testToken, *res.Token)
+	s.Equal(expectedWord, res.Nonce)
+	s.Equal([]FlowStage{StageWaitingForCallback, StageExchangingCode, StageDone}, stages)
+	s.Equal(0, s.provider.Mock().Len())
+}
+
+func (s *TokenSourceTestSuite) Test_Flow_Canceled() {
+	ctx, cancel := context.WithCancel(s.provider.Context())
+
+	flow := NewFlow(
+		s.oidcSource.oidcClient,
+		oidc.Config{ClientID: testClientID},
+		s.oidcSource.callbackSrv,
+		WithOpenBrowser(func(string) error {
+			// User never finishes login.
+			cancel()
+			return nil
+		}),
+	)
+
+	_, err := flow.Start(ctx)
+	s.Require().Error(err)
+	s.Equal("oidc: login flow aborted: context canceled", err.Error())
+}
+
+func (s *TokenSourceTestSuite) Test_Flow_ProviderError() {
+	const expectedWord = "secret_token"
+
+	var (
+		authURL     string
+		callbackErr error
+	)
+	flow := NewFlow(
+		s.oidcSource.oidcClient,
+		oidc.Config{ClientID: testClientID},
+		s.oidcSource.callbackSrv,
+		WithOnAuthURL(func(u string) {
+			authURL = u
+		}),
+		WithOnCallbackError(func(err error) {
+			callbackErr = err
+		}),
+		WithOnToken(func(*oidc.Token) {
+			s.T().Error("OnToken should not be invoked")
+		}),
+		WithOpenBrowser(func(urlToGet string) error {
+			redirectURL, err := stripArgFromURL("redirect_uri", urlToGet)
+			s.Require().NoError(err)
+
+			go func() {
+				res, err := http.Get(fmt.Sprintf(
+					"%s?error=access_denied&error_description=%s&state=%s",
+					redirectURL,
+					url.QueryEscape("User denied access"),
+					expectedWord,
+				))
+				s.Require().NoError(err)
+				s.Equal(http.StatusOK, res.StatusCode)
+			}()
+			return nil
+		}),
+	)
+	flow.genRandToken = func() string {
+		return expectedWord
+	}
+
+	_, err := flow.Start(s.provider.Context())
+	s.Require().Error(err)
+	s.Equal("oidc: Callback error: Got error from provider: access_denied Desc: User denied access", err.Error())
+
+	s.NotEmpty(authURL)
+	s.Require().IsType(&ProviderError{}, callbackErr)
+	s.Equal("access_denied", callbackErr.(*ProviderError).Code)
+	s.Equal("User denied access", callbackErr.(*ProviderError).Description)
+}
+
+func (s *TokenSourceTestSuite) Test_Flow_JWTResponseMode() {
+	const expectedWord = "secret_token"
+
+	response, jwkSetJSON := s.provider.NewIDToken(testClientID, "", "", map[string]interface{}{
+		"state": expectedWord,
+		"code":  "code1",
+	})
+	s.provider.MockPubKeysCall(jwkSetJSON)
+
+	tokenJSON, err := json.Marshal(oidc.TokenResponse{
+		AccessToken:  testToken.AccessToken,
+		RefreshToken: testToken.RefreshToken,
+		IDToken:      testToken.IDToken,
+		TokenType:    "Bearer",
+	})
+	s.Require().NoError(err)
+	s.provider.Mock().Push(func(r *http.Request) (*http.Response, error) {
+		s.Require().NoError(r.ParseForm())
+		s.Equal("code1", r.PostForm.Get("code"))
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	flow := NewFlow(
+		s.oidcSource.oidcClient,
+		oidc.Config{ClientID: testClientID, ClientSecret: testClientSecret},
+		s.oidcSource.callbackSrv,
+		WithJWTResponseMode(),
+		WithOpenBrowser(func(urlToGet string) error {
+			u, err := url.Parse(urlToGet)
+			s.Require().NoError(err)
+			s.Equal(oidc.ResponseModeJWT, u.Query().Get("response_mode"))
+
+			go func() {
+				res, err := http.Get(fmt.Sprintf("%s?response=%s", u.Query().Get("redirect_uri"), response))
+				s.Require().NoError(err)
+				s.Equal(http.StatusOK, res.StatusCode)
+			}()
+			return nil
+		}),
+	)
+	flow.genRandToken = func() string {
+		return expectedWord
+	}
+
+	res, err := flow.Start(s.provider.Context())
+	s.Require().NoError(err)
+	s.Equal(testToken, *res.Token)
+	s.Equal(0, s.provider.Mock().Len())
+}
+
+func (s *TokenSourceTestSuite) Test_Flow_JWTResponseMode_UnsignedResponse() {
+	const expectedWord = "secret_token"
+
+	flow := NewFlow(
+		s.oidcSource.oidcClient,
+		oidc.Config{ClientID: testClientID},
+		s.oidcSource.callbackSrv,
+		WithJWTResponseMode(),
+		WithOpenBrowser(func(urlToGet string) error {
+			redirectURL, err := stripArgFromURL("redirect_uri", urlToGet)
+			s.Require().NoError(err)
+
+			go func() {
+				res, err := http.Get(fmt.Sprintf("%s?code=code1&state=%s", redirectURL, expectedWord))
+				s.Require().NoError(err)
+				s.Equal(http.StatusOK, res.StatusCode)
+			}()
+			return nil
+		}),
+	)
+	flow.genRandToken = func() string {
+		return expectedWord
+	}
+
+	_, err := flow.Start(s.provider.Context())
+	s.Require().Error(err)
+	s.Equal("oidc: Callback error: Missing JWT-secured authorization response.", err.Error())
+}
+
+func (s *TokenSourceTestSuite) Test_Flow_ManualCodeEntry() {
+	const expectedWord = "secret_token"
+
+	tokenJSON, err := json.Marshal(oidc.TokenResponse{
+		AccessToken:  testToken.AccessToken,
+		RefreshToken: testToken.RefreshToken,
+		IDToken:      testToken.IDToken,
+		TokenType:    "Bearer",
+	})
+	s.Require().NoError(err)
+	s.provider.Mock().Push(func(r *http.Request) (*http.Response, error) {
+		s.Require().NoError(r.ParseForm())
+		s.Equal("code1", r.PostForm.Get("code"))
+		s.Equal(OOBRedirectURL, r.PostForm.Get("redirect_uri"))
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	var out bytes.Buffer
+	flow := NewFlow(
+		s.oidcSource.oidcClient,
+		oidc.Config{ClientID: testClientID, ClientSecret: testClientSecret},
+		nil,
+		WithManualCodeEntry(strings.NewReader("\n  code1\n"), &out),
+		WithOpenBrowser(func(string) error {
+			s.T().Error("browser should not be opened")
+			return nil
+		}),
+	)
+	flow.genRandToken = func() string {
+		return expectedWord
+	}
+
+	res, err := flow.Start(s.provider.Context())
+	s.Require().NoError(err)
+	s.Equal(testToken, *res.Token)
+	s.Equal(0, s.provider.Mock().Len())
+
+	authURL := strings.SplitN(out.String(), "\n", 2)[0]
+	redirectURL, err := stripArgFromURL("redirect_uri", authURL)
+	s.Require().NoError(err)
+	s.Equal(OOBRedirectURL, redirectURL)
+	s.Contains(out.String(), manualPrompt)
+}
+
+func (s *TokenSourceTestSuite) Test_Flow_ManualCodeEntry_RedirectURL() {
+	flow := NewFlow(
+		s.oidcSource.oidcClient,
+		oidc.Config{ClientID: testClientID},
+		s.oidcSource.callbackSrv,
+		WithManualCodeEntry(strings.NewReader("http://127.0.0.1:8883/callback?code=code1&state=other\n"), ioutil.Discard),
+	)
+	flow.genRandToken = func() string {
+		return "secret_token"
+	}
+
+	_, err := flow.Start(s.provider.Context())
+	s.Require().Error(err)
+	s.Equal("oidc: Callback error: Invalid state parameter. Got other, expected: secret_token", err.Error())
+
+	_, err = NewFlow(s.oidcSource.oidcClient, oidc.Config{ClientID: testClientID}, nil,
+		WithManualCodeEntry(strings.NewReader(""), ioutil.Discard)).Start(s.provider.Context())
+	s.Require().Error(err)
+	s.Equal("oidc: Callback error: no code was entered", err.Error())
+}
+
+func (s *TokenSourceTestSuite) Test_Flow_Timeout() {
+	callbackSrv := NewLoopbackServer("")
+	var redirectURL string
+	flow := NewFlow(
+		s.oidcSource.oidcClient,
+		oidc.Config{ClientID: testClientID},
+		callbackSrv,
+		WithTimeout(100*time.Millisecond),
+		WithOpenBrowser(func(urlToGet string) error {
+			// User abandons the login.
+			var err error
+			redirectURL, err = stripArgFromURL("redirect_uri", urlToGet)
+			return err
+		}),
+	)
+
+	_, err := flow.Start(s.provider.Context())
+	s.Require().Error(err)
+	s.Require().IsType(&TimeoutError{}, err)
+	s.True(err.(*TimeoutError).Timeout())
+	s.True(err.(*TimeoutError).After >= 100*time.Millisecond)
+
+	// Callback server is shut down once flow returns.
+	s.Empty(callbackSrv.RedirectURL())
+	u, err := url.Parse(redirectURL)
+	s.Require().NoError(err)
+	_, err = net.Dial("tcp", u.Host)
+	s.Error(err)
+}
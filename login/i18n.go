@@ -0,0 +1,160 @@
+package login
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CallbackMessages are texts of the callback pages in single language.
+type CallbackMessages struct {
+	// Success is shown when login succeeded.
+	Success string
+	// Error is shown when login failed. Error details are propagated to the command, not shown to the user.
+	Error string
+}
+
+// CallbackTranslations maps lowercase language tag (e.g "pl" or "pt-br") to callback messages in that language.
+type CallbackTranslations map[string]CallbackMessages
+
+// DefaultLanguage is used when none of the languages accepted by the browser is translated.
+const DefaultLanguage = "en"
+
+// DefaultCallbackTranslations are the bundled translations of callback pages.
+var DefaultCallbackTranslations = CallbackTranslations{
+	"en": {
+		Success: "OIDC authentication flow is completed. You can close browser tab.",
+		Error:   "OIDC authentication flow failed. Check the application for details. You can close browser tab.",
+	},
+	"de": {
+		Success: "Die OIDC-Authentifizierung ist abgeschlossen. Sie können den Browser-Tab schließen.",
+		Error:   "Die OIDC-Authentifizierung ist fehlgeschlagen. Details finden Sie in der Anwendung. Sie können den Browser-Tab schließen.",
+	},
+	"es": {
+		Success: "La autenticación OIDC se ha completado. Puede cerrar la pestaña del navegador.",
+		Error:   "La autenticación OIDC ha fallado. Consulte la aplicación para más detalles. Puede cerrar la pestaña del navegador.",
+	},
+	"fr": {
+		Success: "L'authentification OIDC est terminée. Vous pouvez fermer l'onglet du navigateur.",
+		Error:   "L'authentification OIDC a échoué. Consultez l'application pour plus de détails. Vous pouvez fermer l'onglet du navigateur.",
+	},
+	"it": {
+		Success: "L'autenticazione OIDC è stata completata. Puoi chiudere la scheda del browser.",
+		Error:   "L'autenticazione OIDC non è riuscita. Controlla l'applicazione per i dettagli. Puoi chiudere la scheda del browser.",
+	},
+	"pl": {
+		Success: "Uwierzytelnianie OIDC zakończone. Możesz zamknąć kartę przeglądarki.",
+		Error:   "Uwierzytelnianie OIDC nie powiodło się. Szczegóły znajdziesz w aplikacji. Możesz zamknąć kartę przeglądarki.",
+	},
+	"pt": {
+		Success: "A autenticação OIDC foi concluída. Você pode fechar a aba do navegador.",
+		Error:   "A autenticação OIDC falhou. Verifique a aplicação para mais detalhes. Você pode fechar a aba do navegador.",
+	},
+	"ja": {
+		Success: "OIDC認証が完了しました。ブラウザのタブを閉じてください。",
+		Error:   "OIDC認証に失敗しました。詳細はアプリケーションを確認してください。ブラウザのタブを閉じてください。",
+	},
+	"zh": {
+		Success: "OIDC 身份验证已完成。您可以关闭浏览器标签页。",
+		Error:   "OIDC 身份验证失败。请在应用程序中查看详细信息。您可以关闭浏览器标签页。",
+	},
+}
+
+// LocalizedCallbackResponses returns callback responses in the language negotiated from the request's
+// Accept-Language header. Overrides replace (or add) translations from DefaultCallbackTranslations. To use them:
+//
+//    ok, fail := login.LocalizedCallbackResponses(nil)
+//    flow := login.NewFlow(client, cfg, callbackSrv, login.WithCallbackResponses(login.CallbackResponses{OK: ok, Error: fail}))
+//
+func LocalizedCallbackResponses(overrides CallbackTranslations) (
+	ok func(w http.ResponseWriter, r *http.Request),
+	errResp func(w http.ResponseWriter, r *http.Request, err error),
+) {
+	translations := withOverrides(overrides)
+	ok = func(w http.ResponseWriter, r *http.Request) {
+		lang, m := translations.negotiate(r.Header.Get("Accept-Language"))
+		writeLocalized(w, lang, m.Success)
+	}
+	errResp = func(w http.ResponseWriter, r *http.Request, _ error) {
+		lang, m := translations.negotiate(r.Header.Get("Accept-Language"))
+		writeLocalized(w, lang, m.Error)
+	}
+	return ok, errResp
+}
+
+// withOverrides returns DefaultCallbackTranslations merged with overrides.
+func withOverrides(overrides CallbackTranslations) CallbackTranslations {
+	translations := CallbackTranslations{}
+	for lang, m := range DefaultCallbackTranslations {
+		translations[lang] = m
+	}
+	for lang, m := range overrides {
+		translations[strings.ToLower(lang)] = m
+	}
+	return translations
+}
+
+func writeLocalized(w http.ResponseWriter, lang string, msg string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	// Consistently with default responses, errors are not exposed via status code.
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(msg))
+}
+
+// negotiate returns best translation for given Accept-Language header value. Exact tag matches (e.g "pt-br") are
+// preferred over base language matches ("pt"). It falls back to DefaultLanguage.
+func (t CallbackTranslations) negotiate(acceptLanguage string) (string, CallbackMessages) {
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		if m, ok := t[lang]; ok {
+			return lang, m
+		}
+		if i := strings.Index(lang, "-"); i > 0 {
+			if m, ok := t[lang[:i]]; ok {
+				return lang[:i], m
+			}
+		}
+	}
+	return DefaultLanguage, t[DefaultLanguage]
+}
+
+// parseAcceptLanguage returns lowercase language tags from Accept-Language header value, ordered by quality.
+// See https://tools.ietf.org/html/rfc7231#section-5.3.5.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = v
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		langs = append(langs, weighted{lang: lang, q: q})
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+	ret := make([]string, 0, len(langs))
+	for _, l := range langs {
+		ret = append(ret, l.lang)
+	}
+	return ret
+}
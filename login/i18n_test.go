@@ -0,0 +1,68 @@
+package login
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"pt-br", "pl", "en"}, parseAcceptLanguage("en;q=0.5, pt-BR, pl;q=0.8, de;q=0, *;q=0.1"))
+	assert.Equal(t, []string{}, parseAcceptLanguage(""))
+}
+
+func TestLocalizedCallbackResponses(t *testing.T) {
+	ok, errResp := LocalizedCallbackResponses(CallbackTranslations{
+		"pt-BR": {Success: "Pronto!", Error: "Falhou!"},
+	})
+
+	for _, tcase := range []struct {
+		acceptLanguage string
+
+		expectedLang string
+		expectedOK   string
+		expectedErr  string
+	}{
+		{
+			acceptLanguage: "pl-PL,pl;q=0.9,en;q=0.8",
+			expectedLang:   "pl",
+			expectedOK:     DefaultCallbackTranslations["pl"].Success,
+			expectedErr:    DefaultCallbackTranslations["pl"].Error,
+		},
+		{
+			acceptLanguage: "pt-BR",
+			expectedLang:   "pt-br",
+			expectedOK:     "Pronto!",
+			expectedErr:    "Falhou!",
+		},
+		{
+			acceptLanguage: "pt-PT",
+			expectedLang:   "pt",
+			expectedOK:     DefaultCallbackTranslations["pt"].Success,
+			expectedErr:    DefaultCallbackTranslations["pt"].Error,
+		},
+		{
+			acceptLanguage: "xx",
+			expectedLang:   DefaultLanguage,
+			expectedOK:     DefaultCallbackTranslations[DefaultLanguage].Success,
+			expectedErr:    DefaultCallbackTranslations[DefaultLanguage].Error,
+		},
+	} {
+		r := httptest.NewRequest("GET", "/callback", nil)
+		r.Header.Set("Accept-Language", tcase.acceptLanguage)
+
+		w := httptest.NewRecorder()
+		ok(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tcase.expectedLang, w.Header().Get("Content-Language"))
+		assert.Equal(t, tcase.expectedOK, w.Body.String())
+
+		w = httptest.NewRecorder()
+		errResp(w, r, errors.New("test"))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tcase.expectedErr, w.Body.String())
+	}
+}
@@ -0,0 +1,16 @@
+// Package oskeyring stores secrets in the OS keyring: Keychain on macOS, Credential Manager on Windows and Secret
+// Service (via secret-tool from libsecret) on Linux. It is shared by token caches of the login package.
+package oskeyring
+
+import "errors"
+
+// ErrNotFound is returned by Store.Get when there is no secret for given account.
+var ErrNotFound = errors.New("secret not found")
+
+// Store stores secrets in the OS keyring under Service and account.
+type Store struct {
+	// Service is the name that all secrets of the store are stored under.
+	Service string
+	// Label is human readable description of secrets shown by keyring managers. Used only by Secret Service.
+	Label string
+}
@@ -0,0 +1,49 @@
+package oskeyring
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// securityItemNotFound is exit code of security tool when Keychain item does not exist.
+const securityItemNotFound = 44
+
+// Set stores secret in the user's login Keychain using security tool, replacing previous one.
+func (s Store) Set(account string, secret []byte) error {
+	// Pass commands via stdin (interactive mode), so the secret is not visible in process list.
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf(
+		"add-generic-password -U -s %s -a %s -w %s\n",
+		quoteArg(s.Service), quoteArg(account), base64.StdEncoding.EncodeToString(secret),
+	))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to store secret in Keychain. Err: %v %s", err, stderr.String())
+	}
+	return nil
+}
+
+// Get returns secret from the user's login Keychain.
+func (s Store) Get(account string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("security", "find-generic-password", "-s", s.Service, "-a", account, "-w")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.Sys().(syscall.WaitStatus).ExitStatus() == securityItemNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get secret from Keychain. Err: %v %s", err, stderr.String())
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(stdout.String()))
+}
+
+// quoteArg quotes argument for security interactive mode.
+func quoteArg(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,39 @@
+package oskeyring
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Set stores secret in Secret Service (e.g GNOME Keyring or KWallet) using secret-tool from libsecret, replacing
+// previous one.
+func (s Store) Set(account string, secret []byte) error {
+	// secret-tool reads the secret from stdin, so it is not visible in process list.
+	cmd := exec.Command("secret-tool", "store", "--label="+s.Label, "service", s.Service, "account", account)
+	cmd.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString(secret))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to store secret in Secret Service. Err: %v %s", err, stderr.String())
+	}
+	return nil
+}
+
+// Get returns secret from Secret Service.
+func (s Store) Get(account string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", s.Service, "account", account)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// secret-tool exits with 1 without any output when there is no such secret.
+		if _, ok := err.(*exec.ExitError); ok && stdout.Len() == 0 && stderr.Len() == 0 {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get secret from Secret Service. Err: %v %s", err, stderr.String())
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(stdout.String()))
+}
@@ -0,0 +1,19 @@
+//go:build !windows && !darwin && !linux
+// +build !windows,!darwin,!linux
+
+package oskeyring
+
+import (
+	"errors"
+	"runtime"
+)
+
+// Set returns error, since there is no OS keyring on this platform.
+func (Store) Set(string, []byte) error {
+	return errors.New("keyring is not supported on " + runtime.GOOS)
+}
+
+// Get returns error, since there is no OS keyring on this platform.
+func (Store) Get(string) ([]byte, error) {
+	return nil, errors.New("keyring is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,167 @@
+package oskeyring
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	// credMaxBlobSize is CRED_MAX_CREDENTIAL_BLOB_SIZE. Larger secrets are split into many credentials.
+	credMaxBlobSize = 5 * 512
+
+	errorNotFound syscall.Errno = 1168
+)
+
+// credential is CREDENTIALW structure of the Credential Manager.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// target returns name of credential storing given chunk of the secret. Since credential blob size is limited, secret
+// is split into chunks stored under "<target>", "<target>.1", "<target>.2" etc.
+func (s Store) target(account string, chunk int) string {
+	if chunk == 0 {
+		return s.Service + ":" + account
+	}
+	return fmt.Sprintf("%s:%s.%d", s.Service, account, chunk)
+}
+
+// Set stores secret as generic credential in Windows Credential Manager, replacing previous one.
+func (s Store) Set(account string, secret []byte) error {
+	chunk := 0
+	for {
+		n := len(secret)
+		if n > credMaxBlobSize {
+			n = credMaxBlobSize
+		}
+		if err := credWrite(s.target(account, chunk), account, secret[:n]); err != nil {
+			return err
+		}
+		secret = secret[n:]
+		chunk++
+		if len(secret) == 0 {
+			break
+		}
+	}
+
+	// Remove chunks left from previous, longer secret.
+	for ; ; chunk++ {
+		if err := credDelete(s.target(account, chunk)); err == ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Get returns secret from Windows Credential Manager.
+func (s Store) Get(account string) ([]byte, error) {
+	var secret []byte
+	for chunk := 0; ; chunk++ {
+		b, err := credRead(s.target(account, chunk))
+		if err == ErrNotFound && chunk > 0 {
+			return secret, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		secret = append(secret, b...)
+		if len(b) < credMaxBlobSize {
+			return secret, nil
+		}
+	}
+}
+
+func credWrite(targetName string, userName string, blob []byte) error {
+	if err := procCredWriteW.Find(); err != nil {
+		return fmt.Errorf("Credential Manager is not available. Err: %v", err)
+	}
+	targetPtr, err := syscall.UTF16PtrFromString(targetName)
+	if err != nil {
+		return err
+	}
+	userPtr, err := syscall.UTF16PtrFromString(userName)
+	if err != nil {
+		return err
+	}
+
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         targetPtr,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userPtr,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return fmt.Errorf("CredWriteW failed. Err: %v", err)
+	}
+	return nil
+}
+
+func credRead(targetName string) ([]byte, error) {
+	if err := procCredReadW.Find(); err != nil {
+		return nil, fmt.Errorf("Credential Manager is not available. Err: %v", err)
+	}
+	targetPtr, err := syscall.UTF16PtrFromString(targetName)
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(targetPtr)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if err == errorNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("CredReadW failed. Err: %v", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	b := make([]byte, cred.CredentialBlobSize)
+	if cred.CredentialBlobSize > 0 {
+		copy(b, (*[1 << 30]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize])
+	}
+	return b, nil
+}
+
+func credDelete(targetName string) error {
+	targetPtr, err := syscall.UTF16PtrFromString(targetName)
+	if err != nil {
+		return err
+	}
+	r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(targetPtr)), credTypeGeneric, 0)
+	if r == 0 {
+		if err == errorNotFound {
+			return ErrNotFound
+		}
+		return fmt.Errorf("CredDeleteW failed. Err: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package keyring
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Bplotka/oidc"
+	"github.com/Bplotka/oidc/login"
+	"github.com/Bplotka/oidc/login/internal/oskeyring"
+)
+
+// keyringService is the service name that tokens are stored under in the OS keyring.
+const keyringService = "oidc-tokens"
+
+// secretStore stores secrets in the OS keyring.
+type secretStore interface {
+	Set(account string, secret []byte) error
+	// Get returns oskeyring.ErrNotFound if there is no secret for the account.
+	Get(account string) ([]byte, error)
+}
+
+// Cache is a oidc caching structure that stores whole tokens in the OS keyring: Keychain on macOS, Credential Manager
+// on Windows and Secret Service (via secret-tool from libsecret) on Linux, so refresh tokens never hit the disk
+// unencrypted. Tokens are stored under account named after clientID and arg[0], like in disk cache.
+// NOTE: There is no fallback if the keyring is not available (e.g no Secret Service is running in SSH session). Use
+// disk.NewEncryptedCache with disk.NewNativeKeyProtector in such environments.
+type Cache struct {
+	cfg   login.OIDCConfig
+	store secretStore
+}
+
+// NewCache constructs keyring cache.
+func NewCache(cfg login.OIDCConfig) *Cache {
+	return &Cache{cfg: cfg, store: oskeyring.Store{Service: keyringService, Label: "OIDC token"}}
+}
+
+func (c *Cache) account() string {
+	cliToolName := filepath.Base(os.Args[0])
+	return fmt.Sprintf("token_%s_%s", cliToolName, c.cfg.ClientID)
+}
+
+// Token retrieves token from the keyring.
+func (c *Cache) Token() (*oidc.Token, error) {
+	bytes, err := c.store.Get(c.account())
+	if err == oskeyring.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get cached token from keyring. Err: %v", err)
+	}
+
+	token := &oidc.Token{}
+	if err := json.Unmarshal(bytes, token); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal token JSON. Err: %v", err)
+	}
+	return token, nil
+}
+
+// SaveToken saves token in the keyring, replacing previous one.
+func (c *Cache) SaveToken(token *oidc.Token) error {
+	marshaledToken, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := c.store.Set(c.account(), marshaledToken); err != nil {
+		return fmt.Errorf("Failed caching token in keyring. Err: %v", err)
+	}
+	return nil
+}
+
+// Config returns OIDC configuration.
+func (c *Cache) Config() login.OIDCConfig {
+	return c.cfg
+}
@@ -0,0 +1,53 @@
+package keyring
+
+import (
+	"testing"
+
+	"github.com/Bplotka/oidc"
+	"github.com/Bplotka/oidc/login"
+	"github.com/Bplotka/oidc/login/internal/oskeyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore map[string][]byte
+
+func (m memoryStore) Set(account string, secret []byte) error {
+	m[account] = secret
+	return nil
+}
+
+func (m memoryStore) Get(account string) ([]byte, error) {
+	secret, ok := m[account]
+	if !ok {
+		return nil, oskeyring.ErrNotFound
+	}
+	return secret, nil
+}
+
+func TestKeyringCache(t *testing.T) {
+	store := memoryStore{}
+	cache := &Cache{cfg: login.OIDCConfig{ClientID: "client1"}, store: store}
+
+	token, err := cache.Token()
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	saved := &oidc.Token{
+		AccessToken:  "access1",
+		RefreshToken: "refresh1",
+		IDToken:      "id1",
+	}
+	require.NoError(t, cache.SaveToken(saved))
+	assert.Len(t, store, 1)
+	assert.Contains(t, store, cache.account())
+
+	token, err = cache.Token()
+	require.NoError(t, err)
+	assert.Equal(t, saved, token)
+
+	// Other client ID does not see the token.
+	token, err = (&Cache{cfg: login.OIDCConfig{ClientID: "client2"}, store: store}).Token()
+	require.NoError(t, err)
+	assert.Nil(t, token)
+}
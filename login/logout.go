@@ -0,0 +1,94 @@
+package login
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Bplotka/oidc"
+)
+
+// Logout invalidates token cached in cache: it revokes its refresh token and access token on the provider and
+// clears the cache, so the next use of token source requires new login. Cache is cleared even if revocation fails,
+// in which case the revocation error is returned. If provider does not support revocation, only the cache is
+// cleared. opts are passed to the oidc.Client used for revocation.
+//
+// Token sources that use the cache need to be reset (e.g using clearIDToken function returned by NewOIDCTokenSource),
+// since they might still hold revoked token in memory.
+//
+// Logout does not terminate user's session on the provider, so the next login might not ask for credentials. Use
+// BrowserLogout for that.
+func Logout(ctx context.Context, cache Cache, opts ...oidc.Option) error {
+	return logout(ctx, cache, nil, "", opts...)
+}
+
+// BrowserLogout does the same as Logout and additionally opens the browser on provider's end session endpoint (see
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html) to terminate user's session on the provider.
+// Cached ID token is passed as a hint. postLogoutRedirectURI is optional and needs to be registered for the client.
+// If provider does not support RP-initiated logout, it is equivalent to Logout.
+func BrowserLogout(ctx context.Context, cache Cache, postLogoutRedirectURI string, opts ...oidc.Option) error {
+	return logout(ctx, cache, openBrowser, postLogoutRedirectURI, opts...)
+}
+
+func logout(ctx context.Context, cache Cache, openBrowser func(string) error, postLogoutRedirectURI string, opts ...oidc.Option) error {
+	token, err := cache.Token()
+	if err != nil {
+		return fmt.Errorf("Failed to get cached token. Err: %v", err)
+	}
+	if token == nil && openBrowser == nil {
+		// Nothing to log out from.
+		return nil
+	}
+	if token == nil {
+		token = &oidc.Token{}
+	}
+
+	cfg := cache.Config()
+	oidcClient, err := oidc.NewClient(ctx, cfg.Provider, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize OIDC client. Err: %v", err)
+	}
+
+	revokeErr := revoke(ctx, oidcClient, cfg, token)
+
+	if err := cache.SaveToken(&oidc.Token{}); err != nil {
+		return fmt.Errorf("Failed to clear cached token. Err: %v", err)
+	}
+	if revokeErr != nil {
+		return revokeErr
+	}
+
+	if openBrowser == nil || oidcClient.Discovery().EndSessionURL == "" {
+		return nil
+	}
+	logoutURL, err := oidcClient.LogoutURL(token.IDToken, postLogoutRedirectURI, "")
+	if err != nil {
+		return err
+	}
+	if err := openBrowser(logoutURL); err != nil {
+		return fmt.Errorf("Failed to open browser for logout. Err: %v", err)
+	}
+	return nil
+}
+
+func revoke(ctx context.Context, oidcClient *oidc.Client, cfg OIDCConfig, token *oidc.Token) error {
+	if oidcClient.Discovery().RevocationURL == "" {
+		return nil
+	}
+
+	oidcConfig := oidc.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+	}
+	// Refresh token goes first, since it is long-lived. Revoking it often revokes access token as well.
+	if token.RefreshToken != "" {
+		if err := oidcClient.Revoke(ctx, oidcConfig, token.RefreshToken, oidc.TokenTypeHintRefreshToken); err != nil {
+			return fmt.Errorf("Failed to revoke refresh token. Err: %v", err)
+		}
+	}
+	if token.AccessToken != "" {
+		if err := oidcClient.Revoke(ctx, oidcConfig, token.AccessToken, oidc.TokenTypeHintAccessToken); err != nil {
+			return fmt.Errorf("Failed to revoke access token. Err: %v", err)
+		}
+	}
+	return nil
+}
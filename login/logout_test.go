@@ -0,0 +1,92 @@
+package login
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/Bplotka/oidc"
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *TokenSourceTestSuite) Test_Logout_RevokesAndClearsCache() {
+	s.provider.MockDiscoveryCall()
+
+	var hints, revoked []string
+	for i := 0; i < 2; i++ {
+		s.provider.Mock().Push(func(r *http.Request) (*http.Response, error) {
+			s.Equal(s.provider.Discovery.RevocationURL, r.URL.String())
+			s.NoError(r.ParseForm())
+			revoked = append(revoked, r.PostForm.Get("token"))
+			hints = append(hints, r.PostForm.Get("token_type_hint"))
+			return rt.StringResponseFunc(http.StatusOK, "")(r)
+		})
+	}
+
+	token := testToken
+	s.cache.On("Token").Return(&token, nil)
+	s.cache.On("SaveToken", mock.AnythingOfType("*oidc.Token")).Run(func(args mock.Arguments) {
+		s.Equal(&oidc.Token{}, args.Get(0))
+	}).Return(nil)
+
+	err := Logout(s.provider.Context(), s.cache, s.provider.ClientOption())
+	s.Require().NoError(err)
+
+	s.Equal([]string{testToken.RefreshToken, testToken.AccessToken}, revoked)
+	s.Equal([]string{oidc.TokenTypeHintRefreshToken, oidc.TokenTypeHintAccessToken}, hints)
+
+	s.cache.AssertExpectations(s.T())
+	s.Equal(0, s.provider.Mock().Len())
+}
+
+func (s *TokenSourceTestSuite) Test_Logout_RevokeFails_ClearsCache() {
+	s.provider.MockDiscoveryCall()
+	s.provider.Mock().Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "invalid_request"}`)))
+
+	token := testToken
+	s.cache.On("Token").Return(&token, nil)
+	s.cache.On("SaveToken", mock.AnythingOfType("*oidc.Token")).Return(nil)
+
+	err := Logout(s.provider.Context(), s.cache, s.provider.ClientOption())
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Failed to revoke refresh token")
+
+	s.cache.AssertExpectations(s.T())
+	s.Equal(0, s.provider.Mock().Len())
+}
+
+func (s *TokenSourceTestSuite) Test_Logout_NoToken() {
+	s.cache.On("Token").Return(nil, nil)
+
+	err := Logout(s.provider.Context(), s.cache, s.provider.ClientOption())
+	s.Require().NoError(err)
+
+	// Config expected by SetupTest is not needed without token.
+	s.cache.AssertCalled(s.T(), "Token")
+	s.cache.AssertNotCalled(s.T(), "SaveToken", mock.Anything)
+}
+
+func (s *TokenSourceTestSuite) Test_BrowserLogout_EndsProviderSession() {
+	s.provider.MockDiscoveryCall()
+	s.provider.Mock().Push(rt.StringResponseFunc(http.StatusOK, ""))
+
+	token := oidc.Token{IDToken: "idtoken1", RefreshToken: "refresh1"}
+	s.cache.On("Token").Return(&token, nil)
+	s.cache.On("SaveToken", mock.AnythingOfType("*oidc.Token")).Return(nil)
+
+	var logoutURL string
+	err := logout(s.provider.Context(), s.cache, func(u string) error {
+		logoutURL = u
+		return nil
+	}, "http://127.0.0.1/logged-out", s.provider.ClientOption())
+	s.Require().NoError(err)
+
+	u, err := url.Parse(logoutURL)
+	s.Require().NoError(err)
+	s.Equal(s.provider.Discovery.EndSessionURL, u.Scheme+"://"+u.Host+u.Path)
+	s.Equal("idtoken1", u.Query().Get("id_token_hint"))
+	s.Equal("http://127.0.0.1/logged-out", u.Query().Get("post_logout_redirect_uri"))
+
+	s.cache.AssertExpectations(s.T())
+	s.Equal(0, s.provider.Mock().Len())
+}
@@ -0,0 +1,73 @@
+package login
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// OOBRedirectURL is the out-of-band redirect URI, for which providers show the code to the user instead of redirecting
+// the browser. Flow with manual code entry and without callback server uses it. Note that some providers (e.g Google)
+// no longer support it; register loopback redirect URI and pass callback server to the flow for them instead.
+const OOBRedirectURL = "urn:ietf:wg:oauth:2.0:oob"
+
+// WithManualCodeEntry makes flow print auth URL to out instead of opening browser and read response pasted by the
+// user from in, e.g for SSH sessions and containers without browser. User can open the URL on any machine and paste
+// either the code shown by the provider or the full URL the browser was redirected to, even if it failed to load.
+// With callback server, redirect URI is the server's one and callback received by the server is accepted as well;
+// without it (nil), OOBRedirectURL is used. Flow stops waiting for input when its context is done, but line already
+// being read is consumed.
+func WithManualCodeEntry(in io.Reader, out io.Writer) FlowOption {
+	return func(f *Flow) {
+		f.manualIn = in
+		f.manualOut = out
+	}
+}
+
+// manualPrompt is shown after auth URL in manual code entry mode.
+const manualPrompt = "Open the URL above in a browser on any machine and log in. Then paste the code or the full URL " +
+	"of the page you were redirected to (even if it failed to load):"
+
+// promptManualResponse prints auth URL to out and returns channel with the authorization response read from in.
+func promptManualResponse(in io.Reader, out io.Writer, authURL string, expectedState string) <-chan *manualResponse {
+	fmt.Fprintf(out, "%s\n\n%s\n", authURL, manualPrompt)
+
+	ch := make(chan *manualResponse, 1)
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			line, err := r.ReadString('\n')
+			line = strings.TrimSpace(line)
+			if line != "" {
+				ch <- &manualResponse{form: parseManualResponse(line, expectedState)}
+				return
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = errors.New("no code was entered")
+				}
+				ch <- &manualResponse{err: err}
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+type manualResponse struct {
+	form url.Values
+	err  error
+}
+
+// parseManualResponse returns parameters of authorization response given by the user. Input is either the URL that
+// browser was redirected to, or bare code, e.g shown by the provider for OOBRedirectURL. Bare code has no state, so
+// expectedState is assumed: code was copied by the user directly, not received from unknown redirect.
+func parseManualResponse(input string, expectedState string) url.Values {
+	if u, err := url.Parse(input); err == nil && u.Scheme != "" && u.RawQuery != "" {
+		return u.Query()
+	}
+	return url.Values{codeParam: {input}, stateParam: {expectedState}}
+}
@@ -0,0 +1,204 @@
+//go:build go1.16
+// +build go1.16
+
+package login
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// SuccessPageTemplate is the name of the success page template in CallbackPagesConfig.Assets.
+	SuccessPageTemplate = "success.html"
+	// ErrorPageTemplate is the name of the error page template in CallbackPagesConfig.Assets.
+	ErrorPageTemplate = "error.html"
+)
+
+// CallbackPagesConfig configures branded callback pages.
+type CallbackPagesConfig struct {
+	// Assets are static assets served by the callback server under callback path + "/assets/". If it contains
+	// SuccessPageTemplate or ErrorPageTemplate, these are used as html/template templates of the pages instead of the
+	// default ones. Default templates use "style.css" and "logo.svg" or "logo.png" if present.
+	Assets fs.FS
+	// SuccessTemplate and ErrorTemplate if not nil, are used as templates of the pages instead of the ones from Assets
+	// or the default ones, e.g for templates parsed with custom functions. Template without actions serves static
+	// page.
+	SuccessTemplate *template.Template
+	ErrorTemplate   *template.Template
+	// ShowErrorDetails if true, shows message of any callback error (e.g state mismatch or failed code exchange) on the
+	// error page as CallbackPageData.ErrorDetails. By default only errors returned by the provider are shown, since
+	// other errors may reveal internals of the application.
+	ShowErrorDetails bool
+	// Translations override DefaultCallbackTranslations used for the page message.
+	Translations CallbackTranslations
+	// AutoClose if not zero, makes the page close itself after given time (if browser allows that).
+	AutoClose time.Duration
+}
+
+// CallbackPageData is passed to callback page templates.
+type CallbackPageData struct {
+	// Success is true for the success page.
+	Success bool
+	// Lang is the language of Message, negotiated from Accept-Language header.
+	Lang string
+	// Message is the localized message for the user.
+	Message string
+	// ProviderError is set on the error page if the provider returned an error with its description.
+	ProviderError *ProviderError
+	// ErrorDetails is the message of the error on the error page if CallbackPagesConfig.ShowErrorDetails is true.
+	ErrorDetails string
+	// AssetsURL is the URL path of assets e.g for <img src="{{.AssetsURL}}/logo.png">.
+	AssetsURL string
+	// StyleURL and LogoURL are URLs of the "style.css", "logo.svg" or "logo.png" assets or empty if there are none.
+	StyleURL string
+	LogoURL  string
+	// AutoCloseMillis is the time after which the page should close itself. It is 0 if auto close is disabled.
+	AutoCloseMillis int64
+}
+
+// CallbackPages renders branded callback pages. Use WithCallbackPages to configure them for login flow.
+type CallbackPages struct {
+	cfg          CallbackPagesConfig
+	translations CallbackTranslations
+	success      *template.Template
+	failure      *template.Template
+	style        string
+	logo         string
+}
+
+// NewCallbackPages parses templates from cfg.Assets.
+func NewCallbackPages(cfg CallbackPagesConfig) (*CallbackPages, error) {
+	p := &CallbackPages{
+		cfg:          cfg,
+		translations: withOverrides(cfg.Translations),
+		success:      defaultPageTemplate,
+		failure:      defaultPageTemplate,
+	}
+	if cfg.Assets != nil {
+		var err error
+		if p.success, err = parsePageTemplate(cfg.Assets, SuccessPageTemplate); err != nil {
+			return nil, err
+		}
+		if p.failure, err = parsePageTemplate(cfg.Assets, ErrorPageTemplate); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SuccessTemplate != nil {
+		p.success = cfg.SuccessTemplate
+	}
+	if cfg.ErrorTemplate != nil {
+		p.failure = cfg.ErrorTemplate
+	}
+	if cfg.Assets == nil {
+		return p, nil
+	}
+
+	if assetExists(cfg.Assets, "style.css") {
+		p.style = "style.css"
+	}
+	for _, logo := range []string{"logo.svg", "logo.png"} {
+		if assetExists(cfg.Assets, logo) {
+			p.logo = logo
+			break
+		}
+	}
+	return p, nil
+}
+
+func parsePageTemplate(assets fs.FS, name string) (*template.Template, error) {
+	if !assetExists(assets, name) {
+		return defaultPageTemplate, nil
+	}
+	return template.ParseFS(assets, name)
+}
+
+func assetExists(assets fs.FS, name string) bool {
+	_, err := fs.Stat(assets, name)
+	return err == nil
+}
+
+// WithCallbackPages makes login flow respond on callback with the branded pages and serve their assets.
+func WithCallbackPages(p *CallbackPages) FlowOption {
+	return func(f *Flow) {
+		f.respondOK = func(w http.ResponseWriter, r *http.Request) {
+			p.render(w, r, nil)
+		}
+		f.respondErr = func(w http.ResponseWriter, r *http.Request, err error) {
+			p.render(w, r, err)
+		}
+		if p.cfg.Assets != nil {
+			f.assets = http.FileServer(http.FS(p.cfg.Assets))
+		}
+	}
+}
+
+func (p *CallbackPages) render(w http.ResponseWriter, r *http.Request, err error) {
+	lang, m := p.translations.negotiate(r.Header.Get("Accept-Language"))
+	data := CallbackPageData{
+		Success:         err == nil,
+		Lang:            lang,
+		Message:         m.Success,
+		AssetsURL:       strings.TrimSuffix(assetsPath(r.URL.Path), "/"),
+		AutoCloseMillis: int64(p.cfg.AutoClose / time.Millisecond),
+	}
+	tmpl := p.success
+	if err != nil {
+		data.Message = m.Error
+		if pErr, ok := err.(*ProviderError); ok {
+			data.ProviderError = pErr
+		} else if p.cfg.ShowErrorDetails {
+			data.ErrorDetails = err.Error()
+		}
+		tmpl = p.failure
+	}
+	if p.style != "" {
+		data.StyleURL = data.AssetsURL + "/" + p.style
+	}
+	if p.logo != "" {
+		data.LogoURL = data.AssetsURL + "/" + p.logo
+	}
+
+	// Render first to not send partial page on template error.
+	var buf bytes.Buffer
+	if tErr := tmpl.Execute(&buf, data); tErr != nil {
+		writeLocalized(w, lang, data.Message)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	// Consistently with default responses, errors are not exposed via status code.
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+var defaultPageTemplate = template.Must(template.New("default").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="color-scheme" content="light dark">
+<title>{{.Message}}</title>
+<style>
+body { font-family: sans-serif; display: flex; flex-direction: column; align-items: center; justify-content: center; min-height: 90vh; margin: 0; background: #fff; color: #222; }
+.error { color: #b00020; }
+@media (prefers-color-scheme: dark) {
+  body { background: #121212; color: #eee; }
+  .error { color: #cf6679; }
+}
+</style>
+{{if .StyleURL}}<link rel="stylesheet" href="{{.StyleURL}}">{{end}}
+</head>
+<body>
+{{if .LogoURL}}<img class="logo" src="{{.LogoURL}}" alt="">{{end}}
+<p class="message{{if not .Success}} error{{end}}">{{.Message}}</p>
+{{with .ProviderError}}<p class="provider-error">{{.Code}}{{if .Description}}: {{.Description}}{{end}}</p>{{end}}
+{{with .ErrorDetails}}<p class="error-details">{{.}}</p>{{end}}
+{{if .AutoCloseMillis}}<script>setTimeout(function() { window.close(); }, {{.AutoCloseMillis}});</script>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,110 @@
+//go:build go1.16
+// +build go1.16
+
+package login
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackPages_Default(t *testing.T) {
+	p, err := NewCallbackPages(CallbackPagesConfig{
+		Assets: fstest.MapFS{
+			"logo.svg": &fstest.MapFile{Data: []byte("<svg></svg>")},
+		},
+		AutoClose: 2 * time.Second,
+	})
+	require.NoError(t, err)
+
+	f := &Flow{}
+	WithCallbackPages(p)(f)
+
+	r := httptest.NewRequest("GET", "/something/callback?code=1&state=2", nil)
+	r.Header.Set("Accept-Language", "de")
+	w := httptest.NewRecorder()
+	f.respondOK(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `<html lang="de">`)
+	assert.Contains(t, w.Body.String(), DefaultCallbackTranslations["de"].Success)
+	assert.Contains(t, w.Body.String(), `<img class="logo" src="/something/callback/assets/logo.svg" alt="">`)
+	assert.Contains(t, w.Body.String(), "2000")
+	assert.NotContains(t, w.Body.String(), "style.css")
+
+	w = httptest.NewRecorder()
+	f.respondErr(w, r, &ProviderError{Code: "access_denied", Description: "<denied>"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), DefaultCallbackTranslations["de"].Error)
+	assert.Contains(t, w.Body.String(), "access_denied: &lt;denied&gt;")
+
+	w = httptest.NewRecorder()
+	f.assets.ServeHTTP(w, httptest.NewRequest("GET", "/logo.svg", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<svg></svg>", w.Body.String())
+}
+
+func TestCallbackPages_CustomTemplates(t *testing.T) {
+	p, err := NewCallbackPages(CallbackPagesConfig{
+		Assets: fstest.MapFS{
+			SuccessPageTemplate: &fstest.MapFile{Data: []byte("OK {{.Lang}} {{.Message}}")},
+			ErrorPageTemplate:   &fstest.MapFile{Data: []byte("ERR {{.Message}}")},
+		},
+		Translations: CallbackTranslations{"en": {Success: "Welcome!", Error: "Oops!"}},
+	})
+	require.NoError(t, err)
+
+	f := &Flow{}
+	WithCallbackPages(p)(f)
+
+	r := httptest.NewRequest("GET", "/callback", nil)
+	w := httptest.NewRecorder()
+	f.respondOK(w, r)
+	assert.Equal(t, "OK en Welcome!", w.Body.String())
+
+	w = httptest.NewRecorder()
+	f.respondErr(w, r, errors.New("test"))
+	assert.Equal(t, "ERR Oops!", w.Body.String())
+
+	_, err = NewCallbackPages(CallbackPagesConfig{
+		Assets: fstest.MapFS{
+			SuccessPageTemplate: &fstest.MapFile{Data: []byte("{{")},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestCallbackPages_ErrorDetails(t *testing.T) {
+	p, err := NewCallbackPages(CallbackPagesConfig{
+		ErrorTemplate: template.Must(template.New("error").Parse("ERR {{.ErrorDetails}}")),
+	})
+	require.NoError(t, err)
+	f := &Flow{}
+	WithCallbackPages(p)(f)
+
+	r := httptest.NewRequest("GET", "/callback", nil)
+	w := httptest.NewRecorder()
+	f.respondErr(w, r, errors.New("Invalid state parameter"))
+	assert.Equal(t, "ERR ", w.Body.String(), "details should be hidden by default")
+
+	w = httptest.NewRecorder()
+	f.respondOK(w, r)
+	assert.Contains(t, w.Body.String(), DefaultCallbackTranslations["en"].Success, "default success page should be used")
+
+	p, err = NewCallbackPages(CallbackPagesConfig{ShowErrorDetails: true})
+	require.NoError(t, err)
+	WithCallbackPages(p)(f)
+
+	w = httptest.NewRecorder()
+	f.respondErr(w, r, errors.New("Invalid state <parameter>"))
+	assert.Contains(t, w.Body.String(), `<p class="error-details">Invalid state &lt;parameter&gt;</p>`)
+}
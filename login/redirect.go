@@ -0,0 +1,69 @@
+package login
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// NewServerForRedirectURIs creates callback server for the first of the redirect URIs registered for the client (e.g
+// OIDCConfig.RedirectURIs) that can be served locally: http URI with loopback host and free port. Registered URIs
+// without port are treated as allowing any port (see https://tools.ietf.org/html/rfc8252#section-7.3), so random free
+// port is used. It fails early if none of the URIs is usable, explaining why for each of them.
+func NewServerForRedirectURIs(registered []string) (srv *CallbackServer, closeSrv func(), err error) {
+	if len(registered) == 0 {
+		return nil, nil, errors.New("No redirect URIs registered for the client. Register loopback redirect URI e.g http://127.0.0.1:8883/callback")
+	}
+
+	var reasons []string
+	for _, raw := range registered {
+		u, err := url.Parse(raw)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: not a valid URL", raw))
+			continue
+		}
+		if u.Scheme != "http" {
+			reasons = append(reasons, fmt.Sprintf("%s: only http scheme can be served locally", raw))
+			continue
+		}
+		if !isLoopback(u.Hostname()) {
+			reasons = append(reasons, fmt.Sprintf("%s: not a loopback host", raw))
+			continue
+		}
+
+		anyPort := u.Port() == ""
+		bindURL := *u
+		if anyPort {
+			bindURL.Host = net.JoinHostPort(u.Hostname(), "0")
+		}
+		srv, closeSrv, err := NewServer(bindURL.String())
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %v", raw, err))
+			continue
+		}
+
+		// Redirect URL must match the registered one, except for port, if any port is allowed.
+		redirectURL := *u
+		if anyPort {
+			listenURL, err := url.Parse(srv.redirectURL)
+			if err != nil {
+				closeSrv()
+				return nil, nil, err
+			}
+			redirectURL.Host = net.JoinHostPort(u.Hostname(), listenURL.Port())
+		}
+		srv.redirectURL = redirectURL.String()
+		return srv, closeSrv, nil
+	}
+	return nil, nil, fmt.Errorf("None of the registered redirect URIs can be used for local callback:\n - %s", strings.Join(reasons, "\n - "))
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
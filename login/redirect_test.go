@@ -0,0 +1,146 @@
+package login
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServerForRedirectURIs_AnyPort(t *testing.T) {
+	srv, closeSrv, err := NewServerForRedirectURIs([]string{
+		"https://app.example.com/callback",
+		"http://example.com/callback",
+		"http://localhost/something/callback",
+	})
+	require.NoError(t, err)
+	defer closeSrv()
+
+	u, err := url.Parse(srv.RedirectURL())
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", u.Hostname())
+	assert.NotEmpty(t, u.Port())
+	assert.NotEqual(t, "0", u.Port())
+	assert.Equal(t, "/something/callback", u.Path)
+}
+
+func TestNewServerForRedirectURIs_PortTaken(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	taken := "http://" + l.Addr().String() + "/callback"
+	srv, closeSrv, err := NewServerForRedirectURIs([]string{taken, "http://127.0.0.1/callback"})
+	require.NoError(t, err)
+	defer closeSrv()
+
+	assert.NotEqual(t, taken, srv.RedirectURL())
+}
+
+func TestNewServerForRedirectURIs_NoneUsable(t *testing.T) {
+	_, _, err := NewServerForRedirectURIs([]string{
+		"https://app.example.com/callback",
+		"http://example.com/callback",
+	})
+	require.Error(t, err)
+	assert.Equal(t, "None of the registered redirect URIs can be used for local callback:\n"+
+		" - https://app.example.com/callback: only http scheme can be served locally\n"+
+		" - http://example.com/callback: not a loopback host", err.Error())
+
+	_, _, err = NewServerForRedirectURIs(nil)
+	assert.Error(t, err)
+}
+
+func TestNewServerWithConfig(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	srv, closeSrv, err := NewServerWithConfig(ServerConfig{Host: "localhost", Port: port, Path: "oauth2/callback"})
+	require.NoError(t, err)
+	expected := fmt.Sprintf("http://localhost:%d/oauth2/callback", port)
+	assert.Equal(t, expected, srv.RedirectURL())
+
+	resp, err := http.Get(expected)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// Taken port is not replaced with a free one, since redirect URL would not match.
+	_, _, err = NewServerWithConfig(ServerConfig{Host: "localhost", Port: port})
+	assert.Error(t, err)
+	closeSrv()
+
+	srv, closeSrv, err = NewServer(expected)
+	require.NoError(t, err)
+	defer closeSrv()
+	assert.Equal(t, expected, srv.RedirectURL())
+
+	_, _, err = NewServer("http://127.0.0.1/callback")
+	assert.Error(t, err)
+}
+
+func TestNewLoopbackServer(t *testing.T) {
+	srv := NewLoopbackServer("")
+	assert.Empty(t, srv.RedirectURL())
+
+	redirectURL, stop, err := srv.start()
+	require.NoError(t, err)
+	assert.Equal(t, redirectURL, srv.RedirectURL())
+	u, err := url.Parse(redirectURL)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", u.Hostname())
+	assert.NotEqual(t, "0", u.Port())
+	assert.Equal(t, DefaultCallbackPath, u.Path)
+
+	resp, err := http.Get(redirectURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	stop()
+	assert.Empty(t, srv.RedirectURL())
+	_, err = net.Dial("tcp", u.Host)
+	assert.Error(t, err, "server should not listen after login")
+}
+
+func TestServerConfig_Responses(t *testing.T) {
+	srv, closeSrv, err := NewServerWithConfig(ServerConfig{Responses: CallbackResponses{
+		Error: func(w http.ResponseWriter, _ *http.Request, err error) {
+			w.Write([]byte("server: " + err.Error()))
+		},
+	}})
+	require.NoError(t, err)
+	defer closeSrv()
+
+	for _, c := range []struct {
+		respondErr func(w http.ResponseWriter, r *http.Request, err error)
+		expected   string
+	}{
+		{expected: "server: Invalid state parameter. Got other, expected: state1"},
+		{
+			respondErr: func(w http.ResponseWriter, _ *http.Request, _ error) {
+				w.Write([]byte("flow"))
+			},
+			expected: "flow",
+		},
+	} {
+		req := &callbackRequest{ctx: context.Background(), expectedState: "state1", result: make(chan *callbackResponse, 1), respondErr: c.respondErr}
+		srv.ExpectCallback(req)
+
+		resp, err := http.Get(srv.RedirectURL() + "?code=code1&state=other")
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, string(body))
+		assert.Error(t, (<-req.result).err)
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,7 +28,7 @@ type Cache interface {
 // It caches fetched tokens in provided TokenCache e.g on disk or in k8s config.
 type OIDCTokenSource struct {
 	ctx    context.Context
-	logger *log.Logger
+	logger oidc.Logger
 	cfg    Config
 
 	oidcClient *oidc.Client
@@ -41,6 +41,13 @@ type OIDCTokenSource struct {
 	openBrowser  func(string) error
 	genRandToken func() string
 
+	// onDeviceAuth if not nil, makes token source log in using device authorization grant instead of browser.
+	onDeviceAuth func(*oidc.DeviceAuthResponse) error
+	// onBackchannelAuth if not nil, makes token source log in using backchannel authentication (CIBA) instead of
+	// browser.
+	onBackchannelAuth   func(*oidc.BackchannelAuthResponse) error
+	backchannelAuthOpts []oidc.BackchannelAuthOption
+
 	mu sync.Mutex
 }
 
@@ -52,14 +59,14 @@ func NewOIDCTokenSource(ctx context.Context, logger *log.Logger, cfg Config, cac
 		return nil, nil, errors.New("cache cannot be nil")
 	}
 
-	oidcClient, err := oidc.NewClient(ctx, cache.Config().Provider)
+	oidcClient, err := oidc.NewClient(ctx, cache.Config().Provider, cfg.clientOptions()...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to initialize OIDC client. Err: %v", err)
 	}
 
 	s := &OIDCTokenSource{
 		ctx:    ctx,
-		logger: logger,
+		logger: cfg.loggerOr(logger),
 		cfg:    cfg,
 
 		oidcClient: oidcClient,
@@ -73,10 +80,101 @@ func NewOIDCTokenSource(ctx context.Context, logger *log.Logger, cfg Config, cac
 	if cfg.NonceCheck {
 		s.nonce = rand128Bits()
 	}
+	src, clearIDToken = s.reuse()
+	return src, clearIDToken, nil
+}
+
+// NewDeviceTokenSource constructs token source like NewOIDCTokenSource, but logging in using device authorization
+// grant (see https://tools.ietf.org/html/rfc8628), so it works on headless machines and in SSH sessions without
+// browser and callback server. onDeviceAuth is called with user code and verification URI that need to be shown
+// to the user, e.g:
+//
+//    func(d *oidc.DeviceAuthResponse) error {
+//        fmt.Printf("Open %s and enter code %s\n", d.VerificationURI, d.UserCode)
+//        return nil
+//    }
+//
+// Nonce check is not supported by device authorization grant, so cfg.NonceCheck is ignored.
+func NewDeviceTokenSource(ctx context.Context, logger *log.Logger, cfg Config, cache Cache, onDeviceAuth func(*oidc.DeviceAuthResponse) error) (src oidc.TokenSource, clearIDToken func() error, err error) {
+	if cache == nil {
+		return nil, nil, errors.New("cache cannot be nil")
+	}
+	if onDeviceAuth == nil {
+		return nil, nil, errors.New("onDeviceAuth cannot be nil")
+	}
+
+	oidcClient, err := oidc.NewClient(ctx, cache.Config().Provider, cfg.clientOptions()...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize OIDC client. Err: %v", err)
+	}
+
+	cfg.NonceCheck = false
+	s := &OIDCTokenSource{
+		ctx:    ctx,
+		logger: cfg.loggerOr(logger),
+		cfg:    cfg,
+
+		oidcClient: oidcClient,
+		cache:      cache,
+
+		onDeviceAuth: onDeviceAuth,
+	}
+	src, clearIDToken = s.reuse()
+	return src, clearIDToken, nil
+}
+
+// NewBackchannelTokenSource constructs token source like NewOIDCTokenSource, but logging in using Client-Initiated
+// Backchannel Authentication in poll mode (see
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html). Instead of a browser
+// redirect, provider asks the user identified by loginHint to approve the login on their authentication device, e.g by
+// push notification on the phone. onBackchannelAuth is called once the request is started, e.g to tell the user to
+// check their phone. Additional options, e.g oidc.WithBindingMessage, are passed to the backchannel authentication
+// request.
+//
+// Nonce check is not supported by backchannel authentication, so cfg.NonceCheck is ignored.
+func NewBackchannelTokenSource(ctx context.Context, logger *log.Logger, cfg Config, cache Cache, loginHint string, onBackchannelAuth func(*oidc.BackchannelAuthResponse) error, opts ...oidc.BackchannelAuthOption) (src oidc.TokenSource, clearIDToken func() error, err error) {
+	if cache == nil {
+		return nil, nil, errors.New("cache cannot be nil")
+	}
+	if loginHint == "" {
+		return nil, nil, errors.New("loginHint cannot be empty")
+	}
+	if onBackchannelAuth == nil {
+		return nil, nil, errors.New("onBackchannelAuth cannot be nil")
+	}
+
+	oidcClient, err := oidc.NewClient(ctx, cache.Config().Provider, cfg.clientOptions()...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize OIDC client. Err: %v", err)
+	}
+
+	cfg.NonceCheck = false
+	s := &OIDCTokenSource{
+		ctx:    ctx,
+		logger: cfg.loggerOr(logger),
+		cfg:    cfg,
+
+		oidcClient: oidcClient,
+		cache:      cache,
+
+		onBackchannelAuth:   onBackchannelAuth,
+		backchannelAuthOpts: append([]oidc.BackchannelAuthOption{oidc.WithLoginHint(loginHint)}, opts...),
+	}
+	src, clearIDToken = s.reuse()
+	return src, clearIDToken, nil
+}
 
-	reuseTokenSource, reset := oidc.NewReuseTokenSourceWithDebugLogger(ctx, logger, nil, s)
+// reuse wraps token source with ReuseTokenSource.
+func (s *OIDCTokenSource) reuse() (oidc.TokenSource, func() error) {
+	cfg := s.cfg
+	var reuseOpts []oidc.ReuseTokenSourceOption
+	if cfg.MinAccessTokenValidity > 0 {
+		reuseOpts = append(reuseOpts, oidc.WithMinRemainingValidity(cfg.MinAccessTokenValidity))
+	}
+	reuseOpts = append(reuseOpts, oidc.WithTokenSourceLogger(s.logger))
+	reuseTokenSource, reset := oidc.NewReuseTokenSource(s.ctx, nil, s, reuseOpts...)
 	// Our clear ID token function needs to reset reuse token to make sense.
-	return reuseTokenSource, s.clearIDToken(reset), nil
+	return reuseTokenSource, s.clearIDToken(reset)
 }
 
 func (s *OIDCTokenSource) clearIDToken(resetTS func()) func() error {
@@ -89,7 +187,7 @@ func (s *OIDCTokenSource) clearIDToken(resetTS func()) func() error {
 
 		token, err := s.cache.Token()
 		if err != nil {
-			s.logger.Printf("Error: %v\n", err)
+			s.logger.Warn("Failed to get cached token. Nothing to clear.", "err", err)
 			// Nothing to clear.
 			// TODO(Bplotka): This is not true if we cannot get cache file at all. Fix that.
 			return nil
@@ -105,57 +203,136 @@ func (s *OIDCTokenSource) clearIDToken(resetTS func()) func() error {
 	}
 }
 
-func (s *OIDCTokenSource) getOIDCConfig() oidc.Config {
-	cfg := s.cache.Config()
-	oidcConfig := oidc.Config{
-		ClientID:     cfg.ClientID,
-		ClientSecret: cfg.ClientSecret,
-		Scopes:       cfg.Scopes,
+// InvalidateToken drops ID and access token of cached token if it is still t (or unconditionally if t is nil), so next
+// call refreshes it using refresh token, or logs in again. It is called by ForceRefresh and InvalidateToken of the
+// oidc.ReuseTokenSource returned by constructors.
+func (s *OIDCTokenSource) InvalidateToken(t *oidc.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.cache.Token()
+	if err != nil {
+		return err
 	}
-	return oidcConfig
+	if token == nil || (t != nil && token.AccessToken != t.AccessToken) {
+		// Nothing to invalidate, or token was already replaced.
+		return nil
+	}
+
+	token.IDToken = ""
+	token.AccessToken = ""
+	return s.cache.SaveToken(token)
 }
 
-func (s *OIDCTokenSource) getOIDCConfigWithRedirectURL(redirectURL string) oidc.Config {
+func (s *OIDCTokenSource) getOIDCConfig(scopes []string) oidc.Config {
 	cfg := s.cache.Config()
 	oidcConfig := oidc.Config{
 		ClientID:     cfg.ClientID,
 		ClientSecret: cfg.ClientSecret,
-		Scopes:       cfg.Scopes,
-		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		Resources:    cfg.Resources,
 	}
 	return oidcConfig
 }
 
+// scopes returns scopes to ask for. With incremental consent these are configured scopes merged with scopes already
+// granted to the cached token, so no scope is lost on refresh or new login.
+func (s *OIDCTokenSource) scopes(cachedToken *oidc.Token) []string {
+	scopes := s.cache.Config().Scopes
+	if !s.cfg.IncrementalConsent || cachedToken == nil {
+		return scopes
+	}
+
+	merged := append([]string(nil), scopes...)
+	for _, granted := range strings.Fields(cachedToken.Scope) {
+		if !containsScope(merged, granted) {
+			merged = append(merged, granted)
+		}
+	}
+	return merged
+}
+
+// missingScopes returns configured scopes that were not granted to the cached token. It is always empty without
+// incremental consent. Tokens without recorded scopes are treated as missing all of them.
+func (s *OIDCTokenSource) missingScopes(cachedToken *oidc.Token) []string {
+	if !s.cfg.IncrementalConsent || cachedToken == nil {
+		return nil
+	}
+
+	granted := strings.Fields(cachedToken.Scope)
+	var missing []string
+	for _, scope := range s.cache.Config().Scopes {
+		if !containsScope(granted, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// recordScopes sets requested scopes as granted ones if provider did not return them, which means they were granted
+// as requested. This is needed only with incremental consent to tell which scopes cached token has.
+func (s *OIDCTokenSource) recordScopes(token *oidc.Token, scopes []string) {
+	if s.cfg.IncrementalConsent && token.Scope == "" {
+		token.Scope = strings.Join(scopes, " ")
+	}
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // OIDCToken is used to obtain new OIDC Token (which includes e.g access token, refresh token and id token). It does that by
 // using a Refresh Token to obtain new Tokens. If the cached one is still valid it returns it immediately.
 func (s *OIDCTokenSource) OIDCToken() (*oidc.Token, error) {
+	return s.OIDCTokenCtx(s.ctx)
+}
+
+// OIDCTokenCtx is like OIDCToken, but uses ctx for verification, refresh and login requests.
+func (s *OIDCTokenSource) OIDCTokenCtx(ctx context.Context) (*oidc.Token, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	cachedToken, err := s.cache.Token()
 	if err != nil {
-		s.logger.Printf("Warn: Failed to get cached token or token is invalid. Err: %v", err)
+		s.logger.Warn("Failed to get cached token or token is invalid.", "err", err)
+	} else if missing := s.missingScopes(cachedToken); len(missing) > 0 {
+		// Refresh cannot add scopes, so new login is needed.
+		s.logger.Info("Cached token was not granted required scopes. Asking for additional consent.", "missing_scopes", missing)
 	} else if cachedToken != nil {
-		err = cachedToken.IsValid(s.ctx, s.Verifier())
+		err = cachedToken.IsValidFor(ctx, s.Verifier(), s.cfg.MinAccessTokenValidity)
 		if err == nil {
 			// Successfully retrieved a non-expired cached token and only if we have ID token as well.
 			return cachedToken, nil
 		}
-		s.logger.Printf("Warn: Cached token is not valid. Cause: %v\n", err)
+		s.logger.Warn("Cached token is not valid.", "cause", err)
 		if cachedToken.RefreshToken != "" {
 			// Only if we have refresh token, we can refresh NewIDToken.
-			oidcToken, err := s.refreshToken(cachedToken.RefreshToken)
+			oidcToken, err := s.refreshToken(ctx, cachedToken.RefreshToken, s.scopes(cachedToken))
 			if err == nil {
 				return oidcToken, nil
 			}
 
 			// Our refresh token expired.
-			s.logger.Printf("Warn: Refresh token expired. Err: %v", err)
+			s.logger.Warn("Refresh token expired.", "err", err)
 		}
 	}
 	// Our request for access token was denied, either we had no RefreshToken, it was invalid or expired.
-	newToken, err := s.newToken()
+	ctx, span := s.oidcClient.StartSpan(ctx, "oidc.Login",
+		oidc.SpanAttribute{Key: oidc.SpanAttrClientID, Value: s.cache.Config().ClientID},
+		oidc.SpanAttribute{Key: oidc.SpanAttrLoginFlow, Value: s.loginFlow()},
+	)
+	newToken, err := s.newToken(ctx, s.scopes(cachedToken))
+	span.End(err)
 	if err != nil {
+		if _, ok := err.(*TimeoutError); ok {
+			// Returned as is, so callers can check for abandoned login.
+			return nil, err
+		}
 		return nil, fmt.Errorf("Failed to obtain new token. Err: %v", err)
 	}
 
@@ -170,21 +347,21 @@ func (s *OIDCTokenSource) Verifier() oidc.Verifier {
 	})
 }
 
-func (s *OIDCTokenSource) refreshToken(refreshToken string) (*oidc.Token, error) {
-	s.logger.Printf("Debug: Cached token has none or expired ID token or access token. " +
+func (s *OIDCTokenSource) refreshToken(ctx context.Context, refreshToken string, scopes []string) (*oidc.Token, error) {
+	s.logger.Debug("Cached token has none or expired ID token or access token. " +
 		"Try to refresh access token using refresh token.")
 
 	token, err := oidc.NewTokenRefresher(
-		s.ctx,
+		ctx,
 		s.oidcClient,
-		s.getOIDCConfig(),
+		s.getOIDCConfig(scopes),
 		refreshToken,
 	).OIDCToken()
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = s.Verifier().Verify(s.ctx, token.IDToken)
+	_, err = s.Verifier().Verify(ctx, token.IDToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify idToken from provider. Err: %v", err)
 	}
@@ -197,73 +374,170 @@ func (s *OIDCTokenSource) refreshToken(refreshToken string) (*oidc.Token, error)
 		return nil, fmt.Errorf("got expired access token in token from provider")
 	}
 
-	err = s.cache.SaveToken(token)
-	if err != nil {
-		s.logger.Printf("Warn: Cannot cache token. Err: %v", err)
+	if s.cfg.MinAccessTokenValidity > 0 && token.IsAccessTokenExpiringWithin(s.cfg.MinAccessTokenValidity) {
+		return nil, fmt.Errorf("got access token from provider that expires in less than required %v", s.cfg.MinAccessTokenValidity)
 	}
 
+	s.recordScopes(token, scopes)
+	s.saveToken(token)
+
 	return token, nil
 }
 
+// saveToken caches new token and passes it to OnNewToken hook.
+func (s *OIDCTokenSource) saveToken(token *oidc.Token) {
+	if err := s.cache.SaveToken(token); err != nil {
+		s.logger.Warn("Cannot cache token.", "err", err)
+	}
+	if s.cfg.OnNewToken != nil {
+		s.cfg.OnNewToken(token)
+	}
+}
+
+const (
+	// DefaultLoginTimeout is how long browser login waits for the callback if Config.LoginTimeout is not set.
+	DefaultLoginTimeout = 1 * time.Minute
+	// DefaultManualLoginTimeout is how long login with Config.NoBrowser waits for the user to paste the code if
+	// Config.LoginTimeout is not set.
+	DefaultManualLoginTimeout = 5 * time.Minute
+)
+
+// loginFlow returns name of the flow used by newToken, for tracing.
+func (s *OIDCTokenSource) loginFlow() string {
+	switch {
+	case s.onDeviceAuth != nil:
+		return "device"
+	case s.onBackchannelAuth != nil:
+		return "backchannel"
+	}
+	return "auth_code"
+}
+
 // newToken calls URL to Provider auth endpoint via browser with response type set to `code`. The URL have redirectURL set
 // to CallbackServer that exposes callback handler.
 // In case of none CallbackServer it will block login.
 // NOTE: this flow will fail on any random request that will fly to callback handler in the moment of running this method.
 // Currently there is no way to differentiate it with proper redirect call from Provider.
-func (s *OIDCTokenSource) newToken() (*oidc.Token, error) {
-	if s.callbackSrv == nil {
+func (s *OIDCTokenSource) newToken(ctx context.Context, scopes []string) (*oidc.Token, error) {
+	if s.onDeviceAuth != nil {
+		return s.newDeviceToken(ctx, scopes)
+	}
+	if s.onBackchannelAuth != nil {
+		return s.newBackchannelToken(ctx, scopes)
+	}
+	if s.callbackSrv == nil && !s.cfg.NoBrowser {
 		return nil, errors.New("Refresh token expired or not specified. Login disabled.")
 	}
-	s.logger.Print("Debug: Performing auth Code flow to obtain entirely new OIDC token.")
-
-	state := s.genRandToken()
-	nonce := ""
-	extra := url.Values{}
+	s.logger.Debug("Performing auth Code flow to obtain entirely new OIDC token.")
+
+	timeout := s.cfg.LoginTimeout
+	if timeout == 0 {
+		timeout = DefaultLoginTimeout
+		if s.cfg.NoBrowser {
+			// User might need to log in on another machine, so give them more time.
+			timeout = DefaultManualLoginTimeout
+		}
+	}
+	flowOpts := []FlowOption{WithTimeout(timeout)}
+	if s.cfg.NoBrowser {
+		flowOpts = append(flowOpts, WithManualCodeEntry(os.Stdin, os.Stderr))
+	} else {
+		flowOpts = append(flowOpts, WithOpenBrowser(func(authURL string) error {
+			s.logger.Info("Opening browser to access URL.", "url", authURL)
+			return s.openBrowser(authURL)
+		}))
+	}
 	if s.cfg.NonceCheck {
-		nonce = s.genRandToken()
-		extra.Set("nonce", nonce)
+		flowOpts = append(flowOpts, WithNonceCheck())
 	}
+	if s.cfg.IncrementalConsent {
+		flowOpts = append(flowOpts, WithAuthCodeOptions(oidc.WithIncludeGrantedScopes()))
+	}
+	flowOpts = append(flowOpts, s.cfg.FlowOptions...)
+	flow := NewFlow(s.oidcClient, s.getOIDCConfig(scopes), s.callbackSrv, flowOpts...)
+	flow.genRandToken = s.genRandToken
 
-	ctx, cancel := context.WithTimeout(s.ctx, 1*time.Minute)
+	ctx, cancel := cancelOnInterrupt(ctx)
 	defer cancel()
 
-	s.callbackSrv.ExpectCallback(&callbackRequest{
-		ctx:           ctx,
-		expectedState: state,
-		client:        s.oidcClient,
-		cfg:           s.getOIDCConfigWithRedirectURL(s.callbackSrv.RedirectURL()),
-	})
+	// Flow returns *TimeoutError with instructions for the user if they did not finish login in time.
+	res, err := flow.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.nonce = res.Nonce
+	s.recordScopes(res.Token, scopes)
+	s.saveToken(res.Token)
+	return res.Token, nil
+}
+
+// newDeviceToken performs device authorization grant to obtain entirely new OIDC token.
+func (s *OIDCTokenSource) newDeviceToken(ctx context.Context, scopes []string) (*oidc.Token, error) {
+	s.logger.Debug("Performing device authorization grant to obtain entirely new OIDC token.")
+
+	cfg := s.getOIDCConfig(scopes)
+	d, err := s.oidcClient.DeviceAuth(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.onDeviceAuth(d); err != nil {
+		return nil, err
+	}
 
-	authURL := s.oidcClient.AuthCodeURL(s.getOIDCConfigWithRedirectURL(s.callbackSrv.RedirectURL()), state, extra)
-	s.logger.Printf("Info: Opening browser to access URL: %s", authURL)
-	err := s.openBrowser(authURL)
+	ctx, cancel := cancelOnInterrupt(ctx)
+	defer cancel()
+
+	token, err := s.oidcClient.DeviceAccessToken(ctx, cfg, d)
 	if err != nil {
-		return nil, fmt.Errorf("oidc: Failed to open browser. Please open this URL in browser: %s Err: %v", authURL, err)
+		return nil, err
 	}
 
-	quit := make(chan os.Signal)
-	signal.Notify(quit, os.Interrupt)
-	go func() {
-		<-quit
-		cancel()
-	}()
+	s.recordScopes(token, scopes)
+	s.saveToken(token)
+	return token, nil
+}
 
-	select {
-	// TODO(bplotka): What if someone will scan our callback endpoint?
-	case msg := <-s.callbackSrv.Callback():
-		// Give some time for server to finish request.
-		time.Sleep(200 * time.Millisecond)
-		if msg.err != nil {
-			return nil, fmt.Errorf("oidc: Callback error: %v", msg.err)
-		}
+// newBackchannelToken performs backchannel authentication to obtain entirely new OIDC token.
+func (s *OIDCTokenSource) newBackchannelToken(ctx context.Context, scopes []string) (*oidc.Token, error) {
+	s.logger.Debug("Performing backchannel authentication to obtain entirely new OIDC token.")
 
-		s.nonce = nonce
-		err = s.cache.SaveToken(msg.token)
-		if err != nil {
-			s.logger.Printf("Warn: Cannot cache token. Err: %v", err)
-		}
-		return msg.token, nil
-	case <-ctx.Done():
-		return nil, fmt.Errorf("oidc Deadline Exceeded: Timed out waiting for token. Please retry the command and open the URL printed above in a browser if it doesn't open automatically")
+	cfg := s.getOIDCConfig(scopes)
+	b, err := s.oidcClient.BackchannelAuth(ctx, cfg, s.backchannelAuthOpts...)
+	if err != nil {
+		return nil, err
 	}
+	if err := s.onBackchannelAuth(b); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := cancelOnInterrupt(ctx)
+	defer cancel()
+
+	token, err := s.oidcClient.BackchannelToken(ctx, cfg, b)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordScopes(token, scopes)
+	s.saveToken(token)
+	return token, nil
+}
+
+// cancelOnInterrupt returns context that is cancelled when user interrupts the process (e.g with Ctrl+C), so
+// long polling for login can be aborted.
+func cancelOnInterrupt(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	go func() {
+		defer signal.Stop(quit)
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
 }
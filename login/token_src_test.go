@@ -31,6 +31,7 @@ const (
 var (
 	testToken = oidc.Token{
 		AccessToken:  "access1",
+		TokenType:    "Bearer",
 		RefreshToken: "refresh1",
 		IDToken:      "idtoken1",
 	}
@@ -78,12 +79,12 @@ func (s *TokenSourceTestSuite) SetupSuite() {
 	defer func() {
 		oidc.DefaultKeySetExpiration = oldKeySetExpiration
 	}()
-	oidcClient, err := oidc.NewClient(s.provider.Context(), s.testOIDCCfg.Provider)
+	oidcClient, err := oidc.NewClient(s.provider.Context(), s.testOIDCCfg.Provider, s.provider.ClientOption())
 	s.Require().NoError(err)
 
 	s.oidcSource = &OIDCTokenSource{
 		ctx:    s.provider.Context(),
-		logger: log.New(os.Stdout, "", 0),
+		logger: oidc.NewStdLogger(log.New(os.Stdout, "", 0)),
 		cfg:    s.testCfg,
 
 		oidcClient:  oidcClient,
@@ -163,16 +164,23 @@ func stripArgFromURL(arg string, urlToStrip string) (string, error) {
 }
 
 func (s *TokenSourceTestSuite) callSuccessfulCallback(expectedWord string) func(string) error {
+	return s.callSuccessfulCallbackWithScopes(expectedWord, s.testOIDCCfg.Scopes, "")
+}
+
+// callSuccessfulCallbackWithScopes expects given scopes and extra params (sorted between client_id and nonce) in
+// the auth URL.
+func (s *TokenSourceTestSuite) callSuccessfulCallbackWithScopes(expectedWord string, scopes []string, extraParams string) func(string) error {
 	return func(urlToGet string) error {
 		redirectURL, err := stripArgFromURL("redirect_uri", urlToGet)
 		s.Require().NoError(err)
 
 		s.Equal(fmt.Sprintf(
-			"https://issuer.org/auth1?client_id=%s&nonce=%s&redirect_uri=%s&response_type=code&scope=%s&state=%s",
+			"https://issuer.org/auth1?client_id=%s%s&nonce=%s&redirect_uri=%s&response_type=code&scope=%s&state=%s",
 			testClientID,
+			extraParams,
 			expectedWord,
 			url.QueryEscape(redirectURL),
-			strings.Join(s.testOIDCCfg.Scopes, "+"),
+			strings.Join(scopes, "+"),
 			expectedWord,
 		), urlToGet)
 
@@ -284,10 +292,15 @@ func (s *TokenSourceTestSuite) Test_IDTokenWrongNonce_RefreshToken_OK() {
 	// For 2th verification inside reuse TokenSource.
 	s.provider.MockPubKeysCall(jwkSetJSON2)
 
+	var newTokens []*oidc.Token
+	s.oidcSource.cfg.OnNewToken = func(t *oidc.Token) { newTokens = append(newTokens, t) }
+	defer func() { s.oidcSource.cfg.OnNewToken = nil }()
+
 	token, err := s.oidcSource.OIDCToken()
 	s.Require().NoError(err)
 
 	s.Equal(expectedToken, *token)
+	s.Equal([]*oidc.Token{token}, newTokens)
 
 	s.cache.AssertExpectations(s.T())
 	s.Equal(0, s.provider.Mock().Len())
@@ -320,6 +333,101 @@ func (s *TokenSourceTestSuite) Test_IDTokenWrongNonce_RefreshTokenErr_NewToken_O
 	s.Equal(0, s.provider.Mock().Len())
 }
 
+func (s *TokenSourceTestSuite) Test_IncrementalConsent_MissingScope_NewToken_OKCallback() {
+	s.oidcSource.cfg.IncrementalConsent = true
+	defer func() {
+		s.oidcSource.cfg.IncrementalConsent = false
+	}()
+
+	requiredScopes := []string{oidc.ScopeOpenID, oidc.ScopeProfile}
+	oidcCfg := s.testOIDCCfg
+	oidcCfg.Scopes = requiredScopes
+	s.cache = new(MockCache)
+	s.cache.On("Config").Return(oidcCfg)
+	s.oidcSource.cache = s.cache
+
+	// Cached token is valid, but it was not granted profile scope.
+	cachedToken := testToken
+	cachedToken.Scope = "openid email"
+	s.cache.On("Token").Return(&cachedToken, nil)
+
+	expectedScopes := []string{oidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail}
+	expectedToken := testToken
+	expectedToken.Scope = strings.Join(expectedScopes, " ")
+	s.cache.On("SaveToken", &expectedToken).Return(nil)
+
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+	s.oidcSource.openBrowser = s.callSuccessfulCallbackWithScopes(expectedWord, expectedScopes, "&include_granted_scopes=true")
+
+	token, err := s.oidcSource.OIDCToken()
+	s.Require().NoError(err)
+
+	s.Equal(expectedToken, *token)
+
+	s.cache.AssertExpectations(s.T())
+	s.Equal(0, s.provider.Mock().Len())
+}
+
+func (s *TokenSourceTestSuite) Test_IncrementalConsent_SharedCache() {
+	s.oidcSource.cfg.IncrementalConsent = true
+	defer func() {
+		s.oidcSource.cfg.IncrementalConsent = false
+	}()
+
+	// Two token sources with different scopes use the same cache entry.
+	idToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, s.oidcSource.nonce)
+	stored := testToken
+	stored.IDToken = idToken
+	stored.Scope = "openid email"
+	storedToken := &stored
+
+	narrowCfg := s.testOIDCCfg
+	narrowCfg.Scopes = []string{oidc.ScopeOpenID, oidc.ScopeEmail}
+	narrow := new(MockCache)
+	narrow.On("Config").Return(narrowCfg)
+	narrow.On("Token").Return(func() *oidc.Token { return storedToken }, nil)
+
+	wideCfg := s.testOIDCCfg
+	wideCfg.Scopes = []string{oidc.ScopeOpenID, oidc.ScopeProfile}
+	wide := new(MockCache)
+	wide.On("Config").Return(wideCfg)
+	wide.On("Token").Return(func() *oidc.Token { return storedToken }, nil)
+	wide.On("SaveToken", mock.AnythingOfType("*oidc.Token")).Run(func(args mock.Arguments) {
+		storedToken = args.Get(0).(*oidc.Token)
+	}).Return(nil)
+
+	// Token with narrow scopes is reused by narrow source.
+	s.oidcSource.cache = narrow
+	s.provider.MockPubKeysCall(jwkSetJSON)
+	token, err := s.oidcSource.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal(stored, *token)
+	s.Equal(0, s.provider.Mock().Len())
+
+	// Wide source logs in with scopes of both and replaces the shared token.
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+	s.oidcSource.cache = wide
+	s.oidcSource.openBrowser = s.callSuccessfulCallbackWithScopes(expectedWord, []string{oidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail}, "&include_granted_scopes=true")
+	token, err = s.oidcSource.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("openid profile email", token.Scope)
+	s.Equal(token, storedToken)
+	s.Equal(0, s.provider.Mock().Len())
+
+	// Replaced token still has all scopes of narrow source, so it does not need to log in again.
+	s.oidcSource.cache = narrow
+	s.Empty(s.oidcSource.missingScopes(storedToken))
+
+	narrow.AssertExpectations(s.T())
+	wide.AssertExpectations(s.T())
+}
+
 func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_ErrCallback() {
 	s.cache.On("Token").Return(nil, nil)
 
@@ -406,3 +514,115 @@ func (s *TokenSourceTestSuite) Test_ClearIDToken_ClearOnlyIDToken() {
 
 	s.cache.AssertExpectations(s.T())
 }
+
+func (s *TokenSourceTestSuite) Test_InvalidateToken() {
+	token := oidc.Token{
+		AccessToken:  "accessToken",
+		IDToken:      "idToken",
+		RefreshToken: "refreshToken",
+	}
+	// Just to satisfy mock.
+	s.cache.Config()
+
+	s.cache.On("Token").Return(&token, nil)
+
+	// Token was already replaced.
+	s.Require().NoError(s.oidcSource.InvalidateToken(&oidc.Token{AccessToken: "oldAccessToken"}))
+	s.cache.AssertNotCalled(s.T(), "SaveToken", mock.Anything)
+
+	s.cache.On("SaveToken", mock.Anything).Run(func(a mock.Arguments) {
+		t, ok := a.Get(0).(*oidc.Token)
+		s.Require().True(ok)
+
+		s.Assert().Empty(t.AccessToken)
+		s.Assert().Empty(t.IDToken)
+		s.Assert().Equal("refreshToken", t.RefreshToken)
+	}).Return(nil)
+	s.Require().NoError(s.oidcSource.InvalidateToken(&oidc.Token{AccessToken: "accessToken"}))
+
+	s.cache.AssertExpectations(s.T())
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewDeviceToken_OK() {
+	s.cache.On("Token").Return(nil, nil)
+	s.cache.On("SaveToken", &testToken).Return(nil)
+
+	s.provider.Mock().Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{
+		"device_code": "device1",
+		"user_code": "ABCD-EFGH",
+		"verification_uri": "https://issuer.org/device",
+		"expires_in": 600,
+		"interval": 1
+	}`)))
+
+	tokenJSON, err := json.Marshal(oidc.TokenResponse{
+		AccessToken:  testToken.AccessToken,
+		RefreshToken: testToken.RefreshToken,
+		IDToken:      testToken.IDToken,
+		TokenType:    "Bearer",
+	})
+	s.Require().NoError(err)
+	s.provider.Mock().Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+
+	var userCode string
+	s.oidcSource.onDeviceAuth = func(d *oidc.DeviceAuthResponse) error {
+		userCode = d.UserCode
+		return nil
+	}
+	defer func() {
+		s.oidcSource.onDeviceAuth = nil
+	}()
+
+	token, err := s.oidcSource.OIDCToken()
+	s.Require().NoError(err)
+
+	s.Equal(testToken, *token)
+	s.Equal("ABCD-EFGH", userCode)
+
+	s.cache.AssertExpectations(s.T())
+	s.Equal(0, s.provider.Mock().Len())
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewBackchannelToken_OK() {
+	s.cache.On("Token").Return(nil, nil)
+	s.cache.On("SaveToken", &testToken).Return(nil)
+
+	s.provider.Mock().Push(func(r *http.Request) (*http.Response, error) {
+		s.Require().NoError(r.ParseForm())
+		s.Equal("user@example.com", r.PostForm.Get("login_hint"))
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{
+			"auth_req_id": "req1",
+			"expires_in": 120,
+			"interval": 1
+		}`))(r)
+	})
+
+	tokenJSON, err := json.Marshal(oidc.TokenResponse{
+		AccessToken:  testToken.AccessToken,
+		RefreshToken: testToken.RefreshToken,
+		IDToken:      testToken.IDToken,
+		TokenType:    "Bearer",
+	})
+	s.Require().NoError(err)
+	s.provider.Mock().Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+
+	var authReqID string
+	s.oidcSource.onBackchannelAuth = func(b *oidc.BackchannelAuthResponse) error {
+		authReqID = b.AuthReqID
+		return nil
+	}
+	s.oidcSource.backchannelAuthOpts = []oidc.BackchannelAuthOption{oidc.WithLoginHint("user@example.com")}
+	defer func() {
+		s.oidcSource.onBackchannelAuth = nil
+		s.oidcSource.backchannelAuthOpts = nil
+	}()
+
+	token, err := s.oidcSource.OIDCToken()
+	s.Require().NoError(err)
+
+	s.Equal(testToken, *token)
+	s.Equal("req1", authReqID)
+
+	s.cache.AssertExpectations(s.T())
+	s.Equal(0, s.provider.Mock().Len())
+}
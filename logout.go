@@ -0,0 +1,43 @@
+package oidc
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// LogoutURL returns a URL to OIDC provider's end session endpoint that terminates user's session on the provider
+// (see https://openid.net/specs/openid-connect-rpinitiated-1_0.html). Open it in user's browser.
+// All arguments are optional: idTokenHint is previously issued ID token, postLogoutRedirectURI is where provider
+// redirects the browser after logout (it needs to be registered for the client) and state is passed back to it.
+func (c *Client) LogoutURL(idTokenHint string, postLogoutRedirectURI string, state string) (string, error) {
+	discovery := c.Discovery()
+	if discovery.EndSessionURL == "" {
+		return "", errors.New("oidc: end session endpoint is not supported by this provider")
+	}
+
+	v := url.Values{}
+	if idTokenHint != "" {
+		v.Set("id_token_hint", idTokenHint)
+	}
+	if postLogoutRedirectURI != "" {
+		v.Set("post_logout_redirect_uri", postLogoutRedirectURI)
+	}
+	if state != "" {
+		v.Set("state", state)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(discovery.EndSessionURL)
+	if len(v) == 0 {
+		return buf.String(), nil
+	}
+	if strings.Contains(discovery.EndSessionURL, "?") {
+		buf.WriteByte('&')
+	} else {
+		buf.WriteByte('?')
+	}
+	buf.WriteString(v.Encode())
+	return buf.String(), nil
+}
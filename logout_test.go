@@ -0,0 +1,21 @@
+package oidc
+
+import (
+	"net/url"
+)
+
+func (s *ClientTestSuite) TestLogoutURL() {
+	logoutURL, err := s.client.LogoutURL("idtoken1", "https://app.org/logged-out", "state1")
+	s.Require().NoError(err)
+
+	u, err := url.Parse(logoutURL)
+	s.Require().NoError(err)
+	s.Equal(testDiscovery.EndSessionURL, u.Scheme+"://"+u.Host+u.Path)
+	s.Equal("idtoken1", u.Query().Get("id_token_hint"))
+	s.Equal("https://app.org/logged-out", u.Query().Get("post_logout_redirect_uri"))
+	s.Equal("state1", u.Query().Get("state"))
+
+	logoutURL, err = s.client.LogoutURL("", "", "")
+	s.Require().NoError(err)
+	s.Equal(testDiscovery.EndSessionURL, logoutURL)
+}
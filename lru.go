@@ -0,0 +1,121 @@
+package oidc
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheLimits specifies memory bounds for an internal cache.
+type CacheLimits struct {
+	// MaxEntries is the maximum number of entries kept in cache. Least recently used entries are evicted first.
+	// Zero or negative means no limit.
+	MaxEntries int
+
+	// TTL is the maximum time an entry is kept in cache. Zero or negative means entries never expire on their own.
+	TTL time.Duration
+}
+
+// lruCache is a LRU cache with TTL eviction. It is safe for concurrent use.
+type lruCache struct {
+	mu sync.Mutex
+
+	limits  CacheLimits
+	timeNow func() time.Time
+
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key    string
+	value  interface{}
+	expiry time.Time
+}
+
+func newLRUCache(limits CacheLimits, now func() time.Time) *lruCache {
+	if now == nil {
+		now = time.Now
+	}
+	return &lruCache{
+		limits:  limits,
+		timeNow: now,
+		ll:      list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+// Get returns value for given key if present and not expired.
+func (c *lruCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if !entry.expiry.IsZero() && !c.timeNow().Before(entry.expiry) {
+		c.removeElement(el)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+// Add adds value under given key, using the cache TTL for expiration.
+func (c *lruCache) Add(key string, value interface{}) {
+	c.AddWithExpiry(key, value, time.Time{})
+}
+
+// AddWithExpiry adds value under given key that expires at given time or when cache TTL passes, whichever is first.
+// Zero expiry means that only cache TTL applies.
+func (c *lruCache) AddWithExpiry(key string, value interface{}, expiry time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limits.TTL > 0 {
+		ttlExpiry := c.timeNow().Add(c.limits.TTL)
+		if expiry.IsZero() || ttlExpiry.Before(expiry) {
+			expiry = ttlExpiry
+		}
+	}
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expiry = expiry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expiry: expiry})
+	if c.limits.MaxEntries > 0 && c.ll.Len() > c.limits.MaxEntries {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Remove removes given key from cache.
+func (c *lruCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Len returns number of entries in cache including expired ones that were not evicted yet.
+func (c *lruCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *lruCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
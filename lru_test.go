@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache_MaxEntries(t *testing.T) {
+	c := newLRUCache(CacheLimits{MaxEntries: 2}, nil)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	// Touch "a" so "b" is the least recently used.
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	c.Add("c", 3)
+	assert.Equal(t, 2, c.Len())
+
+	_, ok = c.Get("b")
+	assert.False(t, ok, "least recently used entry should be evicted")
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	v, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+}
+
+func TestLRUCache_TTL(t *testing.T) {
+	currTime := time.Now()
+	c := newLRUCache(CacheLimits{TTL: 10 * time.Second}, func() time.Time {
+		return currTime
+	})
+
+	c.Add("a", 1)
+	c.AddWithExpiry("b", 2, currTime.Add(5*time.Second))
+	c.AddWithExpiry("c", 3, currTime.Add(1*time.Hour))
+
+	currTime = currTime.Add(6 * time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("b")
+	assert.False(t, ok, "entry should expire at given expiry")
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+
+	currTime = currTime.Add(5 * time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok, "entry should expire after TTL")
+	_, ok = c.Get("c")
+	assert.False(t, ok, "cache TTL should be applied when given expiry is later")
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLRUCache_Remove(t *testing.T) {
+	c := newLRUCache(CacheLimits{}, nil)
+
+	c.Add("a", 1)
+	c.Add("a", 2)
+	assert.Equal(t, 1, c.Len())
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+
+	c.Remove("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,67 @@
+package oidc
+
+// ProviderMetadata is the complete provider metadata returned by discovery endpoint, as defined by
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata and
+// https://tools.ietf.org/html/rfc8414#section-2. Use it to detect provider features instead of hardcoding them.
+// Fields not listed here are available via Client.Claims.
+type ProviderMetadata struct {
+	// DiscoveryJSON holds all endpoints.
+	DiscoveryJSON
+
+	// CheckSessionURL is the OP iframe for session management if supported.
+	CheckSessionURL string `json:"check_session_iframe,omitempty"`
+	// ServiceDocumentation is URL of human-readable documentation of the provider.
+	ServiceDocumentation string `json:"service_documentation,omitempty"`
+
+	ScopesSupported        []string `json:"scopes_supported,omitempty"`
+	ClaimsSupported        []string `json:"claims_supported,omitempty"`
+	ClaimTypesSupported    []string `json:"claim_types_supported,omitempty"`
+	ResponseTypesSupported []string `json:"response_types_supported,omitempty"`
+	ResponseModesSupported []string `json:"response_modes_supported,omitempty"`
+	GrantTypesSupported    []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported  []string `json:"subject_types_supported,omitempty"`
+	ACRValuesSupported     []string `json:"acr_values_supported,omitempty"`
+	UILocalesSupported     []string `json:"ui_locales_supported,omitempty"`
+	// CodeChallengeMethodsSupported are PKCE methods supported by the provider. Empty if PKCE is not advertised.
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported,omitempty"`
+
+	IDTokenSigningAlgsSupported               []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	IDTokenEncryptionAlgsSupported            []string `json:"id_token_encryption_alg_values_supported,omitempty"`
+	UserInfoSigningAlgsSupported              []string `json:"userinfo_signing_alg_values_supported,omitempty"`
+	RequestObjectSigningAlgsSupported         []string `json:"request_object_signing_alg_values_supported,omitempty"`
+	DPoPSigningAlgsSupported                  []string `json:"dpop_signing_alg_values_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported         []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgsSupported     []string `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+	RevocationEndpointAuthMethodsSupported    []string `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+
+	ClaimsParameterSupported       bool `json:"claims_parameter_supported,omitempty"`
+	RequestParameterSupported      bool `json:"request_parameter_supported,omitempty"`
+	BackchannelLogoutSupported     bool `json:"backchannel_logout_supported,omitempty"`
+	FrontchannelLogoutSupported    bool `json:"frontchannel_logout_supported,omitempty"`
+	TLSClientCertBoundAccessTokens bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+}
+
+// SupportsScope reports whether provider advertises given scope.
+func (m ProviderMetadata) SupportsScope(scope string) bool {
+	return contains(m.ScopesSupported, scope)
+}
+
+// SupportsClaim reports whether provider advertises given claim.
+func (m ProviderMetadata) SupportsClaim(claim string) bool {
+	return contains(m.ClaimsSupported, claim)
+}
+
+// SupportsGrantType reports whether provider supports given grant type. Per RFC 8414, provider that does not
+// advertise grant types supports only "authorization_code" and "implicit".
+func (m ProviderMetadata) SupportsGrantType(grantType string) bool {
+	if len(m.GrantTypesSupported) == 0 {
+		return grantType == "authorization_code" || grantType == "implicit"
+	}
+	return contains(m.GrantTypesSupported, grantType)
+}
+
+// SupportsPKCE reports whether provider advertises PKCEMethodS256 code challenge method.
+func (m ProviderMetadata) SupportsPKCE() bool {
+	return contains(m.CodeChallengeMethodsSupported, PKCEMethodS256)
+}
@@ -0,0 +1,124 @@
+package mocks
+
+import "context"
+import "github.com/Bplotka/oidc"
+import "github.com/stretchr/testify/mock"
+import "net/url"
+
+// TokenClient is an autogenerated mock type for the TokenClient type
+type TokenClient struct {
+	mock.Mock
+}
+
+// Exchange provides a mock function with given fields: ctx, cfg, code, extra
+func (_m *TokenClient) Exchange(ctx context.Context, cfg oidc.Config, code string, extra ...url.Values) (*oidc.Token, error) {
+	_va := make([]interface{}, len(extra))
+	for _i := range extra {
+		_va[_i] = extra[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, cfg, code)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *oidc.Token
+	if rf, ok := ret.Get(0).(func(context.Context, oidc.Config, string, ...url.Values) *oidc.Token); ok {
+		r0 = rf(ctx, cfg, code, extra...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*oidc.Token)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, oidc.Config, string, ...url.Values) error); ok {
+		r1 = rf(ctx, cfg, code, extra...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Refresh provides a mock function with given fields: ctx, cfg, refreshToken
+func (_m *TokenClient) Refresh(ctx context.Context, cfg oidc.Config, refreshToken string) (*oidc.Token, error) {
+	ret := _m.Called(ctx, cfg, refreshToken)
+
+	var r0 *oidc.Token
+	if rf, ok := ret.Get(0).(func(context.Context, oidc.Config, string) *oidc.Token); ok {
+		r0 = rf(ctx, cfg, refreshToken)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*oidc.Token)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, oidc.Config, string) error); ok {
+		r1 = rf(ctx, cfg, refreshToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Revoke provides a mock function with given fields: ctx, cfg, token, tokenTypeHint
+func (_m *TokenClient) Revoke(ctx context.Context, cfg oidc.Config, token string, tokenTypeHint ...string) error {
+	_va := make([]interface{}, len(tokenTypeHint))
+	for _i := range tokenTypeHint {
+		_va[_i] = tokenTypeHint[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, cfg, token)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, oidc.Config, string, ...string) error); ok {
+		r0 = rf(ctx, cfg, token, tokenTypeHint...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TokenVerifier provides a mock function with given fields: cfg
+func (_m *TokenClient) TokenVerifier(cfg oidc.VerificationConfig) oidc.Verifier {
+	ret := _m.Called(cfg)
+
+	var r0 oidc.Verifier
+	if rf, ok := ret.Get(0).(func(oidc.VerificationConfig) oidc.Verifier); ok {
+		r0 = rf(cfg)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(oidc.Verifier)
+		}
+	}
+
+	return r0
+}
+
+// UserInfo provides a mock function with given fields: ctx, tokenSource
+func (_m *TokenClient) UserInfo(ctx context.Context, tokenSource oidc.TokenSource) (*oidc.UserInfo, error) {
+	ret := _m.Called(ctx, tokenSource)
+
+	var r0 *oidc.UserInfo
+	if rf, ok := ret.Get(0).(func(context.Context, oidc.TokenSource) *oidc.UserInfo); ok {
+		r0 = rf(ctx, tokenSource)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*oidc.UserInfo)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, oidc.TokenSource) error); ok {
+		r1 = rf(ctx, tokenSource)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+// MTLSEndpointAliases are provider's endpoints that accept TLS client authentication, if they differ from the regular
+// ones. See https://tools.ietf.org/html/rfc8705#section-5.
+type MTLSEndpointAliases struct {
+	TokenURL         string `json:"token_endpoint,omitempty"`
+	RevocationURL    string `json:"revocation_endpoint,omitempty"`
+	IntrospectionURL string `json:"introspection_endpoint,omitempty"`
+	PARURL           string `json:"pushed_authorization_request_endpoint,omitempty"`
+	// BackchannelAuthURL is the Client-Initiated Backchannel Authentication endpoint.
+	BackchannelAuthURL string `json:"backchannel_authentication_endpoint,omitempty"`
+}
+
+// ErrCertificateBindingMismatch is returned when token is bound to a different certificate than the presented one.
+var ErrCertificateBindingMismatch = errors.New("oidc: token is bound to a different certificate")
+
+// WithTLSClientAuth makes client authenticate to the token, revocation, introspection, pushed authorization
+// request and backchannel authentication endpoints with TLS client certificate instead of client secret (tls_client_auth, see
+// https://tools.ietf.org/html/rfc8705#section-2). httpClient needs to present the client certificate, e.g one
+// constructed with NewTLSClientAuthHTTPClient. mTLS endpoint aliases are used if provider advertises them.
+// Access tokens obtained this way are usually bound to the certificate.
+func WithTLSClientAuth(httpClient *http.Client) Option {
+	return func(o *options) {
+		o.mtlsHTTPClient = httpClient
+	}
+}
+
+// NewTLSClientAuthHTTPClient returns HTTP client with default settings that presents given client certificate.
+func NewTLSClientAuthHTTPClient(cert tls.Certificate) *http.Client {
+	return NewHTTPClient(HTTPClientConfig{TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}}})
+}
+
+func mtlsTokenURL(a *MTLSEndpointAliases) string         { return a.TokenURL }
+func mtlsRevocationURL(a *MTLSEndpointAliases) string    { return a.RevocationURL }
+func mtlsIntrospectionURL(a *MTLSEndpointAliases) string { return a.IntrospectionURL }
+func mtlsPARURL(a *MTLSEndpointAliases) string           { return a.PARURL }
+
+// CertificateThumbprint returns base64url encoded SHA-256 thumbprint of the certificate, as used in "x5t#S256"
+// confirmation claim.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type confirmationClaims struct {
+	Cnf struct {
+		X5tS256 string `json:"x5t#S256"`
+	} `json:"cnf"`
+}
+
+// checkCertificateBinding checks that "cnf" claim binds token to the certificate.
+func checkCertificateBinding(claimsFn func(v interface{}) error, cert *x509.Certificate) error {
+	var claims confirmationClaims
+	if err := claimsFn(&claims); err != nil {
+		return err
+	}
+	if claims.Cnf.X5tS256 == "" {
+		return errors.New("oidc: token is not bound to certificate")
+	}
+	if claims.Cnf.X5tS256 != CertificateThumbprint(cert) {
+		return ErrCertificateBindingMismatch
+	}
+	return nil
+}
+
+// VerifyCertificateBinding checks that access token is bound to the client certificate presented on the TLS
+// connection to the resource server, e.g r.TLS.PeerCertificates[0]. See https://tools.ietf.org/html/rfc8705#section-3.
+func (a *AccessToken) VerifyCertificateBinding(cert *x509.Certificate) error {
+	return checkCertificateBinding(a.Claims, cert)
+}
+
+// VerifyCertificateBinding checks that introspected token is bound to the client certificate presented on the TLS
+// connection to the resource server. See https://tools.ietf.org/html/rfc8705#section-3.2.
+func (i *IntrospectionResponse) VerifyCertificateBinding(cert *x509.Certificate) error {
+	return checkCertificateBinding(i.Claims, cert)
+}
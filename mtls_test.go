@@ -0,0 +1,89 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCertificate(t *testing.T) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client1"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestNewTLSClientAuthHTTPClient(t *testing.T) {
+	cert := tls.Certificate{Certificate: [][]byte{testCertificate(t).Raw}}
+	client := NewTLSClientAuthHTTPClient(cert)
+
+	transport := client.Transport.(*http.Transport)
+	assert.True(t, transport != defaultHTTPClient.Transport, "transport should not be shared with default client")
+	require.NotNil(t, transport.TLSClientConfig)
+	assert.Equal(t, []tls.Certificate{cert}, transport.TLSClientConfig.Certificates)
+	assert.Nil(t, defaultHTTPClient.Transport.(*http.Transport).TLSClientConfig)
+}
+
+func (s *ClientTestSuite) TestToken_TLSClientAuth() {
+	client := s.client.Provider().Client(WithTLSClientAuth(s.s.HTTPClient()))
+	client.discovery.MTLSEndpointAliases = &MTLSEndpointAliases{TokenURL: exampleIssuer + "/mtls/token1"}
+
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken: "access1",
+		TokenType:   "Bearer",
+	})
+	s.NoError(err)
+
+	var form url.Values
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal(exampleIssuer+"/mtls/token1", r.URL.String())
+		_, _, ok := r.BasicAuth()
+		s.False(ok, "client secret must not be sent")
+		s.NoError(r.ParseForm())
+		form = r.PostForm
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	_, err = client.Exchange(s.testCtx, Config{ClientID: "client1", ClientSecret: "secret1"}, "code1")
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Equal("client1", form.Get("client_id"))
+	s.Equal("code1", form.Get("code"))
+}
+
+func TestAccessToken_VerifyCertificateBinding(t *testing.T) {
+	cert := testCertificate(t)
+
+	bound := &AccessToken{claims: []byte(`{"cnf": {"x5t#S256": "` + CertificateThumbprint(cert) + `"}}`)}
+	assert.NoError(t, bound.VerifyCertificateBinding(cert))
+
+	other := &AccessToken{claims: []byte(`{"cnf": {"x5t#S256": "` + CertificateThumbprint(testCertificate(t)) + `"}}`)}
+	assert.Equal(t, ErrCertificateBindingMismatch, other.VerifyCertificateBinding(cert))
+
+	notBound := &AccessToken{claims: []byte(`{"sub": "user1"}`)}
+	assert.Error(t, notBound.VerifyCertificateBinding(cert))
+}
@@ -0,0 +1,142 @@
+package oidchttp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Bplotka/oidc"
+)
+
+// ClaimMatcher reports whether decoded claim value is acceptable.
+type ClaimMatcher func(value interface{}) bool
+
+// Equals matches claims equal to given string.
+func Equals(expected string) ClaimMatcher {
+	return func(value interface{}) bool {
+		s, ok := value.(string)
+		return ok && s == expected
+	}
+}
+
+// OneOf matches string claims equal to any of given values, or list claims containing any of them.
+func OneOf(expected ...string) ClaimMatcher {
+	return func(value interface{}) bool {
+		var values []interface{}
+		switch v := value.(type) {
+		case []interface{}:
+			values = v
+		default:
+			values = []interface{}{v}
+		}
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			for _, e := range expected {
+				if s == e {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// RequireScopes returns middleware that rejects requests whose token is not granted all given scopes with 403 and
+// "insufficient_scope" error. It must be used behind Middleware, e.g to require different scopes for different routes.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return authorize(func(v *verifiedToken) *challenge {
+		return checkScopes(v, scopes)
+	})
+}
+
+// RequireClaim returns middleware that rejects requests whose token has no claim of given name accepted by matcher
+// with 403 and "access_denied" error. Name can point to nested claim using dots, e.g "realm_access.roles". It must be
+// used behind Middleware.
+func RequireClaim(name string, matcher ClaimMatcher) func(http.Handler) http.Handler {
+	return authorize(func(v *verifiedToken) *challenge {
+		claims, err := v.claims()
+		if err != nil {
+			return &challenge{status: http.StatusUnauthorized, code: "invalid_token", description: err.Error()}
+		}
+		value, ok := lookupClaim(claims, name)
+		if !ok || !matcher(value) {
+			return accessDenied(fmt.Sprintf("token claim %s does not match", name))
+		}
+		return nil
+	})
+}
+
+// RequireGroups returns middleware that rejects requests whose token is not in any of given groups with 403 and
+// "access_denied" error. Groups are read from given claim (e.g oidc.DefaultClaimsMapping.Groups) the same way as by
+// oidc.ClaimsMapping. It must be used behind Middleware.
+func RequireGroups(claim string, groups ...string) func(http.Handler) http.Handler {
+	mapping := oidc.ClaimsMapping{Groups: claim}
+	return authorize(func(v *verifiedToken) *challenge {
+		claims, err := v.claims()
+		if err != nil {
+			return &challenge{status: http.StatusUnauthorized, code: "invalid_token", description: err.Error()}
+		}
+		identity, err := mapping.IdentityFromClaims(claims)
+		if err != nil {
+			return accessDenied(err.Error())
+		}
+		for _, g := range identity.Groups {
+			for _, required := range groups {
+				if g == required {
+					return nil
+				}
+			}
+		}
+		return accessDenied(fmt.Sprintf("token is not in any of required groups %s", strings.Join(groups, ", ")))
+	})
+}
+
+// authorize returns middleware that passes the request only if check of token verified by Middleware succeeds.
+func authorize(check func(v *verifiedToken) *challenge) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v, ok := r.Context().Value(contextKey{}).(*verifiedToken)
+			if !ok {
+				// Not behind Middleware or the route skipped it, so there is nothing to authorize.
+				(&challenge{status: http.StatusUnauthorized}).write(w, "")
+				return
+			}
+			if ch := check(v); ch != nil {
+				if ch.status != http.StatusForbidden || ch.code == "insufficient_scope" {
+					ch.write(w, "")
+					return
+				}
+				// Authorization failures other than scopes are not bearer token problems, so there is no challenge.
+				writeError(w, ch.status, ch.code, ch.description)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func accessDenied(description string) *challenge {
+	return &challenge{status: http.StatusForbidden, code: "access_denied", description: description}
+}
+
+// lookupClaim returns claim of given name, following dots into nested objects.
+func lookupClaim(claims map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := claims[name]; ok {
+		return v, true
+	}
+
+	var cur interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
@@ -0,0 +1,115 @@
+package oidchttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Bplotka/oidc"
+	"github.com/Bplotka/oidc/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorization(t *testing.T) {
+	provider := &oidc_testing.Provider{}
+	provider.Setup(t)
+
+	idToken, jwkSetJSON := provider.NewIDToken(testClientID, testSubject, "", map[string]interface{}{
+		"scope":        "openid read",
+		"groups":       []string{"dev", "ops"},
+		"tenant":       "acme",
+		"realm_access": map[string]interface{}{"roles": []string{"admin"}},
+	})
+	verifier, err := oidc.NewStaticVerifier(provider.IssuerURL, jwkSetJSON, oidc.VerificationConfig{ClientID: testClientID})
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	authenticate := Middleware(verifier, WithIDTokens())
+
+	for _, c := range []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		skipAuthn  bool
+
+		expectedStatus    int
+		expectedChallenge string
+		expectedBody      string
+	}{
+		{
+			name:           "scopes granted",
+			middleware:     RequireScopes("read"),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:              "scope missing",
+			middleware:        RequireScopes("read", "write"),
+			expectedStatus:    http.StatusForbidden,
+			expectedChallenge: `Bearer error="insufficient_scope", error_description="token is missing required scope write", scope="read write"`,
+			expectedBody:      `{"error":"insufficient_scope","error_description":"token is missing required scope write"}` + "\n",
+		},
+		{
+			name:           "claim matches",
+			middleware:     RequireClaim("tenant", Equals("acme")),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "nested claim matches",
+			middleware:     RequireClaim("realm_access.roles", OneOf("admin")),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "claim does not match",
+			middleware:     RequireClaim("tenant", Equals("other")),
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"access_denied","error_description":"token claim tenant does not match"}` + "\n",
+		},
+		{
+			name:           "claim missing",
+			middleware:     RequireClaim("missing", OneOf("x")),
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"access_denied","error_description":"token claim missing does not match"}` + "\n",
+		},
+		{
+			name:           "in group",
+			middleware:     RequireGroups("groups", "admins", "ops"),
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "not in group",
+			middleware:     RequireGroups("groups", "admins"),
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"access_denied","error_description":"token is not in any of required groups admins"}` + "\n",
+		},
+		{
+			name:              "not authenticated",
+			middleware:        RequireScopes("read"),
+			skipAuthn:         true,
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: "Bearer",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			h := c.middleware(ok)
+			if !c.skipAuthn {
+				h = authenticate(h)
+			}
+			r := httptest.NewRequest("GET", "/api", nil)
+			r.Header.Set("Authorization", "Bearer "+idToken)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			assert.Equal(t, c.expectedStatus, rec.Code)
+			assert.Equal(t, c.expectedChallenge, rec.Header().Get("WWW-Authenticate"))
+			if c.expectedBody != "" {
+				assert.Equal(t, c.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
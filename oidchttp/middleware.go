@@ -0,0 +1,228 @@
+// Package oidchttp provides net/http middleware for resource servers that authenticates requests with bearer tokens
+// verified by oidc.Verifier, as described in https://tools.ietf.org/html/rfc6750.
+package oidchttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Bplotka/oidc"
+)
+
+type config struct {
+	idTokens bool
+	scopes   []string
+	realm    string
+}
+
+// Option configures Middleware.
+type Option func(*config)
+
+// WithIDTokens makes middleware verify bearer tokens as ID tokens instead of JWT access tokens, e.g for APIs called
+// directly by CLI tools using login package. Scopes are then checked against "scope" claim of ID token, if any.
+func WithIDTokens() Option {
+	return func(c *config) {
+		c.idTokens = true
+	}
+}
+
+// WithRequiredScopes rejects tokens that are not granted all given scopes with 403 and "insufficient_scope" error.
+func WithRequiredScopes(scopes ...string) Option {
+	return func(c *config) {
+		c.scopes = append(c.scopes, scopes...)
+	}
+}
+
+// WithRealm sets realm attribute of WWW-Authenticate challenges.
+func WithRealm(realm string) Option {
+	return func(c *config) {
+		c.realm = realm
+	}
+}
+
+// Middleware returns net/http middleware that passes only requests with valid bearer token in Authorization header.
+// Other requests are rejected with WWW-Authenticate challenge: 401 without error code if there is no token, 400 with
+// "invalid_request" if the header is malformed, 401 with "invalid_token" if verification fails and 403 with
+// "insufficient_scope" if required scopes are missing. Verified token is available to handlers via
+// AccessTokenFromContext (or IDTokenFromContext with WithIDTokens).
+func Middleware(verifier oidc.Verifier, opts ...Option) func(http.Handler) http.Handler {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v, challenge := c.authenticate(r, verifier)
+			if challenge != nil {
+				challenge.write(w, c.realm)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, v)))
+		})
+	}
+}
+
+type contextKey struct{}
+
+// verifiedToken is the token verified by the middleware. Exactly one of the fields is set.
+type verifiedToken struct {
+	accessToken *oidc.AccessToken
+	idToken     *oidc.IDToken
+
+	// scope is the space-separated list of scopes granted to the token.
+	scope string
+}
+
+// claims decodes all claims of the token.
+func (v *verifiedToken) claims() (map[string]interface{}, error) {
+	claims := map[string]interface{}{}
+	if v.idToken != nil {
+		return claims, v.idToken.Claims(&claims)
+	}
+	return claims, v.accessToken.Claims(&claims)
+}
+
+// AccessTokenFromContext returns access token verified by Middleware. Claims are available via AccessToken.Claims.
+func AccessTokenFromContext(ctx context.Context) (*oidc.AccessToken, bool) {
+	v, ok := ctx.Value(contextKey{}).(*verifiedToken)
+	if !ok || v.accessToken == nil {
+		return nil, false
+	}
+	return v.accessToken, true
+}
+
+// IDTokenFromContext returns ID token verified by Middleware configured with WithIDTokens. Claims are available via
+// IDToken.Claims.
+func IDTokenFromContext(ctx context.Context) (*oidc.IDToken, bool) {
+	v, ok := ctx.Value(contextKey{}).(*verifiedToken)
+	if !ok || v.idToken == nil {
+		return nil, false
+	}
+	return v.idToken, true
+}
+
+// SubjectFromContext returns subject of the token verified by Middleware, regardless of its type.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(contextKey{}).(*verifiedToken)
+	if !ok {
+		return "", false
+	}
+	if v.idToken != nil {
+		return v.idToken.Subject, true
+	}
+	return v.accessToken.Subject, true
+}
+
+// challenge is a rejection of the request. See https://tools.ietf.org/html/rfc6750#section-3.
+type challenge struct {
+	status      int
+	code        string
+	description string
+	scope       string
+}
+
+func (c *challenge) write(w http.ResponseWriter, realm string) {
+	var params []string
+	if realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", realm))
+	}
+	if c.code != "" {
+		params = append(params, fmt.Sprintf("error=%q", c.code))
+	}
+	if c.description != "" {
+		// Descriptions must not include quotes, backslashes and line breaks.
+		params = append(params, fmt.Sprintf("error_description=\"%s\"", strings.NewReplacer(`"`, "'", `\`, "/", "\n", " ").Replace(c.description)))
+	}
+	if c.scope != "" {
+		params = append(params, fmt.Sprintf("scope=%q", c.scope))
+	}
+
+	value := "Bearer"
+	if len(params) > 0 {
+		value += " " + strings.Join(params, ", ")
+	}
+	w.Header().Set("WWW-Authenticate", value)
+	if c.code == "" {
+		http.Error(w, http.StatusText(c.status), c.status)
+		return
+	}
+
+	writeError(w, c.status, c.code, c.description)
+}
+
+// writeError writes error response with JSON body mirroring OAuth2 error responses, so clients can parse it the same
+// way as errors from the token endpoint.
+func writeError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Code        string `json:"error"`
+		Description string `json:"error_description,omitempty"`
+	}{Code: code, Description: description})
+}
+
+// authenticate verifies bearer token of the request.
+func (c *config) authenticate(r *http.Request, verifier oidc.Verifier) (*verifiedToken, *challenge) {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if auth == "" {
+		return nil, &challenge{status: http.StatusUnauthorized}
+	}
+	parts := strings.SplitN(auth, " ", 2)
+	if !strings.EqualFold(parts[0], "bearer") {
+		// Other authentication scheme, so just ask for bearer token.
+		return nil, &challenge{status: http.StatusUnauthorized}
+	}
+	if len(parts) < 2 || strings.TrimSpace(parts[1]) == "" {
+		return nil, &challenge{status: http.StatusBadRequest, code: "invalid_request", description: "malformed Authorization header"}
+	}
+	raw := strings.TrimSpace(parts[1])
+
+	v := &verifiedToken{}
+	if c.idTokens {
+		idToken, err := verifier.VerifyIDToken(r.Context(), raw)
+		if err != nil {
+			return nil, &challenge{status: http.StatusUnauthorized, code: "invalid_token", description: err.Error()}
+		}
+		var claims struct {
+			Scope string `json:"scope"`
+		}
+		if err := idToken.Claims(&claims); err != nil {
+			return nil, &challenge{status: http.StatusUnauthorized, code: "invalid_token", description: err.Error()}
+		}
+		v.idToken, v.scope = idToken, claims.Scope
+	} else {
+		accessToken, err := verifier.VerifyAccessToken(r.Context(), raw)
+		if err != nil {
+			return nil, &challenge{status: http.StatusUnauthorized, code: "invalid_token", description: err.Error()}
+		}
+		v.accessToken, v.scope = accessToken, accessToken.Scope
+	}
+
+	if ch := checkScopes(v, c.scopes); ch != nil {
+		return nil, ch
+	}
+	return v, nil
+}
+
+// checkScopes returns insufficient_scope challenge if v is not granted all of required scopes.
+func checkScopes(v *verifiedToken, required []string) *challenge {
+	granted := map[string]struct{}{}
+	for _, s := range strings.Fields(v.scope) {
+		granted[s] = struct{}{}
+	}
+	for _, s := range required {
+		if _, ok := granted[s]; !ok {
+			return &challenge{
+				status:      http.StatusForbidden,
+				code:        "insufficient_scope",
+				description: fmt.Sprintf("token is missing required scope %s", s),
+				scope:       strings.Join(required, " "),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package oidchttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Bplotka/oidc"
+	"github.com/Bplotka/oidc/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID = "clientID1"
+	testSubject  = "subject1"
+)
+
+func TestMiddleware(t *testing.T) {
+	provider := &oidc_testing.Provider{}
+	provider.Setup(t)
+
+	idToken, jwkSetJSON := provider.NewIDToken(testClientID, testSubject, "", map[string]interface{}{"scope": "openid read"})
+	verifier, err := oidc.NewStaticVerifier(provider.IssuerURL, jwkSetJSON, oidc.VerificationConfig{ClientID: testClientID})
+	require.NoError(t, err)
+
+	handler := Middleware(verifier, WithIDTokens(), WithRequiredScopes("read"), WithRealm("api"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, ok := SubjectFromContext(r.Context())
+		require.True(t, ok)
+		_, ok = IDTokenFromContext(r.Context())
+		require.True(t, ok)
+		_, ok = AccessTokenFromContext(r.Context())
+		require.False(t, ok)
+		w.Write([]byte(subject))
+	}))
+
+	for _, c := range []struct {
+		name          string
+		authorization string
+		handler       http.Handler
+
+		expectedStatus    int
+		expectedChallenge string
+		expectedBody      string
+	}{
+		{
+			name:              "no token",
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer realm="api"`,
+		},
+		{
+			name:              "malformed header",
+			authorization:     "Bearer ",
+			expectedStatus:    http.StatusBadRequest,
+			expectedChallenge: `Bearer realm="api", error="invalid_request", error_description="malformed Authorization header"`,
+		},
+		{
+			name:           "invalid token",
+			authorization:  "Bearer not-a-jwt",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "valid token",
+			authorization:  "Bearer " + idToken,
+			expectedStatus: http.StatusOK,
+			expectedBody:   testSubject,
+		},
+		{
+			name:          "missing scope",
+			authorization: "Bearer " + idToken,
+			handler: Middleware(verifier, WithIDTokens(), WithRequiredScopes("read", "write"))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				t.Error("handler should not be called")
+			})),
+			expectedStatus:    http.StatusForbidden,
+			expectedChallenge: `Bearer error="insufficient_scope", error_description="token is missing required scope write", scope="read write"`,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			h := c.handler
+			if h == nil {
+				h = handler
+			}
+			r := httptest.NewRequest("GET", "/api", nil)
+			if c.authorization != "" {
+				r.Header.Set("Authorization", c.authorization)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			assert.Equal(t, c.expectedStatus, rec.Code)
+			if c.expectedChallenge != "" {
+				assert.Equal(t, c.expectedChallenge, rec.Header().Get("WWW-Authenticate"))
+			}
+			if c.expectedStatus == http.StatusUnauthorized && c.authorization != "" {
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+			}
+			if c.expectedBody != "" {
+				assert.Equal(t, c.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package oidc
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures optional behavior of the Client.
+type Option func(*options)
+
+type options struct {
+	auditHook   AuditHook
+	requestHook RequestHook
+	tracer      Tracer
+	logger      Logger
+	httpClient  *http.Client
+	dpop        *DPoPKey
+	// mtlsHTTPClient if not nil, authenticates the client with TLS client certificate.
+	mtlsHTTPClient *http.Client
+	// clock if not nil, is used instead of time.Now.
+	clock func() time.Time
+	// keySetTTL if positive, is used instead of DefaultKeySetExpiration.
+	keySetTTL time.Duration
+	// keySetMinRefreshInterval if positive, is used instead of DefaultKeySetMinRefreshInterval.
+	keySetMinRefreshInterval time.Duration
+	// discoveryRefreshInterval if positive, enables periodic refresh of discovery document.
+	discoveryRefreshInterval time.Duration
+}
+
+// WithKeySetCacheTTL sets how long provider's keys are cached if JWKS response does not specify it with Cache-Control
+// max-age directive. Defaults to DefaultKeySetExpiration. Expired keys are refreshed in the background, so
+// verification does not wait for provider. The option has effect only when passed to NewProvider or NewClient, since
+// keys are shared by all clients of the provider.
+func WithKeySetCacheTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.keySetTTL = ttl
+	}
+}
+
+// WithKeySetMinRefreshInterval sets minimum time between key fetches triggered by tokens signed with key that is not
+// in the cached key set. Such tokens are rejected without fetching keys within the interval, so it bounds both the
+// time to pick up rotated keys and the load tokens with random key IDs can put on the provider. Defaults to
+// DefaultKeySetMinRefreshInterval. As WithKeySetCacheTTL, it has effect only when passed to NewProvider or NewClient.
+func WithKeySetMinRefreshInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.keySetMinRefreshInterval = interval
+	}
+}
+
+// WithDiscoveryRefreshInterval makes provider fetch discovery document again once it is older than given interval,
+// so long-lived clients notice changed endpoints (e.g rotated jwks_uri) without being recreated. Refresh runs in the
+// background when the document is used, so callers never wait for it. If refresh fails, the previous document is used
+// until the next interval. Discovery is fetched only once by default. As WithKeySetCacheTTL, it has effect only when
+// passed to NewProvider or NewClient.
+func WithDiscoveryRefreshInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.discoveryRefreshInterval = interval
+	}
+}
+
+// WithClock sets time source used instead of time.Now by the client: for verifiers created by it (unless
+// VerificationConfig.Now is set), for computing access token expiry and for checking token validity in token
+// sources. Use it to freeze time in tests or to use NTP-corrected clock in long-running daemons.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.clock = now
+	}
+}
@@ -0,0 +1,84 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"time"
+)
+
+// PushedAuthResponse is the response of the pushed authorization request endpoint. See
+// https://tools.ietf.org/html/rfc9126#section-2.2.
+type PushedAuthResponse struct {
+	// RequestURI references pushed request in the authorization request.
+	RequestURI string `json:"request_uri"`
+	// ExpiresIn is lifetime of the RequestURI in seconds.
+	ExpiresIn int `json:"expires_in"`
+
+	// Expiry is the time RequestURI expires, computed from ExpiresIn.
+	Expiry time.Time `json:"-"`
+}
+
+// PushAuthRequest pushes authorization request built from cfg and options (the same as for AuthCodeURLWithOptions)
+// directly to the provider (see https://tools.ietf.org/html/rfc9126), authenticating the client. Use returned
+// RequestURI with AuthCodeURLForRequestURI, or use PushedAuthCodeURL to do both at once.
+func (c *Client) PushAuthRequest(ctx context.Context, cfg Config, opts ...AuthCodeOption) (*PushedAuthResponse, error) {
+	discovery := c.Discovery()
+	if discovery.PARURL == "" {
+		return nil, errors.New("oidc: pushed authorization request endpoint is not supported by this provider")
+	}
+
+	v := authCodeValues(cfg, opts...)
+	req, err := c.newClientAuthRequest(discovery.PARURL, mtlsPARURL, cfg, v)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := c.doClientAuthRequest(ctx, RequestPAR, req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, wrapErrorf(&NetworkError{Err: err}, "oidc: cannot push authorization request: %v", err)
+	}
+	if code := r.StatusCode; code < 200 || code > 299 {
+		return nil, newOAuth2Error("oidc: cannot push authorization request", r, body)
+	}
+
+	var p PushedAuthResponse
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode pushed authorization response: %v", err)
+	}
+	if p.RequestURI == "" {
+		return nil, fmt.Errorf("oidc: pushed authorization response without request_uri: %s", body)
+	}
+	if p.ExpiresIn > 0 {
+		p.Expiry = time.Now().Add(time.Duration(p.ExpiresIn) * time.Second)
+	}
+	return &p, nil
+}
+
+// AuthCodeURLForRequestURI returns a URL to OIDC provider's consent page for request pushed with PushAuthRequest.
+func (c *Client) AuthCodeURLForRequestURI(cfg Config, requestURI string) string {
+	return c.authURL(url.Values{
+		"client_id":   {cfg.ClientID},
+		"request_uri": {requestURI},
+	})
+}
+
+// PushedAuthCodeURL is like AuthCodeURLWithOptions, but it pushes the authorization request to the provider first,
+// so the returned URL carries only client ID and reference to the pushed request. It is required by providers with
+// DiscoveryJSON.RequirePAR, e.g FAPI compliant ones.
+func (c *Client) PushedAuthCodeURL(ctx context.Context, cfg Config, opts ...AuthCodeOption) (string, error) {
+	p, err := c.PushAuthRequest(ctx, cfg, opts...)
+	if err != nil {
+		return "", err
+	}
+	return c.AuthCodeURLForRequestURI(cfg, p.RequestURI), nil
+}
@@ -0,0 +1,60 @@
+package oidc
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestPushedAuthCodeURL() {
+	var form url.Values
+	var user string
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal(testDiscovery.PARURL, r.URL.String())
+		s.NoError(r.ParseForm())
+		form = r.PostForm
+		user, _, _ = r.BasicAuth()
+		return rt.JSONResponseFunc(http.StatusCreated, []byte(`{
+			"request_uri": "urn:ietf:params:oauth:request_uri:req1",
+			"expires_in": 60
+		}`))(r)
+	})
+
+	cfg := Config{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		RedirectURL:  "https://app.org/callback",
+		Scopes:       []string{ScopeOpenID},
+	}
+	authURL, err := s.client.PushedAuthCodeURL(s.testCtx, cfg, WithState("state1"))
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Equal("client1", user)
+	s.Equal(ResponseTypeCode, form.Get("response_type"))
+	s.Equal("client1", form.Get("client_id"))
+	s.Equal("https://app.org/callback", form.Get("redirect_uri"))
+	s.Equal(ScopeOpenID, form.Get("scope"))
+	s.Equal("state1", form.Get("state"))
+
+	u, err := url.Parse(authURL)
+	s.Require().NoError(err)
+	s.Equal(testDiscovery.AuthURL, u.Scheme+"://"+u.Host+u.Path)
+	s.Equal(url.Values{
+		"client_id":   {"client1"},
+		"request_uri": {"urn:ietf:params:oauth:request_uri:req1"},
+	}, u.Query())
+}
+
+func (s *ClientTestSuite) TestPushAuthRequest_Error() {
+	s.s.Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "invalid_request"}`)))
+
+	_, err := s.client.PushAuthRequest(s.testCtx, Config{ClientID: "client1"})
+	s.Require().Error(err)
+	s.Equal(0, s.s.Len())
+
+	oerr, ok := err.(*OAuth2Error)
+	s.Require().True(ok)
+	s.Equal("invalid_request", oerr.Code)
+}
@@ -0,0 +1,311 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discoveryRefreshTimeout limits background discovery refresh, which is not bound to any caller's context.
+const discoveryRefreshTimeout = 1 * time.Minute
+
+// Provider holds discovery metadata and key set of a single OIDC issuer. Many Clients (e.g for different client IDs
+// or with different options) can be created cheaply from single Provider. They share discovery result, keys cache,
+// verification cache and retry budget instead of fetching and caching everything again.
+// Provider is safe for concurrent use.
+type Provider struct {
+	issuer string
+	// wellKnown is URL of discovery endpoint. It is empty if provider was constructed from explicit metadata.
+	wellKnown string
+
+	// mu guards all discovery fields below.
+	mu  sync.Mutex
+	doc *discoveryDoc
+	// generation is incremented every time refresh changes doc, so clients know their copy is stale.
+	generation uint64
+	fetchedAt  time.Time
+	// refreshing is true while background discovery refresh is in progress.
+	refreshing bool
+
+	jwks   *remoteKeySet
+	keySet keySet
+	// verifications caches results of successful ID token verifications for all clients of this provider.
+	verifications *lruCache
+
+	opts options
+}
+
+// discoveryDoc is discovery document of the provider together with values derived from it.
+type discoveryDoc struct {
+	// Raw claims returned by the server on discovery endpoint.
+	rawDiscoveryClaims []byte
+	discovery          DiscoveryJSON
+	metadata           ProviderMetadata
+	// tokenAuthMethods are client authentication methods supported by the token endpoint.
+	tokenAuthMethods []string
+	// idTokenSigningAlgs are algorithms provider signs ID tokens with.
+	idTokenSigningAlgs []string
+}
+
+func newDiscoveryDoc(metadata ProviderMetadata, rawDiscoveryClaims []byte) *discoveryDoc {
+	return &discoveryDoc{
+		rawDiscoveryClaims: rawDiscoveryClaims,
+		discovery:          metadata.DiscoveryJSON,
+		metadata:           metadata,
+		tokenAuthMethods:   metadata.TokenEndpointAuthMethodsSupported,
+		idTokenSigningAlgs: metadata.IDTokenSigningAlgsSupported,
+	}
+}
+
+// NewProvider uses the OpenID Connect discovery mechanism to construct a Provider. Given options are the defaults for
+// all clients created from it.
+func NewProvider(ctx context.Context, issuer string, opts ...Option) (*Provider, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	wellKnown := strings.TrimSuffix(issuer, "/") + DiscoveryEndpoint
+	doc, err := fetchDiscovery(ctx, o, issuer, wellKnown)
+	if err != nil {
+		return nil, err
+	}
+	p := newProvider(doc, o)
+	p.wellKnown = wellKnown
+	return p, nil
+}
+
+func fetchDiscovery(ctx context.Context, o options, issuer string, wellKnown string) (doc *discoveryDoc, err error) {
+	ctx, span := startSpan(ctx, o.tracer, "oidc.Discovery", SpanAttribute{Key: SpanAttrIssuer, Value: issuer})
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequest("GET", wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(ctx, o.httpClient, o.requestHook, RequestDiscovery, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp, body)
+	}
+	var metadata ProviderMetadata
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode provider discovery object: %v", err)
+	}
+	if metadata.Issuer != issuer {
+		return nil, fmt.Errorf("oidc: issuer did not match the issuer returned by provider, expected %q got %q", issuer, metadata.Issuer)
+	}
+	o.log().Debug("oidc: Fetched discovery document.", "issuer", issuer)
+	return newDiscoveryDoc(metadata, body), nil
+}
+
+// NewProviderFromMetadata constructs Provider from explicitly given metadata instead of the discovery endpoint, e.g
+// for providers without a well-known document or when it is not reachable. Issuer, TokenURL and JWKSURL are required.
+// Endpoints not given are treated as not supported by the provider. Keys are still fetched from JWKSURL; use
+// NewStaticVerifier if they also need to be static.
+//
+//	provider, err := oidc.NewProviderFromMetadata(oidc.ProviderMetadata{
+//	    DiscoveryJSON: oidc.DiscoveryJSON{
+//	        Issuer:      "https://issuer-oidc.org",
+//	        AuthURL:     "https://issuer-oidc.org/auth",
+//	        TokenURL:    "https://issuer-oidc.org/token",
+//	        JWKSURL:     "https://issuer-oidc.org/keys",
+//	        UserInfoURL: "https://issuer-oidc.org/userinfo",
+//	    },
+//	})
+func NewProviderFromMetadata(metadata ProviderMetadata, opts ...Option) (*Provider, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch {
+	case metadata.Issuer == "":
+		return nil, errors.New("oidc: issuer is required in provider metadata")
+	case metadata.TokenURL == "":
+		return nil, errors.New("oidc: token endpoint is required in provider metadata")
+	case metadata.JWKSURL == "":
+		return nil, errors.New("oidc: JWKS URL is required in provider metadata")
+	}
+
+	// Raw claims are as if returned by discovery endpoint, so Claims works the same way.
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to encode provider metadata: %v", err)
+	}
+	return newProvider(newDiscoveryDoc(metadata, raw), o), nil
+}
+
+// NewClientFromMetadata constructs Client from explicitly given metadata instead of the discovery endpoint. See
+// NewProviderFromMetadata.
+func NewClientFromMetadata(metadata ProviderMetadata, opts ...Option) (*Client, error) {
+	p, err := NewProviderFromMetadata(metadata, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return p.Client(), nil
+}
+
+func newProvider(doc *discoveryDoc, o options) *Provider {
+	keySetTTL := DefaultKeySetExpiration
+	if o.keySetTTL > 0 {
+		keySetTTL = o.keySetTTL
+	}
+	keySetMinRefreshInterval := DefaultKeySetMinRefreshInterval
+	if o.keySetMinRefreshInterval > 0 {
+		keySetMinRefreshInterval = o.keySetMinRefreshInterval
+	}
+	jwks := newRemoteKeySet(doc.discovery.JWKSURL, o.httpClient)
+	jwks.requestHook = o.requestHook
+	jwks.logger = o.log()
+	p := &Provider{
+		issuer:        doc.discovery.Issuer,
+		doc:           doc,
+		jwks:          jwks,
+		keySet:        newCachedKeySet(jwks, keySetTTL, keySetMinRefreshInterval, o.clock),
+		verifications: newLRUCache(DefaultVerificationCacheLimits, time.Now),
+		opts:          o,
+	}
+	p.fetchedAt = p.now()
+	return p
+}
+
+// Client returns new Client for the provider. Given options are applied on top of the provider's options. Keys are
+// always fetched using HTTP client given to NewProvider, since they are shared.
+func (p *Provider) Client(opts ...Option) *Client {
+	o := p.opts
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	doc, generation := p.document()
+	return &Client{
+		provider:      p,
+		issuer:        p.issuer,
+		discoveryDoc:  *doc,
+		generation:    generation,
+		keySet:        p.keySet,
+		verifications: p.verifications,
+		retryBudget:   RetryBudgetFor(p.issuer),
+		opts:          o,
+	}
+}
+
+// document returns current discovery document and its generation. If discovery refresh is enabled with
+// WithDiscoveryRefreshInterval and the document is older than the interval, it is refreshed in the background, so
+// callers never wait for discovery request.
+func (p *Provider) document() (*discoveryDoc, uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.wellKnown != "" && p.opts.discoveryRefreshInterval > 0 && !p.refreshing &&
+		!p.now().Before(p.fetchedAt.Add(p.opts.discoveryRefreshInterval)) {
+		p.refreshing = true
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), discoveryRefreshTimeout)
+			defer cancel()
+
+			// Failure is only retried after the interval, stale document is still better than none.
+			if err := p.Refresh(ctx); err != nil {
+				p.opts.log().Warn("oidc: Background discovery refresh failed. Using stale document.", "issuer", p.issuer, "err", err)
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.refreshing = false
+		}()
+	}
+	return p.doc, p.generation
+}
+
+// Refresh fetches discovery document again. Changes (e.g rotated jwks_uri or new endpoints) are picked up by the
+// provider and all clients created from it. Use it with WithDiscoveryRefreshInterval for periodic refresh, or call it
+// directly e.g on configuration reload. Refresh of provider constructed with NewProviderFromMetadata is no-op.
+func (p *Provider) Refresh(ctx context.Context) error {
+	if p.wellKnown == "" {
+		return nil
+	}
+
+	doc, err := fetchDiscovery(ctx, p.opts, p.issuer, p.wellKnown)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fetchedAt = p.now()
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(doc.rawDiscoveryClaims, p.doc.rawDiscoveryClaims) {
+		return nil
+	}
+	p.opts.log().Info("oidc: Discovery document changed.", "issuer", p.issuer, "jwks_url", doc.discovery.JWKSURL)
+	if doc.discovery.JWKSURL != p.doc.discovery.JWKSURL {
+		p.jwks.setURL(doc.discovery.JWKSURL)
+		if ks, ok := p.keySet.(*cachedKeySet); ok {
+			ks.expire()
+		}
+	}
+	p.doc = doc
+	p.generation++
+	return nil
+}
+
+// now returns current time of the provider's clock.
+func (p *Provider) now() time.Time {
+	if p.opts.clock != nil {
+		return p.opts.clock()
+	}
+	return time.Now()
+}
+
+// Issuer returns issuer URL of the provider.
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+// Discovery returns standard discovery fields held by OIDC provider.
+func (p *Provider) Discovery() DiscoveryJSON {
+	doc, _ := p.document()
+	return doc.discovery
+}
+
+// Metadata returns complete provider metadata. See Client.Metadata.
+func (p *Provider) Metadata() ProviderMetadata {
+	doc, _ := p.document()
+	return doc.metadata
+}
+
+// KeySetStats returns counters of provider's key set fetches and rotations, shared by all clients of the provider.
+func (p *Provider) KeySetStats() KeySetStats {
+	if ks, ok := p.keySet.(*cachedKeySet); ok {
+		return ks.Stats()
+	}
+	return KeySetStats{}
+}
+
+// Claims unmarshals raw fields returned by the server during discovery. See Client.Claims.
+func (p *Provider) Claims(v interface{}) error {
+	doc, _ := p.document()
+	return doc.claims(v)
+}
+
+func (d *discoveryDoc) claims(v interface{}) error {
+	if d.rawDiscoveryClaims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return json.Unmarshal(d.rawDiscoveryClaims, v)
+}
@@ -0,0 +1,171 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Bplotka/go-httpt"
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestProvider_SharedKeys() {
+	jsonDiscovery, err := json.Marshal(testDiscovery)
+	s.NoError(err)
+	srv := httpt.NewServer(s.T())
+	srv.On("GET", exampleIssuer+DiscoveryEndpoint).
+		Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+
+	provider, err := NewProvider(context.TODO(), exampleIssuer, WithHTTPClient(srv.HTTPClient()))
+	s.Require().NoError(err)
+	s.Equal(exampleIssuer, provider.Issuer())
+	s.Equal(testDiscovery, provider.Discovery())
+
+	client1 := provider.Client()
+	client2 := provider.Client()
+	s.Equal(provider, client1.Provider())
+	s.Equal(testDiscovery, client2.Discovery())
+
+	idToken, jwkSetJSON := s.validIDToken()
+
+	// Only single keys fetch is expected, since clients share provider's keys cache.
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = client1.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.TODO(), idToken)
+	s.NoError(err)
+	_, err = client2.Verifier(VerificationConfig{ClientID: "client1", ClaimNonce: "nonce1"}).Verify(context.TODO(), idToken)
+	s.NoError(err)
+
+	s.Equal(0, srv.Len())
+}
+
+func (s *ClientTestSuite) TestProvider_KeySetMinRefreshInterval() {
+	jsonDiscovery, err := json.Marshal(testDiscovery)
+	s.NoError(err)
+	srv := httpt.NewServer(s.T())
+	srv.On("GET", exampleIssuer+DiscoveryEndpoint).
+		Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+
+	provider, err := NewProvider(context.TODO(), exampleIssuer, WithHTTPClient(srv.HTTPClient()),
+		WithKeySetCacheTTL(1*time.Hour), WithKeySetMinRefreshInterval(1*time.Nanosecond))
+	s.Require().NoError(err)
+	v := provider.Client().Verifier(VerificationConfig{ClientID: "client1", SupportedSigningAlgs: []string{"ES256"}})
+	claims := map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	}
+
+	// Keys are cached, but rotated key is fetched right away.
+	for i := 0; i < 2; i++ {
+		idToken, jwkSetJSON := s.keyIDSignedJWT(fmt.Sprintf("key%d", i), claims)
+		srv.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+		_, err = v.Verify(context.TODO(), idToken)
+		s.Require().NoError(err)
+		s.Equal(0, srv.Len())
+	}
+	s.Equal(KeySetStats{Fetches: 2, Rotations: 1, UnknownKeyRefreshes: 1}, provider.KeySetStats())
+}
+
+func (s *ClientTestSuite) TestProvider_Metadata() {
+	jsonDiscovery, err := json.Marshal(ProviderMetadata{
+		DiscoveryJSON:                 testDiscovery,
+		ScopesSupported:               []string{ScopeOpenID, ScopeEmail},
+		ClaimsSupported:               []string{"sub", "email"},
+		CodeChallengeMethodsSupported: []string{"plain", PKCEMethodS256},
+		BackchannelLogoutSupported:    true,
+	})
+	s.NoError(err)
+	srv := httpt.NewServer(s.T())
+	srv.On("GET", exampleIssuer+DiscoveryEndpoint).
+		Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+
+	provider, err := NewProvider(context.TODO(), exampleIssuer, WithHTTPClient(srv.HTTPClient()))
+	s.Require().NoError(err)
+
+	m := provider.Client().Metadata()
+	s.Equal(testDiscovery, m.DiscoveryJSON)
+	s.Equal(testDiscovery, provider.Client().Discovery())
+	s.True(m.SupportsScope(ScopeEmail))
+	s.False(m.SupportsScope(ScopeOfflineAccess))
+	s.True(m.SupportsClaim("email"))
+	s.True(m.SupportsPKCE())
+	s.True(m.BackchannelLogoutSupported)
+	// Grant types are not advertised, so RFC 8414 defaults apply.
+	s.True(m.SupportsGrantType("authorization_code"))
+	s.False(m.SupportsGrantType("refresh_token"))
+}
+
+func (s *ClientTestSuite) TestNewClientFromMetadata() {
+	_, err := NewClientFromMetadata(ProviderMetadata{DiscoveryJSON: DiscoveryJSON{Issuer: exampleIssuer, TokenURL: exampleIssuer + "/token1"}})
+	s.EqualError(err, "oidc: JWKS URL is required in provider metadata")
+
+	srv := httpt.NewServer(s.T())
+	client, err := NewClientFromMetadata(ProviderMetadata{DiscoveryJSON: testDiscovery}, WithHTTPClient(srv.HTTPClient()))
+	s.Require().NoError(err)
+	s.Equal(testDiscovery, client.Discovery())
+
+	var claims struct {
+		TokenURL string `json:"token_endpoint"`
+	}
+	s.Require().NoError(client.Claims(&claims))
+	s.Equal(testDiscovery.TokenURL, claims.TokenURL)
+
+	// No discovery request is made, only keys are fetched on verification.
+	idToken, jwkSetJSON := s.validIDToken()
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = client.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.TODO(), idToken)
+	s.NoError(err)
+	s.Equal(0, srv.Len())
+}
+
+func (s *ClientTestSuite) TestProvider_DiscoveryRefresh() {
+	jsonDiscovery, err := json.Marshal(testDiscovery)
+	s.NoError(err)
+	rotated := testDiscovery
+	rotated.JWKSURL = exampleIssuer + "/jwks2"
+	rotated.UserInfoURL = exampleIssuer + "/info2"
+	jsonRotated, err := json.Marshal(rotated)
+	s.NoError(err)
+
+	srv := httpt.NewServer(s.T())
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+
+	currTime := time.Now()
+	provider, err := NewProvider(context.TODO(), exampleIssuer, WithHTTPClient(srv.HTTPClient()),
+		WithClock(func() time.Time { return currTime }), WithDiscoveryRefreshInterval(1*time.Hour))
+	s.Require().NoError(err)
+	client := provider.Client()
+
+	// Fresh document is not refetched.
+	s.Equal(testDiscovery, client.Discovery())
+	s.Equal(0, srv.Len())
+
+	// Failed refresh keeps the previous document.
+	srv.Push(rt.JSONResponseFunc(http.StatusInternalServerError, []byte(`{}`)))
+	s.Error(provider.Refresh(context.TODO()))
+	s.Equal(testDiscovery, client.Discovery())
+
+	// Stale document is refreshed in the background and picked up by existing clients.
+	currTime = currTime.Add(2 * time.Hour)
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jsonRotated))
+	s.Equal(testDiscovery, client.Discovery())
+	for i := 0; i < 100 && client.Discovery() != rotated; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Equal(rotated, client.Discovery())
+	s.Equal(rotated, provider.Client().Discovery())
+	s.Equal(0, srv.Len())
+
+	// Keys are fetched from rotated JWKS URL.
+	idToken, jwkSetJSON := s.validIDToken()
+	srv.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal(rotated.JWKSURL, r.URL.String())
+		return rt.JSONResponseFunc(http.StatusOK, jwkSetJSON)(r)
+	})
+	_, err = client.Verifier(VerificationConfig{ClientID: "client1", Now: time.Now}).Verify(context.TODO(), idToken)
+	s.NoError(err)
+	s.Equal(0, srv.Len())
+}
@@ -0,0 +1,204 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// ClientMetadata describes client to register. See https://tools.ietf.org/html/rfc7591#section-2 and
+// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata.
+type ClientMetadata struct {
+	RedirectURIs            []string `json:"redirect_uris,omitempty"`
+	PostLogoutRedirectURIs  []string `json:"post_logout_redirect_uris,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	ResponseTypes           []string `json:"response_types,omitempty"`
+	ApplicationType         string   `json:"application_type,omitempty"`
+	ClientName              string   `json:"client_name,omitempty"`
+	ClientURI               string   `json:"client_uri,omitempty"`
+	LogoURI                 string   `json:"logo_uri,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+	Contacts                []string `json:"contacts,omitempty"`
+	JWKSURI                 string   `json:"jwks_uri,omitempty"`
+}
+
+// ClientRegistration is the registered client returned by the provider. See
+// https://tools.ietf.org/html/rfc7591#section-3.2.1.
+type ClientRegistration struct {
+	ClientMetadata
+
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	// ClientIDIssuedAt is time client ID was issued at, if returned.
+	ClientIDIssuedAt NumericDate `json:"client_id_issued_at,omitempty"`
+	// ClientSecretExpiresAt is time client secret expires. Zero means it never expires.
+	ClientSecretExpiresAt NumericDate `json:"client_secret_expires_at,omitempty"`
+
+	// RegistrationAccessToken and RegistrationClientURI are used to manage the registration (see
+	// https://tools.ietf.org/html/rfc7592). Store them along with client credentials.
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+
+	// Raw response.
+	claims []byte
+}
+
+// Claims unmarshals the raw JSON registration response into a provided struct, e.g for provider specific metadata.
+func (r *ClientRegistration) Claims(v interface{}) error {
+	if r.claims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return json.Unmarshal(r.claims, v)
+}
+
+// Config returns client configuration of the registered client. First registered redirect URI is used.
+func (r *ClientRegistration) Config() Config {
+	cfg := Config{
+		ClientID:     r.ClientID,
+		ClientSecret: r.ClientSecret,
+		Scopes:       strings.Fields(r.Scope),
+	}
+	if len(r.RedirectURIs) > 0 {
+		cfg.RedirectURL = r.RedirectURIs[0]
+	}
+	return cfg
+}
+
+// RegistrationOption configures client registration request.
+type RegistrationOption func(req *http.Request)
+
+// WithInitialAccessToken authorizes registration with initial access token issued by the provider's administrator,
+// as required e.g by Keycloak unless anonymous registration is allowed.
+func WithInitialAccessToken(token string) RegistrationOption {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// Register registers new client on provider's registration endpoint (see https://tools.ietf.org/html/rfc7591).
+func (c *Client) Register(ctx context.Context, metadata ClientMetadata, opts ...RegistrationOption) (*ClientRegistration, error) {
+	discovery := c.Discovery()
+	if discovery.RegistrationURL == "" {
+		return nil, errors.New("oidc: registration endpoint is not supported by this provider")
+	}
+
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", discovery.RegistrationURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, opt := range opts {
+		opt(req)
+	}
+	return c.doRegistrationRequest(ctx, req, "oidc: cannot register client")
+}
+
+// ReadRegistration returns current registration of the client. See https://tools.ietf.org/html/rfc7592#section-2.1.
+func (c *Client) ReadRegistration(ctx context.Context, reg *ClientRegistration) (*ClientRegistration, error) {
+	req, err := newRegistrationManagementRequest("GET", reg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.doRegistrationRequest(ctx, req, "oidc: cannot read client registration")
+}
+
+// UpdateRegistration replaces metadata of the registered client. Provider might return new client secret or
+// registration access token, so use the returned registration from now on.
+// See https://tools.ietf.org/html/rfc7592#section-2.2.
+func (c *Client) UpdateRegistration(ctx context.Context, reg *ClientRegistration, metadata ClientMetadata) (*ClientRegistration, error) {
+	body, err := json.Marshal(struct {
+		ClientMetadata
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret,omitempty"`
+	}{
+		ClientMetadata: metadata,
+		ClientID:       reg.ClientID,
+		ClientSecret:   reg.ClientSecret,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := newRegistrationManagementRequest("PUT", reg, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.doRegistrationRequest(ctx, req, "oidc: cannot update client registration")
+}
+
+// DeleteRegistration deregisters the client. See https://tools.ietf.org/html/rfc7592#section-2.3.
+func (c *Client) DeleteRegistration(ctx context.Context, reg *ClientRegistration) error {
+	req, err := newRegistrationManagementRequest("DELETE", reg, nil)
+	if err != nil {
+		return err
+	}
+
+	r, err := doRequest(ctx, c.opts.httpClient, c.opts.requestHook, RequestRegistration, req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return wrapErrorf(&NetworkError{Err: err}, "oidc: cannot delete client registration: %v", err)
+	}
+	if code := r.StatusCode; code < 200 || code > 299 {
+		return newOAuth2Error("oidc: cannot delete client registration", r, body)
+	}
+	return nil
+}
+
+func newRegistrationManagementRequest(method string, reg *ClientRegistration, body []byte) (*http.Request, error) {
+	if reg.RegistrationClientURI == "" || reg.RegistrationAccessToken == "" {
+		return nil, errors.New("oidc: client registration has no registration client URI or access token")
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, reg.RegistrationClientURI, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+reg.RegistrationAccessToken)
+	return req, nil
+}
+
+func (c *Client) doRegistrationRequest(ctx context.Context, req *http.Request, errPrefix string) (*ClientRegistration, error) {
+	req.Header.Set("Accept", "application/json")
+	r, err := doRequest(ctx, c.opts.httpClient, c.opts.requestHook, RequestRegistration, req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, wrapErrorf(&NetworkError{Err: err}, "%s: %v", errPrefix, err)
+	}
+	if code := r.StatusCode; code < 200 || code > 299 {
+		return nil, newOAuth2Error(errPrefix, r, body)
+	}
+
+	reg := &ClientRegistration{claims: body}
+	if err := json.Unmarshal(body, reg); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode client registration: %v", err)
+	}
+	if reg.ClientID == "" {
+		return nil, fmt.Errorf("oidc: client registration response without client_id: %s", body)
+	}
+	return reg, nil
+}
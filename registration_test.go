@@ -0,0 +1,84 @@
+package oidc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+const testRegistrationResponse = `{
+	"client_id": "client1",
+	"client_secret": "secret1",
+	"client_secret_expires_at": 0,
+	"redirect_uris": ["http://127.0.0.1:8883/callback"],
+	"scope": "openid email",
+	"registration_access_token": "regtoken1",
+	"registration_client_uri": "https://issuer.org/register1/client1"
+}`
+
+func (s *ClientTestSuite) TestRegister() {
+	var metadata map[string]interface{}
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal("POST", r.Method)
+		s.Equal(testDiscovery.RegistrationURL, r.URL.String())
+		s.Equal("Bearer initial1", r.Header.Get("Authorization"))
+		body, err := ioutil.ReadAll(r.Body)
+		s.NoError(err)
+		s.NoError(json.Unmarshal(body, &metadata))
+		return rt.JSONResponseFunc(http.StatusCreated, []byte(testRegistrationResponse))(r)
+	})
+
+	reg, err := s.client.Register(s.testCtx, ClientMetadata{
+		RedirectURIs: []string{"http://127.0.0.1:8883/callback"},
+		ClientName:   "cli1",
+		Scope:        "openid email",
+	}, WithInitialAccessToken("initial1"))
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Equal("cli1", metadata["client_name"])
+	s.Nil(metadata["logo_uri"])
+
+	s.Equal("client1", reg.ClientID)
+	s.Equal("regtoken1", reg.RegistrationAccessToken)
+	s.Equal(Config{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		RedirectURL:  "http://127.0.0.1:8883/callback",
+		Scopes:       []string{"openid", "email"},
+	}, reg.Config())
+}
+
+func (s *ClientTestSuite) TestRegistrationManagement() {
+	reg := &ClientRegistration{
+		ClientID:                "client1",
+		ClientSecret:            "secret1",
+		RegistrationAccessToken: "regtoken1",
+		RegistrationClientURI:   "https://issuer.org/register1/client1",
+	}
+
+	var body map[string]interface{}
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal("PUT", r.Method)
+		s.Equal(reg.RegistrationClientURI, r.URL.String())
+		s.Equal("Bearer regtoken1", r.Header.Get("Authorization"))
+		b, err := ioutil.ReadAll(r.Body)
+		s.NoError(err)
+		s.NoError(json.Unmarshal(b, &body))
+		return rt.JSONResponseFunc(http.StatusOK, []byte(testRegistrationResponse))(r)
+	})
+	_, err := s.client.UpdateRegistration(s.testCtx, reg, ClientMetadata{ClientName: "cli2"})
+	s.Require().NoError(err)
+	s.Equal("client1", body["client_id"])
+	s.Equal("cli2", body["client_name"])
+
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal("DELETE", r.Method)
+		s.Equal("Bearer regtoken1", r.Header.Get("Authorization"))
+		return rt.StringResponseFunc(http.StatusNoContent, "")(r)
+	})
+	s.Require().NoError(s.client.DeleteRegistration(s.testCtx, reg))
+	s.Equal(0, s.s.Len())
+}
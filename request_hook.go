@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestEndpoint is a provider endpoint reported to RequestHook.
+type RequestEndpoint string
+
+const (
+	// RequestDiscovery is the discovery document request.
+	RequestDiscovery RequestEndpoint = "discovery"
+	// RequestJWKS is the provider's key set request.
+	RequestJWKS RequestEndpoint = "jwks"
+	// RequestToken is any token endpoint request, e.g code exchange, refresh or token exchange.
+	RequestToken RequestEndpoint = "token"
+	// RequestUserInfo is the user info request.
+	RequestUserInfo RequestEndpoint = "userinfo"
+	// RequestRevocation is the token revocation request.
+	RequestRevocation RequestEndpoint = "revocation"
+	// RequestIntrospection is the token introspection request.
+	RequestIntrospection RequestEndpoint = "introspection"
+	// RequestPAR is the pushed authorization request.
+	RequestPAR RequestEndpoint = "par"
+	// RequestBackchannelAuth is the backchannel authentication request.
+	RequestBackchannelAuth RequestEndpoint = "backchannel-auth"
+	// RequestDeviceAuth is the device authorization request.
+	RequestDeviceAuth RequestEndpoint = "device-auth"
+	// RequestRegistration is any dynamic client registration request.
+	RequestRegistration RequestEndpoint = "registration"
+)
+
+// RequestEvent describes single HTTP round trip to the provider. It never contains request or response bodies,
+// headers or URL query, since they can carry tokens, codes or client secrets.
+type RequestEvent struct {
+	Time     time.Time
+	Endpoint RequestEndpoint
+	Method   string
+	// URL is the request URL without query and user info.
+	URL string
+	// StatusCode is the response status code. Zero if request failed before response was received.
+	StatusCode int
+	Duration   time.Duration
+	// Error describes a reason of network failure. Empty if response was received, even with error status code.
+	Error string
+}
+
+// Fields returns event as a list of key-value pairs for structured loggers.
+func (e RequestEvent) Fields() []interface{} {
+	fields := []interface{}{
+		"time", e.Time,
+		"endpoint", string(e.Endpoint),
+		"method", e.Method,
+		"url", e.URL,
+		"status", e.StatusCode,
+		"duration", e.Duration,
+	}
+	if e.Error != "" {
+		fields = append(fields, "error", e.Error)
+	}
+	return fields
+}
+
+// RequestHook is notified after every HTTP round trip to the provider made by the Client, its provider and
+// verifiers, e.g for logging, tracing or debugging provider quirks. Request must be safe for concurrent use and
+// should not block.
+type RequestHook interface {
+	Request(event RequestEvent)
+}
+
+// RequestHookFunc is a function adapter for RequestHook.
+type RequestHookFunc func(event RequestEvent)
+
+// Request calls f(event).
+func (f RequestHookFunc) Request(event RequestEvent) {
+	f(event)
+}
+
+// WithRequestHook sets a hook that is notified about every HTTP request to the provider (discovery, keys, token, user
+// info etc). Discovery and keys requests are reported only if the option is passed to NewProvider or NewClient, since
+// they are shared by all clients of the provider.
+func WithRequestHook(hook RequestHook) Option {
+	return func(o *options) {
+		o.requestHook = hook
+	}
+}
+
+// reportRequest reports finished round trip of req to the hook if any.
+func reportRequest(hook RequestHook, endpoint RequestEndpoint, req *http.Request, start time.Time, resp *http.Response, err error) {
+	if hook == nil {
+		return
+	}
+
+	u := *req.URL
+	u.RawQuery, u.ForceQuery, u.User, u.Fragment = "", false, nil, ""
+	e := RequestEvent{
+		Time:     start,
+		Endpoint: endpoint,
+		Method:   req.Method,
+		URL:      u.String(),
+		Duration: time.Since(start),
+	}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	hook.Request(e)
+}
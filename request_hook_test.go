@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/Bplotka/go-httpt"
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestRequestHook() {
+	jsonDiscovery, err := json.Marshal(testDiscovery)
+	s.NoError(err)
+	idToken, jwkSetJSON := s.validIDToken()
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access1",
+		RefreshToken: "refresh2",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+	})
+	s.NoError(err)
+
+	var (
+		mu     sync.Mutex
+		events []RequestEvent
+	)
+	hook := RequestHookFunc(func(e RequestEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+
+	srv := httpt.NewServer(s.T())
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+	client, err := NewClient(context.TODO(), exampleIssuer, WithHTTPClient(srv.HTTPClient()), WithRequestHook(hook))
+	s.Require().NoError(err)
+
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+	_, err = NewTokenRefresher(context.TODO(), client, Config{ClientID: "client1"}, "refresh1").OIDCToken()
+	s.NoError(err)
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = client.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.TODO(), idToken)
+	s.NoError(err)
+
+	srv.Push(rt.JSONResponseFunc(http.StatusUnauthorized, []byte(`{"error":"invalid_token"}`)))
+	_, err = client.UserInfo(context.TODO(), StaticTokenSource(&Token{AccessToken: "access1", TokenType: "Bearer"}))
+	s.Error(err)
+
+	srv.Push(func(*http.Request) (*http.Response, error) { return nil, errors.New("connection refused") })
+	err = client.Revoke(context.TODO(), Config{ClientID: "client1"}, "refresh2")
+	s.Error(err)
+	s.Equal(0, srv.Len())
+
+	mu.Lock()
+	defer mu.Unlock()
+	s.Require().Len(events, 5)
+	for i, expected := range []struct {
+		endpoint RequestEndpoint
+		method   string
+		url      string
+		status   int
+	}{
+		{endpoint: RequestDiscovery, method: "GET", url: exampleIssuer + DiscoveryEndpoint, status: http.StatusOK},
+		{endpoint: RequestToken, method: "POST", url: testDiscovery.TokenURL, status: http.StatusOK},
+		{endpoint: RequestJWKS, method: "GET", url: testDiscovery.JWKSURL, status: http.StatusOK},
+		{endpoint: RequestUserInfo, method: "GET", url: testDiscovery.UserInfoURL, status: http.StatusUnauthorized},
+		{endpoint: RequestRevocation, method: "POST", url: testDiscovery.RevocationURL},
+	} {
+		s.Equal(expected.endpoint, events[i].Endpoint, "event %d", i)
+		s.Equal(expected.method, events[i].Method, "event %d", i)
+		s.Equal(expected.url, events[i].URL, "event %d", i)
+		s.Equal(expected.status, events[i].StatusCode, "event %d", i)
+	}
+	s.Empty(events[3].Error)
+	s.NotEmpty(events[4].Error)
+}
@@ -0,0 +1,103 @@
+package oidc
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures retries of transient refresh failures, e.g 5xx or 429 responses and network errors. Every
+// retry must be allowed by retry budget of the client (see Client.RetryBudget), so retries never overload failing
+// provider.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of refresh requests, including the first one. Values lower than 2 disable
+	// retries.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the wait before the first retry. It is doubled on each next retry up to
+	// MaxBackoff. Actual wait is chosen randomly between zero and the bound (full jitter).
+	InitialBackoff time.Duration
+	// MaxBackoff is the upper bound of the wait before any retry. Responses with Retry-After header asking to wait
+	// longer are not retried. Zero means no upper bound.
+	MaxBackoff time.Duration
+}
+
+// backoff returns how long to wait before given retry (1 for first one) for err.
+func (p *RetryPolicy) backoff(retry int, err error) (time.Duration, bool) {
+	if d, ok := retryAfter(err); ok {
+		if p.MaxBackoff > 0 && d > p.MaxBackoff {
+			return 0, false
+		}
+		return d, true
+	}
+
+	bound := p.InitialBackoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || bound < p.MaxBackoff); i++ {
+		bound *= 2
+	}
+	if p.MaxBackoff > 0 && bound > p.MaxBackoff {
+		bound = p.MaxBackoff
+	}
+	if bound <= 0 {
+		return 0, true
+	}
+	return time.Duration(rand.Int63n(int64(bound) + 1)), true
+}
+
+// retry calls fn until it succeeds, returns non-retryable error, attempts are exhausted or retry budget does not allow
+// more retries. Nil policy means fn is called once.
+func (p *RetryPolicy) retry(ctx context.Context, budget *RetryBudget, logger Logger, fn func() (*Token, error)) (*Token, error) {
+	t, err := fn()
+	if p == nil {
+		return t, err
+	}
+	for attempt := 1; err != nil && attempt < p.MaxAttempts && IsRetryable(err) && budget.AllowRetry(); attempt++ {
+		wait, ok := p.backoff(attempt, err)
+		if !ok {
+			break
+		}
+		logger.Debug("oidc: Retrying token request.", "attempt", attempt, "wait", wait, "err", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		t, err = fn()
+	}
+	return t, err
+}
+
+// retryAfter returns wait requested by the provider in Retry-After header of 429 or 503 response causing err.
+func retryAfter(err error) (time.Duration, bool) {
+	var d time.Duration
+	found := walkErrors(err, func(err error) bool {
+		if oerr, ok := err.(*OAuth2Error); ok && oerr.RetryAfter > 0 {
+			d = oerr.RetryAfter
+			return true
+		}
+		return false
+	})
+	return d, found
+}
+
+// parseRetryAfter parses Retry-After header value given either in seconds or as HTTP date. See
+// https://tools.ietf.org/html/rfc7231#section-7.1.3.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
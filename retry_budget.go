@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"sync"
+)
+
+var (
+	// DefaultRetryBudgetMaxTokens is the default capacity of retry budget. See RetryBudget for details.
+	DefaultRetryBudgetMaxTokens = 10.0
+	// DefaultRetryBudgetTokenRatio is the default number of tokens added to retry budget on each successful request.
+	DefaultRetryBudgetTokenRatio = 0.1
+
+	// maxRetryBudgets bounds how many issuers we hold retry budgets for.
+	maxRetryBudgets = 1000
+)
+
+// RetryBudget is a retry throttling mechanism modeled after gRPC retry throttling
+// (https://github.com/grpc/proposal/blob/master/A6-client-retries.md#throttling-retry-attempts-and-hedged-rpcs).
+//
+// Budget starts with maxTokens tokens. Each failed request to the provider takes one token, each successful request
+// gives back tokenRatio tokens. Retries are allowed only when there is more than half of maxTokens in the budget.
+// This makes aggregate retry traffic towards a failing provider capped, no matter how many token sources retry.
+// RetryBudget is safe for concurrent use.
+type RetryBudget struct {
+	mu sync.Mutex
+
+	maxTokens  float64
+	tokenRatio float64
+	tokens     float64
+}
+
+// NewRetryBudget constructs new RetryBudget with full capacity.
+func NewRetryBudget(maxTokens float64, tokenRatio float64) *RetryBudget {
+	return &RetryBudget{
+		maxTokens:  maxTokens,
+		tokenRatio: tokenRatio,
+		tokens:     maxTokens,
+	}
+}
+
+// AllowRetry returns true if retry is allowed by the budget. Nil budget allows all retries.
+func (b *RetryBudget) AllowRetry() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tokens > b.maxTokens/2
+}
+
+// OnSuccess should be called after every successful request to the provider.
+func (b *RetryBudget) OnSuccess() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += b.tokenRatio
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+// OnFailure should be called after every failed request to the provider that might be retried.
+func (b *RetryBudget) OnFailure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens--
+	if b.tokens < 0 {
+		b.tokens = 0
+	}
+}
+
+var retryBudgets = struct {
+	sync.Mutex
+	budgets *lruCache
+}{
+	budgets: newLRUCache(CacheLimits{MaxEntries: maxRetryBudgets}, nil),
+}
+
+// RetryBudgetFor returns process-wide retry budget shared by all clients and token sources for given issuer.
+func RetryBudgetFor(issuer string) *RetryBudget {
+	retryBudgets.Lock()
+	defer retryBudgets.Unlock()
+
+	if b, ok := retryBudgets.budgets.Get(issuer); ok {
+		return b.(*RetryBudget)
+	}
+
+	b := NewRetryBudget(DefaultRetryBudgetMaxTokens, DefaultRetryBudgetTokenRatio)
+	retryBudgets.budgets.Add(issuer, b)
+	return b
+}
@@ -0,0 +1,41 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+	b := NewRetryBudget(4, 0.5)
+	assert.True(t, b.AllowRetry())
+
+	b.OnFailure()
+	assert.True(t, b.AllowRetry(), "3 tokens left, more than half of capacity")
+
+	b.OnFailure()
+	assert.False(t, b.AllowRetry(), "2 tokens left, exactly half of capacity")
+
+	for i := 0; i < 10; i++ {
+		b.OnFailure()
+	}
+	assert.False(t, b.AllowRetry())
+
+	// Budget never goes below zero, so 5 successes (2.5 tokens) are enough to allow retries again.
+	for i := 0; i < 5; i++ {
+		b.OnSuccess()
+	}
+	assert.True(t, b.AllowRetry())
+
+	for i := 0; i < 100; i++ {
+		b.OnSuccess()
+	}
+	b.OnFailure()
+	b.OnFailure()
+	assert.False(t, b.AllowRetry(), "budget should never exceed its capacity")
+}
+
+func TestRetryBudgetFor_SharedPerIssuer(t *testing.T) {
+	assert.True(t, RetryBudgetFor("https://issuer1.org") == RetryBudgetFor("https://issuer1.org"))
+	assert.False(t, RetryBudgetFor("https://issuer1.org") == RetryBudgetFor("https://issuer2.org"))
+}
@@ -0,0 +1,76 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 5, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 30 * time.Millisecond}
+	for retry, bound := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 5: 30 * time.Millisecond} {
+		d, ok := p.backoff(retry, nil)
+		assert.True(t, ok)
+		assert.True(t, d >= 0 && d <= bound, "retry %d: %v not in [0, %v]", retry, d, bound)
+	}
+
+	d, ok := p.backoff(1, &OAuth2Error{HTTPStatus: http.StatusTooManyRequests, RetryAfter: 20 * time.Millisecond})
+	assert.True(t, ok)
+	assert.Equal(t, 20*time.Millisecond, d)
+
+	_, ok = p.backoff(1, &OAuth2Error{HTTPStatus: http.StatusTooManyRequests, RetryAfter: time.Minute})
+	assert.False(t, ok, "Retry-After longer than MaxBackoff should not be retried")
+}
+
+func (s *ClientTestSuite) TestTokenRefresher_RefreshRetry() {
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access2",
+		RefreshToken: "refresh2",
+		TokenType:    "Bearer",
+		ExpiresIn:    expirationTime(3600),
+	})
+	s.Require().NoError(err)
+
+	cfg := Config{ClientID: "client1", RefreshRetry: &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}}
+	s.s.Push(rt.JSONResponseFunc(http.StatusServiceUnavailable, []byte(`{"error": "temporarily_unavailable"}`)))
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		resp, err := rt.JSONResponseFunc(http.StatusTooManyRequests, []byte(`{"error": "slow_down"}`))(r)
+		resp.Header.Set("Retry-After", "0")
+		return resp, err
+	})
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+
+	token, err := NewTokenRefresher(s.testCtx, s.client, cfg, "refresh1").OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken)
+	s.Equal(0, s.s.Len())
+
+	// Non-retryable errors are returned right away.
+	s.s.Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "invalid_grant"}`)))
+	_, err = NewTokenRefresher(s.testCtx, s.client, cfg, "refresh1").OIDCToken()
+	s.Require().Error(err)
+	s.True(IsAuthError(err))
+	s.Equal(0, s.s.Len())
+
+	// Attempts are limited.
+	for i := 0; i < 3; i++ {
+		s.s.Push(rt.JSONResponseFunc(http.StatusBadGateway, []byte(`{}`)))
+	}
+	_, err = NewTokenRefresher(s.testCtx, s.client, cfg, "refresh1").OIDCToken()
+	s.Require().Error(err)
+	s.True(IsRetryable(err))
+	s.Equal(0, s.s.Len())
+}
@@ -0,0 +1,83 @@
+package oidc
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// staticKeySet is keySet with fixed keys that are never fetched.
+type staticKeySet []jose.JSONWebKey
+
+// Keys returns static keys.
+func (s staticKeySet) Keys(_ context.Context) ([]jose.JSONWebKey, error) {
+	return s, nil
+}
+
+// NewStaticVerifier constructs verifier of tokens issued by given issuer that uses keys from the JWKS JSON document
+// instead of discovery and keys endpoint. No requests are ever made by it, so it is suitable for air-gapped
+// environments or providers not reachable at runtime. Keys need to be updated manually (by constructing new verifier)
+// when provider rotates them.
+func NewStaticVerifier(issuer string, jwksJSON []byte, cfg VerificationConfig) (*IDTokenVerifier, error) {
+	keys, err := decodeKeySet(jwksJSON)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("oidc: no supported keys in JWKS")
+	}
+	return newVerifier(staticKeySet(keys), cfg, issuer, nil), nil
+}
+
+// NewStaticVerifierFromPEM is like NewStaticVerifier, but uses public keys from PEM encoded data, e.g read from
+// files. Each data can contain many PEM blocks of "PUBLIC KEY" (PKIX, RSA, ECDSA or Ed25519), "RSA PUBLIC KEY" (PKCS #1)
+// or "CERTIFICATE" type. Since PEM keys have no key IDs, every key is tried for tokens with any "kid" header.
+func NewStaticVerifierFromPEM(issuer string, cfg VerificationConfig, pemData ...[]byte) (*IDTokenVerifier, error) {
+	var keys []jose.JSONWebKey
+	for _, data := range pemData {
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			key, err := parsePEMPublicKey(block)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, jose.JSONWebKey{Key: key, Use: "sig"})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("oidc: no public keys in PEM data")
+	}
+	return newVerifier(staticKeySet(keys), cfg, issuer, nil), nil
+}
+
+func parsePEMPublicKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to parse PEM public key: %v", err)
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to parse PEM RSA public key: %v", err)
+		}
+		return key, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to parse PEM certificate: %v", err)
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("oidc: unsupported PEM block type %q, expected public key or certificate", block.Type)
+}
@@ -0,0 +1,93 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+func (s *ClientTestSuite) TestStaticVerifier() {
+	claims := map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	}
+	rawToken, jwkSetJSON := s.signedJWT(claims)
+
+	_, err := NewStaticVerifier(exampleIssuer, []byte(`{"keys": []}`), VerificationConfig{ClientID: "client1"})
+	s.Error(err)
+
+	v, err := NewStaticVerifier(exampleIssuer, jwkSetJSON, VerificationConfig{ClientID: "client1"})
+	s.Require().NoError(err)
+	token, err := v.VerifyIDToken(s.testCtx, rawToken)
+	s.Require().NoError(err)
+	s.Equal("subject1", token.Subject)
+
+	otherToken, _ := s.signedJWT(claims)
+	_, err = v.VerifyIDToken(s.testCtx, otherToken)
+	s.Error(err)
+
+	// No requests are made.
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestStaticVerifierFromPEM() {
+	claims := map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	ecDER, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	s.Require().NoError(err)
+
+	pemData := append(
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecDER})...,
+	)
+
+	_, err = NewStaticVerifierFromPEM(exampleIssuer, VerificationConfig{ClientID: "client1"}, []byte("not pem"))
+	s.Error(err)
+	_, err = NewStaticVerifierFromPEM(exampleIssuer, VerificationConfig{ClientID: "client1"},
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	s.Error(err)
+
+	v, err := NewStaticVerifierFromPEM(exampleIssuer, VerificationConfig{
+		ClientID:             "client1",
+		SupportedSigningAlgs: []string{string(jose.RS256), string(jose.ES256)},
+	}, pemData)
+	s.Require().NoError(err)
+
+	for _, signingKey := range []jose.SigningKey{
+		{Algorithm: jose.RS256, Key: rsaKey},
+		{Algorithm: jose.ES256, Key: ecKey},
+	} {
+		// Key ID of token does not matter for keys without ID.
+		signer, err := jose.NewSigner(signingKey, (&jose.SignerOptions{}).WithHeader("kid", "some-key"))
+		s.Require().NoError(err)
+		payload, err := json.Marshal(claims)
+		s.Require().NoError(err)
+		jws, err := signer.Sign(payload)
+		s.Require().NoError(err)
+		rawToken, err := jws.CompactSerialize()
+		s.Require().NoError(err)
+
+		token, err := v.VerifyIDToken(s.testCtx, rawToken)
+		s.Require().NoError(err, "alg %s", signingKey.Algorithm)
+		s.Equal("subject1", token.Subject)
+	}
+	s.Equal(0, s.s.Len())
+}
@@ -0,0 +1,128 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// EnvTokenSource returns a TokenSource that always returns pre-issued token from environment variable, e.g injected in
+// CI. Variable holds either raw access token or token JSON (see Token.MarshalJSON). Token is never refreshed. Verifier
+// of the returned source is the given one, e.g NewStaticVerifier to verify ID tokens offline. It can be nil if tokens
+// are not meant to be verified by the client, in which case ReuseTokenSource and CacheTokenSource wrapping the source
+// check only access token expiry (see Token.IsValidFor).
+func EnvTokenSource(envVar string, verifier Verifier) (TokenSource, error) {
+	t, err := parseStaticToken([]byte(os.Getenv(envVar)))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to read token from %s environment variable: %v", envVar, err)
+	}
+	return staticTokenSource{t: t, verifier: verifier}, nil
+}
+
+// FileTokenSourceOption configures FileTokenSource.
+type FileTokenSourceOption func(*fileTokenSource)
+
+// WithReloadOnChange makes FileTokenSource read the file again when its modification time or size changes, e.g when
+// token is rotated by a sidecar. The file is checked on every call for the token.
+func WithReloadOnChange() FileTokenSourceOption {
+	return func(s *fileTokenSource) {
+		s.reload = true
+	}
+}
+
+// FileTokenSource returns a TokenSource that returns pre-issued token read from the file, in the same formats as
+// EnvTokenSource. Token is never refreshed. The file is read once, unless WithReloadOnChange is given. Verifier of the
+// returned source is the given one and can be nil, as in EnvTokenSource.
+func FileTokenSource(path string, verifier Verifier, opts ...FileTokenSourceOption) (TokenSource, error) {
+	s := &fileTokenSource{path: os.ExpandEnv(path), verifier: verifier, parse: parseStaticToken}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type fileTokenSource struct {
+	path     string
+	verifier Verifier
+	reload   bool
+	parse    func([]byte) (*Token, error)
+
+	mu      sync.Mutex
+	t       *Token
+	modTime time.Time
+	size    int64
+}
+
+// load reads the token from the file, if it changed since last load.
+func (s *fileTokenSource) load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("oidc: failed to read token file: %v", err)
+	}
+	if s.t != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("oidc: failed to read token file: %v", err)
+	}
+	t, err := s.parse(b)
+	if err != nil {
+		return fmt.Errorf("oidc: failed to read token from %s: %v", s.path, err)
+	}
+	s.t, s.modTime, s.size = t, info.ModTime(), info.Size()
+	return nil
+}
+
+// OIDCToken returns token from the file, reading it again if it changed and WithReloadOnChange was given.
+func (s *fileTokenSource) OIDCToken() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reload {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	return s.t, nil
+}
+
+// OIDCTokenCtx is the same as OIDCToken, since no requests are made.
+func (s *fileTokenSource) OIDCTokenCtx(_ context.Context) (*Token, error) {
+	return s.OIDCToken()
+}
+
+// Verifier returns verifier given on construction.
+func (s *fileTokenSource) Verifier() Verifier {
+	return s.verifier
+}
+
+// parseStaticToken parses raw access token or token JSON.
+func parseStaticToken(b []byte) (*Token, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, errors.New("no token")
+	}
+	if b[0] != '{' {
+		return &Token{AccessToken: string(b)}, nil
+	}
+
+	t := &Token{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token JSON: %v", err)
+	}
+	if t.AccessToken == "" && t.IDToken == "" {
+		return nil, errors.New("token JSON has neither access nor ID token")
+	}
+	return t, nil
+}
@@ -0,0 +1,80 @@
+package oidc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvTokenSource(t *testing.T) {
+	_, err := EnvTokenSource("TEST_OIDC_TOKEN", nil)
+	assert.Error(t, err, "no token in env")
+
+	require.NoError(t, os.Setenv("TEST_OIDC_TOKEN", " access1\n"))
+	defer os.Unsetenv("TEST_OIDC_TOKEN")
+	src, err := EnvTokenSource("TEST_OIDC_TOKEN", nil)
+	require.NoError(t, err)
+	token, err := src.OIDCToken()
+	require.NoError(t, err)
+	assert.Equal(t, &Token{AccessToken: "access1"}, token)
+	assert.Nil(t, src.Verifier())
+
+	// Sources without verifier can be reused, checking only access token expiry.
+	reuse, _ := NewReuseTokenSource(context.Background(), nil, src)
+	token, err = reuse.OIDCToken()
+	require.NoError(t, err)
+	assert.Equal(t, "access1", token.AccessToken)
+	token, err = reuse.OIDCToken()
+	require.NoError(t, err)
+	assert.Equal(t, "access1", token.AccessToken)
+
+	require.NoError(t, os.Setenv("TEST_OIDC_TOKEN", `{"version":1,"access_token":"access2","id_token":"id2"}`))
+	src, err = EnvTokenSource("TEST_OIDC_TOKEN", nil)
+	require.NoError(t, err)
+	token, err = src.OIDCToken()
+	require.NoError(t, err)
+	assert.Equal(t, "access2", token.AccessToken)
+	assert.Equal(t, "id2", token.IDToken)
+
+	require.NoError(t, os.Setenv("TEST_OIDC_TOKEN", `{"refresh_token":"refresh1"}`))
+	_, err = EnvTokenSource("TEST_OIDC_TOKEN", nil)
+	assert.Error(t, err)
+}
+
+func TestFileTokenSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oidc-token-file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	_, err = FileTokenSource(path, nil)
+	assert.Error(t, err, "no file")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("access1\n"), 0600))
+	src, err := FileTokenSource(path, nil)
+	require.NoError(t, err)
+	reloading, err := FileTokenSource(path, nil, WithReloadOnChange())
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("access22\n"), 0600))
+	future := time.Now().Add(1 * time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	token, err := src.OIDCToken()
+	require.NoError(t, err)
+	assert.Equal(t, "access1", token.AccessToken, "file is read once without reload")
+
+	token, err = reloading.OIDCToken()
+	require.NoError(t, err)
+	assert.Equal(t, "access22", token.AccessToken)
+
+	require.NoError(t, ioutil.WriteFile(path, nil, 0600))
+	_, err = reloading.OIDCToken()
+	assert.Error(t, err, "empty file")
+}
@@ -26,6 +26,12 @@ func TestDiscovery(testIssuerURL string) oidc.DiscoveryJSON {
 		AuthURL:  testIssuerURL + "/auth1",
 		TokenURL: testIssuerURL + "/token1",
 		JWKSURL:  testIssuerURL + "/jwks1",
+
+		RevocationURL: testIssuerURL + "/revoke1",
+		DeviceAuthURL: testIssuerURL + "/device1",
+		EndSessionURL: testIssuerURL + "/logout1",
+
+		BackchannelAuthURL: testIssuerURL + "/bc-authorize1",
 	}
 }
 
@@ -55,10 +61,22 @@ func (p *Provider) Setup(t *testing.T) {
 }
 
 // Context that should be used to propagate mocked HTTP client.
+//
+// Deprecated: Use ClientOption instead.
 func (p *Provider) Context() context.Context {
 	return p.testCtx
 }
 
+// HTTPClient returns mocked HTTP client.
+func (p *Provider) HTTPClient() *http.Client {
+	return p.srv.HTTPClient()
+}
+
+// ClientOption returns oidc.Client option that makes client use mocked HTTP client.
+func (p *Provider) ClientOption() oidc.Option {
+	return oidc.WithHTTPClient(p.srv.HTTPClient())
+}
+
 // Mock allows to mock provider response on certain requests.
 func (p *Provider) Mock() *httpt.Server {
 	return p.srv
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -23,28 +24,110 @@ type Token struct {
 	// the requests. It can be used for API access or token revocation.
 	AccessToken string `json:"access_token"`
 
+	// TokenType is the type of AccessToken as returned by the provider. Empty means Bearer.
+	TokenType string `json:"token_type,omitempty"`
+
 	// AccessTokenExpiry is time when access token will be invalid.
 	AccessTokenExpiry time.Time `json:"expiry"`
 
 	// RefreshToken is used to refresh the access token and ID token if they expire.
 	RefreshToken string `json:"refresh_token,omitempty"`
 
+	// Scope is the space-separated list of scopes granted to AccessToken, if returned by the provider.
+	Scope string `json:"scope,omitempty"`
+
 	// NewIDToken is a security token that contains Claims about the Authentication of an End-User by an Authorization
 	// Server when using a Client, and potentially other requested Claims that helps in authorization itself.
 	// The ID Token is always represented as a JWT.
 	IDToken string `json:"id_token"`
+
+	// extra holds raw JSON values of token response fields not represented by fields above, e.g provider-specific
+	// ones. Nil if there are none.
+	extra map[string]json.RawMessage
+}
+
+// TokenJSONVersion is the current version of Token JSON schema. See Token.MarshalJSON.
+const TokenJSONVersion = 1
+
+// tokenJSON is the stable JSON schema of Token. Fields can be only added. Any incompatible change requires
+// TokenJSONVersion bump.
+type tokenJSON struct {
+	Version      int                        `json:"version"`
+	IDToken      string                     `json:"id_token"`
+	AccessToken  string                     `json:"access_token"`
+	RefreshToken string                     `json:"refresh_token,omitempty"`
+	TokenType    string                     `json:"token_type,omitempty"`
+	Expiry       *time.Time                 `json:"expiry,omitempty"`
+	Scope        string                     `json:"scope,omitempty"`
+	Extra        map[string]json.RawMessage `json:"extra,omitempty"`
 }
 
-// Claims unmarshals the raw JSON payload of the NewIDToken into a provided struct.
+// MarshalJSON encodes token in the stable, versioned JSON schema:
 //
-//		var claims struct {
-//			Email         string `json:"email"`
-//			EmailVerified bool   `json:"email_verified"`
-//		}
-//		if err := oidc.Token{NewIDToken: "<id token>"}.Claims(idTokenVerifier, &claims); err != nil {
-//			// handle error
-//		}
+//	{
+//		"version":       1,                        // TokenJSONVersion
+//		"id_token":      "<compact JWT>",
+//		"access_token":  "<access token>",
+//		"refresh_token": "<refresh token>",        // omitted if empty
+//		"token_type":    "Bearer",                 // omitted if empty
+//		"expiry":        "2017-10-12T15:04:05Z",   // access token expiry RFC 3339, omitted if token does not expire
+//		"scope":         "openid email",           // granted scopes, omitted if unknown
+//		"extra":         {"ext": "value"},         // other token response fields, omitted if none
+//	}
 //
+// Such JSON can be decoded by any version of this library that supports given schema version.
+func (t Token) MarshalJSON() ([]byte, error) {
+	j := tokenJSON{
+		Version:      TokenJSONVersion,
+		IDToken:      t.IDToken,
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		TokenType:    t.TokenType,
+		Scope:        t.Scope,
+		Extra:        t.extra,
+	}
+	if !t.AccessTokenExpiry.IsZero() {
+		expiry := t.AccessTokenExpiry
+		j.Expiry = &expiry
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes token encoded by MarshalJSON. It also accepts unversioned JSON written by previous
+// versions of this library. It returns error for schema versions newer than TokenJSONVersion.
+func (t *Token) UnmarshalJSON(b []byte) error {
+	var j tokenJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.Version > TokenJSONVersion {
+		return fmt.Errorf("oidc: unsupported token JSON version %d, max supported is %d", j.Version, TokenJSONVersion)
+	}
+
+	*t = Token{
+		IDToken:      j.IDToken,
+		AccessToken:  j.AccessToken,
+		RefreshToken: j.RefreshToken,
+		TokenType:    j.TokenType,
+		Scope:        j.Scope,
+		extra:        j.Extra,
+	}
+	if j.Expiry != nil {
+		t.AccessTokenExpiry = *j.Expiry
+	}
+	return nil
+}
+
+// Claims verifies the IDToken and unmarshals its raw JSON payload into a provided struct. On Go 1.18+ see also
+// ClaimsAs.
+//
+//	var claims struct {
+//		Email         string `json:"email"`
+//		EmailVerified bool   `json:"email_verified"`
+//	}
+//	if err := oidc.Token{IDToken: "<id token>"}.Claims(ctx, idTokenVerifier, &claims); err != nil {
+//		// handle error
+//	}
 func (t Token) Claims(ctx context.Context, verifier Verifier, v interface{}) error {
 	idToken, err := verifier.Verify(ctx, t.IDToken)
 	if err != nil {
@@ -54,35 +137,144 @@ func (t Token) Claims(ctx context.Context, verifier Verifier, v interface{}) err
 	return idToken.Claims(v)
 }
 
+// UnverifiedClaims unmarshals the raw JSON payload of the IDToken into a provided struct WITHOUT verifying it.
+// Use it only for tokens that were already verified e.g returned by TokenSource.
+func (t Token) UnverifiedClaims(v interface{}) error {
+	payload, err := t.RawClaims()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// RawIDToken returns exact compact serialized ID token JWT as returned by the provider, e.g to forward it downstream.
+func (t Token) RawIDToken() string {
+	return t.IDToken
+}
+
+// RawClaims returns raw JSON payload of the IDToken WITHOUT verifying it.
+func (t Token) RawClaims() (json.RawMessage, error) {
+	if t.IDToken == "" {
+		return nil, errors.New("oidc: no IDToken")
+	}
+	payload, err := parseJWT(t.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(payload), nil
+}
+
+// Extra returns value of given token endpoint response field, e.g provider-specific "id_token_expires_in", or nil if
+// the response did not include it. Values are decoded as by json.Unmarshal into interface{}, so numbers are float64.
+// Standard fields like "scope" are returned from the token fields.
+func (t Token) Extra(key string) interface{} {
+	if v := t.standardField(key); v != "" {
+		return v
+	}
+	var v interface{}
+	if err := json.Unmarshal(t.extra[key], &v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// RawExtra returns raw JSON value of given non-standard token endpoint response field, e.g to unmarshal
+// resource-specific data into a struct. It returns nil if the response did not include it.
+func (t Token) RawExtra(key string) json.RawMessage {
+	return t.extra[key]
+}
+
+func (t Token) standardField(key string) string {
+	switch key {
+	case "access_token":
+		return t.AccessToken
+	case "token_type":
+		return t.TokenType
+	case "refresh_token":
+		return t.RefreshToken
+	case "scope":
+		return t.Scope
+	case "id_token":
+		return t.IDToken
+	}
+	return ""
+}
+
 // SetAuthHeader sets the Authorization header to r using the access
-// token in t.
-func (t *Token) SetAuthHeader(r *http.Request) {
-	r.Header.Set("Authorization", "Bearer "+t.AccessToken)
+// token in t, with authorization scheme matching its TokenType. Bearer (also when TokenType is empty) and DPoP tokens
+// are supported; DPoP proof is not added, use Transport with WithDPoPProofs for that. Other token types e.g MAC
+// are rejected, since sending them as bearer tokens would fail anyway.
+func (t *Token) SetAuthHeader(r *http.Request) error {
+	scheme, err := t.authScheme()
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", scheme+" "+t.AccessToken)
+	return nil
+}
+
+// authScheme returns authorization scheme for TokenType. Token types are case insensitive. See
+// https://tools.ietf.org/html/rfc6749#section-5.1.
+func (t *Token) authScheme() (string, error) {
+	switch {
+	case t.TokenType == "" || strings.EqualFold(t.TokenType, "Bearer"):
+		return "Bearer", nil
+	case strings.EqualFold(t.TokenType, TokenTypeDPoP):
+		return TokenTypeDPoP, nil
+	}
+	return "", fmt.Errorf("oidc: unsupported token type %q", t.TokenType)
 }
 
 // IsAccessTokenExpired returns true if access token expired.
 func (t *Token) IsAccessTokenExpired() bool {
+	return t.IsAccessTokenExpiringWithin(tokenExpiryDelta)
+}
+
+// IsAccessTokenExpiringWithin returns true if access token expires within given duration from now.
+// Token without expiry never expires.
+func (t *Token) IsAccessTokenExpiringWithin(d time.Duration) bool {
+	return t.accessTokenExpiringWithin(d, time.Now())
+}
+
+func (t *Token) accessTokenExpiringWithin(d time.Duration, now time.Time) bool {
 	if t.AccessTokenExpiry.IsZero() {
 		return false
 	}
-	return t.AccessTokenExpiry.Add(-tokenExpiryDelta).Before(time.Now())
+	return t.AccessTokenExpiry.Add(-d).Before(now)
 }
 
 // IsValid validates oidc token by validating AccessToken and ID Token.
 // If error is nil, the token is valid.
 func (t *Token) IsValid(ctx context.Context, verifier Verifier) error {
-	_, err := verifier.Verify(ctx, t.IDToken)
-	if err != nil {
-		return fmt.Errorf("token: IDToken is not valid. Err: %v", err)
+	return t.IsValidFor(ctx, verifier, tokenExpiryDelta)
+}
+
+// IsValidFor is the same as IsValid, but additionally requires AccessToken to be valid for at least minValidity.
+// Access token expiry is checked against the verifier's clock (see VerificationConfig.Now and WithClock) if it is
+// IDTokenVerifier, or time.Now otherwise. If verifier is nil, ID token is not verified and only AccessToken and its
+// expiry are checked.
+func (t *Token) IsValidFor(ctx context.Context, verifier Verifier, minValidity time.Duration) error {
+	if verifier != nil {
+		if _, err := verifier.Verify(ctx, t.IDToken); err != nil {
+			return fmt.Errorf("token: IDToken is not valid. Err: %v", err)
+		}
 	}
 
 	if t.AccessToken == "" {
 		return errors.New("token: No AccessToken.")
 	}
 
-	if t.IsAccessTokenExpired() {
+	now := time.Now()
+	if v, ok := verifier.(*IDTokenVerifier); ok {
+		now = v.now()
+	}
+	if t.accessTokenExpiringWithin(tokenExpiryDelta, now) {
 		return errors.New("token: AccessToken expired.")
 	}
+
+	if minValidity > tokenExpiryDelta && t.accessTokenExpiringWithin(minValidity, now) {
+		return fmt.Errorf("token: AccessToken expires in less than required %v.", minValidity)
+	}
 	return nil
 }
 
@@ -106,6 +298,10 @@ type IDToken struct {
 	// common uses, this is the client that initialized the auth flow.
 	Audience Audience `json:"aud"`
 
+	// The party to which the token was issued. If present, this package ensures it is the ClientID. It is required
+	// when the token has more than one audience.
+	AuthorizedParty string `json:"azp,omitempty"`
+
 	// A unique string which identifies the end user.
 	Subject string `json:"sub"`
 
@@ -122,6 +318,8 @@ type IDToken struct {
 
 	// Raw payload of the id_token.
 	claims []byte
+	// Raw compact serialized id_token.
+	raw string
 }
 
 type Audience []string
@@ -146,18 +344,17 @@ func (a *Audience) UnmarshalJSON(b []byte) error {
 
 // Claims unmarshals the raw JSON payload of the ID Token into a provided struct.
 //
-//		idToken, err := idTokenVerifier.Verify(rawIDToken)
-//		if err != nil {
-//			// handle error
-//		}
-//		var claims struct {
-//			Email         string `json:"email"`
-//			EmailVerified bool   `json:"email_verified"`
-//		}
-//		if err := idToken.Claims(&claims); err != nil {
-//			// handle error
-//		}
-//
+//	idToken, err := idTokenVerifier.Verify(rawIDToken)
+//	if err != nil {
+//		// handle error
+//	}
+//	var claims struct {
+//		Email         string `json:"email"`
+//		EmailVerified bool   `json:"email_verified"`
+//	}
+//	if err := idToken.Claims(&claims); err != nil {
+//		// handle error
+//	}
 func (i *IDToken) Claims(v interface{}) error {
 	if i.claims == nil {
 		return errors.New("oidc: claims not set")
@@ -165,6 +362,159 @@ func (i *IDToken) Claims(v interface{}) error {
 	return json.Unmarshal(i.claims, v)
 }
 
+// Raw returns the exact compact serialized JWT this ID token was parsed from.
+func (i *IDToken) Raw() string {
+	return i.raw
+}
+
+// RawClaims returns the raw JSON payload of the ID token.
+func (i *IDToken) RawClaims() json.RawMessage {
+	return json.RawMessage(i.claims)
+}
+
+// AccessToken is an OAuth2 access token in JWT format verified by Verifier.VerifyAccessToken.
+type AccessToken struct {
+	// The URL of the server which issued this token.
+	Issuer string `json:"iss"`
+
+	// The resource servers (or clients) this token is intended for.
+	Audience Audience `json:"aud"`
+
+	// A unique string which identifies the end user or the client itself.
+	Subject string `json:"sub"`
+
+	// Expiry of the token.
+	Expiry NumericDate `json:"exp"`
+
+	// When the token was issued by the provider.
+	IssuedAt NumericDate `json:"iat"`
+
+	// The client ID of the OAuth2 client that requested the token, if included by the provider.
+	ClientID string `json:"client_id"`
+
+	// Space-separated list of scopes granted to the token, if included by the provider.
+	Scope string `json:"scope"`
+
+	// Unique identifier of the token, if included by the provider.
+	ID string `json:"jti"`
+
+	// Raw payload of the access token.
+	claims []byte
+	// Raw compact serialized access token.
+	raw string
+}
+
+// Claims unmarshals the raw JSON payload of the access token into a provided struct.
+func (a *AccessToken) Claims(v interface{}) error {
+	if a.claims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return json.Unmarshal(a.claims, v)
+}
+
+// validate checks claims required by JWT profile for access tokens (see https://tools.ietf.org/html/rfc9068#section-2.2).
+// Other required claims are checked by the verifier.
+func (a *AccessToken) validate() error {
+	if a.Subject == "" {
+		return errors.New("oidc: access token does not include required \"sub\" claim")
+	}
+	if a.ClientID == "" {
+		return errors.New("oidc: access token does not include required \"client_id\" claim")
+	}
+	if a.ID == "" {
+		return errors.New("oidc: access token does not include required \"jti\" claim")
+	}
+	return nil
+}
+
+// Raw returns the exact compact serialized JWT this access token was parsed from.
+func (a *AccessToken) Raw() string {
+	return a.raw
+}
+
+// BackChannelLogoutEvent is the member of "events" claim that identifies logout token.
+// See https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken.
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutToken is a back-channel logout token verified by Verifier.VerifyLogoutToken.
+type LogoutToken struct {
+	// The URL of the server which issued this token.
+	Issuer string `json:"iss"`
+
+	// The clients this token is intended for.
+	Audience Audience `json:"aud"`
+
+	// The end user that was logged out. Either Subject or SessionID is always present.
+	Subject string `json:"sub"`
+
+	// The session that was logged out. Either Subject or SessionID is always present.
+	SessionID string `json:"sid"`
+
+	// When the token was issued by the provider.
+	IssuedAt NumericDate `json:"iat"`
+
+	// Expiry of the token, if included by the provider.
+	Expiry NumericDate `json:"exp"`
+
+	// Unique identifier of the token. Can be used to detect replays.
+	ID string `json:"jti"`
+
+	// Events the token describes. Always includes BackChannelLogoutEvent.
+	Events map[string]json.RawMessage `json:"events"`
+
+	// Logout token must not include nonce, so it can't be confused with an ID token.
+	nonce *string
+	// Raw payload of the logout token.
+	claims []byte
+	// Raw compact serialized logout token.
+	raw string
+}
+
+// validate checks claims required by the Back-Channel Logout spec.
+func (l *LogoutToken) validate() error {
+	if l.Subject == "" && l.SessionID == "" {
+		return errors.New("oidc: logout token must include \"sub\" or \"sid\" claim")
+	}
+	if l.ID == "" {
+		return errors.New("oidc: logout token does not include required \"jti\" claim")
+	}
+	if _, ok := l.Events[BackChannelLogoutEvent]; !ok {
+		return fmt.Errorf("oidc: logout token \"events\" claim does not include %q", BackChannelLogoutEvent)
+	}
+	if l.nonce != nil {
+		return errors.New("oidc: logout token must not include \"nonce\" claim")
+	}
+	return nil
+}
+
+// UnmarshalJSON unmarshals logout token claims, detecting forbidden "nonce" claim.
+func (l *LogoutToken) UnmarshalJSON(b []byte) error {
+	type logoutToken LogoutToken
+	var t struct {
+		logoutToken
+		Nonce *string `json:"nonce"`
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return err
+	}
+	*l = LogoutToken(t.logoutToken)
+	l.nonce = t.Nonce
+	return nil
+}
+
+// Claims unmarshals the raw JSON payload of the logout token into a provided struct.
+func (l *LogoutToken) Claims(v interface{}) error {
+	if l.claims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return json.Unmarshal(l.claims, v)
+}
+
+// Raw returns the exact compact serialized JWT this logout token was parsed from.
+func (l *LogoutToken) Raw() string {
+	return l.raw
+}
+
 // NumericDate represents date and time as the number of seconds since the
 // epoch, including leap seconds. Non-integer values can be represented
 // in the serialized format, but we round to the nearest second.
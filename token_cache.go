@@ -0,0 +1,284 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TokenCache persists tokens between runs of the process, e.g so CLI tools don't need to run the login flow on every
+// invocation. login.Cache implementations satisfy it as well.
+type TokenCache interface {
+	// Token returns cached token or nil without error if there is no token cached.
+	Token() (*Token, error)
+	// SaveToken caches given token, replacing previous one.
+	SaveToken(t *Token) error
+}
+
+// ConditionalTokenCache is a TokenCache shared by many processes that supports optimistic locking, so processes that
+// refreshed the same token concurrently end up using the same token. ReuseTokenSource uses it when given cache
+// implements it.
+type ConditionalTokenCache interface {
+	TokenCache
+	// SaveTokenIf saves t only if cached token is still old (nil meaning no token is cached). Otherwise it returns false
+	// and the currently cached token.
+	SaveTokenIf(old *Token, t *Token) (saved bool, current *Token, err error)
+}
+
+// sameToken returns true if both tokens are nil or have the same access, refresh and ID tokens.
+func sameToken(a *Token, b *Token) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.AccessToken == b.AccessToken && a.RefreshToken == b.RefreshToken && a.IDToken == b.IDToken
+}
+
+const (
+	// fileLockTimeout is how long FileTokenCache waits for lock held by other process.
+	fileLockTimeout = 10 * time.Second
+	// fileLockStaleAge is age after which lock file is considered left by crashed process and removed.
+	fileLockStaleAge = 1 * time.Minute
+)
+
+// FileTokenCache is a TokenCache that stores token as JSON in a single file, readable only by its owner. Access is
+// synchronized between processes with lock file created next to it, and token is written atomically, so concurrent
+// invocations of the same CLI never read partially written token.
+type FileTokenCache struct {
+	path string
+}
+
+// NewFileTokenCache constructs FileTokenCache that stores token in given file. Environment variables in path are
+// expanded, e.g "$HOME/.mytool/token". Missing directories are created with 0700 permissions on first save.
+func NewFileTokenCache(path string) *FileTokenCache {
+	return &FileTokenCache{path: os.ExpandEnv(path)}
+}
+
+// Token reads token from the file. It returns nil token if the file does not exist.
+func (c *FileTokenCache) Token() (*Token, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return c.read()
+}
+
+// SaveToken writes token to the file with 0600 permissions.
+func (c *FileTokenCache) SaveToken(t *Token) error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("oidc: failed to create token cache dir: %v", err)
+	}
+
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return c.write(t)
+}
+
+// SaveTokenIf writes token to the file only if the file still contains old token. The check and write are done under
+// the same lock, so processes sharing the file don't overwrite each other's refreshed tokens.
+func (c *FileTokenCache) SaveTokenIf(old *Token, t *Token) (bool, *Token, error) {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return false, nil, fmt.Errorf("oidc: failed to create token cache dir: %v", err)
+	}
+
+	unlock, err := c.lock()
+	if err != nil {
+		return false, nil, err
+	}
+	defer unlock()
+
+	current, err := c.read()
+	if err != nil {
+		return false, nil, err
+	}
+	if !sameToken(current, old) {
+		return false, current, nil
+	}
+	return true, t, c.write(t)
+}
+
+func (c *FileTokenCache) read() (*Token, error) {
+	b, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to read cached token: %v", err)
+	}
+	t := &Token{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal cached token: %v", err)
+	}
+	return t, nil
+}
+
+func (c *FileTokenCache) write(t *Token) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	// Write to temporary file first, so readers never see partially written token.
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("oidc: failed to create temporary token file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// TempFile creates files with 0600 already, but make sure umask or platform did not change it.
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("oidc: failed to set permissions of token file: %v", err)
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("oidc: failed to write token: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("oidc: failed to write token: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("oidc: failed to save token: %v", err)
+	}
+	return nil
+}
+
+// lock acquires lock file of the cache. Lock files older than fileLockStaleAge are assumed to be left by crashed
+// process and are removed.
+func (c *FileTokenCache) lock() (unlock func(), err error) {
+	lockPath := c.path + ".lock"
+	deadline := time.Now().Add(fileLockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if os.IsNotExist(err) {
+			// No cache dir yet, so there is nothing to synchronize.
+			return func() {}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("oidc: failed to lock token cache: %v", err)
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > fileLockStaleAge {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("oidc: timed out waiting for token cache lock " + lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// TokenKV is the subset of key-value store commands used by KVTokenCache. It is trivial to implement using e.g Redis
+// GET, SET with PX and compare-and-swap done with WATCH/MULTI transaction or Lua script.
+type TokenKV interface {
+	// Get returns value of the key. It returns nil value without error if key does not exist.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set sets key to value with given TTL. Zero TTL means no expiry.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// CompareAndSwap sets key to value with given TTL only if its current value is old (nil meaning key does not
+	// exist). It returns false without error if current value is different.
+	CompareAndSwap(ctx context.Context, key string, old []byte, value []byte, ttl time.Duration) (bool, error)
+}
+
+// KVTokenCache is a ConditionalTokenCache backed by key-value store shared by all instances of horizontally-scaled
+// service using the same client identity, e.g Redis. TTL of the key is tied to the access token expiry. Tokens with
+// refresh token are stored without TTL, since refresh tokens outlive access tokens.
+type KVTokenCache struct {
+	ctx     context.Context
+	kv      TokenKV
+	key     string
+	timeNow func() time.Time
+}
+
+// NewKVTokenCache constructs KVTokenCache that stores token under given key. Ctx is used for all requests to the store.
+func NewKVTokenCache(ctx context.Context, kv TokenKV, key string) *KVTokenCache {
+	return &KVTokenCache{ctx: ctx, kv: kv, key: key, timeNow: time.Now}
+}
+
+// Token returns token stored under the key or nil if there is none.
+func (c *KVTokenCache) Token() (*Token, error) {
+	t, _, err := c.get()
+	return t, err
+}
+
+// SaveToken stores token under the key.
+func (c *KVTokenCache) SaveToken(t *Token) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return c.kv.Set(c.ctx, c.key, b, c.ttl(t))
+}
+
+// SaveTokenIf stores token under the key only if it still holds old token, using compare-and-swap.
+func (c *KVTokenCache) SaveTokenIf(old *Token, t *Token) (bool, *Token, error) {
+	current, raw, err := c.get()
+	if err != nil {
+		return false, nil, err
+	}
+	if !sameToken(current, old) {
+		return false, current, nil
+	}
+
+	b, err := json.Marshal(t)
+	if err != nil {
+		return false, nil, err
+	}
+	swapped, err := c.kv.CompareAndSwap(c.ctx, c.key, raw, b, c.ttl(t))
+	if err != nil {
+		return false, nil, err
+	}
+	if swapped {
+		return true, t, nil
+	}
+
+	// Changed between get and swap.
+	current, _, err = c.get()
+	if err != nil {
+		return false, nil, err
+	}
+	return false, current, nil
+}
+
+func (c *KVTokenCache) get() (*Token, []byte, error) {
+	b, err := c.kv.Get(c.ctx, c.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc: failed to get cached token: %v", err)
+	}
+	if b == nil {
+		return nil, nil, nil
+	}
+	t := &Token{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, nil, fmt.Errorf("oidc: failed to unmarshal cached token: %v", err)
+	}
+	return t, b, nil
+}
+
+// ttl returns TTL of the key for given token.
+func (c *KVTokenCache) ttl(t *Token) time.Duration {
+	if t.RefreshToken != "" || t.AccessTokenExpiry.IsZero() {
+		return 0
+	}
+	ttl := t.AccessTokenExpiry.Sub(c.timeNow())
+	if ttl < time.Millisecond {
+		// Zero means no expiry, so expired token needs minimal positive TTL.
+		ttl = time.Millisecond
+	}
+	return ttl
+}
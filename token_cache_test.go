@@ -0,0 +1,207 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTokenCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oidc-token-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cache := NewFileTokenCache(filepath.Join(dir, "tool", "token"))
+	token, err := cache.Token()
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	require.NoError(t, cache.SaveToken(&Token{AccessToken: "access1", RefreshToken: "refresh1"}))
+	info, err := os.Stat(filepath.Join(dir, "tool", "token"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	token, err = cache.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "access1", token.AccessToken)
+	assert.Equal(t, "refresh1", token.RefreshToken)
+
+	// Lock left by crashed process does not block the cache forever.
+	lockPath := filepath.Join(dir, "tool", "token.lock")
+	require.NoError(t, ioutil.WriteFile(lockPath, nil, 0600))
+	old := time.Now().Add(-2 * fileLockStaleAge)
+	require.NoError(t, os.Chtimes(lockPath, old, old))
+	require.NoError(t, cache.SaveToken(&Token{AccessToken: "access2"}))
+
+	token, err = cache.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "access2", token.AccessToken)
+	_, err = os.Stat(lockPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func (s *ClientTestSuite) TestReuseTokenSource_TokenCache() {
+	dir, err := ioutil.TempDir("", "oidc-token-cache")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+
+	idToken, jwkSetJSON := s.validIDToken()
+	cache := NewFileTokenCache(filepath.Join(dir, "token"))
+	s.Require().NoError(cache.SaveToken(&Token{
+		AccessToken:       "access1",
+		AccessTokenExpiry: time.Now().Add(-1 * time.Minute),
+		RefreshToken:      "refresh-cached",
+		IDToken:           idToken,
+	}))
+
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access2",
+		RefreshToken: "refresh2",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    expirationTime(3600),
+	})
+	s.Require().NoError(err)
+
+	// Expired cached token is refreshed with its refresh token and new token is cached.
+	src := s.client.TokenSource(s.testCtx, Config{ClientID: "client1"}, nil, WithTokenCache(cache))
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Require().NoError(r.ParseForm())
+		s.Equal("refresh-cached", r.PostForm.Get("refresh_token"))
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+	token, err := src.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken)
+	s.Equal(0, s.s.Len())
+
+	cached, err := cache.Token()
+	s.Require().NoError(err)
+	s.Equal("access2", cached.AccessToken)
+	s.Equal("refresh2", cached.RefreshToken)
+
+	// Next invocation reuses cached token without refresh. Client already has the keys, so nothing is fetched.
+	src = s.client.TokenSource(s.testCtx, Config{ClientID: "client1"}, nil, WithTokenCache(cache))
+	token, err = src.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken)
+	s.Equal(0, s.s.Len())
+}
+
+type memoryKV struct {
+	values map[string][]byte
+	ttls   map[string]time.Duration
+}
+
+func newMemoryKV() *memoryKV {
+	return &memoryKV{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (m *memoryKV) Get(_ context.Context, key string) ([]byte, error) {
+	return m.values[key], nil
+}
+
+func (m *memoryKV) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.values[key] = value
+	m.ttls[key] = ttl
+	return nil
+}
+
+func (m *memoryKV) CompareAndSwap(ctx context.Context, key string, old []byte, value []byte, ttl time.Duration) (bool, error) {
+	if !bytes.Equal(m.values[key], old) {
+		return false, nil
+	}
+	return true, m.Set(ctx, key, value, ttl)
+}
+
+func TestKVTokenCache(t *testing.T) {
+	kv := newMemoryKV()
+	now := time.Now()
+	cache := NewKVTokenCache(context.Background(), kv, "token1")
+	cache.timeNow = func() time.Time { return now }
+
+	token, err := cache.Token()
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	require.NoError(t, cache.SaveToken(&Token{AccessToken: "access1", AccessTokenExpiry: now.Add(1 * time.Minute)}))
+	assert.Equal(t, 1*time.Minute, kv.ttls["token1"])
+	require.NoError(t, cache.SaveToken(&Token{AccessToken: "access1", AccessTokenExpiry: now.Add(1 * time.Minute), RefreshToken: "refresh1"}))
+	assert.Equal(t, time.Duration(0), kv.ttls["token1"], "token with refresh token should not expire")
+
+	old, err := cache.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "refresh1", old.RefreshToken)
+
+	saved, current, err := cache.SaveTokenIf(old, &Token{AccessToken: "access2", RefreshToken: "refresh2"})
+	require.NoError(t, err)
+	assert.True(t, saved)
+	assert.Equal(t, "access2", current.AccessToken)
+
+	// Old token was already replaced.
+	saved, current, err = cache.SaveTokenIf(old, &Token{AccessToken: "access3", RefreshToken: "refresh3"})
+	require.NoError(t, err)
+	assert.False(t, saved)
+	assert.Equal(t, "access2", current.AccessToken)
+}
+
+type funcTokenSource struct {
+	fn       func() (*Token, error)
+	verifier Verifier
+}
+
+func (s *funcTokenSource) OIDCToken() (*Token, error) {
+	return s.fn()
+}
+
+func (s *funcTokenSource) Verifier() Verifier {
+	return s.verifier
+}
+
+func (s *ClientTestSuite) TestReuseTokenSource_ConditionalTokenCache() {
+	idToken, jwkSetJSON := s.validIDToken()
+	verifier, err := NewStaticVerifier(exampleIssuer, jwkSetJSON, VerificationConfig{ClientID: "client1"})
+	s.Require().NoError(err)
+
+	kv := newMemoryKV()
+	cache := NewKVTokenCache(s.testCtx, kv, "token1")
+	s.Require().NoError(cache.SaveToken(&Token{AccessToken: "access0", RefreshToken: "refresh0", IDToken: idToken, AccessTokenExpiry: time.Now().Add(-1 * time.Minute)}))
+
+	// Other instance saves its refreshed token while this one refreshes.
+	other := &Token{AccessToken: "access-other", IDToken: idToken, AccessTokenExpiry: time.Now().Add(1 * time.Hour)}
+	src := &funcTokenSource{verifier: verifier, fn: func() (*Token, error) {
+		s.Require().NoError(cache.SaveToken(other))
+		return &Token{AccessToken: "access1", IDToken: idToken, AccessTokenExpiry: time.Now().Add(1 * time.Hour)}, nil
+	}}
+	reuse, _ := NewReuseTokenSource(s.testCtx, nil, src, WithTokenCache(cache))
+
+	token, err := reuse.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access-other", token.AccessToken, "token saved concurrently should win")
+	cached, err := cache.Token()
+	s.Require().NoError(err)
+	s.Equal("access-other", cached.AccessToken)
+
+	// Failed refresh falls back to token refreshed meanwhile by other instance.
+	s.Require().NoError(cache.SaveToken(&Token{AccessToken: "access0", RefreshToken: "refresh0", IDToken: idToken, AccessTokenExpiry: time.Now().Add(-1 * time.Minute)}))
+	src.fn = func() (*Token, error) {
+		s.Require().NoError(cache.SaveToken(other))
+		return nil, errors.New("refresh token already used")
+	}
+	reuse, _ = NewReuseTokenSource(s.testCtx, nil, src, WithTokenCache(cache))
+	token, err = reuse.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access-other", token.AccessToken)
+}
@@ -0,0 +1,122 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoCachedToken is returned by CacheTokenSource when there is no valid token in the cache.
+var ErrNoCachedToken = errors.New("oidc: no valid cached token")
+
+// tokenSaver is implemented by token sources that can store tokens obtained by other sources, e.g CacheTokenSource.
+type tokenSaver interface {
+	SaveToken(t *Token) error
+}
+
+// ChainTokenSource returns a TokenSource that tries sources in order and returns token of the first one that
+// succeeds, standardizing "try cached, else refresh, else log in again" pattern, e.g:
+//
+//    oidc.ChainTokenSource(
+//        oidc.CacheTokenSource(cache, verifier),
+//        client.TokenSource(ctx, cfg, cachedToken),
+//        loginSource,
+//    )
+//
+// Token obtained from a later source is promoted to earlier sources that can store tokens (e.g CacheTokenSource), so
+// next call is served by them. Failing to promote does not fail the call. If all sources fail, returned error lists
+// errors of all of them. Verifier is the first non-nil verifier of sources.
+func ChainTokenSource(sources ...TokenSource) TokenSource {
+	return &chainTokenSource{sources: sources}
+}
+
+type chainTokenSource struct {
+	sources []TokenSource
+}
+
+// OIDCToken returns token of the first source that succeeds.
+func (c *chainTokenSource) OIDCToken() (*Token, error) {
+	return c.token(func(src TokenSource) (*Token, error) { return src.OIDCToken() })
+}
+
+// OIDCTokenCtx is like OIDCToken, but passes ctx to sources.
+func (c *chainTokenSource) OIDCTokenCtx(ctx context.Context) (*Token, error) {
+	return c.token(func(src TokenSource) (*Token, error) { return tokenCtx(ctx, src) })
+}
+
+func (c *chainTokenSource) token(get func(TokenSource) (*Token, error)) (*Token, error) {
+	if len(c.sources) == 0 {
+		return nil, errors.New("oidc: no token sources in chain")
+	}
+
+	errs := make([]string, 0, len(c.sources))
+	for i, src := range c.sources {
+		t, err := get(src)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("source %d: %v", i, err))
+			continue
+		}
+		for _, prev := range c.sources[:i] {
+			if saver, ok := prev.(tokenSaver); ok {
+				// Next call falls back to the same source again if this fails.
+				_ = saver.SaveToken(t)
+			}
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("oidc: all token sources failed: %s", strings.Join(errs, "; "))
+}
+
+// Verifier returns the first non-nil verifier of sources, or nil if none has one.
+func (c *chainTokenSource) Verifier() Verifier {
+	for _, src := range c.sources {
+		if v := src.Verifier(); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// CacheTokenSource returns a TokenSource that returns token from cache as long as it is valid according to verifier
+// (see Token.IsValidFor). Otherwise it returns ErrNoCachedToken. It never obtains new tokens, so it is meant to be
+// the first source of ChainTokenSource, which saves tokens obtained by later sources in the cache. If verifier is nil,
+// only access token expiry of cached token is checked.
+func CacheTokenSource(cache TokenCache, verifier Verifier) TokenSource {
+	return &cacheTokenSource{cache: cache, verifier: verifier}
+}
+
+type cacheTokenSource struct {
+	cache    TokenCache
+	verifier Verifier
+}
+
+// OIDCToken returns valid cached token.
+func (s *cacheTokenSource) OIDCToken() (*Token, error) {
+	return s.OIDCTokenCtx(context.Background())
+}
+
+// OIDCTokenCtx returns valid cached token, using ctx for its verification.
+func (s *cacheTokenSource) OIDCTokenCtx(ctx context.Context) (*Token, error) {
+	t, err := s.cache.Token()
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrNoCachedToken
+	}
+	if err := t.IsValidFor(ctx, s.verifier, tokenExpiryDelta); err != nil {
+		return nil, wrapErrorf(ErrNoCachedToken, "%v: %v", ErrNoCachedToken, err)
+	}
+	return t, nil
+}
+
+// SaveToken saves token in the cache.
+func (s *cacheTokenSource) SaveToken(t *Token) error {
+	return s.cache.SaveToken(t)
+}
+
+// Verifier returns verifier given on construction.
+func (s *cacheTokenSource) Verifier() Verifier {
+	return s.verifier
+}
@@ -0,0 +1,84 @@
+package oidc
+
+import (
+	"errors"
+	"time"
+)
+
+type failingTokenSource struct {
+	calls int
+}
+
+func (s *failingTokenSource) OIDCToken() (*Token, error) {
+	s.calls++
+	return nil, errors.New("login disabled")
+}
+
+func (s *failingTokenSource) Verifier() Verifier {
+	return nil
+}
+
+type memoryTokenCache struct {
+	t *Token
+}
+
+func (c *memoryTokenCache) Token() (*Token, error) {
+	return c.t, nil
+}
+
+func (c *memoryTokenCache) SaveToken(t *Token) error {
+	c.t = t
+	return nil
+}
+
+func (s *ClientTestSuite) TestChainTokenSource() {
+	idToken, jwkSetJSON := s.validIDToken()
+	verifier, err := NewStaticVerifier(exampleIssuer, jwkSetJSON, VerificationConfig{ClientID: "client1"})
+	s.Require().NoError(err)
+
+	cache := &memoryTokenCache{}
+	failing := &failingTokenSource{}
+	login := &countingTokenSource{idToken: idToken, expiry: 1 * time.Hour, verifier: verifier}
+	chain := ChainTokenSource(CacheTokenSource(cache, verifier), failing, login)
+	s.Equal(verifier, chain.Verifier())
+
+	// Nothing cached, refresh fails, so token is obtained by login and promoted to the cache.
+	token, err := chain.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+	s.Equal(token, cache.t)
+	s.Equal(1, failing.calls)
+
+	token, err = chain.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+	s.Equal(1, failing.calls, "cached token should be used")
+	s.Equal(int32(1), login.calls)
+
+	// Expired cached token is not used.
+	cache.t = &Token{AccessToken: "expired", IDToken: idToken, AccessTokenExpiry: time.Now().Add(-1 * time.Minute)}
+	token, err = chain.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken)
+
+	_, err = ChainTokenSource(CacheTokenSource(&memoryTokenCache{}, verifier), failing).OIDCToken()
+	s.Require().Error(err)
+	s.Contains(err.Error(), "source 0: "+ErrNoCachedToken.Error())
+	s.Contains(err.Error(), "source 1: login disabled")
+}
+
+func (s *ClientTestSuite) TestCacheTokenSource_NilVerifier() {
+	cache := &memoryTokenCache{t: &Token{AccessToken: "access1", AccessTokenExpiry: time.Now().Add(1 * time.Hour)}}
+	chain := ChainTokenSource(CacheTokenSource(cache, nil), &failingTokenSource{})
+	s.Nil(chain.Verifier())
+
+	// Without verifier, only access token expiry is checked.
+	token, err := chain.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+
+	cache.t = &Token{AccessToken: "expired", AccessTokenExpiry: time.Now().Add(-1 * time.Minute)}
+	_, err = chain.OIDCToken()
+	s.Require().Error(err)
+	s.Contains(err.Error(), ErrNoCachedToken.Error())
+}
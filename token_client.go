@@ -0,0 +1,36 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+)
+
+//go:generate mockery -name TokenClient -case underscore
+
+// TokenClient covers token operations of the Client. Depend on it instead of *Client to be able to substitute fakes
+// (e.g mocks.TokenClient) in tests.
+type TokenClient interface {
+	// Exchange converts an authorization code into a token.
+	Exchange(ctx context.Context, cfg Config, code string, extra ...url.Values) (*Token, error)
+	// Refresh obtains new token using refresh token.
+	Refresh(ctx context.Context, cfg Config, refreshToken string) (*Token, error)
+	// Revoke revokes access or refresh token.
+	Revoke(ctx context.Context, cfg Config, token string, tokenTypeHint ...string) error
+	// UserInfo queries the provider's user info endpoint using token from token source.
+	UserInfo(ctx context.Context, tokenSource TokenSource) (*UserInfo, error)
+	// TokenVerifier returns verifier of tokens issued by the provider.
+	TokenVerifier(cfg VerificationConfig) Verifier
+}
+
+var _ TokenClient = &Client{}
+
+// Refresh obtains new token using refresh token. Returned token is not verified.
+// Use TokenSource to refresh tokens only when needed.
+func (c *Client) Refresh(ctx context.Context, cfg Config, refreshToken string) (*Token, error) {
+	return NewTokenRefresher(ctx, c, cfg, refreshToken).OIDCToken()
+}
+
+// TokenVerifier is equivalent to Verifier, but returns Verifier interface to satisfy TokenClient.
+func (c *Client) TokenVerifier(cfg VerificationConfig) Verifier {
+	return c.Verifier(cfg)
+}
@@ -0,0 +1,29 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestRefresh() {
+	idToken, _ := s.validIDToken()
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access2",
+		RefreshToken: "refresh2",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+	})
+	s.NoError(err)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+
+	var c TokenClient = s.client
+	tk, err := c.Refresh(s.testCtx, Config{ClientID: "client1"}, "refresh1")
+	s.NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Equal("access2", tk.AccessToken)
+	s.Equal("refresh2", tk.RefreshToken)
+	s.Equal(idToken, tk.IDToken)
+}
@@ -0,0 +1,129 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Token type identifiers for token exchange. See https://tools.ietf.org/html/rfc8693#section-3.
+const (
+	TokenTypeAccessToken  = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeRefreshToken = "urn:ietf:params:oauth:token-type:refresh_token"
+	TokenTypeIDToken      = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeJWT          = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchangeOption sets parameter of the token exchange request built by Client.TokenExchange.
+type TokenExchangeOption func(v url.Values)
+
+// WithAudience adds audience parameter for each given logical name of the target service.
+func WithAudience(audiences ...string) TokenExchangeOption {
+	return func(v url.Values) {
+		for _, a := range audiences {
+			v.Add("audience", a)
+		}
+	}
+}
+
+// WithExchangeResource adds resource parameter for each given URI of the target service.
+func WithExchangeResource(resources ...string) TokenExchangeOption {
+	return func(v url.Values) {
+		for _, r := range resources {
+			v.Add("resource", r)
+		}
+	}
+}
+
+// WithExchangeScopes sets scopes requested for the exchanged token.
+func WithExchangeScopes(scopes ...string) TokenExchangeOption {
+	return func(v url.Values) {
+		v.Set("scope", strings.Join(scopes, " "))
+	}
+}
+
+// WithRequestedTokenType sets type of the requested token e.g TokenTypeIDToken.
+func WithRequestedTokenType(tokenType string) TokenExchangeOption {
+	return func(v url.Values) {
+		v.Set("requested_token_type", tokenType)
+	}
+}
+
+// WithSubjectTokenType sets type of the subject token. It is TokenTypeAccessToken by default.
+func WithSubjectTokenType(tokenType string) TokenExchangeOption {
+	return func(v url.Values) {
+		v.Set("subject_token_type", tokenType)
+	}
+}
+
+// WithActorToken sets token of the party acting on behalf of the subject, for delegation.
+func WithActorToken(actorToken string, tokenType string) TokenExchangeOption {
+	return func(v url.Values) {
+		v.Set("actor_token", actorToken)
+		v.Set("actor_token_type", tokenType)
+	}
+}
+
+// TokenExchange exchanges subject token (access token by default, see WithSubjectTokenType) for a token for another
+// audience or with different scopes, using token exchange (see https://tools.ietf.org/html/rfc8693). It is supported
+// e.g by Keycloak and STS-style providers, for service-to-service impersonation and delegation.
+// Returned token is not verified.
+func (c *Client) TokenExchange(ctx context.Context, cfg Config, subjectToken string, opts ...TokenExchangeOption) (*Token, error) {
+	v := url.Values{
+		"grant_type":         {GrantTypeTokenExchange},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {TokenTypeAccessToken},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	t, err := c.token(ctx, cfg, v)
+	c.audit(AuditTokenExchange, cfg.ClientID, tokenSubject(t), err)
+	return t, err
+}
+
+// ExchangeTokenSource returns a TokenSource that exchanges access tokens of the base TokenSource for tokens for
+// given target audience. Use one per target audience. Exchanged tokens are reused until they expire. They usually
+// have no ID token, so unlike other token sources, it does not verify ID token and returned tokens should not be
+// checked with Token.IsValid.
+func (c *Client) ExchangeTokenSource(ctx context.Context, cfg Config, base TokenSource, audience string, opts ...TokenExchangeOption) TokenSource {
+	return newAccessTokenReuseSource(&exchangingTokenSource{
+		ctx:    ctx,
+		client: c,
+		cfg:    cfg,
+		base:   base,
+		opts:   append([]TokenExchangeOption{WithAudience(audience)}, opts...),
+	})
+}
+
+type exchangingTokenSource struct {
+	ctx    context.Context
+	client *Client
+	cfg    Config
+	base   TokenSource
+	opts   []TokenExchangeOption
+}
+
+// OIDCToken exchanges current base token.
+func (s *exchangingTokenSource) OIDCToken() (*Token, error) {
+	base, err := s.base.OIDCToken()
+	if err != nil {
+		return nil, err
+	}
+	return s.client.TokenExchange(s.ctx, s.cfg, base.AccessToken, s.opts...)
+}
+
+// OIDCTokenCtx exchanges current base token using ctx.
+func (s *exchangingTokenSource) OIDCTokenCtx(ctx context.Context) (*Token, error) {
+	base, err := tokenCtx(ctx, s.base)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.TokenExchange(ctx, s.cfg, base.AccessToken, s.opts...)
+}
+
+// Verifier returns verifier of the base token source.
+func (s *exchangingTokenSource) Verifier() Verifier {
+	return s.base.Verifier()
+}
@@ -0,0 +1,72 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestTokenExchange() {
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken: "exchanged1",
+		TokenType:   "Bearer",
+	})
+	s.NoError(err)
+
+	var form url.Values
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.NoError(r.ParseForm())
+		form = r.PostForm
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	tk, err := s.client.TokenExchange(s.testCtx, Config{ClientID: "client1"}, "access1",
+		WithAudience("service1"),
+		WithExchangeResource("https://service1.org"),
+		WithRequestedTokenType(TokenTypeJWT),
+	)
+	s.NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Equal(GrantTypeTokenExchange, form.Get("grant_type"))
+	s.Equal("access1", form.Get("subject_token"))
+	s.Equal(TokenTypeAccessToken, form.Get("subject_token_type"))
+	s.Equal("service1", form.Get("audience"))
+	s.Equal("https://service1.org", form.Get("resource"))
+	s.Equal(TokenTypeJWT, form.Get("requested_token_type"))
+	s.Equal("exchanged1", tk.AccessToken)
+}
+
+func (s *ClientTestSuite) TestExchangeTokenSource() {
+	base := StaticTokenSource(&Token{AccessToken: "access1"})
+
+	resp := TokenResponse{
+		AccessToken: "exchanged1",
+		TokenType:   "Bearer",
+	}
+	resp.SetExpiry(time.Now().Add(1 * time.Hour))
+	tokenJSON, err := json.Marshal(resp)
+	s.NoError(err)
+
+	var audience string
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.NoError(r.ParseForm())
+		audience = r.PostForm.Get("audience")
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+
+	src := s.client.ExchangeTokenSource(s.testCtx, Config{ClientID: "client1"}, base, "service1")
+	tk, err := src.OIDCToken()
+	s.NoError(err)
+	s.Equal("exchanged1", tk.AccessToken)
+	s.Equal("service1", audience)
+
+	// Exchanged token is reused.
+	tk, err = src.OIDCToken()
+	s.NoError(err)
+	s.Equal("exchanged1", tk.AccessToken)
+	s.Equal(0, s.s.Len())
+}
@@ -3,11 +3,12 @@ package oidc
 import (
 	"context"
 	"errors"
-	"io/ioutil"
+	"fmt"
 	"log"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 )
 
 //go:generate mockery -name TokenSource -case underscore
@@ -20,6 +21,49 @@ type TokenSource interface {
 	Verifier() Verifier
 }
 
+// TokenSourceCtx is a TokenSource that can use context of the caller for requests made to obtain the token, so
+// caller's cancellation and deadline apply to them. All token sources of this package implement it.
+type TokenSourceCtx interface {
+	TokenSource
+	// OIDCTokenCtx is like OIDCToken, but uses given ctx instead of context given on construction of the source.
+	OIDCTokenCtx(ctx context.Context) (*Token, error)
+}
+
+// NewTokenSourceCtx adapts TokenSource to TokenSourceCtx. If src implements TokenSourceCtx, it is returned as it is.
+// Otherwise ctx can't reach requests made by src, so returned source only checks if ctx is done before calling
+// OIDCToken.
+func NewTokenSourceCtx(src TokenSource) TokenSourceCtx {
+	if s, ok := src.(TokenSourceCtx); ok {
+		return s
+	}
+	return tokenSourceCtxAdapter{TokenSource: src}
+}
+
+type tokenSourceCtxAdapter struct {
+	TokenSource
+}
+
+// OIDCTokenCtx returns token from underlying source, unless ctx is done.
+func (a tokenSourceCtxAdapter) OIDCTokenCtx(ctx context.Context) (*Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.OIDCToken()
+}
+
+// tokenCtx returns token from src using ctx if src supports it.
+func tokenCtx(ctx context.Context, src TokenSource) (*Token, error) {
+	return NewTokenSourceCtx(src).OIDCTokenCtx(ctx)
+}
+
+// TokenInvalidator is implemented by token sources that keep tokens on their own, e.g in a cache, so they can be asked
+// to drop token that was rejected by the resource server, even though it seems valid locally.
+type TokenInvalidator interface {
+	// InvalidateToken drops given token if it is still the current one, so next call obtains new one. If t is nil,
+	// current token is dropped.
+	InvalidateToken(t *Token) error
+}
+
 // ReuseTokenSource is a oidc TokenSource that holds a single token in memory
 // and validates its expiry before each call to retrieve it with
 // Token. If it's expired, it will be auto-refreshed using the
@@ -27,62 +71,275 @@ type TokenSource interface {
 type ReuseTokenSource struct {
 	ctx context.Context // ctx for HTTP requests.
 
-	new TokenSource // called when t is expired.
-	mu  sync.Mutex  // guards t
-	t   *Token
+	new        TokenSource // called when t is expired.
+	mu         sync.Mutex  // guards t, refreshing and invalidated
+	t          *Token
+	refreshing *refreshCall // in-flight refresh, if any.
+	// invalidated is access token of the last invalidated token, so its copy in cache is not reused.
+	invalidated string
+
+	// logger logs why token was not reused and cache failures.
+	logger Logger
 
-	// Optional std logger for debug log. The only case which will be logged is why OIDC token was invalid.
-	debugLogger *log.Logger
+	// minValidity is minimum time access token needs to be still valid to be returned.
+	minValidity time.Duration
+
+	// cache if not nil, persists tokens between processes.
+	cache TokenCache
+	// onNewToken if not nil, is called with every new token obtained from the underlying source.
+	onNewToken func(*Token)
+
+	// refreshBefore if not zero, is how long before expiry token is refreshed in the background between Start and Stop.
+	refreshBefore time.Duration
+	lifecycleMu   sync.Mutex
+	stop          chan struct{}
+	stopped       chan struct{}
+}
+
+// ReuseTokenSourceOption configures optional behavior of ReuseTokenSource.
+type ReuseTokenSourceOption func(*ReuseTokenSource)
+
+// WithMinRemainingValidity makes ReuseTokenSource refuse to return access tokens that expire in less than minValidity.
+// Such cached token is refreshed and if new token does not satisfy it as well, error is returned.
+func WithMinRemainingValidity(minValidity time.Duration) ReuseTokenSourceOption {
+	return func(s *ReuseTokenSource) {
+		s.minValidity = minValidity
+	}
+}
+
+// WithTokenCache makes ReuseTokenSource read token from cache when it has no valid token, before obtaining new one,
+// and save every new token to it. If underlying source is TokenRefresher, refresh token of cached token is used for
+// refresh, so tokens refreshed by other processes sharing the cache are picked up. Cache errors are only logged.
+func WithTokenCache(cache TokenCache) ReuseTokenSourceOption {
+	return func(s *ReuseTokenSource) {
+		s.cache = cache
+	}
+}
+
+// WithOnNewToken sets hook called with every new token obtained from the underlying source (e.g by refresh), after it
+// is saved in TokenCache, if any. Applications can use it to persist tokens, update metrics or rotate downstream
+// credentials. It is not called for tokens reused from memory or read from TokenCache. Concurrent callers wait for
+// the hook to return, so it should be quick and must not use the token source.
+func WithOnNewToken(fn func(t *Token)) ReuseTokenSourceOption {
+	return func(s *ReuseTokenSource) {
+		s.onNewToken = fn
+	}
 }
 
 // NewReuseTokenSource returns a TokenSource which repeatedly returns the
 // same token as long as it's valid, starting with t.
 // As a second argument it returns reset function that enables to reset h
 // When its cached token is invalid, a new token is obtained from source.
-func NewReuseTokenSource(ctx context.Context, t *Token, src TokenSource) (ret TokenSource, clearIDToken func()) {
+// If src is TokenRefresher without refresh token, refresh token of t is used, so token restored from JSON (see
+// Token.MarshalJSON) can be passed together with NewTokenRefresher(ctx, client, cfg, "").
+func NewReuseTokenSource(ctx context.Context, t *Token, src TokenSource, opts ...ReuseTokenSourceOption) (ret TokenSource, clearIDToken func()) {
 	s := &ReuseTokenSource{
 		ctx:         ctx,
 		t:           t,
 		new:         src,
-		debugLogger: log.New(ioutil.Discard, "", 0),
+		logger:      nopLogger{},
+		minValidity: tokenExpiryDelta,
+	}
+	if r, ok := src.(*TokenRefresher); ok && t != nil && r.refreshToken == "" {
+		r.refreshToken = t.RefreshToken
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	return s, s.reset
 }
 
-// NewReuseTokenSourceWithDebugLogger is the same as NewReuseTokenSource but with logger.
-func NewReuseTokenSourceWithDebugLogger(ctx context.Context, debugLogger *log.Logger, t *Token, src TokenSource) (ret TokenSource, clearIDToken func()) {
-	s := &ReuseTokenSource{
-		ctx:         ctx,
-		t:           t,
-		new:         src,
-		debugLogger: debugLogger,
-	}
-	return s, s.reset
+// NewReuseTokenSourceWithDebugLogger is the same as NewReuseTokenSource but with std logger. See
+// WithTokenSourceLogger for structured loggers.
+func NewReuseTokenSourceWithDebugLogger(ctx context.Context, debugLogger *log.Logger, t *Token, src TokenSource, opts ...ReuseTokenSourceOption) (ret TokenSource, clearIDToken func()) {
+	return NewReuseTokenSource(ctx, t, src, append([]ReuseTokenSourceOption{WithTokenSourceLogger(NewStdLogger(debugLogger))}, opts...)...)
 }
 
 // OIDCToken returns the current token if it's still valid, else will
 // refresh the current token (using r.Context for HTTP client
 // information) and return the new one.
 func (s *ReuseTokenSource) OIDCToken() (*Token, error) {
+	return s.token(s.ctx, s.new.OIDCToken, s.minValidity)
+}
+
+// OIDCTokenCtx is like OIDCToken, but uses ctx for verification and passes it to the underlying source if it
+// implements TokenSourceCtx.
+func (s *ReuseTokenSource) OIDCTokenCtx(ctx context.Context) (*Token, error) {
+	return s.token(ctx, func() (*Token, error) { return tokenCtx(ctx, s.new) }, s.minValidity)
+}
+
+// refreshCall is a refresh shared by all callers that need new token at the same time.
+type refreshCall struct {
+	done chan struct{}
+	t    *Token
+	err  error
+}
+
+// token returns the current token if it's still valid for at least minValidity, else obtains new one with newToken.
+// Concurrent callers that need new token share single refresh and all get its result. Callers waiting for refresh
+// started by other caller stop waiting when their ctx is done; the refresh itself is limited by ctx of the caller that
+// started it.
+func (s *ReuseTokenSource) token(ctx context.Context, newToken func() (*Token, error), minValidity time.Duration) (*Token, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.t != nil {
-		err := s.t.IsValid(s.ctx, s.Verifier())
-		if err == nil {
-			return s.t, nil
+	call := s.refreshing
+	if call == nil {
+		if s.t != nil {
+			err := s.t.IsValidFor(ctx, s.Verifier(), minValidity)
+			if err == nil {
+				t := s.t
+				s.mu.Unlock()
+				return t, nil
+			}
+			s.logger.Debug("reuseTokenSource: Token not valid. Obtaining new one.", "cause", err)
+		} else {
+			s.logger.Debug("reuseTokenSource: No token to reuse. Obtaining new one.")
+		}
+		call = &refreshCall{done: make(chan struct{})}
+		s.refreshing = call
+		s.mu.Unlock()
+
+		call.t, call.err = s.refresh(ctx, newToken, minValidity)
+
+		s.mu.Lock()
+		if call.err == nil {
+			s.t = call.t
 		}
-		s.debugLogger.Printf("reuseTokenSource: Token not valid. Obtaining new one. Cause: %v\n", err)
-	} else {
-		s.debugLogger.Println("reuseTokenSource: No token to reuse. Obtaining new one")
+		s.refreshing = nil
+		s.mu.Unlock()
+		close(call.done)
+		return call.t, call.err
 	}
-	t, err := s.new.OIDCToken()
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.t, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refresh obtains new token from cache or with newToken. Only one refresh runs at a time.
+func (s *ReuseTokenSource) refresh(ctx context.Context, newToken func() (*Token, error), minValidity time.Duration) (*Token, error) {
+	cached, ok := s.cachedToken(ctx, minValidity)
+	if ok {
+		return cached, nil
+	}
+
+	t, err := newToken()
 	if err != nil {
+		// Other process sharing the cache might have refreshed the token meanwhile, e.g invalidating our refresh token.
+		if s.cache != nil {
+			if current, ok := s.cachedToken(ctx, minValidity); ok {
+				return current, nil
+			}
+		}
 		return nil, err
 	}
-	s.t = t
+	if s.minValidity > tokenExpiryDelta && t.IsAccessTokenExpiringWithin(s.minValidity) {
+		return nil, fmt.Errorf("reuseTokenSource: new AccessToken expires in less than required %v", s.minValidity)
+	}
+	if s.cache != nil {
+		t = s.saveToken(ctx, cached, t, minValidity)
+	}
+	if s.onNewToken != nil {
+		s.onNewToken(t)
+	}
 	return t, nil
 }
 
+// saveToken saves new token t in cache. If cache is ConditionalTokenCache and other process saved another token since
+// old was read, that token is returned instead if it is valid, so all processes sharing the cache use the same token.
+func (s *ReuseTokenSource) saveToken(ctx context.Context, old *Token, t *Token, minValidity time.Duration) *Token {
+	cc, ok := s.cache.(ConditionalTokenCache)
+	if !ok {
+		if err := s.cache.SaveToken(t); err != nil {
+			s.logger.Warn("reuseTokenSource: Failed to cache token.", "err", err)
+		}
+		return t
+	}
+
+	saved, current, err := cc.SaveTokenIf(old, t)
+	if err != nil {
+		s.logger.Warn("reuseTokenSource: Failed to cache token.", "err", err)
+		return t
+	}
+	if saved || current == nil {
+		return t
+	}
+	if err := current.IsValidFor(ctx, s.Verifier(), minValidity); err != nil {
+		s.logger.Debug("reuseTokenSource: Token cached concurrently is not valid, keeping new one.", "cause", err)
+		return t
+	}
+	s.logger.Debug("reuseTokenSource: Token was refreshed concurrently by other process. Using cached one.")
+	return current
+}
+
+// cachedToken returns token from cache and whether it is valid for at least minValidity. If it is not valid, refresh
+// token of cached token is passed to the underlying source, since it may be newer than the one the source has.
+func (s *ReuseTokenSource) cachedToken(ctx context.Context, minValidity time.Duration) (*Token, bool) {
+	if s.cache == nil {
+		return nil, false
+	}
+	t, err := s.cache.Token()
+	if err != nil {
+		s.logger.Warn("reuseTokenSource: Failed to read cached token.", "err", err)
+		return nil, false
+	}
+	if t == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	invalidated := s.invalidated != "" && s.invalidated == t.AccessToken
+	s.mu.Unlock()
+
+	err = t.IsValidFor(ctx, s.Verifier(), minValidity)
+	if err == nil && invalidated {
+		err = errors.New("token was invalidated")
+	}
+	if err != nil {
+		s.logger.Debug("reuseTokenSource: Cached token not valid.", "cause", err)
+		if r, ok := s.new.(*TokenRefresher); ok && t.RefreshToken != "" {
+			r.refreshToken = t.RefreshToken
+		}
+		return t, false
+	}
+	return t, true
+}
+
+// InvalidateToken drops token rejected by the resource server (e.g with 401), so next call obtains new one, even if
+// the token seems valid locally. If rejected is not the current token anymore (e.g other goroutine that got the same
+// rejection already replaced it), nothing happens, so concurrent rejections cause single refresh. If rejected is nil,
+// current token is dropped. Underlying source implementing TokenInvalidator is asked to drop the token as well.
+func (s *ReuseTokenSource) InvalidateToken(rejected *Token) error {
+	s.mu.Lock()
+	if s.t != nil && rejected != nil && s.t.AccessToken != rejected.AccessToken {
+		s.mu.Unlock()
+		return nil
+	}
+	if rejected == nil {
+		rejected = s.t
+	}
+	s.t = nil
+	if rejected != nil {
+		s.invalidated = rejected.AccessToken
+	}
+	s.mu.Unlock()
+
+	if inv, ok := s.new.(TokenInvalidator); ok {
+		return inv.InvalidateToken(rejected)
+	}
+	return nil
+}
+
+// ForceRefresh drops current token and obtains new one, using ctx like OIDCTokenCtx.
+func (s *ReuseTokenSource) ForceRefresh(ctx context.Context) (*Token, error) {
+	if err := s.InvalidateToken(nil); err != nil {
+		return nil, err
+	}
+	return s.OIDCTokenCtx(ctx)
+}
+
 // Verifier returns verifier from underlying token source.
 func (s *ReuseTokenSource) Verifier() Verifier {
 	return s.new.Verifier()
@@ -122,8 +379,13 @@ func NewTokenRefresher(ctx context.Context, client *Client, cfg Config, refreshT
 // synchronizes calls to this method with its own mutex.
 // NOTE: Returned token is not verified.
 func (tf *TokenRefresher) OIDCToken() (*Token, error) {
+	return tf.OIDCTokenCtx(tf.ctx)
+}
+
+// OIDCTokenCtx is like OIDCToken, but uses ctx for the refresh request.
+func (tf *TokenRefresher) OIDCTokenCtx(ctx context.Context) (*Token, error) {
 	if tf.refreshToken == "" {
-		return nil, errors.New("oauth2: token expired and refresh token is not set")
+		return nil, ErrNoRefreshToken
 	}
 
 	v := url.Values{
@@ -135,7 +397,10 @@ func (tf *TokenRefresher) OIDCToken() (*Token, error) {
 		v.Set("scope", strings.Join(tf.cfg.Scopes, " "))
 	}
 
-	tk, err := tf.client.token(tf.ctx, tf.cfg.ClientID, tf.cfg.ClientSecret, v)
+	tk, err := tf.cfg.RefreshRetry.retry(ctx, tf.client.retryBudget, tf.client.opts.log(), func() (*Token, error) {
+		return tf.client.token(ctx, tf.cfg, v)
+	})
+	tf.client.audit(AuditRefresh, tf.cfg.ClientID, tokenSubject(tk), err)
 	if err != nil {
 		return nil, err
 	}
@@ -152,16 +417,61 @@ func (tf *TokenRefresher) Verifier() Verifier {
 	return tf.client.Verifier(VerificationConfig{ClientID: tf.cfg.ClientID})
 }
 
+// accessTokenReuseSource is like ReuseTokenSource, but reuses token as long as its access token is not expired. It is
+// meant for tokens that have no ID token, e.g exchanged ones.
+type accessTokenReuseSource struct {
+	new TokenSource
+
+	mu sync.Mutex
+	t  *Token
+}
+
+func newAccessTokenReuseSource(src TokenSource) *accessTokenReuseSource {
+	return &accessTokenReuseSource{new: src}
+}
+
+// OIDCToken returns cached token or obtains new one if access token of cached one expired.
+func (s *accessTokenReuseSource) OIDCToken() (*Token, error) {
+	return s.token(s.new.OIDCToken)
+}
+
+// OIDCTokenCtx is like OIDCToken, but passes ctx to underlying source.
+func (s *accessTokenReuseSource) OIDCTokenCtx(ctx context.Context) (*Token, error) {
+	return s.token(func() (*Token, error) { return tokenCtx(ctx, s.new) })
+}
+
+// token returns cached token or obtains new one with newToken.
+func (s *accessTokenReuseSource) token(newToken func() (*Token, error)) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.t != nil && s.t.AccessToken != "" && !s.t.IsAccessTokenExpired() {
+		return s.t, nil
+	}
+	t, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	s.t = t
+	return t, nil
+}
+
+// Verifier returns verifier from underlying token source.
+func (s *accessTokenReuseSource) Verifier() Verifier {
+	return s.new.Verifier()
+}
+
 // StaticTokenSource returns a TokenSource that always returns the same token.
 // Because the provided token t is never refreshed, StaticTokenSource is only
 // useful for tokens that never expire.
 func StaticTokenSource(t *Token) TokenSource {
-	return staticTokenSource{t}
+	return staticTokenSource{t: t}
 }
 
 // staticTokenSource is a TokenSource that always returns the same Token.
 type staticTokenSource struct {
-	t *Token
+	t        *Token
+	verifier Verifier
 }
 
 // OIDCToken returns saved pointer to token.
@@ -169,7 +479,12 @@ func (s staticTokenSource) OIDCToken() (*Token, error) {
 	return s.t, nil
 }
 
-// Verifier returns nil, since it is static.
+// OIDCTokenCtx returns saved pointer to token.
+func (s staticTokenSource) OIDCTokenCtx(_ context.Context) (*Token, error) {
+	return s.t, nil
+}
+
+// Verifier returns verifier given on construction. It is nil for StaticTokenSource, since it is static.
 func (s staticTokenSource) Verifier() Verifier {
-	return nil
+	return s.verifier
 }
@@ -0,0 +1,58 @@
+//go:build go1.18
+// +build go1.18
+
+package oidc
+
+import (
+	"context"
+	"errors"
+)
+
+// TokenSourceT wraps TokenSource to return token together with claims of its ID token verified with the source's
+// Verifier and decoded into the caller's type T.
+type TokenSourceT[T any] struct {
+	ctx context.Context
+	src TokenSource
+}
+
+// NewTokenSourceT constructs TokenSourceT. Given context is used for ID token verification.
+func NewTokenSourceT[T any](ctx context.Context, src TokenSource) *TokenSourceT[T] {
+	return &TokenSourceT[T]{ctx: ctx, src: src}
+}
+
+// OIDCToken returns token from the underlying TokenSource and its verified ID token claims.
+// It is safe for concurrent use if the underlying TokenSource is.
+func (s *TokenSourceT[T]) OIDCToken() (*Token, T, error) {
+	return s.token(s.ctx, s.src.OIDCToken)
+}
+
+// OIDCTokenCtx is like OIDCToken, but uses ctx for ID token verification and passes it to the underlying source if it
+// implements TokenSourceCtx.
+func (s *TokenSourceT[T]) OIDCTokenCtx(ctx context.Context) (*Token, T, error) {
+	return s.token(ctx, func() (*Token, error) { return tokenCtx(ctx, s.src) })
+}
+
+func (s *TokenSourceT[T]) token(ctx context.Context, newToken func() (*Token, error)) (*Token, T, error) {
+	var claims T
+
+	token, err := newToken()
+	if err != nil {
+		return nil, claims, err
+	}
+
+	verifier := s.src.Verifier()
+	if verifier == nil {
+		return nil, claims, errors.New("oidc: token source has no verifier, cannot verify claims")
+	}
+
+	claims, err = ClaimsAs[T](ctx, verifier, *token)
+	if err != nil {
+		return nil, claims, err
+	}
+	return token, claims, nil
+}
+
+// TokenSource returns the underlying TokenSource.
+func (s *TokenSourceT[T]) TokenSource() TokenSource {
+	return s.src
+}
@@ -0,0 +1,48 @@
+//go:build go1.18
+// +build go1.18
+
+package oidc
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+type testTokenSource struct {
+	token    *Token
+	verifier Verifier
+}
+
+func (s *testTokenSource) OIDCToken() (*Token, error) {
+	return s.token, nil
+}
+
+func (s *testTokenSource) Verifier() Verifier {
+	return s.verifier
+}
+
+func (s *ClientTestSuite) TestTokenSourceT() {
+	idToken, jwkSetJSON := s.validIDToken()
+	token := &Token{
+		AccessToken:       "access1",
+		IDToken:           idToken,
+		AccessTokenExpiry: time.Now().Add(1 * time.Hour),
+	}
+
+	src := NewTokenSourceT[testClaims](s.testCtx, &testTokenSource{
+		token:    token,
+		verifier: s.client.Verifier(VerificationConfig{ClientID: "client1"}),
+	})
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	gotToken, claims, err := src.OIDCToken()
+	s.NoError(err)
+	s.Equal(token, gotToken)
+	s.Equal(testClaims{Subject: "subject1", Nonce: "nonce1"}, claims)
+	s.Equal(0, s.s.Len())
+
+	_, _, err = NewTokenSourceT[testClaims](s.testCtx, StaticTokenSource(token)).OIDCToken()
+	s.Error(err, "static token source has no verifier")
+}
@@ -0,0 +1,222 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+type testCtxKey struct{}
+
+func (s *ClientTestSuite) TestReuseTokenSource_OIDCTokenCtx() {
+	idToken, jwkSetJSON := s.validIDToken()
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access2",
+		RefreshToken: "refresh2",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    expirationTime(3600),
+	})
+	s.Require().NoError(err)
+
+	src := s.client.TokenSource(s.testCtx, Config{ClientID: "client1"}, &Token{RefreshToken: "refresh1"})
+	ctxSrc, ok := src.(TokenSourceCtx)
+	s.Require().True(ok)
+
+	ctx := context.WithValue(s.testCtx, testCtxKey{}, "caller")
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		// Refresh request uses caller's context, not the one given on construction.
+		s.Equal("caller", r.Context().Value(testCtxKey{}))
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+	token, err := ctxSrc.OIDCTokenCtx(ctx)
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken)
+	s.Equal(0, s.s.Len())
+
+	// Valid token is reused.
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	token, err = ctxSrc.OIDCTokenCtx(ctx)
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken)
+	s.Equal(0, s.s.Len())
+}
+
+type noCtxTokenSource struct {
+	calls int
+}
+
+func (s *noCtxTokenSource) OIDCToken() (*Token, error) {
+	s.calls++
+	return &Token{AccessToken: "access1"}, nil
+}
+
+func (s *noCtxTokenSource) Verifier() Verifier {
+	return nil
+}
+
+func (s *ClientTestSuite) TestNewTokenSourceCtx() {
+	static := StaticTokenSource(&Token{AccessToken: "access1"})
+	s.Equal(static, NewTokenSourceCtx(static))
+
+	src := &noCtxTokenSource{}
+	adapted := NewTokenSourceCtx(src)
+
+	token, err := adapted.OIDCTokenCtx(context.Background())
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+	s.Equal(1, src.calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = adapted.OIDCTokenCtx(ctx)
+	s.Equal(context.Canceled, err)
+	s.Equal(1, src.calls)
+}
+
+type blockingTokenSource struct {
+	calls    int32
+	release  chan struct{}
+	token    *Token
+	verifier Verifier
+}
+
+func (s *blockingTokenSource) OIDCToken() (*Token, error) {
+	atomic.AddInt32(&s.calls, 1)
+	<-s.release
+	return s.token, nil
+}
+
+func (s *blockingTokenSource) Verifier() Verifier {
+	return s.verifier
+}
+
+func (s *ClientTestSuite) TestReuseTokenSource_ConcurrentRefresh() {
+	idToken, jwkSetJSON := s.validIDToken()
+	verifier, err := NewStaticVerifier(exampleIssuer, jwkSetJSON, VerificationConfig{ClientID: "client1"})
+	s.Require().NoError(err)
+
+	src := &blockingTokenSource{
+		release:  make(chan struct{}),
+		token:    &Token{AccessToken: "access1", IDToken: idToken, AccessTokenExpiry: time.Now().Add(1 * time.Hour)},
+		verifier: verifier,
+	}
+	reuse, _ := NewReuseTokenSource(s.testCtx, nil, src)
+
+	var wg sync.WaitGroup
+	tokens := make([]*Token, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			t, err := reuse.OIDCToken()
+			s.NoError(err)
+			tokens[i] = t
+		}(i)
+	}
+	for atomic.LoadInt32(&src.calls) == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	// Callers waiting for the refresh can give up.
+	ctx, cancel := context.WithCancel(s.testCtx)
+	cancel()
+	_, err = reuse.(TokenSourceCtx).OIDCTokenCtx(ctx)
+	s.Equal(context.Canceled, err)
+
+	time.Sleep(50 * time.Millisecond)
+	close(src.release)
+	wg.Wait()
+
+	s.Equal(int32(1), atomic.LoadInt32(&src.calls), "concurrent callers should share single refresh")
+	for _, t := range tokens {
+		s.Equal(src.token, t)
+	}
+}
+
+func (s *ClientTestSuite) TestReuseTokenSource_OnNewToken() {
+	idToken, jwkSetJSON := s.validIDToken()
+	verifier, err := NewStaticVerifier(exampleIssuer, jwkSetJSON, VerificationConfig{ClientID: "client1"})
+	s.Require().NoError(err)
+
+	var newTokens []string
+	src := &countingTokenSource{idToken: idToken, expiry: 1 * time.Hour, verifier: verifier}
+	reuse, reset := NewReuseTokenSource(s.testCtx, nil, src, WithOnNewToken(func(t *Token) {
+		newTokens = append(newTokens, t.AccessToken)
+	}))
+
+	for i := 0; i < 2; i++ {
+		token, err := reuse.OIDCToken()
+		s.Require().NoError(err)
+		s.Equal("access1", token.AccessToken)
+	}
+	s.Equal([]string{"access1"}, newTokens, "reused token is not new")
+
+	reset()
+	_, err = reuse.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal([]string{"access1", "access2"}, newTokens)
+}
+
+func (s *ClientTestSuite) TestReuseTokenSource_InvalidateToken() {
+	idToken, jwkSetJSON := s.validIDToken()
+	verifier, err := NewStaticVerifier(exampleIssuer, jwkSetJSON, VerificationConfig{ClientID: "client1"})
+	s.Require().NoError(err)
+
+	src := &countingTokenSource{idToken: idToken, expiry: 1 * time.Hour, verifier: verifier}
+	ret, _ := NewReuseTokenSource(s.testCtx, nil, src)
+	reuse := ret.(*ReuseTokenSource)
+
+	token1, err := reuse.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access1", token1.AccessToken)
+
+	token2, err := reuse.ForceRefresh(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal("access2", token2.AccessToken)
+
+	// Rejection of the old token does not drop the new one.
+	s.Require().NoError(reuse.InvalidateToken(token1))
+	token, err := reuse.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken)
+
+	s.Require().NoError(reuse.InvalidateToken(token2))
+	token, err = reuse.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access3", token.AccessToken)
+}
+
+func (s *ClientTestSuite) TestReuseTokenSource_InvalidateToken_TokenCache() {
+	dir, err := ioutil.TempDir("", "oidc-token-cache")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+
+	idToken, jwkSetJSON := s.validIDToken()
+	verifier, err := NewStaticVerifier(exampleIssuer, jwkSetJSON, VerificationConfig{ClientID: "client1"})
+	s.Require().NoError(err)
+
+	src := &countingTokenSource{idToken: idToken, expiry: 1 * time.Hour, verifier: verifier}
+	cache := NewFileTokenCache(filepath.Join(dir, "token"))
+	ret, _ := NewReuseTokenSource(s.testCtx, nil, src, WithTokenCache(cache))
+	reuse := ret.(*ReuseTokenSource)
+
+	_, err = reuse.OIDCToken()
+	s.Require().NoError(err)
+
+	// Invalidated token is not reused from cache, even though it seems valid.
+	token, err := reuse.ForceRefresh(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken)
+	cached, err := cache.Token()
+	s.Require().NoError(err)
+	s.Equal("access2", cached.AccessToken)
+}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"testing"
 	"time"
 
 	"github.com/Bplotka/go-httpt/rt"
 	"github.com/Bplotka/go-jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/square/go-jose.v2"
 )
 
@@ -87,6 +90,221 @@ func (s *ClientTestSuite) TestToken_SetAuthHeader() {
 		AccessToken: "access1",
 	}
 	r := httptest.NewRequest("GET", "http://127.0.0.1/something", nil)
-	token.SetAuthHeader(r)
+	s.Require().NoError(token.SetAuthHeader(r))
 	s.Equal("Bearer access1", r.Header.Get("Authorization"))
+
+	token.TokenType = "bearer"
+	s.Require().NoError(token.SetAuthHeader(r))
+	s.Equal("Bearer access1", r.Header.Get("Authorization"))
+
+	token.TokenType = "dpop"
+	s.Require().NoError(token.SetAuthHeader(r))
+	s.Equal("DPoP access1", r.Header.Get("Authorization"))
+
+	token.TokenType = "mac"
+	r = httptest.NewRequest("GET", "http://127.0.0.1/something", nil)
+	s.Error(token.SetAuthHeader(r))
+	s.Empty(r.Header.Get("Authorization"))
+}
+
+func (s *ClientTestSuite) TestToken_Extra() {
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{
+		"access_token": "access1",
+		"token_type": "Bearer",
+		"expires_in": 3600,
+		"scope": "openid email",
+		"id_token_expires_in": 600,
+		"tenant": {"id": "tenant1"}
+	}`)))
+	token, err := s.client.Exchange(s.testCtx, Config{ClientID: "client1"}, "code1")
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+
+	s.Equal("openid email", token.Extra("scope"))
+	s.Equal(600.0, token.Extra("id_token_expires_in"))
+	s.Nil(token.Extra("expires_in"), "fields parsed into token fields are not kept raw")
+	s.Nil(token.Extra("unknown"))
+
+	var tenant struct {
+		ID string `json:"id"`
+	}
+	s.Require().NoError(json.Unmarshal(token.RawExtra("tenant"), &tenant))
+	s.Equal("tenant1", tenant.ID)
+
+	// Extra fields survive caching.
+	b, err := json.Marshal(token)
+	s.Require().NoError(err)
+	var decoded Token
+	s.Require().NoError(json.Unmarshal(b, &decoded))
+	s.Equal(600.0, decoded.Extra("id_token_expires_in"))
+}
+
+func (s *ClientTestSuite) TestVerify_MaxTokenLifetime() {
+	idToken, jwkSetJSON := s.validIDToken()
+
+	// Token is valid for 1h.
+	_, err := s.client.Verifier(VerificationConfig{
+		ClientID:         "client1",
+		MaxTokenLifetime: 30 * time.Minute,
+	}).Verify(s.testCtx, idToken)
+	s.Error(err)
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = s.client.Verifier(VerificationConfig{
+		ClientID:         "client1",
+		MaxTokenLifetime: 2 * time.Hour,
+	}).Verify(s.testCtx, idToken)
+	s.NoError(err)
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestToken_IsValidFor() {
+	idToken, jwkSetJSON := s.validIDToken()
+	token := Token{
+		AccessToken:       "access1",
+		IDToken:           idToken,
+		AccessTokenExpiry: time.Now().Add(1 * time.Minute),
+	}
+	s.False(token.IsAccessTokenExpired())
+	s.True(token.IsAccessTokenExpiringWithin(2 * time.Minute))
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	verifier := s.client.Verifier(VerificationConfig{
+		ClientID: "client1",
+	})
+	s.NoError(token.IsValidFor(s.testCtx, verifier, 30*time.Second))
+	// Verification result is cached, so no more keys are fetched.
+	s.Error(token.IsValidFor(s.testCtx, verifier, 2*time.Minute))
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestToken_RawAccessors() {
+	idToken, jwkSetJSON := s.validIDToken()
+	token := Token{
+		AccessToken: "access1",
+		IDToken:     idToken,
+	}
+	s.Equal(idToken, token.RawIDToken())
+
+	rawClaims, err := token.RawClaims()
+	s.NoError(err)
+	claims := map[string]interface{}{}
+	s.NoError(json.Unmarshal(rawClaims, &claims))
+	s.Equal("subject1", claims["sub"])
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	verified, err := s.client.Verifier(VerificationConfig{ClientID: "client1"}).Verify(s.testCtx, idToken)
+	s.NoError(err)
+	s.Equal(idToken, verified.Raw())
+	s.Equal(rawClaims, verified.RawClaims())
+
+	s.Equal(0, s.s.Len())
+}
+
+func TestToken_JSON(t *testing.T) {
+	expiry := time.Date(2017, 10, 12, 15, 4, 5, 0, time.UTC)
+	token := Token{
+		AccessToken:       "access1",
+		TokenType:         "Bearer",
+		AccessTokenExpiry: expiry,
+		RefreshToken:      "refresh1",
+		IDToken:           "id1",
+	}
+
+	b, err := json.Marshal(token)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":1,"id_token":"id1","access_token":"access1","refresh_token":"refresh1","token_type":"Bearer","expiry":"2017-10-12T15:04:05Z"}`, string(b))
+
+	var decoded Token
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, token, decoded)
+
+	// No expiry means never expires.
+	b, err = json.Marshal(Token{AccessToken: "access1", IDToken: "id1"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":1,"id_token":"id1","access_token":"access1"}`, string(b))
+
+	// Unversioned JSON from previous library versions.
+	decoded = Token{}
+	require.NoError(t, json.Unmarshal([]byte(`{"access_token":"access1","expiry":"0001-01-01T00:00:00Z","refresh_token":"refresh1","id_token":"id1"}`), &decoded))
+	assert.Equal(t, Token{AccessToken: "access1", RefreshToken: "refresh1", IDToken: "id1"}, decoded)
+	assert.True(t, decoded.AccessTokenExpiry.IsZero())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"version":2,"access_token":"access1"}`), &decoded))
+}
+
+func (s *ClientTestSuite) TestToken_JSON_NewReuseTokenSource() {
+	idToken, jwkSetJSON := s.validIDToken()
+	expiry := time.Now().Add(1 * time.Hour).Truncate(time.Second)
+	b, err := json.Marshal(Token{AccessToken: "access1", RefreshToken: "refresh1", IDToken: idToken, AccessTokenExpiry: expiry})
+	s.Require().NoError(err)
+
+	var restored Token
+	s.Require().NoError(json.Unmarshal(b, &restored))
+	s.True(expiry.Equal(restored.AccessTokenExpiry))
+
+	// Restored valid token is reused.
+	src, _ := NewReuseTokenSource(s.testCtx, &restored, NewTokenRefresher(s.testCtx, s.client, Config{ClientID: "client1"}, ""))
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	token, err := src.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+	s.Equal(0, s.s.Len())
+
+	// Restored expired token is refreshed with its refresh token.
+	restored.AccessTokenExpiry = time.Now().Add(-1 * time.Minute)
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access2",
+		RefreshToken: "refresh2",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    expirationTime(3600),
+	})
+	s.Require().NoError(err)
+	src, _ = NewReuseTokenSource(s.testCtx, &restored, NewTokenRefresher(s.testCtx, s.client, Config{ClientID: "client1"}, ""))
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Require().NoError(r.ParseForm())
+		s.Equal("refresh1", r.PostForm.Get("refresh_token"))
+		return rt.JSONResponseFunc(http.StatusOK, tokenJSON)(r)
+	})
+	token, err = src.OIDCToken()
+	s.Require().NoError(err)
+	s.Equal("access2", token.AccessToken)
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestWithClock() {
+	frozen := time.Now().Add(2 * time.Hour)
+	client := s.client.Provider().Client(WithClock(func() time.Time { return frozen }))
+
+	idToken, jwkSetJSON := s.validIDToken()
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken: "access1",
+		IDToken:     idToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   3600,
+	})
+	s.NoError(err)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+
+	token, err := client.Exchange(s.testCtx, Config{ClientID: "client1"}, "code1")
+	s.Require().NoError(err)
+	s.Equal(frozen.Add(1*time.Hour), token.AccessTokenExpiry)
+
+	// ID token valid for 1h is expired in client's time.
+	err = token.IsValid(s.testCtx, client.Verifier(VerificationConfig{ClientID: "client1"}))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "token is expired")
+
+	// Access token is expired in real time, but not in client's time.
+	token.AccessTokenExpiry = time.Now().Add(-1 * time.Minute)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	s.NoError(token.IsValid(s.testCtx, client.Verifier(VerificationConfig{
+		ClientID:  "client1",
+		Now:       func() time.Time { return token.AccessTokenExpiry.Add(-10 * time.Minute) },
+		ClockSkew: 15 * time.Minute,
+	})))
+	s.Equal(0, s.s.Len())
 }
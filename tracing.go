@@ -0,0 +1,83 @@
+package oidc
+
+import "context"
+
+// Span attribute keys set by the Client.
+const (
+	SpanAttrIssuer     = "oidc.issuer"
+	SpanAttrClientID   = "oidc.client_id"
+	SpanAttrGrantType  = "oidc.grant_type"
+	SpanAttrTokenType  = "oidc.token_type"
+	SpanAttrLoginFlow  = "oidc.login_flow"
+	SpanAttrErrorCode  = "oidc.error_code"
+	SpanAttrHTTPStatus = "http.status_code"
+)
+
+// SpanAttribute is a key-value attribute of a span. Value is string, int or bool.
+type SpanAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts spans around discovery, token requests, verification, user info and login flows, so auth latency
+// shows up in distributed traces. See adapters/oidcotel for OpenTelemetry implementation. Tracer must be safe for
+// concurrent use.
+type Tracer interface {
+	// StartSpan starts span of given name as a child of span in ctx (if any) and returns ctx with the new span.
+	StartSpan(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span)
+}
+
+// Span is a single traced operation started by Tracer.
+type Span interface {
+	SetAttributes(attrs ...SpanAttribute)
+	// End finishes the span. Err is the operation's error, if any.
+	End(err error)
+}
+
+// WithTracer sets tracer used for spans of all operations of the Client and its verifiers. Discovery spans are
+// recorded only if the option is passed to NewProvider or NewClient.
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// StartSpan starts span using tracer given by WithTracer, or returns no-op span if there is none. It is meant for
+// flows built on top of the Client, e.g by login package, so they are traced the same way.
+func (c *Client) StartSpan(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	return startSpan(ctx, c.opts.tracer, name, append([]SpanAttribute{{Key: SpanAttrIssuer, Value: c.issuer}}, attrs...)...)
+}
+
+func startSpan(ctx context.Context, tracer Tracer, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.StartSpan(ctx, name, attrs...)
+}
+
+// endSpan finishes span, adding HTTP status and OAuth2 error code of err if there are any.
+func endSpan(span Span, err error) {
+	if err != nil {
+		var attrs []SpanAttribute
+		walkErrors(err, func(err error) bool {
+			switch e := err.(type) {
+			case *OAuth2Error:
+				attrs = append(attrs, SpanAttribute{Key: SpanAttrErrorCode, Value: e.Code}, SpanAttribute{Key: SpanAttrHTTPStatus, Value: e.HTTPStatus})
+				return true
+			case *HTTPError:
+				attrs = append(attrs, SpanAttribute{Key: SpanAttrHTTPStatus, Value: e.StatusCode})
+				return true
+			}
+			return false
+		})
+		if len(attrs) > 0 {
+			span.SetAttributes(attrs...)
+		}
+	}
+	span.End(err)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...SpanAttribute) {}
+func (noopSpan) End(error)                      {}
@@ -0,0 +1,106 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/Bplotka/go-httpt"
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &recordedSpan{name: name, attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, s)
+	return ctx, &recordingSpan{tracer: t, span: s, attrs: attrs}
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	span   *recordedSpan
+	attrs  []SpanAttribute
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...SpanAttribute) {
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func (s *recordingSpan) End(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	for _, a := range s.attrs {
+		s.span.attrs[a.Key] = a.Value
+	}
+	s.span.err, s.span.ended = err, true
+}
+
+func (s *ClientTestSuite) TestTracer() {
+	jsonDiscovery, err := json.Marshal(testDiscovery)
+	s.NoError(err)
+	idToken, jwkSetJSON := s.validIDToken()
+	tokenJSON, err := json.Marshal(TokenResponse{
+		AccessToken:  "access1",
+		RefreshToken: "refresh2",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+	})
+	s.NoError(err)
+
+	tracer := &recordingTracer{}
+	srv := httpt.NewServer(s.T())
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+	client, err := NewClient(context.TODO(), exampleIssuer, WithHTTPClient(srv.HTTPClient()), WithTracer(tracer))
+	s.Require().NoError(err)
+
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+	_, err = NewTokenRefresher(context.TODO(), client, Config{ClientID: "client1"}, "refresh1").OIDCToken()
+	s.NoError(err)
+	srv.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = client.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.TODO(), idToken)
+	s.NoError(err)
+
+	srv.Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error":"invalid_grant"}`)))
+	_, err = NewTokenRefresher(context.TODO(), client, Config{ClientID: "client1"}, "refresh2").OIDCToken()
+	s.Error(err)
+	s.Equal(0, srv.Len())
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	s.Require().Len(tracer.spans, 4)
+	for i, expected := range []struct {
+		name  string
+		attrs map[string]interface{}
+	}{
+		{name: "oidc.Discovery", attrs: map[string]interface{}{SpanAttrIssuer: exampleIssuer}},
+		{name: "oidc.Token", attrs: map[string]interface{}{SpanAttrIssuer: exampleIssuer, SpanAttrClientID: "client1", SpanAttrGrantType: GrantTypeRefreshToken}},
+		{name: "oidc.Verify", attrs: map[string]interface{}{SpanAttrIssuer: exampleIssuer, SpanAttrClientID: "client1", SpanAttrTokenType: idTokenRules.name}},
+		{name: "oidc.Token", attrs: map[string]interface{}{
+			SpanAttrIssuer:     exampleIssuer,
+			SpanAttrClientID:   "client1",
+			SpanAttrGrantType:  GrantTypeRefreshToken,
+			SpanAttrErrorCode:  "invalid_grant",
+			SpanAttrHTTPStatus: http.StatusBadRequest,
+		}},
+	} {
+		s.Equal(expected.name, tracer.spans[i].name, "span %d", i)
+		s.Equal(expected.attrs, tracer.spans[i].attrs, "span %d", i)
+		s.True(tracer.spans[i].ended, "span %d", i)
+	}
+	s.NoError(tracer.spans[1].err)
+	s.Error(tracer.spans[3].err)
+}
@@ -0,0 +1,221 @@
+package oidc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Transport is an http.RoundTripper that authenticates every request with bearer access token from TokenSource.
+// Token freshness is evaluated on every request, so fresh (refreshed if needed) token is always used, as long as
+// TokenSource refreshes tokens, e.g ReuseTokenSource.
+type Transport struct {
+	base http.RoundTripper
+	src  TokenSource
+
+	// onTokenExpiry if not nil, is called when access token used for request expires while its response body is still
+	// being read, e.g for streams.
+	onTokenExpiry func(req *http.Request)
+	// expiryMargin specifies how long before the actual token expiry onTokenExpiry is called.
+	expiryMargin time.Duration
+
+	// dpop if not nil, signs DPoP proofs for DPoP-bound access tokens.
+	dpop *DPoPKey
+
+	// retryUnauthorized makes transport retry requests rejected with 401 once with new token.
+	retryUnauthorized bool
+}
+
+// TransportOption configures Transport.
+type TransportOption func(*Transport)
+
+// WithTokenExpiryCallback sets callback that is invoked when access token used for a long-lived request (e.g
+// server-sent events stream or watch) expires before its response body is closed. The callback receives the original
+// request and is invoked at most once per request, from separate goroutine. It is the right place to re-establish the
+// stream: cancel old request's context and send the request again, which will use fresh token. margin specifies how
+// long before the actual expiry the callback is invoked, so there is time to reconnect.
+func WithTokenExpiryCallback(margin time.Duration, callback func(req *http.Request)) TransportOption {
+	return func(t *Transport) {
+		t.onTokenExpiry = callback
+		t.expiryMargin = margin
+	}
+}
+
+// WithDPoPProofs makes transport send access tokens with DPoP authorization scheme and DPoP proofs bound to them,
+// signed with given key (see https://tools.ietf.org/html/rfc9449). Tokens need to be obtained by client with WithDPoP
+// option with the same key. Requests rejected for missing nonce are retried once with nonce provided by the server,
+// if their body can be sent again (it is nil or http.Request.GetBody is set).
+func WithDPoPProofs(key *DPoPKey) TransportOption {
+	return func(t *Transport) {
+		t.dpop = key
+	}
+}
+
+// WithRetryOnUnauthorized makes transport retry request rejected with 401 Unauthorized once, with new token, e.g when
+// token was revoked or rotated before its expiry. Rejected token is dropped from TokenSource implementing
+// TokenInvalidator (e.g ReuseTokenSource), so it is refreshed even though it seems valid locally. Request is retried
+// only if new token differs and the request body can be sent again (it is nil or http.Request.GetBody is set).
+func WithRetryOnUnauthorized() TransportOption {
+	return func(t *Transport) {
+		t.retryUnauthorized = true
+	}
+}
+
+// NewTransport constructs Transport that uses given base RoundTripper to perform requests. If base is nil,
+// http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, src TokenSource, opts ...TransportOption) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{
+		base: base,
+		src:  src,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// RoundTrip authorizes and performs request using base RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Request's context limits obtaining the token too, if the source supports it.
+	token, err := tokenCtx(req.Context(), t.src)
+	if err != nil {
+		// RoundTripper must always close the body, including on errors.
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, wrapErrorf(err, "oidc: transport: failed to obtain token: %v", err)
+	}
+
+	resp, err := t.roundTrip(cloneRequest(req), token)
+	if err != nil {
+		return nil, err
+	}
+	if t.dpop != nil && t.dpop.recordNonce(req.URL, resp) && isDPoPNonceChallenge(resp) {
+		if retryReq, ok := retryRequest(req); ok {
+			resp.Body.Close()
+			resp, err = t.roundTrip(retryReq, token)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if t.retryUnauthorized && resp.StatusCode == http.StatusUnauthorized && (req.Body == nil || req.GetBody != nil) {
+		if newToken, ok := t.newToken(req, token); ok {
+			if retryReq, ok := retryRequest(req); ok {
+				resp.Body.Close()
+				token = newToken
+				resp, err = t.roundTrip(retryReq, token)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if t.onTokenExpiry != nil && !token.AccessTokenExpiry.IsZero() && resp.Body != nil {
+		resp.Body = newExpiryWatchingBody(resp.Body, time.Until(token.AccessTokenExpiry.Add(-t.expiryMargin)), func() {
+			t.onTokenExpiry(req)
+		})
+	}
+	return resp, nil
+}
+
+// newToken drops rejected token from the source and obtains new one. It returns false if source can't drop tokens or
+// did not return different token.
+func (t *Transport) newToken(req *http.Request, rejected *Token) (*Token, bool) {
+	inv, ok := t.src.(TokenInvalidator)
+	if !ok {
+		return nil, false
+	}
+	if err := inv.InvalidateToken(rejected); err != nil {
+		return nil, false
+	}
+	token, err := tokenCtx(req.Context(), t.src)
+	if err != nil || token.AccessToken == rejected.AccessToken {
+		return nil, false
+	}
+	return token, true
+}
+
+// retryRequest returns copy of req to send again. It returns false if req body can't be sent again.
+func retryRequest(req *http.Request) (*http.Request, bool) {
+	retryReq := cloneRequest(req)
+	if req.Body == nil {
+		return retryReq, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	retryReq.Body = body
+	return retryReq, true
+}
+
+// roundTrip authorizes request with token and performs it using base RoundTripper.
+func (t *Transport) roundTrip(authReq *http.Request, token *Token) (*http.Response, error) {
+	if t.dpop == nil {
+		err := token.SetAuthHeader(authReq)
+		if err == nil && strings.EqualFold(token.TokenType, TokenTypeDPoP) {
+			err = errors.New("oidc: transport: DPoP-bound access token requires WithDPoPProofs option")
+		}
+		if err != nil {
+			if authReq.Body != nil {
+				authReq.Body.Close()
+			}
+			return nil, err
+		}
+		return t.base.RoundTrip(authReq)
+	}
+
+	if err := t.dpop.setProof(authReq, token.AccessToken); err != nil {
+		if authReq.Body != nil {
+			authReq.Body.Close()
+		}
+		return nil, err
+	}
+	authReq.Header.Set("Authorization", TokenTypeDPoP+" "+token.AccessToken)
+	return t.base.RoundTrip(authReq)
+}
+
+// cloneRequest returns shallow copy of the request with a deep copy of the headers, since RoundTripper must not
+// modify the request.
+func cloneRequest(r *http.Request) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Header = make(http.Header, len(r.Header))
+	for k, s := range r.Header {
+		r2.Header[k] = append([]string(nil), s...)
+	}
+	return r2
+}
+
+// expiryWatchingBody invokes callback if the body was not closed before given time.
+type expiryWatchingBody struct {
+	io.ReadCloser
+
+	timer *time.Timer
+}
+
+func newExpiryWatchingBody(body io.ReadCloser, expiresIn time.Duration, callback func()) *expiryWatchingBody {
+	if expiresIn < 0 {
+		expiresIn = 0
+	}
+	return &expiryWatchingBody{
+		ReadCloser: body,
+		timer:      time.AfterFunc(expiresIn, callback),
+	}
+}
+
+// Close stops watching and closes underlying body.
+func (b *expiryWatchingBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,108 @@
+package oidc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	expired := make(chan *http.Request, 1)
+	client := &http.Client{
+		Transport: NewTransport(nil, StaticTokenSource(&Token{
+			AccessToken:       "access1",
+			AccessTokenExpiry: time.Now().Add(100 * time.Millisecond),
+		}), WithTokenExpiryCallback(50*time.Millisecond, func(req *http.Request) {
+			expired <- req
+		})),
+	}
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer access1", string(body))
+	assert.Empty(t, req.Header.Get("Authorization"), "original request must not be modified")
+
+	// Body is not closed yet, so the stream outlives the token.
+	select {
+	case r := <-expired:
+		assert.Equal(t, req, r)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected token expiry callback")
+	}
+	resp.Body.Close()
+
+	// Closed stream is not reported.
+	client.Transport = NewTransport(nil, StaticTokenSource(&Token{
+		AccessToken:       "access2",
+		AccessTokenExpiry: time.Now().Add(100 * time.Millisecond),
+	}), WithTokenExpiryCallback(0, func(req *http.Request) {
+		expired <- req
+	}))
+	resp, err = client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	select {
+	case <-expired:
+		t.Fatal("unexpected token expiry callback")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// Unsupported token types are not sent.
+	client.Transport = NewTransport(nil, StaticTokenSource(&Token{AccessToken: "access3", TokenType: "mac"}))
+	_, err = client.Get(srv.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported token type")
+}
+
+func (s *ClientTestSuite) TestTransport_RetryOnUnauthorized() {
+	idToken, jwkSetJSON := s.validIDToken()
+	verifier, err := NewStaticVerifier(exampleIssuer, jwkSetJSON, VerificationConfig{ClientID: "client1"})
+	s.Require().NoError(err)
+	src := &countingTokenSource{idToken: idToken, expiry: 1 * time.Hour, verifier: verifier}
+	reuse, _ := NewReuseTokenSource(s.testCtx, nil, src)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer access1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, reuse, WithRetryOnUnauthorized())}
+	resp, err := client.Get(srv.URL)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, resp.StatusCode)
+	s.Equal("Bearer access2", string(body))
+	s.Equal(int32(2), atomic.LoadInt32(&src.calls))
+
+	// Without the option 401 is returned to the caller.
+	src = &countingTokenSource{idToken: idToken, expiry: 1 * time.Hour, verifier: verifier}
+	reuse, _ = NewReuseTokenSource(s.testCtx, nil, src)
+	client.Transport = NewTransport(nil, reuse)
+	resp, err = client.Get(srv.URL)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.Equal(http.StatusUnauthorized, resp.StatusCode)
+	s.Equal(int32(1), atomic.LoadInt32(&src.calls))
+}
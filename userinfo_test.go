@@ -0,0 +1,43 @@
+package oidc
+
+import (
+	"net/http"
+
+	"github.com/Bplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestUserInfo() {
+	idToken, _ := s.signedJWT(map[string]interface{}{"sub": "user1"})
+
+	s.s.Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal(testDiscovery.UserInfoURL, r.URL.String())
+		s.Equal("Bearer access1", r.Header.Get("Authorization"))
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"sub": "user1", "email": "user1@example.org", "name": "User One"}`))(r)
+	})
+
+	var profile struct {
+		Name string `json:"name"`
+	}
+	err := s.client.UserInfoClaims(s.testCtx, StaticTokenSource(&Token{AccessToken: "access1", IDToken: idToken}), &profile)
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+	s.Equal("User One", profile.Name)
+}
+
+func (s *ClientTestSuite) TestUserInfo_SubjectMismatch() {
+	idToken, _ := s.signedJWT(map[string]interface{}{"sub": "user1"})
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{"sub": "user2"}`)))
+
+	_, err := s.client.UserInfo(s.testCtx, StaticTokenSource(&Token{AccessToken: "access1", IDToken: idToken}))
+	s.Equal(ErrUserInfoSubjectMismatch, err)
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestUserInfo_NoSubject() {
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{"email": "user1@example.org"}`)))
+
+	_, err := s.client.UserInfo(s.testCtx, StaticTokenSource(&Token{AccessToken: "access1"}))
+	s.Error(err)
+	s.Equal(0, s.s.Len())
+}
@@ -5,6 +5,12 @@ package oidc
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -25,15 +31,50 @@ const (
 
 // Verifier is anything that can verify token and returned parsed standard oidc.NewIDToken.
 // For example oidc.IDTokenVerifier.
+//
+// Every purpose-specific method applies only the rules defined for given token type, while sharing the
+// provider's key set and signature verification.
 type Verifier interface {
+	// Verify is equivalent to VerifyIDToken.
 	Verify(ctx context.Context, rawIDToken string) (*IDToken, error)
+
+	// VerifyIDToken verifies ID token as described in
+	// https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation.
+	VerifyIDToken(ctx context.Context, rawIDToken string) (*IDToken, error)
+	// VerifyAccessToken verifies access token issued by the provider in JWT format.
+	VerifyAccessToken(ctx context.Context, rawAccessToken string) (*AccessToken, error)
+	// VerifyLogoutToken verifies logout token as described in
+	// https://openid.net/specs/openid-connect-backchannel-1_0.html#Validation.
+	VerifyLogoutToken(ctx context.Context, rawLogoutToken string) (*LogoutToken, error)
+	// VerifyUserInfo verifies signed user info response as described in
+	// https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse.
+	VerifyUserInfo(ctx context.Context, rawUserInfo string) (*UserInfo, error)
+}
+
+// DefaultVerificationCacheLimits specifies bounds for cache of successful ID token verifications shared by all verifiers
+// of single Client. Cached result is never used after token expiry.
+var DefaultVerificationCacheLimits = CacheLimits{
+	MaxEntries: 1000,
+	TTL:        30 * time.Second,
 }
 
+// MinNotBeforeLeeway is minimal tolerance of "nbf" and "iat" claims in the future, used when VerificationConfig.ClockSkew
+// is smaller. Providers round these claims and clocks drift slightly, so tokens issued a moment ago by provider with
+// clock ahead of local one are not rejected by default.
+var MinNotBeforeLeeway = 5 * time.Second
+
 // IDTokenVerifier provides verification for ID Tokens.
 type IDTokenVerifier struct {
 	keySet keySet
 	cfg    VerificationConfig
 	issuer string
+
+	// Optional cache of successful verifications.
+	results *lruCache
+	// Optional hook notified about failed verifications.
+	auditHook AuditHook
+	// Optional tracer of verifications.
+	tracer Tracer
 }
 
 // VerificationConfig is the configuration for an IDTokenVerifier.
@@ -45,31 +86,113 @@ type VerificationConfig struct {
 	// If not provided, users must explicitly set SkipClientIDCheck.
 	ClientID string
 
+	// IssuerAliases are accepted in "iss" claim in addition to the issuer, for providers that are inconsistent between
+	// discovery and tokens, e.g "https://issuer-oidc.org/" with trailing slash. Aliases are opt-in, since accepting
+	// tokens with unexpected issuer is against the spec.
+	IssuerAliases []string
+
+	// AdditionalAudiences are accepted in "aud" claim in addition to ClientID, e.g when tokens are issued for an API
+	// that is identified by different audience than the client. If ID token has more than one audience, its
+	// authorizing party (azp) claim is still required to be ClientID.
+	AdditionalAudiences []string
+
 	// ClaimNonce for Verification.
 	ClaimNonce string
 
-	// If specified, only this set of algorithms may be used to sign the JWT.
+	// If specified, only this set of algorithms may be used to sign the JWT, e.g RS256, PS256 (PS384, PS512) for
+	// RSA-PSS signatures, ES256 (ES384, ES512) for providers signing with elliptic curve keys or EdDSA for providers
+	// signing with Ed25519 keys (Go 1.13+ only).
 	//
-	// Since many providers only support RS256, SupportedSigningAlgs defaults to this value.
+	// For verifiers created by Client.Verifier, SupportedSigningAlgs defaults to asymmetric algorithms advertised by
+	// the provider in id_token_signing_alg_values_supported discovery field. Otherwise, since many providers only
+	// support RS256, SupportedSigningAlgs defaults to this value.
 	SupportedSigningAlgs []string
 
+	// ClientSecret if not empty, enables verification of tokens signed with HMAC using client secret as the key, as
+	// done e.g by Auth0 legacy apps. To prevent downgrade attacks, HMAC algorithm (HS256, HS384 or HS512) needs to be
+	// explicitly listed in SupportedSigningAlgs, and HMAC-signed tokens are never verified with provider's keys.
+	ClientSecret string
+
+	// DecryptionKey if not nil, is the client's private key (*rsa.PrivateKey or *ecdsa.PrivateKey) used to decrypt ID
+	// tokens encrypted by the provider (JWE with RSA-OAEP, RSA-OAEP-256 or ECDH-ES key management and A128GCM or
+	// A256GCM content encryption). Decrypted token is verified as any signed ID token.
+	DecryptionKey crypto.PrivateKey
+
 	// Time function to check Token expiry. Defaults to time.Now
 	Now func() time.Time
+
+	// ClockSkew is tolerated difference between provider's and local clock. Tokens are accepted for ClockSkew after
+	// their "exp", and ClockSkew (but at least MinNotBeforeLeeway) before their "nbf" and "iat". Defaults to 0.
+	ClockSkew time.Duration
+
+	// MaxTokenLifetime if specified, rejects tokens that are valid for longer than this duration since issue time
+	// (or since now, if token does not include "iat").
+	MaxTokenLifetime time.Duration
+
+	// StrictAccessTokens if true, makes VerifyAccessToken accept only JWT access tokens following RFC 9068: "typ"
+	// header needs to be "at+jwt" and "sub", "client_id", "iat" and "jti" claims are required. Otherwise, any JWT
+	// signed by the provider with expected issuer and audience is accepted as access token.
+	StrictAccessTokens bool
+
+	// RequiredScopes if specified, makes VerifyAccessToken reject tokens that don't include all of these scopes in
+	// "scope" claim.
+	RequiredScopes []string
 }
 
-func newVerifier(keySet keySet, cfg VerificationConfig, issuer string) *IDTokenVerifier {
+func newVerifier(keySet keySet, cfg VerificationConfig, issuer string, results *lruCache) *IDTokenVerifier {
 	// If SupportedSigningAlgs is empty defaults to only support RS256.
 	if len(cfg.SupportedSigningAlgs) == 0 {
 		cfg.SupportedSigningAlgs = []string{string(jose.RS256)}
 	}
 
 	return &IDTokenVerifier{
-		keySet: keySet,
-		cfg:    cfg,
-		issuer: issuer,
+		keySet:  keySet,
+		cfg:     cfg,
+		issuer:  issuer,
+		results: results,
 	}
 }
 
+// resultKey returns key for verification results cache. It includes every configuration field that affects the result.
+func (v *IDTokenVerifier) resultKey(rawIDToken string) string {
+	return strings.Join([]string{
+		v.issuer,
+		strings.Join(v.cfg.IssuerAliases, ","),
+		v.cfg.ClientID,
+		strings.Join(v.cfg.AdditionalAudiences, ","),
+		v.cfg.ClaimNonce,
+		strings.Join(v.cfg.SupportedSigningAlgs, ","),
+		v.cfg.MaxTokenLifetime.String(),
+		v.cfg.ClockSkew.String(),
+		secretDigest(v.cfg.ClientSecret),
+		keyDigest(v.cfg.DecryptionKey),
+		rawIDToken,
+	}, "\x00")
+}
+
+// secretDigest returns digest of secret safe to be kept in cache keys.
+func secretDigest(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(secret)))
+}
+
+// keyDigest returns digest identifying private key in cache keys. Public part of the key is used when available.
+func keyDigest(key crypto.PrivateKey) string {
+	if key == nil {
+		return ""
+	}
+	if k, ok := key.(interface {
+		Public() crypto.PublicKey
+	}); ok {
+		if b, err := x509.MarshalPKIXPublicKey(k.Public()); err == nil {
+			return secretDigest(string(b))
+		}
+	}
+	return secretDigest(fmt.Sprintf("%#v", key))
+}
+
 func parseJWT(p string) ([]byte, error) {
 	parts := strings.Split(p, ".")
 	if len(parts) < 2 {
@@ -96,113 +219,482 @@ func contains(sli []string, ele string) bool {
 //
 // See: https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
 //
-//    oidcToken, err := client.Exchange(ctx, r.URL.Query().Get("code"))
-//    if err != nil {
-//        // handle error
-//    }
-//
-//    token, err := verifier.Verify(ctx, oidcToken.IDToken)
+//	oidcToken, err := client.Exchange(ctx, r.URL.Query().Get("code"))
+//	if err != nil {
+//	    // handle error
+//	}
 //
+//	token, err := verifier.Verify(ctx, oidcToken.IDToken)
 func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken string) (*IDToken, error) {
-	jws, err := jose.ParseSigned(rawIDToken)
+	return v.VerifyIDToken(ctx, rawIDToken)
+}
+
+// VerifyIDToken parses a raw ID Token, verifies it's been signed by the provider, preforms
+// any additional checks depending on the Config, and returns the payload.
+func (v *IDTokenVerifier) VerifyIDToken(ctx context.Context, rawIDToken string) (*IDToken, error) {
+	ctx, span := v.startSpan(ctx, idTokenRules)
+	token, err := v.verifyIDToken(ctx, rawIDToken)
+	if err != nil {
+		v.auditFailure(rawIDToken, err)
+	}
+	endSpan(span, err)
+	return token, err
+}
+
+// VerifyAccessToken parses a raw access token in JWT format, verifies it's been signed by the provider and returns
+// its payload. Config.ClientID is used as expected audience. Nonce is not checked, since access tokens do not carry it.
+// See VerificationConfig.StrictAccessTokens for enforcing JWT profile for access tokens (RFC 9068) and
+// VerificationConfig.RequiredScopes for checking granted scopes.
+func (v *IDTokenVerifier) VerifyAccessToken(ctx context.Context, rawAccessToken string) (*AccessToken, error) {
+	ctx, span := v.startSpan(ctx, accessTokenRules)
+	token, err := v.verifyAccessToken(ctx, rawAccessToken)
+	if err != nil {
+		v.auditFailure(rawAccessToken, err)
+	}
+	endSpan(span, err)
+	return token, err
+}
+
+// VerifyLogoutToken parses a raw back-channel logout token, verifies it's been signed by the provider and
+// checks it according to the OpenID Connect Back-Channel Logout spec. Config.ClientID is used as expected audience.
+func (v *IDTokenVerifier) VerifyLogoutToken(ctx context.Context, rawLogoutToken string) (*LogoutToken, error) {
+	ctx, span := v.startSpan(ctx, logoutTokenRules)
+	token, err := v.verifyLogoutToken(ctx, rawLogoutToken)
+	if err != nil {
+		v.auditFailure(rawLogoutToken, err)
+	}
+	endSpan(span, err)
+	return token, err
+}
+
+// VerifyUserInfo parses signed user info response (content type application/jwt), verifies it's been signed by the
+// provider and returns its claims. Issuer and audience are checked only if present, since the spec does not
+// require them. Use it in place of Client.UserInfo response decoding for providers that sign user info.
+func (v *IDTokenVerifier) VerifyUserInfo(ctx context.Context, rawUserInfo string) (*UserInfo, error) {
+	ctx, span := v.startSpan(ctx, userInfoTokenRules)
+	userInfo, err := v.verifyUserInfo(ctx, rawUserInfo)
+	if err != nil {
+		v.auditFailure(rawUserInfo, err)
+	}
+	endSpan(span, err)
+	return userInfo, err
+}
+
+func (v *IDTokenVerifier) startSpan(ctx context.Context, rules tokenRules) (context.Context, Span) {
+	return startSpan(ctx, v.tracer, "oidc.Verify",
+		SpanAttribute{Key: SpanAttrIssuer, Value: v.issuer},
+		SpanAttribute{Key: SpanAttrClientID, Value: v.cfg.ClientID},
+		SpanAttribute{Key: SpanAttrTokenType, Value: rules.name},
+	)
+}
+
+func (v *IDTokenVerifier) auditFailure(rawJWT string, err error) {
+	auditEvent(v.auditHook, AuditVerifyFailure, v.issuer, v.cfg.ClientID, unverifiedSubject(rawJWT), err)
+}
+
+func (v *IDTokenVerifier) now() time.Time {
+	if v.cfg.Now != nil {
+		return v.cfg.Now()
+	}
+	return time.Now()
+}
+
+// tokenRules describes checks of registered claims that apply to token of given purpose.
+type tokenRules struct {
+	// name of the token used in error messages.
+	name string
+
+	// If true, token without "iss" or "aud" claims are accepted. The claims are still checked when present.
+	optionalIssuerAndAudience bool
+	// If true, token without "exp" claim are accepted. It is still checked when present.
+	optionalExpiry  bool
+	requireIssuedAt bool
+	// If true, "azp" claim is required for token with multiple audiences and needs to be ClientID when present.
+	checkAuthorizedParty bool
+	// JWT access tokens (with "at+jwt" type) are rejected, unless allowAccessTokenType is true, so they can't be used
+	// as tokens of other purposes. If requireAccessTokenType is true, other types are rejected.
+	allowAccessTokenType   bool
+	requireAccessTokenType bool
+}
+
+var (
+	idTokenRules           = tokenRules{name: "id token", checkAuthorizedParty: true}
+	accessTokenRules       = tokenRules{name: "access token", allowAccessTokenType: true}
+	strictAccessTokenRules = tokenRules{name: "access token", requireIssuedAt: true, allowAccessTokenType: true, requireAccessTokenType: true}
+	logoutTokenRules       = tokenRules{name: "logout token", optionalExpiry: true, requireIssuedAt: true}
+	userInfoTokenRules     = tokenRules{name: "user info", optionalIssuerAndAudience: true, optionalExpiry: true}
+)
+
+// registeredClaims are claims checked for every verified JWT.
+type registeredClaims struct {
+	Issuer          string      `json:"iss"`
+	Audience        Audience    `json:"aud"`
+	AuthorizedParty string      `json:"azp"`
+	Expiry          NumericDate `json:"exp"`
+	IssuedAt        NumericDate `json:"iat"`
+	NotBefore       NumericDate `json:"nbf"`
+}
+
+// verifyJWT performs checks shared by all token purposes. It checks registered claims first, so invalid tokens are
+// rejected before possibly re-syncing keys, then verifies the signature. It returns verified payload.
+func (v *IDTokenVerifier) verifyJWT(ctx context.Context, rawJWT string, rules tokenRules) ([]byte, error) {
+	jws, err := jose.ParseSigned(rawJWT)
 	if err != nil {
 		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
 	}
+	if err := checkJWTType(jws, rules); err != nil {
+		return nil, err
+	}
 
-	// Throw out tokens with invalid claims before trying to verify the token. This lets
-	// us do cheap checks before possibly re-syncing keys.
-	payload, err := parseJWT(rawIDToken)
+	payload, err := parseJWT(rawJWT)
 	if err != nil {
 		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
 	}
-	var token IDToken
-	if err := json.Unmarshal(payload, &token); err != nil {
+	var claims registeredClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
 		return nil, fmt.Errorf("oidc: failed to unmarshal claims: %v", err)
 	}
 
-	token.claims = payload
+	if err := v.checkRegisteredClaims(claims, rules); err != nil {
+		return nil, err
+	}
+
+	if err := v.verifySignature(ctx, rawJWT, jws, payload, rules.name); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// JWTTypeAccessToken is the "typ" header of JWT access tokens (see https://tools.ietf.org/html/rfc9068).
+const JWTTypeAccessToken = "at+jwt"
+
+// checkJWTType checks "typ" header of the JWT. Both "at+jwt" and "application/at+jwt" are accepted as access token type.
+func checkJWTType(jws *jose.JSONWebSignature, rules tokenRules) error {
+	var typ string
+	if len(jws.Signatures) > 0 {
+		typ, _ = jws.Signatures[0].Protected.ExtraHeaders[jose.HeaderType].(string)
+	}
+	isAccessTokenType := strings.TrimPrefix(strings.ToLower(typ), "application/") == JWTTypeAccessToken
+
+	if rules.requireAccessTokenType && !isAccessTokenType {
+		return fmt.Errorf("oidc: %s has unexpected type %q, expected %q", rules.name, typ, JWTTypeAccessToken)
+	}
+	if isAccessTokenType && !rules.allowAccessTokenType {
+		return fmt.Errorf("oidc: JWT access token can't be used as %s", rules.name)
+	}
+	return nil
+}
+
+func (v *IDTokenVerifier) checkRegisteredClaims(claims registeredClaims, rules tokenRules) error {
+	now := v.now()
 
 	// Check issuer.
-	if token.Issuer != v.issuer {
+	if claims.Issuer != v.issuer && !(rules.optionalIssuerAndAudience && claims.Issuer == "") {
 		// Google sometimes returns "accounts.google.com" as the issuer claim instead of
 		// the required "https://accounts.google.com". Detect this case and allow it only
 		// for Google.
 		//
-		// We will not add hooks to let other providers go off spec like this.
-		if !(v.issuer == issuerGoogleAccounts && token.Issuer == issuerGoogleAccountsNoScheme) {
-			return nil, fmt.Errorf("oidc: id token issued by a different provider, expected %q got %q", v.issuer, token.Issuer)
+		// Other providers going off spec like this need explicit IssuerAliases.
+		if !(v.issuer == issuerGoogleAccounts && claims.Issuer == issuerGoogleAccountsNoScheme) && !contains(v.cfg.IssuerAliases, claims.Issuer) {
+			return fmt.Errorf("oidc: %s issued by a different provider, expected %q got %q", rules.name, v.issuer, claims.Issuer)
 		}
 	}
 
-	// This check DOES NOT ensure that the ClientID is the party to which the ID Token was issued (i.e. Authorized party).
-	if v.cfg.ClientID != "" {
-		if !contains(token.Audience, v.cfg.ClientID) {
-			return nil, fmt.Errorf("oidc: expected Audience %q got %q", v.cfg.ClientID, token.Audience)
+	if !(rules.optionalIssuerAndAudience && len(claims.Audience) == 0) {
+		if v.cfg.ClientID != "" {
+			if !v.acceptsAudience(claims.Audience) {
+				return fmt.Errorf("oidc: expected Audience %q got %q", v.acceptedAudiences(), claims.Audience)
+			}
+		} else {
+			return fmt.Errorf("oidc: Invalid configuration. ClientID must be provided")
 		}
-	} else {
-		return nil, fmt.Errorf("oidc: Invalid configuration. ClientID must be provided")
 	}
 
-	now := time.Now
-	if v.cfg.Now != nil {
-		now = v.cfg.Now
+	// Ensure that the ClientID is the party to which the ID Token was issued (i.e. Authorized party).
+	if rules.checkAuthorizedParty {
+		if claims.AuthorizedParty == "" && len(claims.Audience) > 1 {
+			return fmt.Errorf("oidc: %s has multiple audiences %q, but no authorized party (azp) claim", rules.name, claims.Audience)
+		}
+		if claims.AuthorizedParty != "" && claims.AuthorizedParty != v.cfg.ClientID {
+			return fmt.Errorf("oidc: expected authorized party (azp) %q got %q", v.cfg.ClientID, claims.AuthorizedParty)
+		}
 	}
 
-	if token.Expiry.Time().Before(now()) {
-		return nil, fmt.Errorf("oidc: token is expired (Token Expiry: %v)", token.Expiry)
+	if !(rules.optionalExpiry && claims.Expiry == 0) {
+		if claims.Expiry.Time().Before(now.Add(-v.cfg.ClockSkew)) {
+			return fmt.Errorf("oidc: token is expired (Token Expiry: %v)", claims.Expiry)
+		}
+
+		if v.cfg.MaxTokenLifetime > 0 {
+			issuedAt := claims.IssuedAt.Time()
+			if claims.IssuedAt == 0 {
+				issuedAt = now
+			}
+			if lifetime := claims.Expiry.Time().Sub(issuedAt); lifetime > v.cfg.MaxTokenLifetime {
+				return fmt.Errorf("oidc: token lifetime %v exceeds maximum allowed %v", lifetime, v.cfg.MaxTokenLifetime)
+			}
+		}
+	}
+
+	leeway := v.cfg.ClockSkew
+	if leeway < MinNotBeforeLeeway {
+		leeway = MinNotBeforeLeeway
+	}
+	// Compared with second precision of NumericDate, since providers round these claims.
+	latest := NewNumericDate(now.Add(leeway))
+	if claims.NotBefore != 0 && claims.NotBefore > latest {
+		return fmt.Errorf("oidc: %s is not valid yet (Not Before: %v)", rules.name, claims.NotBefore)
+	}
+	if claims.IssuedAt != 0 && claims.IssuedAt > latest {
+		return fmt.Errorf("oidc: %s is issued in the future (Issued At: %v)", rules.name, claims.IssuedAt)
+	}
+
+	if rules.requireIssuedAt && claims.IssuedAt == 0 {
+		return fmt.Errorf("oidc: %s does not include required \"iat\" claim", rules.name)
+	}
+	return nil
+}
+
+// verifySignature verifies signature of jws using the provider's keys and checks that it matches payload.
+func (v *IDTokenVerifier) verifySignature(ctx context.Context, rawJWT string, jws *jose.JSONWebSignature, payload []byte, name string) error {
+	if len(jws.Signatures) == 1 && isHMACAlg(jws.Signatures[0].Header.Algorithm) {
+		return v.verifyHMAC(jws, payload, name)
 	}
 
 	// If a set of required algorithms/keys has been provided, ensure that the signature verify will use those.
-	keyIDs := make(map[string]struct{})
+	// Key IDs mapped to algorithms of signatures made with them.
+	keyIDs := make(map[string]string)
 	var gotAlgsForErrLog []string
 	for _, sig := range jws.Signatures {
+		if isHMACAlg(sig.Header.Algorithm) {
+			// Verified only with client secret, see verifyHMAC.
+			gotAlgsForErrLog = append(gotAlgsForErrLog, sig.Header.Algorithm)
+			continue
+		}
 		if len(v.cfg.SupportedSigningAlgs) == 0 || contains(v.cfg.SupportedSigningAlgs, sig.Header.Algorithm) {
-			keyIDs[sig.Header.KeyID] = struct{}{}
+			if err := checkFIPSSigningAlg(sig.Header.Algorithm); err != nil {
+				return err
+			}
+			keyIDs[sig.Header.KeyID] = sig.Header.Algorithm
 		} else {
 			gotAlgsForErrLog = append(gotAlgsForErrLog, sig.Header.Algorithm)
 		}
 	}
 	if len(keyIDs) == 0 {
-		return nil, fmt.Errorf("oidc: no signatures use a supported algorithm, expected %q got %q", v.cfg.SupportedSigningAlgs, gotAlgsForErrLog)
+		return fmt.Errorf("oidc: no signatures use a supported algorithm, expected %q got %q", v.cfg.SupportedSigningAlgs, gotAlgsForErrLog)
+	}
+
+	var gotPayload []byte
+	if ks, ok := v.keySet.(KeySet); ok {
+		// Custom key set verifies signature on its own, e.g using HSM.
+		p, err := ks.VerifySignature(ctx, rawJWT)
+		if err != nil {
+			return wrapErrorf(err, "oidc: failed to verify %s. Err: %v", name, err)
+		}
+		gotPayload = p
+	} else {
+		p, err := verifyWithKeys(ctx, v.keySet, jws, keyIDs)
+		if err != nil {
+			return wrapErrorf(err, "oidc: failed to verify %s. Err: %v", name, err)
+		}
+		gotPayload = p
+	}
+
+	// Ensure that the payload returned by the square actually matches the payload parsed earlier.
+	if !bytes.Equal(gotPayload, payload) {
+		return errors.New("oidc: internal error, payload parsed did not match previous payload")
 	}
+	return nil
+}
 
-	// Get keys from the remote key set. This will always trigger a re-sync.
-	allKeys, err := v.keySet.Keys(ctx)
+// verifyWithKeys verifies jws with keys from the key set matching signature key IDs and algorithms. It returns
+// verified payload.
+func verifyWithKeys(ctx context.Context, ks keySet, jws *jose.JSONWebSignature, keyIDs map[string]string) ([]byte, error) {
+	// Get keys from the key set, cached if possible.
+	allKeys, err := ks.Keys(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("oidc: get keys for id token: %v", err)
+		return nil, wrapErrorf(err, "get keys: %v", err)
 	}
 
-	var keys []jose.JSONWebKey
-	for _, k := range allKeys {
-		if _, ok := keyIDs[k.KeyID]; !ok {
-			continue
+	keys := matchingKeys(allKeys, keyIDs)
+	if r, ok := ks.(keySetRefresher); ok && len(keys) == 0 {
+		// Provider might have rotated keys since they were cached.
+		allKeys, err = r.Refresh(ctx)
+		if err != nil {
+			return nil, wrapErrorf(err, "get keys: %v", err)
 		}
-		keys = append(keys, k)
+		keys = matchingKeys(allKeys, keyIDs)
 	}
 	if len(keys) == 0 {
-		return nil, fmt.Errorf("oidc: no keys match signature ID(s) %v. Got keys: %v", keyIDs, allKeys)
+		return nil, fmt.Errorf("no keys match signature ID(s) %v. Got keys: %v", keyIDs, allKeys)
 	}
 
 	// Try to use a key to validate the signature.
-	var gotPayload []byte
+	var fipsErr error
 	xerr := xerrors.New()
 	for _, key := range keys {
+		if err := checkFIPSVerificationKey(key.Key); err != nil {
+			fipsErr = err
+			xerr.Add(err)
+			continue
+		}
 		p, err := jws.Verify(&key)
 		if err != nil {
 			xerr.Add(err)
 			continue
 		}
-		gotPayload = p
-		break
+		return p, nil
 	}
-	if len(gotPayload) == 0 {
-		return nil, fmt.Errorf("oidc: failed to verify id token. Err: %v", xerr.ErrorOrNil())
+	err = xerr.ErrorOrNil()
+	if fipsErr != nil {
+		return nil, wrapErrorf(fipsErr, "%v", err)
 	}
+	return nil, err
+}
 
-	// Ensure that the payload returned by the square actually matches the payload parsed earlier.
+func isHMACAlg(alg string) bool {
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.HS256, jose.HS384, jose.HS512:
+		return true
+	}
+	return false
+}
+
+// verifyHMAC verifies HMAC signature of jws using client secret. Algorithm needs to be explicitly allowed.
+func (v *IDTokenVerifier) verifyHMAC(jws *jose.JSONWebSignature, payload []byte, name string) error {
+	alg := jws.Signatures[0].Header.Algorithm
+	if v.cfg.ClientSecret == "" || !contains(v.cfg.SupportedSigningAlgs, alg) {
+		return fmt.Errorf("oidc: %s signed with %s, which is allowed only when listed in SupportedSigningAlgs together with ClientSecret", name, alg)
+	}
+	if err := checkFIPSSigningAlg(alg); err != nil {
+		return err
+	}
+
+	gotPayload, err := jws.Verify([]byte(v.cfg.ClientSecret))
+	if err != nil {
+		return fmt.Errorf("oidc: failed to verify %s. Err: %v", name, err)
+	}
 	if !bytes.Equal(gotPayload, payload) {
-		return nil, errors.New("oidc: internal error, payload parsed did not match previous payload")
+		return errors.New("oidc: internal error, payload parsed did not match previous payload")
+	}
+	return nil
+}
+
+// asymmetricSigningAlgs returns algorithms that are verified with provider's public keys. Symmetric (HS*) and "none"
+// algorithms are dropped, since they can't be verified with JWKS.
+func asymmetricSigningAlgs(algs []string) []string {
+	var res []string
+	for _, alg := range algs {
+		switch jose.SignatureAlgorithm(alg) {
+		case jose.RS256, jose.RS384, jose.RS512,
+			jose.PS256, jose.PS384, jose.PS512,
+			jose.ES256, jose.ES384, jose.ES512:
+			res = append(res, alg)
+		case jose.EdDSA:
+			if eddsaSupported {
+				res = append(res, alg)
+			}
+		}
+	}
+	return res
+}
+
+// keyMatchesAlg returns true if key can be used to verify signature of given algorithm. It prevents verifying
+// signature with key of the wrong type or curve, e.g ES256 signature with P-384 key.
+func keyMatchesAlg(key jose.JSONWebKey, alg string) bool {
+	if key.Use != "" && key.Use != "sig" {
+		return false
+	}
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return false
+	}
+
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512:
+		_, ok := key.Key.(*rsa.PublicKey)
+		return ok
+	case jose.ES256:
+		return ecdsaKeyOnCurve(key.Key, elliptic.P256())
+	case jose.ES384:
+		return ecdsaKeyOnCurve(key.Key, elliptic.P384())
+	case jose.ES512:
+		return ecdsaKeyOnCurve(key.Key, elliptic.P521())
+	case jose.EdDSA:
+		// Only Ed25519 curve of OKP keys is supported.
+		return isEd25519Key(key.Key)
 	}
+	// Let the JWS library decide about other algorithms.
+	return true
+}
+
+// matchingKeys returns keys with given IDs that can be used with algorithm of the signature. Keys without ID (e.g
+// static keys loaded from PEM files) match signatures with any key ID.
+func matchingKeys(allKeys []jose.JSONWebKey, keyIDs map[string]string) []jose.JSONWebKey {
+	var keys []jose.JSONWebKey
+	for _, k := range allKeys {
+		if k.KeyID == "" {
+			for _, alg := range keyIDs {
+				if keyMatchesAlg(k, alg) {
+					keys = append(keys, k)
+					break
+				}
+			}
+			continue
+		}
+		alg, ok := keyIDs[k.KeyID]
+		if !ok || !keyMatchesAlg(k, alg) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// acceptedAudiences returns ClientID and AdditionalAudiences.
+func (v *IDTokenVerifier) acceptedAudiences() []string {
+	return append([]string{v.cfg.ClientID}, v.cfg.AdditionalAudiences...)
+}
+
+// acceptsAudience returns true if aud contains any of accepted audiences.
+func (v *IDTokenVerifier) acceptsAudience(aud Audience) bool {
+	for _, a := range v.acceptedAudiences() {
+		if contains(aud, a) {
+			return true
+		}
+	}
+	return false
+}
+
+func ecdsaKeyOnCurve(key interface{}, curve elliptic.Curve) bool {
+	k, ok := key.(*ecdsa.PublicKey)
+	return ok && k.Curve == curve
+}
+
+func (v *IDTokenVerifier) verifyIDToken(ctx context.Context, rawIDToken string) (*IDToken, error) {
+	if v.results != nil {
+		if cached, ok := v.results.Get(v.resultKey(rawIDToken)); ok {
+			token := *(cached.(*IDToken))
+			if !token.Expiry.Time().Before(v.now().Add(-v.cfg.ClockSkew)) {
+				return &token, nil
+			}
+		}
+	}
+
+	rawJWS, err := v.decryptJWE(rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := v.verifyJWT(ctx, rawJWS, idTokenRules)
+	if err != nil {
+		return nil, err
+	}
+
+	var token IDToken
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal claims: %v", err)
+	}
+	token.claims = payload
+	token.raw = rawIDToken
 
 	// Check the nonce after we've verified the token. We don'token want to allow unverified
 	// payloads to trigger a nonce lookup.
@@ -213,5 +705,79 @@ func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken string) (*IDTok
 		}
 	}
 
+	if v.results != nil {
+		cached := token
+		v.results.AddWithExpiry(v.resultKey(rawIDToken), &cached, token.Expiry.Time())
+	}
 	return &token, nil
 }
+
+func (v *IDTokenVerifier) verifyAccessToken(ctx context.Context, rawAccessToken string) (*AccessToken, error) {
+	// Cheap checks before possibly re-syncing keys.
+	payload, err := parseJWT(rawAccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
+	}
+	var token AccessToken
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal claims: %v", err)
+	}
+	rules := accessTokenRules
+	if v.cfg.StrictAccessTokens {
+		rules = strictAccessTokenRules
+		if err := token.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if scopes := strings.Fields(token.Scope); len(v.cfg.RequiredScopes) > 0 {
+		if missing := scopesNotIn(v.cfg.RequiredScopes, scopes); len(missing) > 0 {
+			return nil, fmt.Errorf("oidc: access token does not have required scopes %q", missing)
+		}
+	}
+
+	if _, err := v.verifyJWT(ctx, rawAccessToken, rules); err != nil {
+		return nil, err
+	}
+	token.claims = payload
+	token.raw = rawAccessToken
+	return &token, nil
+}
+
+func (v *IDTokenVerifier) verifyLogoutToken(ctx context.Context, rawLogoutToken string) (*LogoutToken, error) {
+	// Cheap checks before possibly re-syncing keys.
+	payload, err := parseJWT(rawLogoutToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
+	}
+	var token LogoutToken
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal claims: %v", err)
+	}
+	if err := token.validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := v.verifyJWT(ctx, rawLogoutToken, logoutTokenRules); err != nil {
+		return nil, err
+	}
+	token.claims = payload
+	token.raw = rawLogoutToken
+	return &token, nil
+}
+
+func (v *IDTokenVerifier) verifyUserInfo(ctx context.Context, rawUserInfo string) (*UserInfo, error) {
+	payload, err := v.verifyJWT(ctx, rawUserInfo, userInfoTokenRules)
+	if err != nil {
+		return nil, err
+	}
+
+	var userInfo UserInfo
+	if err := json.Unmarshal(payload, &userInfo); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode userinfo: %v", err)
+	}
+	if userInfo.Subject == "" {
+		return nil, errors.New("oidc: user info does not include required \"sub\" claim")
+	}
+	userInfo.claims = payload
+	return &userInfo, nil
+}
@@ -0,0 +1,497 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/Bplotka/go-jwt"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func (s *ClientTestSuite) signedJWT(claims map[string]interface{}) (token string, jwkSetJSON []byte) {
+	builder, err := jwt.NewDefaultBuilder()
+	s.NoError(err)
+
+	token, err = builder.JWS().Claims(claims).CompactSerialize()
+	s.NoError(err)
+
+	jwkSetJSON, err = json.Marshal(&jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{builder.PublicJWK()},
+	})
+	s.NoError(err)
+	return token, jwkSetJSON
+}
+
+func (s *ClientTestSuite) TestVerifier_VerifyAccessToken() {
+	now := time.Now()
+	accessToken, jwkSetJSON := s.signedJWT(map[string]interface{}{
+		"iss":       exampleIssuer,
+		"aud":       "api1",
+		"sub":       "subject1",
+		"exp":       now.Add(1 * time.Hour).Unix(),
+		"iat":       now.Unix(),
+		"client_id": "client1",
+		"scope":     "openid email",
+	})
+
+	_, err := s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyAccessToken(s.testCtx, accessToken)
+	s.Error(err)
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	token, err := s.client.Verifier(VerificationConfig{ClientID: "api1"}).VerifyAccessToken(s.testCtx, accessToken)
+	s.Require().NoError(err)
+	s.Equal("subject1", token.Subject)
+	s.Equal("client1", token.ClientID)
+	s.Equal("openid email", token.Scope)
+	s.Equal(accessToken, token.Raw())
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestVerifier_VerifyLogoutToken() {
+	now := time.Now()
+	claims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": exampleIssuer,
+			"aud": "client1",
+			"sid": "session1",
+			"iat": now.Unix(),
+			"jti": "id1",
+			"events": map[string]interface{}{
+				BackChannelLogoutEvent: map[string]interface{}{},
+			},
+		}
+	}
+
+	for _, malform := range []func(map[string]interface{}){
+		func(c map[string]interface{}) { delete(c, "sid") },
+		func(c map[string]interface{}) { delete(c, "jti") },
+		func(c map[string]interface{}) { delete(c, "iat") },
+		func(c map[string]interface{}) { c["events"] = map[string]interface{}{} },
+		func(c map[string]interface{}) { c["nonce"] = "nonce1" },
+	} {
+		c := claims()
+		malform(c)
+		// All of these are rejected before fetching keys.
+		rawToken, _ := s.signedJWT(c)
+		_, err := s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyLogoutToken(s.testCtx, rawToken)
+		s.Error(err, "claims: %v", c)
+	}
+
+	rawToken, jwkSetJSON := s.signedJWT(claims())
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	token, err := s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyLogoutToken(s.testCtx, rawToken)
+	s.Require().NoError(err)
+	s.Equal("session1", token.SessionID)
+	s.Equal("id1", token.ID)
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestVerifier_VerifyUserInfo() {
+	// Signed user info does not need to include "exp", "iss" or "aud".
+	rawUserInfo, jwkSetJSON := s.signedJWT(map[string]interface{}{
+		"sub":   "subject1",
+		"email": "user@example.com",
+	})
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	userInfo, err := s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyUserInfo(s.testCtx, rawUserInfo)
+	s.Require().NoError(err)
+	s.Equal("subject1", userInfo.Subject)
+	s.Equal("user@example.com", userInfo.Email)
+
+	// If present, they are checked.
+	rawUserInfo, _ = s.signedJWT(map[string]interface{}{
+		"iss": "https://other.example.com",
+		"sub": "subject1",
+	})
+	_, err = s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyUserInfo(s.testCtx, rawUserInfo)
+	s.Error(err)
+
+	s.Equal(0, s.s.Len())
+}
+
+// ecSignedJWT signs claims with new ECDSA key on given curve using given algorithm.
+func (s *ClientTestSuite) ecSignedJWT(curve elliptic.Curve, alg jose.SignatureAlgorithm, claims map[string]interface{}) (token string, jwk jose.JSONWebKey) {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	s.Require().NoError(err)
+
+	jwk = jose.JSONWebKey{Key: &key.PublicKey, KeyID: string(alg) + "-key", Use: "sig"}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", jwk.KeyID))
+	s.Require().NoError(err)
+
+	payload, err := json.Marshal(claims)
+	s.Require().NoError(err)
+	jws, err := signer.Sign(payload)
+	s.Require().NoError(err)
+	token, err = jws.CompactSerialize()
+	s.Require().NoError(err)
+	return token, jwk
+}
+
+func (s *ClientTestSuite) TestVerifier_ECDSA() {
+	claims := map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	}
+	for _, tcase := range []struct {
+		curve elliptic.Curve
+		alg   jose.SignatureAlgorithm
+	}{
+		{curve: elliptic.P256(), alg: jose.ES256},
+		{curve: elliptic.P384(), alg: jose.ES384},
+		{curve: elliptic.P521(), alg: jose.ES512},
+	} {
+		rawToken, jwk := s.ecSignedJWT(tcase.curve, tcase.alg, claims)
+		jwkSetJSON, err := json.Marshal(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}})
+		s.Require().NoError(err)
+
+		// Not allow-listed by default.
+		_, err = s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyIDToken(s.testCtx, rawToken)
+		s.Error(err, "alg %s", tcase.alg)
+
+		s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+		token, err := s.client.Verifier(VerificationConfig{
+			ClientID:             "client1",
+			SupportedSigningAlgs: []string{string(jose.ES256), string(jose.ES384), string(jose.ES512)},
+		}).VerifyIDToken(s.testCtx, rawToken)
+		s.Require().NoError(err, "alg %s", tcase.alg)
+		s.Equal("subject1", token.Subject)
+		s.Equal(0, s.s.Len())
+	}
+}
+
+func (s *ClientTestSuite) TestVerifier_ECDSA_CurveMismatch() {
+	rawToken, jwk := s.ecSignedJWT(elliptic.P384(), jose.ES384, map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	})
+	// Provider advertises the key for different algorithm than token header claims.
+	jwk.Algorithm = string(jose.ES256)
+
+	jwkJSON, err := json.Marshal(jwk)
+	s.Require().NoError(err)
+	// Unsupported keys in the set are skipped, not failing whole set.
+	jwkSetJSON := []byte(`{"keys": [{"kty": "OKP", "crv": "X448", "x": "AAAA", "kid": "other"}, ` + string(jwkJSON) + `]}`)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+
+	_, err = s.client.Verifier(VerificationConfig{
+		ClientID:             "client1",
+		SupportedSigningAlgs: []string{string(jose.ES256), string(jose.ES384)},
+	}).VerifyIDToken(s.testCtx, rawToken)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "no keys match signature")
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestVerifier_RSAPSS() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	})
+	s.Require().NoError(err)
+	jwkSetJSON, err := json.Marshal(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "pss-key", Use: "sig"}}})
+	s.Require().NoError(err)
+
+	// Provider advertising PS256 is verified with default config. Algorithms that can't be verified with JWKS are
+	// never allowed.
+	client := s.client.Provider().Client()
+	client.idTokenSigningAlgs = []string{"none", string(jose.HS256), string(jose.PS256), string(jose.PS384), string(jose.PS512)}
+	for _, alg := range []jose.SignatureAlgorithm{jose.PS256, jose.PS384, jose.PS512} {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", "pss-key"))
+		s.Require().NoError(err)
+		jws, err := signer.Sign(claims)
+		s.Require().NoError(err)
+		rawToken, err := jws.CompactSerialize()
+		s.Require().NoError(err)
+
+		// Not allowed by default for provider that does not advertise it.
+		_, err = s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyIDToken(s.testCtx, rawToken)
+		s.Error(err, "alg %s", alg)
+
+		s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+		token, err := client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyIDToken(s.testCtx, rawToken)
+		s.Require().NoError(err, "alg %s", alg)
+		s.Equal("subject1", token.Subject)
+		s.Equal(0, s.s.Len())
+	}
+	s.Equal([]string{string(jose.PS256), string(jose.PS384), string(jose.PS512)}, client.Verifier(VerificationConfig{}).cfg.SupportedSigningAlgs)
+}
+
+func (s *ClientTestSuite) TestVerifier_HMAC() {
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	})
+	s.Require().NoError(err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret1")}, nil)
+	s.Require().NoError(err)
+	jws, err := signer.Sign(claims)
+	s.Require().NoError(err)
+	rawToken, err := jws.CompactSerialize()
+	s.Require().NoError(err)
+
+	for _, cfg := range []VerificationConfig{
+		// Not opted in.
+		{ClientID: "client1"},
+		{ClientID: "client1", SupportedSigningAlgs: []string{string(jose.HS256)}},
+		// Algorithm not pinned.
+		{ClientID: "client1", ClientSecret: "secret1"},
+		{ClientID: "client1", ClientSecret: "secret1", SupportedSigningAlgs: []string{string(jose.RS256), string(jose.HS512)}},
+		// Wrong secret.
+		{ClientID: "client1", ClientSecret: "secret2", SupportedSigningAlgs: []string{string(jose.HS256)}},
+	} {
+		_, err := s.client.Verifier(cfg).VerifyIDToken(s.testCtx, rawToken)
+		s.Error(err, "cfg %v", cfg)
+	}
+
+	// Keys are never fetched for HMAC-signed tokens.
+	token, err := s.client.Verifier(VerificationConfig{
+		ClientID:             "client1",
+		ClientSecret:         "secret1",
+		SupportedSigningAlgs: []string{string(jose.RS256), string(jose.HS256)},
+	}).VerifyIDToken(s.testCtx, rawToken)
+	s.Require().NoError(err)
+	s.Equal("subject1", token.Subject)
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestVerifier_ClockSkew() {
+	now := time.Now()
+	for _, claims := range []map[string]interface{}{
+		// Expired recently.
+		{"exp": now.Add(-30 * time.Second).Unix()},
+		// Issued by provider with clock ahead.
+		{"exp": now.Add(1 * time.Hour).Unix(), "iat": now.Add(30 * time.Second).Unix()},
+		{"exp": now.Add(1 * time.Hour).Unix(), "nbf": now.Add(30 * time.Second).Unix()},
+	} {
+		claims["iss"] = exampleIssuer
+		claims["aud"] = "client1"
+		claims["sub"] = "subject1"
+		rawToken, jwkSetJSON := s.signedJWT(claims)
+
+		// Rejected before fetching keys.
+		_, err := s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyIDToken(s.testCtx, rawToken)
+		s.Error(err, "claims %v", claims)
+		_, err = s.client.Verifier(VerificationConfig{ClientID: "client1", ClockSkew: 10 * time.Second}).VerifyIDToken(s.testCtx, rawToken)
+		s.Error(err, "claims %v", claims)
+
+		// Key set is not cached in tests, so keys are fetched for every verification that checks signature.
+		s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+		_, err = s.client.Verifier(VerificationConfig{ClientID: "client1", ClockSkew: 1 * time.Minute}).VerifyIDToken(s.testCtx, rawToken)
+		s.NoError(err, "claims %v", claims)
+		s.Equal(0, s.s.Len())
+	}
+}
+
+func (s *ClientTestSuite) TestVerifier_DefaultClockSkew() {
+	// Provider's clock is slightly ahead of local one.
+	now := time.Now()
+	rawToken, jwkSetJSON := s.signedJWT(map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": now.Add(1 * time.Hour).Unix(),
+		"iat": now.Add(2 * time.Second).Unix(),
+		"nbf": now.Add(2 * time.Second).Unix(),
+	})
+
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	token, err := s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyIDToken(s.testCtx, rawToken)
+	s.Require().NoError(err)
+	s.Equal("subject1", token.Subject)
+
+	// Small ClockSkew does not reject more than the default.
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = s.client.Verifier(VerificationConfig{ClientID: "client1", ClockSkew: 1 * time.Second}).VerifyIDToken(s.testCtx, rawToken)
+	s.NoError(err)
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestVerifier_AudienceAndAuthorizedParty() {
+	exp := time.Now().Add(1 * time.Hour).Unix()
+	for _, tcase := range []struct {
+		aud     interface{}
+		azp     string
+		cfg     VerificationConfig
+		isValid bool
+	}{
+		{aud: []string{"client1"}, cfg: VerificationConfig{ClientID: "client1"}, isValid: true},
+		{aud: []string{"client1", "api"}, azp: "client1", cfg: VerificationConfig{ClientID: "client1"}, isValid: true},
+		// Multiple audiences require azp.
+		{aud: []string{"client1", "api"}, cfg: VerificationConfig{ClientID: "client1"}},
+		{aud: []string{"client1", "api"}, azp: "client2", cfg: VerificationConfig{ClientID: "client1"}},
+		{aud: "client1", azp: "client2", cfg: VerificationConfig{ClientID: "client1"}},
+		{aud: "api", cfg: VerificationConfig{ClientID: "client1"}},
+		{aud: "api", cfg: VerificationConfig{ClientID: "client1", AdditionalAudiences: []string{"api"}}, isValid: true},
+		{aud: []string{"api", "other"}, azp: "client1", cfg: VerificationConfig{ClientID: "client1", AdditionalAudiences: []string{"api"}}, isValid: true},
+		{aud: "other", cfg: VerificationConfig{ClientID: "client1", AdditionalAudiences: []string{"api"}}},
+	} {
+		claims := map[string]interface{}{
+			"iss": exampleIssuer,
+			"aud": tcase.aud,
+			"sub": "subject1",
+			"exp": exp,
+		}
+		if tcase.azp != "" {
+			claims["azp"] = tcase.azp
+		}
+		rawToken, jwkSetJSON := s.signedJWT(claims)
+
+		if !tcase.isValid {
+			// Rejected before fetching keys.
+			_, err := s.client.Verifier(tcase.cfg).VerifyIDToken(s.testCtx, rawToken)
+			s.Error(err, "claims %v", claims)
+			continue
+		}
+
+		s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+		token, err := s.client.Verifier(tcase.cfg).VerifyIDToken(s.testCtx, rawToken)
+		s.Require().NoError(err, "claims %v", claims)
+		s.Equal(tcase.azp, token.AuthorizedParty)
+		s.Equal(0, s.s.Len())
+	}
+}
+
+func (s *ClientTestSuite) TestVerifier_IssuerAliases() {
+	exp := time.Now().Add(1 * time.Hour).Unix()
+	for _, tcase := range []struct {
+		iss     string
+		cfg     VerificationConfig
+		isValid bool
+	}{
+		{iss: exampleIssuer + "/", cfg: VerificationConfig{ClientID: "client1"}},
+		{iss: exampleIssuer + "/", cfg: VerificationConfig{ClientID: "client1", IssuerAliases: []string{exampleIssuer + "/"}}, isValid: true},
+		{iss: "other", cfg: VerificationConfig{ClientID: "client1", IssuerAliases: []string{exampleIssuer + "/"}}},
+	} {
+		claims := map[string]interface{}{
+			"iss": tcase.iss,
+			"aud": "client1",
+			"sub": "subject1",
+			"exp": exp,
+		}
+		rawToken, jwkSetJSON := s.signedJWT(claims)
+
+		if !tcase.isValid {
+			// Rejected before fetching keys.
+			_, err := s.client.Verifier(tcase.cfg).VerifyIDToken(s.testCtx, rawToken)
+			s.Error(err, "claims %v", claims)
+			continue
+		}
+
+		s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+		token, err := s.client.Verifier(tcase.cfg).VerifyIDToken(s.testCtx, rawToken)
+		s.Require().NoError(err, "claims %v", claims)
+		s.Equal(tcase.iss, token.Issuer)
+		s.Equal(0, s.s.Len())
+	}
+}
+
+// typedSignedJWT signs claims with new RSA key and sets "typ" header of the token.
+func (s *ClientTestSuite) typedSignedJWT(typ string, claims map[string]interface{}) (token string, jwkSetJSON []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).
+		WithHeader("kid", "typed-key").
+		WithHeader(jose.HeaderType, typ))
+	s.Require().NoError(err)
+	payload, err := json.Marshal(claims)
+	s.Require().NoError(err)
+	jws, err := signer.Sign(payload)
+	s.Require().NoError(err)
+	token, err = jws.CompactSerialize()
+	s.Require().NoError(err)
+
+	jwkSetJSON, err = json.Marshal(&jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "typed-key", Use: "sig"}},
+	})
+	s.Require().NoError(err)
+	return token, jwkSetJSON
+}
+
+func (s *ClientTestSuite) TestVerifier_VerifyAccessToken_JWTProfile() {
+	now := time.Now()
+	claims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":       exampleIssuer,
+			"aud":       "api1",
+			"sub":       "subject1",
+			"exp":       now.Add(1 * time.Hour).Unix(),
+			"iat":       now.Unix(),
+			"jti":       "id1",
+			"client_id": "client1",
+			"scope":     "openid read write",
+		}
+	}
+	strict := VerificationConfig{ClientID: "api1", StrictAccessTokens: true}
+
+	for _, malform := range []func(map[string]interface{}){
+		func(c map[string]interface{}) { delete(c, "sub") },
+		func(c map[string]interface{}) { delete(c, "client_id") },
+		func(c map[string]interface{}) { delete(c, "jti") },
+		func(c map[string]interface{}) { delete(c, "iat") },
+		func(c map[string]interface{}) { delete(c, "aud") },
+	} {
+		c := claims()
+		malform(c)
+		rawToken, _ := s.typedSignedJWT("at+jwt", c)
+		// Rejected before fetching keys.
+		_, err := s.client.Verifier(strict).VerifyAccessToken(s.testCtx, rawToken)
+		s.Error(err, "claims %v", c)
+	}
+
+	// Type is required in strict mode only.
+	rawToken, jwkSetJSON := s.typedSignedJWT("JWT", claims())
+	_, err := s.client.Verifier(strict).VerifyAccessToken(s.testCtx, rawToken)
+	s.Error(err)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = s.client.Verifier(VerificationConfig{ClientID: "api1"}).VerifyAccessToken(s.testCtx, rawToken)
+	s.NoError(err)
+
+	for _, typ := range []string{"at+jwt", "application/at+JWT"} {
+		rawToken, jwkSetJSON = s.typedSignedJWT(typ, claims())
+		s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+		token, err := s.client.Verifier(strict).VerifyAccessToken(s.testCtx, rawToken)
+		s.Require().NoError(err, "typ %s", typ)
+		s.Equal("id1", token.ID)
+		s.Equal("client1", token.ClientID)
+	}
+
+	// Required scopes.
+	rawToken, jwkSetJSON = s.typedSignedJWT("at+jwt", claims())
+	_, err = s.client.Verifier(VerificationConfig{ClientID: "api1", RequiredScopes: []string{"read", "admin"}}).
+		VerifyAccessToken(s.testCtx, rawToken)
+	s.Error(err)
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = s.client.Verifier(VerificationConfig{ClientID: "api1", RequiredScopes: []string{"read", "write"}}).
+		VerifyAccessToken(s.testCtx, rawToken)
+	s.NoError(err)
+
+	// Access token can't be used as ID token.
+	c := claims()
+	c["aud"] = "client1"
+	rawToken, _ = s.typedSignedJWT("at+jwt", c)
+	_, err = s.client.Verifier(VerificationConfig{ClientID: "client1"}).VerifyIDToken(s.testCtx, rawToken)
+	s.Error(err)
+
+	s.Equal(0, s.s.Len())
+}
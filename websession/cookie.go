@@ -0,0 +1,94 @@
+package websession
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrInvalidCookie is returned by CookieCodec.Decode when cookie was not produced by the codec (with any of its keys),
+// was tampered with, was issued for different cookie name or is too old.
+var ErrInvalidCookie = errors.New("websession: invalid cookie")
+
+// CookieCodec encrypts and authenticates cookie values using AES-GCM. Value is bound to the cookie name and to the
+// time it was encoded, so it can't be moved to another cookie and old values expire.
+//
+// Keys are used for key rotation: values are always encoded with the first key, but decoded with any of them. To
+// rotate, prepend a new key and drop the oldest one after MaxAge.
+type CookieCodec struct {
+	aeads []cipher.AEAD
+
+	// MaxAge is maximum age of decoded value. Zero means no limit.
+	MaxAge  time.Duration
+	timeNow func() time.Time
+}
+
+// NewCookieCodec constructs CookieCodec from given keys. Each key must be 16, 24 or 32 bytes long (AES-128, AES-192 or
+// AES-256) and should be generated by crypto/rand.
+func NewCookieCodec(keys ...[]byte) (*CookieCodec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("websession: at least one cookie key is required")
+	}
+
+	c := &CookieCodec{timeNow: time.Now}
+	for i, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("websession: invalid cookie key %d. Err: %v", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("websession: invalid cookie key %d. Err: %v", i, err)
+		}
+		c.aeads = append(c.aeads, aead)
+	}
+	return c, nil
+}
+
+// Encode returns encrypted value safe to use as a value of cookie with given name.
+func (c *CookieCodec) Encode(name string, value []byte) (string, error) {
+	aead := c.aeads[0]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	plaintext := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(plaintext, uint64(c.timeNow().Unix()))
+	copy(plaintext[8:], value)
+
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decode returns value of cookie with given name encoded by Encode with any of the codec's keys.
+func (c *CookieCodec) Decode(name string, encoded string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+
+	for _, aead := range c.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+		if err != nil || len(plaintext) < 8 {
+			continue
+		}
+
+		encodedAt := time.Unix(int64(binary.BigEndian.Uint64(plaintext)), 0)
+		if c.MaxAge > 0 && encodedAt.Add(c.MaxAge).Before(c.timeNow()) {
+			return nil, ErrInvalidCookie
+		}
+		return plaintext[8:], nil
+	}
+	return nil, ErrInvalidCookie
+}
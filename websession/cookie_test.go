@@ -0,0 +1,60 @@
+package websession
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieCodec(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 16)
+
+	_, err := NewCookieCodec()
+	assert.Error(t, err)
+	_, err = NewCookieCodec([]byte("too short"))
+	assert.Error(t, err)
+
+	oldCodec, err := NewCookieCodec(oldKey)
+	require.NoError(t, err)
+	encoded, err := oldCodec.Encode("session", []byte("id1"))
+	require.NoError(t, err)
+	assert.NotContains(t, encoded, "id1")
+
+	value, err := oldCodec.Decode("session", encoded)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("id1"), value)
+
+	// Value is bound to cookie name.
+	_, err = oldCodec.Decode("other", encoded)
+	assert.Equal(t, ErrInvalidCookie, err)
+
+	// Tampered.
+	tampered := "A" + encoded[1:]
+	if encoded[0] == 'A' {
+		tampered = "B" + encoded[1:]
+	}
+	_, err = oldCodec.Decode("session", tampered)
+	assert.Equal(t, ErrInvalidCookie, err)
+
+	// Rotated codec still decodes values encoded with old key, but encodes with new one.
+	rotated, err := NewCookieCodec(newKey, oldKey)
+	require.NoError(t, err)
+	value, err = rotated.Decode("session", encoded)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("id1"), value)
+
+	encoded, err = rotated.Encode("session", []byte("id2"))
+	require.NoError(t, err)
+	_, err = oldCodec.Decode("session", encoded)
+	assert.Equal(t, ErrInvalidCookie, err)
+
+	// Expired.
+	rotated.MaxAge = time.Minute
+	rotated.timeNow = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, err = rotated.Decode("session", encoded)
+	assert.Equal(t, ErrInvalidCookie, err)
+}
@@ -0,0 +1,563 @@
+// Package websession implements OIDC login for server-rendered web apps. It is the server-side counterpart of the
+// CLI-oriented login package: it provides login, callback and logout HTTP handlers, keeps user's tokens in pluggable
+// session Store, refreshes them when needed and exposes verified ID token via request context.
+package websession
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bplotka/oidc"
+)
+
+const (
+	codeParam      = "code"
+	stateParam     = "state"
+	returnURLParam = "return_url"
+
+	errParam     = "error"
+	errDescParam = "error_description"
+
+	// DefaultCookieName is the name of the session cookie used if Config.CookieName is empty.
+	DefaultCookieName = "oidc_session"
+	// DefaultSessionTTL is the max session lifetime used if Config.SessionTTL is zero.
+	DefaultSessionTTL = 24 * time.Hour
+	// loginFlowTTL is how long user has to complete login on the provider's side.
+	loginFlowTTL = 10 * time.Minute
+)
+
+// Config is a websession configuration.
+type Config struct {
+	// OIDC is the client configuration. RedirectURL must point to the Callback handler.
+	OIDC oidc.Config
+
+	// NonceCheck enables nonce in authorization request and its check in the returned ID token.
+	NonceCheck bool
+	// PKCE enables PKCE (S256) in authorization code flow.
+	PKCE bool
+
+	// CookieName is the name of the session cookie. Default: DefaultCookieName.
+	CookieName string
+	// CookiePath is the path of the session cookie. Default: "/".
+	CookiePath string
+	// InsecureCookie allows session cookie to be sent over plain HTTP. Use only for local development.
+	InsecureCookie bool
+	// CookieCodec if specified, encrypts and authenticates session ID in the cookie. Recommended, when Store is
+	// shared, because session IDs are not exposed to browsers in plain form.
+	CookieCodec *CookieCodec
+	// StateStore persists state of login flows in progress. Default: MemoryStateStore, which works only for single
+	// instance web apps.
+	StateStore StateStore
+	// SessionTTL is max lifetime of the session. Default: DefaultSessionTTL.
+	SessionTTL time.Duration
+
+	// PostLogoutURL is where user is redirected after logout. Default: "/".
+	PostLogoutURL string
+	// MinAccessTokenValidity specifies how long the access token needs to be valid, otherwise it is refreshed.
+	MinAccessTokenValidity time.Duration
+
+	// ClaimsMapping specifies how Identity is built from ID token claims. Default: oidc.DefaultClaimsMapping.
+	ClaimsMapping *oidc.ClaimsMapping
+	// OnLogin if specified, is called after successful login, before the session is created. Returned error rejects
+	// the login, e.g when user is not allowed to use the app.
+	OnLogin func(ctx context.Context, result LoginResult) error
+}
+
+// LoginResult describes successful login.
+type LoginResult struct {
+	Identity *oidc.Identity
+	Token    *oidc.Token
+	// ReturnURL is where user will be redirected.
+	ReturnURL string
+}
+
+type contextKey struct{}
+
+// Manager manages user sessions. It is safe for concurrent use.
+type Manager struct {
+	client *oidc.Client
+	cfg    Config
+	store  Store
+	logger *log.Logger
+
+	// refreshLocks serialize token refreshes of each session, so concurrent requests of single session don't use the
+	// same refresh token twice.
+	refreshLocks sessionLocks
+}
+
+// New constructs new session manager.
+func New(client *oidc.Client, cfg Config, store Store) *Manager {
+	return NewWithLogger(client, cfg, store, nil)
+}
+
+// NewWithLogger constructs new session manager with given logger for unexpected errors.
+func NewWithLogger(client *oidc.Client, cfg Config, store Store, logger *log.Logger) *Manager {
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultCookieName
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+	if cfg.SessionTTL == 0 {
+		cfg.SessionTTL = DefaultSessionTTL
+	}
+	if cfg.PostLogoutURL == "" {
+		cfg.PostLogoutURL = "/"
+	}
+	if cfg.ClaimsMapping == nil {
+		cfg.ClaimsMapping = &oidc.DefaultClaimsMapping
+	}
+	if cfg.StateStore == nil {
+		cfg.StateStore = NewMemoryStateStore()
+	}
+	if logger == nil {
+		logger = log.New(noopWriter{}, "", 0)
+	}
+	return &Manager{
+		client: client,
+		cfg:    cfg,
+		store:  store,
+		logger: logger,
+	}
+}
+
+type noopWriter struct{}
+
+func (noopWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func rand128Bits() string {
+	buff := make([]byte, 16) // 128 bit random ID.
+	if _, err := io.ReadFull(rand.Reader, buff); err != nil {
+		panic(err)
+	}
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(buff), "=")
+}
+
+// LoginHandler starts authorization code flow and redirects user to the provider. Optional "return_url" query
+// parameter specifies local path where user is redirected after successful login.
+func (m *Manager) LoginHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.startLogin(w, r, safeReturnURL(r.URL.Query().Get(returnURLParam)))
+	})
+}
+
+func (m *Manager) startLogin(w http.ResponseWriter, r *http.Request, returnURL string) {
+	flow := &FlowState{
+		State:     rand128Bits(),
+		ReturnURL: returnURL,
+		Expiry:    time.Now().Add(loginFlowTTL),
+	}
+
+	authOpts := []oidc.AuthCodeOption{oidc.WithState(flow.State)}
+	if m.cfg.NonceCheck {
+		flow.Nonce = rand128Bits()
+		authOpts = append(authOpts, oidc.WithNonce(flow.Nonce))
+	}
+	if m.cfg.PKCE {
+		verifier, err := oidc.NewPKCEVerifier()
+		if err != nil {
+			m.errRespond(w, http.StatusInternalServerError, fmt.Errorf("Failed to generate PKCE verifier. Err: %v", err))
+			return
+		}
+		flow.PKCEVerifier = verifier
+		authOpts = append(authOpts, oidc.WithPKCE(verifier))
+	}
+
+	authURL := m.client.AuthCodeURLWithOptions(m.cfg.OIDC, authOpts...)
+	if m.client.Discovery().RequirePAR {
+		var err error
+		authURL, err = m.client.PushedAuthCodeURL(r.Context(), m.cfg.OIDC, authOpts...)
+		if err != nil {
+			m.errRespond(w, http.StatusBadGateway, fmt.Errorf("Failed to push authorization request. Err: %v", err))
+			return
+		}
+	}
+
+	handle, err := m.cfg.StateStore.Save(r.Context(), flow)
+	if err != nil {
+		m.errRespond(w, http.StatusInternalServerError, fmt.Errorf("Failed to save login flow state. Err: %v", err))
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.flowCookieName(),
+		Value:    handle,
+		Path:     m.cfg.CookiePath,
+		Expires:  flow.Expiry,
+		Secure:   !m.cfg.InsecureCookie,
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// CallbackHandler handles redirect from the provider. It exchanges code for tokens, verifies ID token and stores them
+// in a new session, then redirects user to the return URL given to LoginHandler.
+func (m *Manager) CallbackHandler() http.Handler {
+	return http.HandlerFunc(m.callbackHandler)
+}
+
+func (m *Manager) callbackHandler(w http.ResponseWriter, r *http.Request) {
+	flowCookie, err := r.Cookie(m.flowCookieName())
+	if err != nil {
+		m.errRespond(w, http.StatusPreconditionFailed, fmt.Errorf("User session error. No login in progress."))
+		return
+	}
+	// Login flow state is single use.
+	m.clearCookie(w, m.flowCookieName())
+	flow, err := m.cfg.StateStore.Take(r.Context(), flowCookie.Value)
+	if err != nil {
+		m.errRespond(w, http.StatusPreconditionFailed, fmt.Errorf("User session error. No login in progress. Err: %v", err))
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		m.errRespond(w, http.StatusBadRequest, fmt.Errorf("Failed to parse request form. Err: %v", err))
+		return
+	}
+	if errorCode := r.Form.Get(errParam); errorCode != "" {
+		m.errRespond(w, http.StatusUnauthorized, fmt.Errorf("Got error from provider: %s Desc: %s", errorCode, r.Form.Get(errDescParam)))
+		return
+	}
+	if state := r.Form.Get(stateParam); state != flow.State {
+		m.errRespond(w, http.StatusBadRequest, fmt.Errorf("Invalid state parameter. Got %s, expected: %s", state, flow.State))
+		return
+	}
+	code := r.Form.Get(codeParam)
+	if code == "" {
+		m.errRespond(w, http.StatusBadRequest, fmt.Errorf("Missing code token."))
+		return
+	}
+
+	var extra []url.Values
+	if flow.PKCEVerifier != "" {
+		extra = append(extra, oidc.PKCEVerifierParam(flow.PKCEVerifier))
+	}
+	token, err := m.client.Exchange(r.Context(), m.cfg.OIDC, code, extra...)
+	if err != nil {
+		m.errRespond(w, http.StatusUnauthorized, fmt.Errorf("Failed to exchange code. Err: %v", err))
+		return
+	}
+
+	idToken, err := m.client.Verifier(oidc.VerificationConfig{
+		ClientID:   m.cfg.OIDC.ClientID,
+		ClaimNonce: flow.Nonce,
+	}).Verify(r.Context(), token.IDToken)
+	if err != nil {
+		m.errRespond(w, http.StatusUnauthorized, fmt.Errorf("Failed to verify ID token. Err: %v", err))
+		return
+	}
+
+	returnURL := flow.ReturnURL
+	if returnURL == "" {
+		returnURL = "/"
+	}
+
+	if m.cfg.OnLogin != nil {
+		identity, err := m.cfg.ClaimsMapping.Identity(idToken)
+		if err != nil {
+			m.errRespond(w, http.StatusUnauthorized, fmt.Errorf("Failed to map ID token claims. Err: %v", err))
+			return
+		}
+		err = m.cfg.OnLogin(r.Context(), LoginResult{Identity: identity, Token: token, ReturnURL: returnURL})
+		if err != nil {
+			m.errRespond(w, http.StatusForbidden, fmt.Errorf("Login rejected. Err: %v", err))
+			return
+		}
+	}
+
+	// New session ID after login prevents session fixation.
+	s := &Session{
+		ID:     rand128Bits(),
+		Token:  token,
+		Expiry: time.Now().Add(m.cfg.SessionTTL),
+	}
+	if err := m.store.Save(r.Context(), s); err != nil {
+		m.errRespond(w, http.StatusInternalServerError, fmt.Errorf("Failed to save session. Err: %v", err))
+		return
+	}
+	if err := m.setCookie(w, s); err != nil {
+		m.errRespond(w, http.StatusInternalServerError, fmt.Errorf("Failed to encode session cookie. Err: %v", err))
+		return
+	}
+
+	http.Redirect(w, r, returnURL, http.StatusFound)
+}
+
+// LogoutHandler deletes user's session and redirects to Config.PostLogoutURL. It does not terminate the session on
+// the provider's side.
+func (m *Manager) LogoutHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := m.sessionID(r); ok {
+			if err := m.store.Delete(r.Context(), id); err != nil {
+				m.logger.Printf("Warn: Failed to delete session. Err: %v", err)
+			}
+		}
+		m.clearCookie(w, m.cfg.CookieName)
+		http.Redirect(w, r, m.cfg.PostLogoutURL, http.StatusFound)
+	})
+}
+
+// Middleware loads user's session, refreshes its tokens if needed and puts verified ID token and token into request
+// context (see IDTokenFromContext and TokenFromContext). If there is no valid session, requests are passed through
+// without them.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sc, err := m.sessionContext(r); err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), contextKey{}, sc))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireLogin is like Middleware, but user without valid session is redirected to the provider to log in and back to
+// the requested URL afterwards.
+func (m *Manager) RequireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc, err := m.sessionContext(r)
+		if err != nil {
+			if r.Method != http.MethodGet {
+				http.Error(w, "Unauthenticated.", http.StatusUnauthorized)
+				return
+			}
+			m.startLogin(w, r, r.URL.RequestURI())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, sc)))
+	})
+}
+
+type sessionContext struct {
+	token   *oidc.Token
+	idToken *oidc.IDToken
+
+	// Identity is built on first use, so sessions of apps that don't use it never fail on unexpected claims.
+	claimsMapping *oidc.ClaimsMapping
+	logger        *log.Logger
+	identityOnce  sync.Once
+	identity      *oidc.Identity
+}
+
+// IdentityFromContext returns identity of the logged in user built using Config.ClaimsMapping. It returns false if
+// there is no session or ID token claims don't match the mapping (e.g claim of unexpected type); the mapping error is
+// logged then.
+func IdentityFromContext(ctx context.Context) (*oidc.Identity, bool) {
+	sc, ok := ctx.Value(contextKey{}).(*sessionContext)
+	if !ok {
+		return nil, false
+	}
+	sc.identityOnce.Do(func() {
+		identity, err := sc.claimsMapping.Identity(sc.idToken)
+		if err != nil {
+			sc.logger.Printf("Warn: Failed to map ID token claims to identity. Err: %v", err)
+			return
+		}
+		sc.identity = identity
+	})
+	return sc.identity, sc.identity != nil
+}
+
+// IDTokenFromContext returns verified ID token of the logged in user. Claims are available via IDToken.Claims.
+func IDTokenFromContext(ctx context.Context) (*oidc.IDToken, bool) {
+	sc, ok := ctx.Value(contextKey{}).(*sessionContext)
+	if !ok {
+		return nil, false
+	}
+	return sc.idToken, true
+}
+
+// TokenFromContext returns tokens of the logged in user, e.g to call APIs on user's behalf.
+func TokenFromContext(ctx context.Context) (*oidc.Token, bool) {
+	sc, ok := ctx.Value(contextKey{}).(*sessionContext)
+	if !ok {
+		return nil, false
+	}
+	return sc.token, true
+}
+
+// sessionID returns session ID from the request's cookie.
+func (m *Manager) sessionID(r *http.Request) (string, bool) {
+	c, err := r.Cookie(m.cfg.CookieName)
+	if err != nil {
+		return "", false
+	}
+	if m.cfg.CookieCodec == nil {
+		return c.Value, true
+	}
+	id, err := m.cfg.CookieCodec.Decode(m.cfg.CookieName, c.Value)
+	if err != nil {
+		return "", false
+	}
+	return string(id), true
+}
+
+func (m *Manager) session(r *http.Request) (*Session, error) {
+	id, ok := m.sessionID(r)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return m.store.Get(r.Context(), id)
+}
+
+func (m *Manager) sessionContext(r *http.Request) (*sessionContext, error) {
+	s, err := m.session(r)
+	if err != nil {
+		return nil, err
+	}
+	token, err := m.refreshIfNeeded(r.Context(), s)
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := m.verifier().Verify(r.Context(), token.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionContext{token: token, idToken: idToken, claimsMapping: m.cfg.ClaimsMapping, logger: m.logger}, nil
+}
+
+func (m *Manager) verifier() oidc.Verifier {
+	return m.client.Verifier(oidc.VerificationConfig{ClientID: m.cfg.OIDC.ClientID})
+}
+
+// refreshIfNeeded returns session token, refreshing and saving it if it is no longer valid.
+func (m *Manager) refreshIfNeeded(ctx context.Context, s *Session) (*oidc.Token, error) {
+	if s.Token.IsValidFor(ctx, m.verifier(), m.cfg.MinAccessTokenValidity) == nil {
+		return s.Token, nil
+	}
+
+	unlock := m.refreshLocks.lock(s.ID)
+	defer unlock()
+
+	// Session might have been refreshed by concurrent request already.
+	current, err := m.store.Get(ctx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Token != nil && current.Token.IsValidFor(ctx, m.verifier(), m.cfg.MinAccessTokenValidity) == nil {
+		return current.Token, nil
+	}
+
+	if current.Token != nil {
+		s = current
+	}
+
+	token, err := oidc.NewTokenRefresher(ctx, m.client, m.cfg.OIDC, s.Token.RefreshToken).OIDCToken()
+	if err != nil {
+		if oidc.IsAuthError(err) {
+			if err := m.store.Delete(ctx, s.ID); err != nil {
+				m.logger.Printf("Warn: Failed to delete session. Err: %v", err)
+			}
+		}
+		return nil, err
+	}
+	// Provider is not required to return new refresh token or ID token on refresh.
+	if token.RefreshToken == "" {
+		token.RefreshToken = s.Token.RefreshToken
+	}
+	if token.IDToken == "" {
+		token.IDToken = s.Token.IDToken
+	}
+
+	s.Token = token
+	if err := m.store.Save(ctx, s); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// sessionLocks is a set of mutexes keyed by session ID, so refreshes of different sessions don't wait for each other.
+// Mutex is removed once nobody holds or waits for it.
+type sessionLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+type sessionLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock locks mutex of given session and returns function that unlocks it.
+func (l *sessionLocks) lock(id string) (unlock func()) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*sessionLock{}
+	}
+	sl, ok := l.locks[id]
+	if !ok {
+		sl = &sessionLock{}
+		l.locks[id] = sl
+	}
+	sl.refs++
+	l.mu.Unlock()
+
+	sl.mu.Lock()
+	return func() {
+		sl.mu.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		sl.refs--
+		if sl.refs == 0 {
+			delete(l.locks, id)
+		}
+	}
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, s *Session) error {
+	value := s.ID
+	if m.cfg.CookieCodec != nil {
+		var err error
+		value, err = m.cfg.CookieCodec.Encode(m.cfg.CookieName, []byte(s.ID))
+		if err != nil {
+			return err
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    value,
+		Path:     m.cfg.CookiePath,
+		Expires:  s.Expiry,
+		Secure:   !m.cfg.InsecureCookie,
+		HttpOnly: true,
+	})
+	return nil
+}
+
+func (m *Manager) flowCookieName() string {
+	return m.cfg.CookieName + "_flow"
+}
+
+func (m *Manager) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     m.cfg.CookiePath,
+		MaxAge:   -1,
+		Secure:   !m.cfg.InsecureCookie,
+		HttpOnly: true,
+	})
+}
+
+// errRespond logs err and responds with generic message for the code. Details are never sent to the browser, since
+// they might include e.g expected state or nonce and provider's responses.
+func (m *Manager) errRespond(w http.ResponseWriter, code int, err error) {
+	m.logger.Printf("Error: %v", err)
+	http.Error(w, http.StatusText(code), code)
+}
+
+// safeReturnURL allows only local paths, so login can't be used as an open redirect.
+func safeReturnURL(returnURL string) string {
+	if !strings.HasPrefix(returnURL, "/") || strings.HasPrefix(returnURL, "//") || strings.HasPrefix(returnURL, "/\\") {
+		return "/"
+	}
+	return returnURL
+}
@@ -0,0 +1,222 @@
+package websession
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Bplotka/go-httpt/rt"
+	"github.com/Bplotka/oidc"
+	"github.com/Bplotka/oidc/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID    = "clientID1"
+	testSubject     = "subject1"
+	testRedirectURL = "https://app.example.com/callback"
+)
+
+func TestManager_LoginFlow(t *testing.T) {
+	provider := &oidc_testing.Provider{}
+	provider.Setup(t)
+	provider.MockDiscoveryCall()
+
+	client, err := oidc.NewClient(context.Background(), provider.IssuerURL, provider.ClientOption())
+	require.NoError(t, err)
+
+	var logins []LoginResult
+	m := New(client, Config{
+		OIDC: oidc.Config{
+			ClientID:    testClientID,
+			RedirectURL: testRedirectURL,
+			Scopes:      []string{oidc.ScopeOpenID},
+		},
+		NonceCheck: true,
+		PKCE:       true,
+		OnLogin: func(_ context.Context, result LoginResult) error {
+			logins = append(logins, result)
+			return nil
+		},
+	}, NewMemoryStore())
+
+	protected := m.RequireLogin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idToken, ok := IDTokenFromContext(r.Context())
+		require.True(t, ok)
+		_, ok = TokenFromContext(r.Context())
+		require.True(t, ok)
+		identity, ok := IdentityFromContext(r.Context())
+		require.True(t, ok)
+		require.Equal(t, idToken.Subject, identity.Subject)
+		w.Write([]byte(idToken.Subject))
+	}))
+
+	// Not logged in user is redirected to the provider.
+	rec := httptest.NewRecorder()
+	protected.ServeHTTP(rec, httptest.NewRequest("GET", "/protected?a=1", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	authURL, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, testClientID, authURL.Query().Get("client_id"))
+	assert.Equal(t, oidc.PKCEMethodS256, authURL.Query().Get("code_challenge_method"))
+	state := authURL.Query().Get("state")
+	nonce := authURL.Query().Get("nonce")
+	require.NotEmpty(t, state)
+	require.NotEmpty(t, nonce)
+	flowCookies := rec.Result().Cookies()
+	require.Len(t, flowCookies, 1)
+
+	// Wrong state.
+	req := httptest.NewRequest("GET", testRedirectURL+"?code=code1&state=wrong", nil)
+	req.AddCookie(flowCookies[0])
+	rec = httptest.NewRecorder()
+	m.CallbackHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.NotContains(t, rec.Body.String(), state, "expected state must not be exposed")
+	assert.Equal(t, http.StatusText(http.StatusBadRequest)+"\n", rec.Body.String())
+
+	// Login flow session is single use, so the same callback cannot be repeated.
+	req = httptest.NewRequest("GET", testRedirectURL+"?code=code1&state="+state, nil)
+	req.AddCookie(flowCookies[0])
+	rec = httptest.NewRecorder()
+	m.CallbackHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	// Proper flow.
+	rec = httptest.NewRecorder()
+	protected.ServeHTTP(rec, httptest.NewRequest("GET", "/protected?a=1", nil))
+	authURL, err = url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	state = authURL.Query().Get("state")
+	nonce = authURL.Query().Get("nonce")
+	flowCookies = rec.Result().Cookies()
+
+	idToken, jwkSetJSON := provider.NewIDToken(testClientID, testSubject, nonce)
+	tokenJSON, err := json.Marshal(oidc.TokenResponse{
+		AccessToken:  "access1",
+		RefreshToken: "refresh1",
+		IDToken:      idToken,
+		TokenType:    "Bearer",
+	})
+	require.NoError(t, err)
+	provider.Mock().Push(rt.JSONResponseFunc(http.StatusOK, tokenJSON))
+	provider.MockPubKeysCall(jwkSetJSON)
+
+	req = httptest.NewRequest("GET", testRedirectURL+"?code=code1&state="+state, nil)
+	req.AddCookie(flowCookies[0])
+	rec = httptest.NewRecorder()
+	m.CallbackHandler().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/protected?a=1", rec.Header().Get("Location"))
+	var sessionCookies []*http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == DefaultCookieName {
+			sessionCookies = append(sessionCookies, c)
+		}
+	}
+	require.Len(t, sessionCookies, 1)
+	assert.NotEqual(t, flowCookies[0].Value, sessionCookies[0].Value)
+	assert.True(t, sessionCookies[0].HttpOnly)
+	assert.True(t, sessionCookies[0].Secure)
+
+	req = httptest.NewRequest("GET", "/protected?a=1", nil)
+	req.AddCookie(sessionCookies[0])
+	rec = httptest.NewRecorder()
+	protected.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, testSubject, rec.Body.String())
+	require.Len(t, logins, 1)
+	assert.Equal(t, testSubject, logins[0].Identity.Subject)
+	assert.Equal(t, "/protected?a=1", logins[0].ReturnURL)
+
+	// Logout.
+	req = httptest.NewRequest("GET", "/logout", nil)
+	req.AddCookie(sessionCookies[0])
+	rec = httptest.NewRecorder()
+	m.LogoutHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	req = httptest.NewRequest("GET", "/protected", nil)
+	req.AddCookie(sessionCookies[0])
+	rec = httptest.NewRecorder()
+	protected.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	assert.Equal(t, 0, provider.Mock().Len())
+}
+
+func TestIdentityFromContext_MappingError(t *testing.T) {
+	var buf bytes.Buffer
+	// ID token with claims that don't map to identity does not fail the session, only IdentityFromContext.
+	ctx := context.WithValue(context.Background(), contextKey{}, &sessionContext{
+		token:         &oidc.Token{},
+		idToken:       &oidc.IDToken{},
+		claimsMapping: &oidc.DefaultClaimsMapping,
+		logger:        log.New(&buf, "", 0),
+	})
+
+	_, ok := IDTokenFromContext(ctx)
+	assert.True(t, ok)
+	identity, ok := IdentityFromContext(ctx)
+	assert.False(t, ok)
+	assert.Nil(t, identity)
+	assert.Contains(t, buf.String(), "Failed to map ID token claims to identity")
+}
+
+func TestSessionLocks(t *testing.T) {
+	var l sessionLocks
+	unlockA := l.lock("a")
+
+	// Other session is not blocked.
+	done := make(chan struct{})
+	go func() {
+		l.lock("b")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock of other session is blocked")
+	}
+
+	// The same session is.
+	locked, released := make(chan struct{}), make(chan struct{})
+	go func() {
+		unlock := l.lock("a")
+		close(locked)
+		unlock()
+		close(released)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lock of the same session is not exclusive")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlockA()
+	<-released
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	assert.Empty(t, l.locks)
+}
+
+func TestSafeReturnURL(t *testing.T) {
+	for in, expected := range map[string]string{
+		"":                    "/",
+		"/path?a=1":           "/path?a=1",
+		"https://evil.com":    "/",
+		"//evil.com":          "/",
+		"/\\evil.com":         "/",
+		"javascript:alert(1)": "/",
+	} {
+		assert.Equal(t, expected, safeReturnURL(in), in)
+	}
+}
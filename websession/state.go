@@ -0,0 +1,181 @@
+package websession
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrStateNotFound is returned by StateStore when login flow state does not exist, was already used or is expired.
+var ErrStateNotFound = errors.New("websession: login flow state not found")
+
+// FlowState is the state of single authorization code flow in progress.
+type FlowState struct {
+	State        string    `json:"state"`
+	Nonce        string    `json:"nonce,omitempty"`
+	PKCEVerifier string    `json:"pkce_verifier,omitempty"`
+	ReturnURL    string    `json:"return_url,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// StateStore persists FlowState between login redirect and callback. For load-balanced web apps it needs to be
+// shared between instances (e.g CookieStateStore or RedisStateStore), since callback can land on a different
+// instance than the one that started the flow.
+//
+// Manager keeps the returned handle in a browser cookie, so the flow can be completed only by the browser that
+// started it.
+type StateStore interface {
+	// Save persists flow state and returns opaque handle to it, safe to be stored in a cookie.
+	Save(ctx context.Context, s *FlowState) (handle string, err error)
+	// Take returns flow state for given handle and makes sure it cannot be taken again. Returns ErrStateNotFound if
+	// there is no such valid flow state.
+	Take(ctx context.Context, handle string) (*FlowState, error)
+}
+
+// MemoryStateStore is an in-process StateStore. It works only for single instance web apps.
+type MemoryStateStore struct {
+	mu      sync.Mutex
+	states  map[string]FlowState
+	timeNow func() time.Time
+}
+
+// NewMemoryStateStore constructs empty MemoryStateStore.
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		states:  map[string]FlowState{},
+		timeNow: time.Now,
+	}
+}
+
+// Save stores flow state under its random state value. It also evicts expired states.
+func (m *MemoryStateStore) Save(_ context.Context, s *FlowState) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.timeNow()
+	for k, old := range m.states {
+		if old.Expiry.Before(now) {
+			delete(m.states, k)
+		}
+	}
+	m.states[s.State] = *s
+	return s.State, nil
+}
+
+// Take returns and removes flow state.
+func (m *MemoryStateStore) Take(_ context.Context, handle string) (*FlowState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.states[handle]
+	if !ok {
+		return nil, ErrStateNotFound
+	}
+	delete(m.states, handle)
+	if s.Expiry.Before(m.timeNow()) {
+		return nil, ErrStateNotFound
+	}
+	return &s, nil
+}
+
+// CookieStateStore keeps flow state in the browser: the handle is the flow state itself, encrypted by CookieCodec.
+// It works for any number of instances as long as they share codec keys. Since the cookie is cleared on callback, but
+// value itself can't be revoked, replay of the same callback is prevented only by the provider (code is single use).
+type CookieStateStore struct {
+	codec *CookieCodec
+}
+
+// NewCookieStateStore constructs CookieStateStore.
+func NewCookieStateStore(codec *CookieCodec) *CookieStateStore {
+	return &CookieStateStore{codec: codec}
+}
+
+const cookieStateName = "websession-flow-state"
+
+// Save encodes flow state.
+func (c *CookieStateStore) Save(_ context.Context, s *FlowState) (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return c.codec.Encode(cookieStateName, b)
+}
+
+// Take decodes flow state.
+func (c *CookieStateStore) Take(_ context.Context, handle string) (*FlowState, error) {
+	b, err := c.codec.Decode(cookieStateName, handle)
+	if err != nil {
+		return nil, ErrStateNotFound
+	}
+	var s FlowState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, ErrStateNotFound
+	}
+	if s.Expiry.Before(c.codec.timeNow()) {
+		return nil, ErrStateNotFound
+	}
+	return &s, nil
+}
+
+// RedisClient is the subset of Redis commands used by RedisStateStore. It is trivial to implement using any Redis
+// client library, e.g with SET key value PX ttl and GETDEL key.
+type RedisClient interface {
+	// Set sets key to value with given TTL.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// GetDel atomically gets and deletes key. It returns nil value without error if key does not exist.
+	GetDel(ctx context.Context, key string) ([]byte, error)
+}
+
+// RedisStateStore keeps flow state in Redis shared by all instances.
+type RedisStateStore struct {
+	client    RedisClient
+	keyPrefix string
+	timeNow   func() time.Time
+}
+
+// NewRedisStateStore constructs RedisStateStore. Keys are prefixed with keyPrefix.
+func NewRedisStateStore(client RedisClient, keyPrefix string) *RedisStateStore {
+	return &RedisStateStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+		timeNow:   time.Now,
+	}
+}
+
+// Save stores flow state with TTL matching its expiry.
+func (r *RedisStateStore) Save(ctx context.Context, s *FlowState) (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	ttl := s.Expiry.Sub(r.timeNow())
+	if ttl < time.Millisecond {
+		// Redis rejects non-positive TTLs.
+		ttl = time.Millisecond
+	}
+	if err := r.client.Set(ctx, r.keyPrefix+s.State, b, ttl); err != nil {
+		return "", err
+	}
+	return s.State, nil
+}
+
+// Take gets and deletes flow state.
+func (r *RedisStateStore) Take(ctx context.Context, handle string) (*FlowState, error) {
+	b, err := r.client.GetDel(ctx, r.keyPrefix+handle)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrStateNotFound
+	}
+	var s FlowState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if s.Expiry.Before(r.timeNow()) {
+		return nil, ErrStateNotFound
+	}
+	return &s, nil
+}
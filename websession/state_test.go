@@ -0,0 +1,81 @@
+package websession
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRedis struct {
+	values map[string][]byte
+	ttls   map[string]time.Duration
+}
+
+func (f *fakeRedis) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	f.values[key] = value
+	f.ttls[key] = ttl
+	return nil
+}
+
+func (f *fakeRedis) GetDel(_ context.Context, key string) ([]byte, error) {
+	v := f.values[key]
+	delete(f.values, key)
+	return v, nil
+}
+
+func TestStateStores(t *testing.T) {
+	codec, err := NewCookieCodec(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	redis := &fakeRedis{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+
+	for name, c := range map[string]struct {
+		store StateStore
+		// Cookie store can't revoke already issued values.
+		singleUse bool
+	}{
+		"memory": {store: NewMemoryStateStore(), singleUse: true},
+		"cookie": {store: NewCookieStateStore(codec)},
+		"redis":  {store: NewRedisStateStore(redis, "flow:"), singleUse: true},
+	} {
+		ctx := context.Background()
+		flow := &FlowState{
+			State:        "state1",
+			Nonce:        "nonce1",
+			PKCEVerifier: "verifier1",
+			ReturnURL:    "/path",
+			Expiry:       time.Now().Add(time.Minute).Round(time.Second),
+		}
+
+		handle, err := c.store.Save(ctx, flow)
+		require.NoError(t, err, name)
+
+		got, err := c.store.Take(ctx, handle)
+		require.NoError(t, err, name)
+		assert.Equal(t, flow.State, got.State, name)
+		assert.Equal(t, flow.Nonce, got.Nonce, name)
+		assert.Equal(t, flow.PKCEVerifier, got.PKCEVerifier, name)
+		assert.Equal(t, flow.ReturnURL, got.ReturnURL, name)
+		assert.True(t, flow.Expiry.Equal(got.Expiry), name)
+
+		if c.singleUse {
+			_, err = c.store.Take(ctx, handle)
+			assert.Equal(t, ErrStateNotFound, err, name)
+		}
+
+		_, err = c.store.Take(ctx, "unknown")
+		assert.Equal(t, ErrStateNotFound, err, name)
+
+		expired := *flow
+		expired.State = "state2"
+		expired.Expiry = time.Now().Add(-time.Minute)
+		handle, err = c.store.Save(ctx, &expired)
+		require.NoError(t, err, name)
+		_, err = c.store.Take(ctx, handle)
+		assert.Equal(t, ErrStateNotFound, err, name)
+	}
+	assert.InDelta(t, time.Minute, redis.ttls["flow:state1"], float64(time.Second))
+}
@@ -0,0 +1,89 @@
+package websession
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Bplotka/oidc"
+)
+
+// ErrSessionNotFound is returned by Store when session does not exist or is expired.
+var ErrSessionNotFound = errors.New("websession: session not found")
+
+// Session is a single user's web session.
+type Session struct {
+	// ID is the random session identifier stored in the cookie.
+	ID string
+	// Token is the token obtained on login and refreshed since then.
+	Token *oidc.Token
+	// Expiry is time after which session is not valid anymore, no matter if token can be refreshed.
+	Expiry time.Time
+}
+
+// Store persists sessions. Implementations must be safe for concurrent use. Store shared between instances
+// (e.g database) allows load-balanced web apps.
+type Store interface {
+	// Get returns session with given ID or ErrSessionNotFound.
+	Get(ctx context.Context, id string) (*Session, error)
+	// Save creates or replaces session.
+	Save(ctx context.Context, s *Session) error
+	// Delete removes session. Deleting non existing session is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore is an in-process Store. Sessions are lost on restart and are not shared between instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+	timeNow  func() time.Time
+}
+
+// NewMemoryStore constructs empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: map[string]Session{},
+		timeNow:  time.Now,
+	}
+}
+
+// Get returns copy of the session with given ID.
+func (m *MemoryStore) Get(_ context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if !s.Expiry.IsZero() && s.Expiry.Before(m.timeNow()) {
+		delete(m.sessions, id)
+		return nil, ErrSessionNotFound
+	}
+	return &s, nil
+}
+
+// Save stores copy of the session. It also evicts expired sessions.
+func (m *MemoryStore) Save(_ context.Context, s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.timeNow()
+	for id, old := range m.sessions {
+		if !old.Expiry.IsZero() && old.Expiry.Before(now) {
+			delete(m.sessions, id)
+		}
+	}
+	m.sessions[s.ID] = *s
+	return nil
+}
+
+// Delete removes the session.
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}